@@ -0,0 +1,53 @@
+package main
+
+import (
+	"binance-pooler/internal/api"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// go run cmd/api/main.go
+func main() {
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		msg := fmt.Sprintf("failed to create app in go pooler: %v", err.Error())
+		log.Fatal(msg)
+	}
+	defer app.Exit(ctx)
+
+	// the rows written by the pooler process are pushed to the live subscribers
+	if app.Conf().Api.ChangeStream {
+		if app.Db() == nil {
+			log.Fatal("change streams are only supported with the mongo storage")
+		}
+
+		db := app.Db()
+		for _, coll := range []*mongo.Collection{
+			db.CryptoSpotOhlcColl(),
+			db.CryptoFuturesOhlcColl(),
+			db.CryptoSpotOhlcDerivedColl(),
+			db.CryptoFuturesOhlcDerivedColl(),
+		} {
+			go func() {
+				if err := market_dto.WatchOhlcCollection(ctx, coll, app.OhlcFeed()); err != nil {
+					log.Fatal(err)
+				}
+			}()
+		}
+	}
+
+	// the http and grpc apis are served depending on the [api] config
+	if err := api.Serve(app); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// on exit, kill the app running on port 4444
+// kill -9 $(lsof -t -i:4444)
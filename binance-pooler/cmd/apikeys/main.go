@@ -0,0 +1,111 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/auth_dto"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `Manage the keys of the http api, which are required if [api] auth is enabled.
+
+	go run cmd/apikeys/main.go create -name grafana -scopes read -quota 1000/1h
+	go run cmd/apikeys/main.go list
+	go run cmd/apikeys/main.go revoke -id 0123456789abcdef
+	go run cmd/apikeys/main.go audit -id 0123456789abcdef -limit 50
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	stores := app.Stores()
+	cmd, args := os.Args[1], os.Args[2:]
+
+	switch cmd {
+	case "create":
+		fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+		name := fs.String("name", "", "name of the key (e.g. the name of the client)")
+		scopes := fs.String("scopes", auth_dto.ScopeRead, "comma separated scopes of the key ("+strings.Join(auth_dto.Scopes, ", ")+")")
+		quota := fs.String("quota", "", "optional quota of the key as requests/window (e.g. 1000/1h)")
+		fs.Parse(args)
+
+		q, err := auth_dto.ParseQuota(*quota)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		key, secret, err := auth_dto.NewApiKey(*name, strings.Split(*scopes, ","), q)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := stores.ApiKeys.InsertKey(*key); err != nil {
+			log.Fatalf("failed to insert the key: %v", err)
+		}
+
+		fmt.Printf(" * created key %v (%v) with the %v scopes and %v quota\n", key.ID, key.Name, strings.Join(key.Scopes, ","), key.Quota)
+		fmt.Printf(" * the key is only shown once: %v\n", secret)
+
+	case "list":
+		keys, err := stores.ApiKeys.GetKeys()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, key := range keys {
+			status := "active"
+			if key.Revoked() {
+				status = "revoked at " + key.RevokedAt.Format(time.RFC3339)
+			}
+
+			fmt.Printf(" * %v  %-20v %v...  scopes=%v quota=%v created=%v %v\n",
+				key.ID, key.Name, key.Hint, strings.Join(key.Scopes, ","), key.Quota, key.CreatedAt.Format(time.RFC3339), status)
+		}
+
+	case "revoke":
+		fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+		id := fs.String("id", "", "id of the key")
+		fs.Parse(args)
+
+		if err := stores.ApiKeys.RevokeKey(*id, time.Now().UTC()); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf(" * revoked key %v\n", *id)
+
+	case "audit":
+		fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+		id := fs.String("id", "", "optional id of the key")
+		limit := fs.Int64("limit", 100, "max number of the returned logs")
+		fs.Parse(args)
+
+		logs, err := stores.ApiAudit.GetAuditLogs(auth_dto.AuditFilter{KeyID: *id, Limit: *limit})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, l := range logs {
+			fmt.Printf(" * %v  %-16v %v %v %v?%v  %vms  %v\n", l.Time.Format(time.RFC3339), l.KeyID, l.Status, l.Method, l.Path, l.Query, l.Duration, l.Ip)
+		}
+
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"flag"
+	"log"
+	"time"
+)
+
+// Backfill the ohlc rows of a symbol from the binance public data archives,
+// which is a lot faster (and does not use the api weight) than requesting
+// years of 1m klines from the rest api.
+//
+//	go run cmd/backfill/main.go -symbol BTCUSDT -interval 1m -from 2021-01-01
+func main() {
+	market := flag.String("market", "spot", "market of the archives (spot or futures)")
+	symbol := flag.String("symbol", "", "symbol of the archives (e.g. BTCUSDT)")
+	interval := flag.String("interval", "1m", "interval of the klines (e.g. 1m, 15m)")
+	from := flag.String("from", "", "start date of the backfill (YYYY-MM-DD)")
+	to := flag.String("to", "", "optional end date (inclusive) of the backfill (YYYY-MM-DD), defaults to now")
+	offline := flag.Bool("offline", false, "only read the archives from the local archive dir")
+	flag.Parse()
+
+	if *symbol == "" {
+		log.Fatal("symbol is required")
+	}
+
+	tf, err := binance.ParseTimeframe(*interval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	t1, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		log.Fatalf("invalid from date: %v", err)
+	}
+
+	t2 := time.Now().UTC()
+	if *to != "" {
+		day, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			log.Fatalf("invalid to date: %v", err)
+		}
+		// the rows of the whole day are included
+		t2 = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	conf := app.Conf().BinanceArchive
+	source := binance.ArchiveSource{BaseUrl: conf.BaseUrl, Dir: conf.Dir, Offline: *offline}
+
+	archiveMarket := binance.ArchiveMarketSpot
+	store := app.Stores().CryptoSpotOhlc
+
+	switch *market {
+	case "spot":
+	case "futures":
+		archiveMarket = binance.ArchiveMarketFuturesUsd
+		store = app.Stores().CryptoFuturesOhlc
+	default:
+		log.Fatalf("unsupported market: %v", *market)
+	}
+
+	s := binance_service.New(app, 1, []binance.Timeframe{tf})
+	if err := s.BackfillFromArchive(source, archiveMarket, store, *symbol, tf, t1, t2); err != nil {
+		log.Fatal(err)
+	}
+}
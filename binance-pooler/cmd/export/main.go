@@ -0,0 +1,187 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/market_io"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Export the stored market data to files.
+//
+// Parquet export of the ohlc rows of a symbol, partitioned by month. Running the
+// command again only writes the months which were not exported yet.
+//
+//	go run cmd/export/main.go -symbol BTCUSDT -interval 1m -from 2024-01-01 -dir ./out/parquet
+//
+// Csv export of the ohlc rows or the assets
+//
+//	go run cmd/export/main.go -format csv -symbol BTCUSDT -interval 1m -from 2024-01-01 -out btc.csv
+//	go run cmd/export/main.go -format csv -kind asset -out assets.csv
+//
+// Csv export of the ohlc rows as they were stored at a time, before the later
+// corrections (see the ohlc revisions)
+//
+//	go run cmd/export/main.go -format csv -symbol BTCUSDT -interval 1m -from 2024-01-01 -as-of 2024-02-01T00:00:00Z
+func main() {
+	format := flag.String("format", "parquet", "format of the export (parquet or csv)")
+	kind := flag.String("kind", "ohlc", "exported data (ohlc or asset). Assets can only be exported as csv")
+	dir := flag.String("dir", "./out/parquet", "root directory of the parquet dataset")
+	out := flag.String("out", "", "path of the csv file, defaults to stdout")
+	market := flag.String("market", "spot", "market of the exported rows (spot or futures)")
+	symbol := flag.String("symbol", "", "symbol of the exported rows (e.g. BTCUSDT)")
+	interval := flag.String("interval", "1m", "interval of the exported rows (e.g. 1m, 15m)")
+	from := flag.String("from", "", "start date of the export (YYYY-MM-DD)")
+	to := flag.String("to", "", "optional end date (inclusive) of the export (YYYY-MM-DD), defaults to now")
+	timeFormat := flag.String("time-format", time.RFC3339, "layout of the csv time values, or unix_ms")
+	columns := flag.String("columns", "", "optional comma separated list of the csv columns")
+	asOf := flag.String("as-of", "", "optional time (RFC3339 or YYYY-MM-DD) at which the exported csv rows were stored")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	spot := *market == "spot"
+	if !spot && *market != "futures" {
+		log.Fatalf("unsupported market: %v", *market)
+	}
+
+	csvSettings := market_io.CsvSettings{TimeFormat: *timeFormat}
+	if *columns != "" {
+		csvSettings.Columns = strings.Split(*columns, ",")
+	}
+
+	if *kind == "asset" {
+		store := app.Stores().CryptoSpotAsset
+		if !spot {
+			store = app.Stores().CryptoFuturesAsset
+		}
+
+		assets, err := store.GetAssets(market_dto.AssetFilter{})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := writeCsv(*out, func(w io.Writer) error {
+			return market_io.WriteAssetCsv(w, assets, csvSettings)
+		}); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	tf, err := binance.ParseTimeframe(*interval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := market_dto.OhlcFilter{Symbol: *symbol, Interval: tf.Milis}
+
+	if filter.From, err = time.Parse(time.DateOnly, *from); err != nil {
+		log.Fatalf("invalid from date: %v", err)
+	}
+
+	if *to != "" {
+		day, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			log.Fatalf("invalid to date: %v", err)
+		}
+		// the rows of the whole day are included
+		filter.To = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	store := app.Stores().CryptoSpotOhlc
+	if !spot {
+		store = app.Stores().CryptoFuturesOhlc
+	}
+
+	var asOfTime time.Time
+	if *asOf != "" {
+		if *format != "csv" {
+			log.Fatal("-as-of can only be used with the csv format")
+		}
+
+		if asOfTime, err = parseAsOf(*asOf); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch *format {
+	case "parquet":
+		exportLog, err := market_io.ExportOhlcToParquet(store, market_io.ParquetExportParams{
+			Dir:      *dir,
+			Symbol:   filter.Symbol,
+			Interval: filter.Interval,
+			From:     filter.From,
+			To:       filter.To,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf(" * exported %v rows, written %v partitions, skipped %v complete partitions\n",
+			exportLog.NumRows, len(exportLog.Written), len(exportLog.Skipped))
+
+	case "csv":
+		var rows []market_dto.OhlcRow
+		if asOfTime.IsZero() {
+			rows, err = store.GetOhlcRows(filter)
+		} else {
+			rows, err = market_dto.GetOhlcRowsAsOf(store, app.Stores().OhlcRevisions, filter, asOfTime)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := writeCsv(*out, func(w io.Writer) error {
+			return market_io.WriteOhlcCsv(w, rows, csvSettings)
+		}); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		log.Fatalf("unsupported format: %v", *format)
+	}
+}
+
+// parseAsOf parses the RFC3339 time or the date (the start of the day).
+func parseAsOf(val string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid as-of time: %v", val)
+}
+
+// writeCsv calls the write function with the file at the path, or stdout if the path is empty.
+func writeCsv(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/market_io"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Import ohlc rows or assets from a csv file. The rows are validated and
+// written with the same upsert functions as the scraped data.
+//
+//	go run cmd/import/main.go -kind ohlc -market spot -in btc.csv
+func main() {
+	kind := flag.String("kind", "ohlc", "imported data (ohlc or asset)")
+	market := flag.String("market", "spot", "market into which the data is imported (spot or futures)")
+	in := flag.String("in", "", "path of the csv file")
+	timeFormat := flag.String("time-format", time.RFC3339, "layout of the csv time values, or unix_ms")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	spot := *market == "spot"
+	if !spot && *market != "futures" {
+		log.Fatalf("unsupported market: %v", *market)
+	}
+
+	settings := market_io.CsvSettings{TimeFormat: *timeFormat}
+
+	var upsertLog *mongodb.UpsertLog
+
+	switch *kind {
+	case "ohlc":
+		var store market_dto.OhlcStore = app.Stores().CryptoSpotOhlc
+		if !spot {
+			store = app.Stores().CryptoFuturesOhlc
+		}
+		upsertLog, err = market_io.ImportOhlcCsv(f, store, settings)
+
+	case "asset":
+		var store market_dto.AssetStore = app.Stores().CryptoSpotAsset
+		if !spot {
+			store = app.Stores().CryptoFuturesAsset
+		}
+		upsertLog, err = market_io.ImportAssetCsv(f, store, settings)
+
+	default:
+		log.Fatalf("unsupported kind: %v", *kind)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf(" * %v\n", upsertLog.String())
+}
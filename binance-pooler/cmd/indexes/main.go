@@ -0,0 +1,49 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/lib/mongodb"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// Sync the indexes of the market data collections with the ones defined in
+// the code. Use the -dry-run flag to only print the changes. Only the db is
+// connected, so the indexes and the migrations of the app are not applied.
+//
+//	go run cmd/indexes/main.go -dry-run -drop
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report the changes")
+	drop := flag.Bool("drop", false, "drop the indexes which are not defined in the code")
+	flag.Parse()
+
+	confPath := core.Environment.GetConfigPath()
+
+	conf, err := core.NewConfig(confPath)
+	if err != nil {
+		log.Fatalf("failed to load config file: %v", err)
+	}
+
+	if conf.StorageBackend() != core.StorageMongo {
+		log.Fatal("indexes can only be synced with the mongo storage")
+	}
+
+	db, err := core.NewMongoDb(conf, core.Environment.ShouldUseTestDb())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Conn().Disconnect(context.Background())
+
+	fmt.Printf(" * using db: %v\n", db.DbName)
+
+	reports, err := core.SyncMongoIndexes(db, conf.Retention, mongodb.SyncSettings{DropUnknown: *drop, DryRun: *dryRun})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, report := range reports {
+		fmt.Printf(" * %v\n", report.String())
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"binance-pooler/internal/api"
+	"binance-pooler/internal/pooler/aggregation_service"
 	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/internal/pooler/retention_service"
 	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/lib/jobs"
 	"binance-pooler/pkg/providers/binance"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"time"
@@ -15,7 +20,15 @@ import (
 )
 
 // go run cmd/pooler/main.go
+//
+// With the -api flag the query api is served by the pooler process, so
+// that the scraped rows are pushed to the live subscribers as they are
+// written, without a mongodb change stream, and the jobs of the scheduler
+// can be triggered, paused and resumed over http.
 func main() {
+	serveApi := flag.Bool("api", false, "serve the query api on the [api] host and ports of the config")
+	flag.Parse()
+
 	loc, err := time.LoadLocation("Europe/Riga")
 	if err != nil {
 		log.Fatal(err)
@@ -30,11 +43,26 @@ func main() {
 	}
 	defer app.Exit(ctx)
 
-	scheduler, err := InitializeScheduler(app, loc)
+	scrapeState := binance_service.NewScrapeState()
+
+	scheduler, err := InitializeScheduler(app, loc, scrapeState)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// has to wrap the jobs before the scheduler is started
+	controller := jobs.New(scheduler)
+
+	if *serveApi {
+		settings := api.Settings{Jobs: controller, ScrapeState: scrapeState}
+
+		go func() {
+			if err := api.Serve(app, settings); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
 	// fmt.Printf("scheduler.Jobs: %#v\n", scheduler.Jobs)
 	// fmt.Printf("format string")
 	scheduler.Start()
@@ -45,7 +73,7 @@ func main() {
 	// }
 }
 
-func InitializeScheduler(app *core.App, loc *time.Location) (*syro.CronScheduler, error) {
+func InitializeScheduler(app *core.App, loc *time.Location, scrapeState *binance_service.ScrapeState) (*syro.CronScheduler, error) {
 	cron := cron.New(cron.WithLocation(loc))
 
 	scheduler := syro.NewCronScheduler(cron, "go-pooler").
@@ -60,9 +88,22 @@ func InitializeScheduler(app *core.App, loc *time.Location) (*syro.CronScheduler
 	if err := binance_service.New(app, 2, timeframes).
 		WithSleepDuration(500 * time.Millisecond).
 		WithDebug().
+		WithScrapeState(scrapeState).
 		AddJobs(scheduler); err != nil {
 		return nil, fmt.Errorf("failed to add binance jobs to scheduler: %v", err)
 	}
 
+	// only the spot rows are scraped, so the futures rows are not aggregated
+	if err := aggregation_service.New(app, binance.TopPairs, aggregation_service.DefaultTimeframes).
+		WithMarkets(api.MarketSpot).
+		AddJobs(scheduler); err != nil {
+		return nil, fmt.Errorf("failed to add aggregation jobs to scheduler: %v", err)
+	}
+
+	if err := retention_service.New(app, app.Conf().Retention).
+		AddJobs(scheduler); err != nil {
+		return nil, fmt.Errorf("failed to add retention jobs to scheduler: %v", err)
+	}
+
 	return scheduler, nil
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/timeset"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Run the candle quality checks on the stored rows and print a report per
+// symbol. With the -quarantine flag, the rejected rows are moved to the
+// quarantine collection.
+//
+//	go run cmd/quality/main.go -interval 1m -from 2024-01-01
+//	go run cmd/quality/main.go -symbol BTCUSDT -interval 1m -from 2024-01-01 -quarantine
+func main() {
+	market := flag.String("market", "spot", "market of the checked rows (spot or futures)")
+	symbol := flag.String("symbol", "", "optional symbol of the checked rows, defaults to all of the stored assets")
+	interval := flag.String("interval", "1m", "interval of the checked rows (e.g. 1m, 15m)")
+	from := flag.String("from", "", "start date of the checked rows (YYYY-MM-DD)")
+	to := flag.String("to", "", "optional end date of the checked rows (YYYY-MM-DD), defaults to now")
+	sigma := flag.Float64("sigma", market_dto.DefaultQualitySettings.MaxJumpSigma, "price spikes over N standard deviations are rejected")
+	quarantine := flag.Bool("quarantine", false, "move the rejected rows to the quarantine collection")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	spot := *market == "spot"
+	if !spot && *market != "futures" {
+		log.Fatalf("unsupported market: %v", *market)
+	}
+
+	ohlcStore, assetStore := app.Stores().CryptoSpotOhlc, app.Stores().CryptoSpotAsset
+	if !spot {
+		ohlcStore, assetStore = app.Stores().CryptoFuturesOhlc, app.Stores().CryptoFuturesAsset
+	}
+
+	dur, err := timeset.ParseInterval(*interval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := market_dto.OhlcFilter{Interval: dur.Milliseconds()}
+
+	if filter.From, err = time.Parse(time.DateOnly, *from); err != nil {
+		log.Fatalf("invalid from date: %v", err)
+	}
+
+	if *to != "" {
+		if filter.To, err = time.Parse(time.DateOnly, *to); err != nil {
+			log.Fatalf("invalid to date: %v", err)
+		}
+	}
+
+	symbols := []string{*symbol}
+	if *symbol == "" {
+		assets, err := assetStore.GetAssets(market_dto.AssetFilter{Source: binance.Source})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		symbols = nil
+		for _, asset := range assets {
+			symbols = append(symbols, asset.Symbol)
+		}
+	}
+
+	settings := market_dto.DefaultQualitySettings
+	settings.Interval = filter.Interval
+	settings.MaxJumpSigma = *sigma
+
+	enc := json.NewEncoder(os.Stdout)
+
+	for _, s := range symbols {
+		filter.Symbol = s
+
+		rows, err := ohlcStore.GetOhlcRows(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(rows) == 0 {
+			continue
+		}
+
+		res := market_dto.ValidateOhlcRows(rows, settings)
+
+		for _, report := range res.Reports {
+			if err := enc.Encode(report); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if !*quarantine || len(res.Quarantined) == 0 {
+			continue
+		}
+
+		for i := range res.Quarantined {
+			res.Quarantined[i].Collection = ohlcStore.Name()
+		}
+
+		if err := app.Stores().OhlcQuarantine.QuarantineOhlcRows(res.Quarantined); err != nil {
+			log.Fatal(err)
+		}
+
+		for _, row := range res.Quarantined {
+			rowFilter := market_dto.OhlcFilter{Symbol: row.Symbol, Interval: row.Interval, From: row.StartTime, To: row.StartTime}
+			if _, err := ohlcStore.DeleteOhlcRows(rowFilter); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, " * %v: moved %v rows to %v\n", s, len(res.Quarantined), app.Stores().OhlcQuarantine.Name())
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+)
+
+// Fetch the stored rows again from the api and print a drift report per
+// symbol. With the -correct flag, the missing and changed rows are upserted.
+//
+//	go run cmd/reconcile/main.go -interval 1h -from 2024-01-01
+//	go run cmd/reconcile/main.go -symbol BTCUSDT -interval 1m -from 2024-01-01 -to 2024-01-02 -correct
+func main() {
+	market := flag.String("market", "spot", "market of the reconciled rows (spot or futures)")
+	symbol := flag.String("symbol", "", "optional symbol of the reconciled rows, defaults to all of the stored assets")
+	interval := flag.String("interval", "1m", "interval of the reconciled rows (e.g. 1m, 15m)")
+	from := flag.String("from", "", "start date of the reconciled rows (YYYY-MM-DD)")
+	to := flag.String("to", "", "optional end date of the reconciled rows (YYYY-MM-DD), defaults to now")
+	tolerance := flag.Float64("tolerance", 1e-9, "relative tolerance of the compared values")
+	correct := flag.Bool("correct", false, "upsert the missing and changed rows")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	api := binance.New().WithLake(app.RawLake())
+
+	spot := *market == "spot"
+	if !spot && *market != "futures" {
+		log.Fatalf("unsupported market: %v", *market)
+	}
+
+	ohlcStore, assetStore, getFunc := app.Stores().CryptoSpotOhlc, app.Stores().CryptoSpotAsset, api.GetSpotKline
+	if !spot {
+		ohlcStore, assetStore, getFunc = app.Stores().CryptoFuturesOhlc, app.Stores().CryptoFuturesAsset, api.GetFutureKline
+	}
+
+	tf, err := binance.ParseTimeframe(*interval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fromTime, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		log.Fatalf("invalid from date: %v", err)
+	}
+
+	toTime := time.Now().UTC()
+	if *to != "" {
+		if toTime, err = time.Parse(time.DateOnly, *to); err != nil {
+			log.Fatalf("invalid to date: %v", err)
+		}
+	}
+
+	symbols := []string{*symbol}
+	if *symbol == "" {
+		assets, err := assetStore.GetAssets(market_dto.AssetFilter{Source: binance.Source})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		symbols = nil
+		for _, asset := range assets {
+			symbols = append(symbols, asset.Symbol)
+		}
+	}
+
+	service := binance_service.New(app, 1, []binance.Timeframe{tf}).WithSleepDuration(200 * time.Millisecond)
+	settings := binance_service.ReconcileSettings{Correct: *correct, Tolerance: *tolerance}
+
+	enc := json.NewEncoder(os.Stdout)
+
+	for _, s := range symbols {
+		report, err := service.Reconcile(ohlcStore, getFunc, s, tf, fromTime, toTime, settings)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
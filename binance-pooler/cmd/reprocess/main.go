@@ -0,0 +1,175 @@
+package main
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/rawlake"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Rebuild the ohlc and asset collections from the raw api responses which are
+// stored in the lake (see the [raw_lake] section of the config). The
+// responses are parsed in the order in which they were fetched, so the
+// latest values of a row are the ones which end up in the collection.
+//
+//	go run cmd/reprocess/main.go -from 2024-01-01 -to 2024-01-02
+//	go run cmd/reprocess/main.go -assets=false -dry-run
+func main() {
+	from := flag.String("from", "", "optional start date of the fetch time of the responses (YYYY-MM-DD)")
+	to := flag.String("to", "", "optional end date (inclusive) of the fetch time of the responses (YYYY-MM-DD)")
+	klines := flag.Bool("klines", true, "rebuild the ohlc collections from the kline responses")
+	assets := flag.Bool("assets", true, "rebuild the asset collections from the exchangeInfo responses")
+	dryRun := flag.Bool("dry-run", false, "only parse the responses and print the number of rows")
+	flag.Parse()
+
+	var filter rawlake.Filter
+	var err error
+	if *from != "" {
+		if filter.From, err = time.Parse(time.DateOnly, *from); err != nil {
+			log.Fatalf("invalid from date: %v", err)
+		}
+	}
+	if *to != "" {
+		day, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			log.Fatalf("invalid to date: %v", err)
+		}
+		// the responses of the whole day are included
+		filter.To = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	// the app of a dry run uses the memory stores, so the indexes and the
+	// migrations of the db are skipped
+	if *dryRun {
+		os.Setenv(core.Environment.DryRunKey, "true")
+	}
+
+	ctx := context.Background()
+
+	app, err := core.NewApp(ctx)
+	if err != nil {
+		log.Fatalf("failed to create app: %v", err)
+	}
+	defer app.Exit(ctx)
+
+	lake := app.RawLake()
+	if lake == nil {
+		log.Fatal("the raw lake is disabled in the config")
+	}
+
+	r := reprocessor{stores: app.Stores(), lake: lake, filter: filter, dryRun: *dryRun}
+
+	if *assets {
+		if err := r.assets(binance.SpotExchangeInfoEndpoint, app.Stores().CryptoSpotAsset); err != nil {
+			log.Fatal(err)
+		}
+		if err := r.assets(binance.FuturesExchangeInfoEndpoint, app.Stores().CryptoFuturesAsset); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *klines {
+		if err := r.klines(binance.SpotKlinesEndpoint, app.Stores().CryptoSpotOhlc); err != nil {
+			log.Fatal(err)
+		}
+		if err := r.klines(binance.FuturesKlinesEndpoint, app.Stores().CryptoFuturesOhlc); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+type reprocessor struct {
+	stores *core.Stores
+	lake   rawlake.Store
+	filter rawlake.Filter
+	dryRun bool
+}
+
+// klines upserts the rows of the stored kline responses of the endpoint.
+// The rows go through the same quality checks as the scraped ones.
+func (r reprocessor) klines(endpoint string, store market_dto.OhlcStore) error {
+	filter := r.filter
+	filter.Endpoint = endpoint
+
+	var numRecords, numRows int
+	err := r.lake.Walk(filter, func(rec rawlake.Record) error {
+		rows, err := binance.ParseRawKlines(rec)
+		if err != nil {
+			return fmt.Errorf("failed to parse the response fetched at %v: %v", rec.FetchedAt, err)
+		}
+
+		numRecords++
+		numRows += len(rows)
+		if r.dryRun || len(rows) == 0 {
+			return nil
+		}
+
+		settings := market_dto.DefaultQualitySettings
+		settings.Interval = rows[0].Interval
+
+		_, res, err := market_dto.UpsertValidOhlcRows(store, r.stores.OhlcQuarantine, rows, settings)
+		if err != nil {
+			return err
+		}
+
+		for _, report := range res.Reports {
+			if report.NumQuarantined > 0 {
+				fmt.Printf(" * quarantined %v rows of %v\n", report.NumQuarantined, report.Symbol)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reprocess %v: %v", endpoint, err)
+	}
+
+	fmt.Printf(" * %v: %v responses, %v rows -> %v\n", endpoint, numRecords, numRows, store.Name())
+	return nil
+}
+
+// assets upserts the assets of the stored exchangeInfo responses of the endpoint.
+func (r reprocessor) assets(endpoint string, store market_dto.AssetStore) error {
+	filter := r.filter
+	filter.Endpoint = endpoint
+
+	var numRecords, numAssets int
+	err := r.lake.Walk(filter, func(rec rawlake.Record) error {
+		var docs []market_dto.Asset[any]
+		switch endpoint {
+		case binance.SpotExchangeInfoEndpoint:
+			data, err := binance.ParseRawSpotAssets(rec)
+			if err != nil {
+				return err
+			}
+			docs = market_dto.AnyAssets(data)
+		default:
+			data, err := binance.ParseRawFuturesAssets(rec)
+			if err != nil {
+				return err
+			}
+			docs = market_dto.AnyAssets(data)
+		}
+
+		numRecords++
+		numAssets += len(docs)
+		if r.dryRun || len(docs) == 0 {
+			return nil
+		}
+
+		_, err := store.UpsertAssets(docs)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reprocess %v: %v", endpoint, err)
+	}
+
+	fmt.Printf(" * %v: %v responses, %v assets -> %v\n", endpoint, numRecords, numAssets, store.Name())
+	return nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/providers/binance"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AssetsResponse is the body of the /assets requests.
+type AssetsResponse struct {
+	Data []market_dto.AssetBase `json:"data"`
+}
+
+// AssetResponse is the body of the /assets/:symbol requests. It holds the
+// stored asset info, the stored time range of each interval and the latest
+// row of the finest scraped interval.
+type AssetResponse struct {
+	market_dto.AssetBase
+	Data     any                       `json:"data"`
+	Coverage []market_dto.OhlcCoverage `json:"coverage"`
+	Latest   *market_dto.OhlcRow       `json:"latest"`
+}
+
+// getAssets returns the assets of the market which match the filters. The
+// order_types param is a comma separated list of the order types which the
+// assets have to support, the q param is a case insensitive part of the symbol.
+//
+//	GET /assets?market=futures&status=TRADING&quote=USDT&contract_type=PERPETUAL&order_types=LIMIT,MARKET&q=btc
+func (s *server) getAssets(c *fiber.Ctx) error {
+	m, err := s.market(c.Query("market"))
+	if err != nil {
+		return err
+	}
+
+	filter := market_dto.AssetFilter{
+		Source:       binance.Source,
+		Status:       c.Query("status"),
+		BaseAsset:    strings.ToUpper(c.Query("base")),
+		QuoteAsset:   strings.ToUpper(c.Query("quote")),
+		ContractType: c.Query("contract_type"),
+		Search:       c.Query("q"),
+	}
+
+	if orderTypes := c.Query("order_types"); orderTypes != "" {
+		filter.OrderTypes = strings.Split(orderTypes, ",")
+	}
+
+	docs, err := m.assets.GetAssets(filter)
+	if err != nil {
+		return err
+	}
+
+	if docs == nil {
+		docs = []market_dto.AssetBase{}
+	}
+
+	return c.JSON(AssetsResponse{Data: docs})
+}
+
+// getAsset returns the asset of the market with its data, coverage and latest row.
+//
+//	GET /assets/BTCUSDT?market=spot
+func (s *server) getAsset(c *fiber.Ctx) error {
+	m, err := s.market(c.Query("market"))
+	if err != nil {
+		return err
+	}
+
+	symbol := strings.ToUpper(c.Params("symbol"))
+
+	asset, err := m.assets.GetAsset(binance.Source, symbol)
+	if errors.Is(err, market_dto.ErrAssetNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "asset not found: "+symbol)
+	}
+	if err != nil {
+		return err
+	}
+
+	scraped, err := m.ohlc.GetCoverage(symbol)
+	if err != nil {
+		return err
+	}
+
+	derived, err := m.derived.GetCoverage(symbol)
+	if err != nil {
+		return err
+	}
+
+	res := AssetResponse{
+		AssetBase: asset.AssetBase,
+		Data:      asset.Data,
+		Coverage:  append(scraped, derived...),
+	}
+
+	sort.SliceStable(res.Coverage, func(i, j int) bool {
+		return res.Coverage[i].Interval < res.Coverage[j].Interval
+	})
+
+	if res.Coverage == nil {
+		res.Coverage = []market_dto.OhlcCoverage{}
+	}
+
+	if res.Latest, err = m.latestRow(symbol, scraped); err != nil {
+		return err
+	}
+
+	return c.JSON(res)
+}
+
+// latestRow returns the latest row of the finest interval of the scraped
+// coverage, or nil if there are no rows.
+func (m *marketStores) latestRow(symbol string, scraped []market_dto.OhlcCoverage) (*market_dto.OhlcRow, error) {
+	// the coverage is sorted by the interval, so the first one is the finest
+	if len(scraped) == 0 {
+		return nil, nil
+	}
+
+	rows, err := m.ohlc.GetOhlcRows(market_dto.OhlcFilter{Symbol: symbol, Interval: scraped[0].Interval, From: scraped[0].To, Limit: 1})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	return &rows[0], nil
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/auth_dto"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tompston/syro"
+)
+
+// apiKeyParam is the query param of the api key, for the clients which
+// can't set the headers (e.g. EventSource in browsers).
+const apiKeyParam = "api_key"
+
+// apiKeyLocal is the key under which the authenticated key is stored in the
+// locals of the request.
+const apiKeyLocal = "api_key"
+
+// requestKey returns the api key of the request, which is read from the
+// Authorization (Bearer) or X-Api-Key header, or the api_key query param.
+func requestKey(c *fiber.Ctx) string {
+	if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+
+	if key := c.Get("X-Api-Key"); key != "" {
+		return key
+	}
+
+	return c.Query(apiKeyParam)
+}
+
+// requireScope returns the middleware which allows the requests with a valid
+// key which has the scope, if the auth is enabled in the config. The quota
+// of the key is checked after the scope.
+func (s *server) requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !s.app.Conf().Api.Auth {
+			return c.Next()
+		}
+
+		key, usage, err := s.authorize(requestKey(c), scope)
+		if key != nil {
+			c.Locals(apiKeyLocal, key)
+		}
+
+		if usage != nil {
+			c.Set("X-RateLimit-Limit", strconv.FormatInt(usage.limit, 10))
+			c.Set("X-RateLimit-Remaining", strconv.FormatInt(usage.remaining, 10))
+			c.Set("X-RateLimit-Reset", strconv.FormatInt(usage.reset.Unix(), 10))
+
+			if !usage.ok {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(usage.reset).Seconds())+1))
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// quotaUsage is the state of the quota of a key after a request.
+type quotaUsage struct {
+	limit     int64
+	remaining int64
+	reset     time.Time // End of the window
+	ok        bool      // False if the quota is exceeded
+}
+
+// authorize checks that the secret belongs to an active key which has the
+// scope and counts the request against the quota of the key. The key is
+// returned if it's valid, even if it does not have the scope, and the usage
+// if the key has a quota. The errors hold the status code of the response.
+func (s *server) authorize(secret, scope string) (*auth_dto.ApiKey, *quotaUsage, error) {
+	if secret == "" {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "api key is required")
+	}
+
+	key, err := s.app.Stores().ApiKeys.GetKeyByHash(auth_dto.HashKey(secret))
+	if errors.Is(err, auth_dto.ErrKeyNotFound) {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.Revoked() {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "api key is revoked")
+	}
+
+	if !key.HasScope(scope) {
+		return key, nil, fiber.NewError(fiber.StatusForbidden, "api key does not have the "+scope+" scope")
+	}
+
+	if !key.Quota.Enabled() {
+		return key, nil, nil
+	}
+
+	remaining, reset, ok := s.quotas.take(key.ID, key.Quota, time.Now())
+	usage := &quotaUsage{limit: key.Quota.Requests, remaining: remaining, reset: reset, ok: ok}
+
+	if !ok {
+		return key, usage, fiber.NewError(fiber.StatusTooManyRequests, "quota of the api key is exceeded")
+	}
+
+	return key, usage, nil
+}
+
+// quotas counts the requests of the keys in fixed windows. The counts are
+// kept in the memory of the process which serves the api.
+type quotas struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+type quotaWindow struct {
+	start time.Time
+	count int64
+}
+
+func newQuotas() *quotas {
+	return &quotas{windows: make(map[string]*quotaWindow)}
+}
+
+// take counts a request of the key. Returns the number of remaining requests
+// in the window, the end of the window and false if the quota is exceeded.
+func (q *quotas) take(keyID string, quota auth_dto.Quota, now time.Time) (int64, time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	w, ok := q.windows[keyID]
+	if !ok || !now.Before(w.start.Add(quota.Window)) {
+		w = &quotaWindow{start: now}
+		q.windows[keyID] = w
+	}
+
+	reset := w.start.Add(quota.Window)
+	if w.count >= quota.Requests {
+		return 0, reset, false
+	}
+
+	w.count++
+	return quota.Requests - w.count, reset, true
+}
+
+// audit writes the requests to the audit log, if it's enabled in the config.
+// The errors are written by the error handler before the log is written, so
+// that the status of the response is logged.
+func (s *server) audit(c *fiber.Ctx) error {
+	if !s.app.Conf().Api.Audit {
+		return c.Next()
+	}
+
+	start := time.Now()
+
+	if err := c.Next(); err != nil {
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+
+	// the strings of the context are only valid in the handler, so they are copied
+	log := auth_dto.AuditLog{
+		Time:     start.UTC(),
+		Method:   strings.Clone(c.Method()),
+		Path:     strings.Clone(c.Path()),
+		Query:    auditQuery(c.Request().URI().QueryString()),
+		Status:   c.Response().StatusCode(),
+		Duration: time.Since(start).Milliseconds(),
+		Ip:       strings.Clone(c.IP()),
+	}
+
+	if key, ok := c.Locals(apiKeyLocal).(*auth_dto.ApiKey); ok {
+		log.KeyID, log.KeyName = key.ID, key.Name
+	}
+
+	if err := s.app.Stores().ApiAudit.InsertAuditLog(log); err != nil {
+		s.app.Logger().WithEvent("api").Error("failed to write the audit log", syro.LogFields{"error": err.Error(), "path": log.Path})
+	}
+
+	return nil
+}
+
+// auditQuery returns the query string without the api key.
+func auditQuery(query []byte) string {
+	values, err := url.ParseQuery(string(query))
+	if err != nil {
+		return ""
+	}
+
+	values.Del(apiKeyParam)
+	return values.Encode()
+}
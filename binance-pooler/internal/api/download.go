@@ -0,0 +1,205 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/market_io"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tompston/syro"
+)
+
+// downloadFormat is a format of the downloaded rows.
+type downloadFormat struct {
+	name        string // value of the format param and extension of the file
+	contentType string
+}
+
+var downloadFormats = []downloadFormat{
+	{"csv", "text/csv"},
+	{"ndjson", "application/x-ndjson"},
+	{"arrow", "application/vnd.apache.arrow.stream"},
+}
+
+// downloadRequest holds the params of a download.
+type downloadRequest struct {
+	format     downloadFormat
+	columns    []string
+	timeFormat string
+	store      market_dto.OhlcStore // nil if there are no rows
+	filter     market_dto.OhlcFilter
+	res        market_dto.Resolution
+	resample   bool
+	partial    bool
+}
+
+// download streams the rows of the symbol and interval straight from the
+// store, so that large ranges are not held in memory. The format is chosen
+// with the Accept header (csv by default), unless the format param is set,
+// and the body is compressed if gzip is accepted. The intervals which are
+// not stored are resampled one bucket at a time. The status is sent before
+// the rows are read, so the errors of the stream are only logged and the
+// body is cut off (the gzip body is left without its trailer).
+//
+//	GET /download?symbol=BTCUSDT&interval=1m&from=2024-01-01&to=2025-01-01&columns=start_time,close
+//	GET /download?market=futures&symbol=BTCUSDT&interval=1d&format=arrow
+func (s *server) download(c *fiber.Ctx) error {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" {
+		return badRequest("symbol is required")
+	}
+
+	res, err := parseResolution(c.Query("interval"))
+	if err != nil {
+		return err
+	}
+
+	m, err := s.market(c.Query("market"))
+	if err != nil {
+		return err
+	}
+
+	from, err := parseTime("from", c.Query("from"))
+	if err != nil {
+		return err
+	}
+
+	to, err := parseTime("to", c.Query("to"))
+	if err != nil {
+		return err
+	}
+
+	d := downloadRequest{res: res, partial: c.QueryBool("partial")}
+
+	if d.format, err = negotiateDownloadFormat(c); err != nil {
+		return err
+	}
+
+	if val := c.Query("columns"); val != "" {
+		d.columns = strings.Split(val, ",")
+	}
+
+	if err := market_io.CheckOhlcColumns(d.columns); err != nil {
+		return badRequest("%v", err)
+	}
+
+	switch c.Query("time_format", "rfc3339") {
+	case "rfc3339":
+	case market_io.TimeFormatUnixMilli:
+		d.timeFormat = market_io.TimeFormatUnixMilli
+	default:
+		return badRequest("time_format must be rfc3339 or %v", market_io.TimeFormatUnixMilli)
+	}
+
+	filter := market_dto.OhlcFilter{Symbol: symbol, From: from, To: to}
+
+	d.store, d.filter, d.resample, err = s.ohlcSource(m, filter, res, d.partial)
+	if err != nil {
+		return err
+	}
+
+	compress := c.Get(fiber.HeaderAcceptEncoding) != "" && c.AcceptsEncodings("gzip") == "gzip"
+
+	c.Set(fiber.HeaderContentType, d.format.contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%v-%v.%v"`, symbol, c.Query("interval"), d.format.name))
+	c.Vary(fiber.HeaderAccept, fiber.HeaderAcceptEncoding)
+	if compress {
+		c.Set(fiber.HeaderContentEncoding, "gzip")
+	}
+
+	c.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		var w io.Writer = bw
+
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(bw)
+			w = gz
+		}
+
+		if err := d.writeTo(w); err != nil {
+			s.app.Logger().WithEvent("api").Error("failed to stream the download", syro.LogFields{"error": err.Error(), "symbol": symbol})
+			bw.Flush()
+			return
+		}
+
+		if gz != nil {
+			gz.Close()
+		}
+		bw.Flush()
+	})
+
+	return nil
+}
+
+// negotiateDownloadFormat returns the format of the format param, or the
+// format of the Accept header.
+func negotiateDownloadFormat(c *fiber.Ctx) (downloadFormat, error) {
+	if name := c.Query("format"); name != "" {
+		for _, f := range downloadFormats {
+			if f.name == name {
+				return f, nil
+			}
+		}
+		return downloadFormat{}, badRequest("format must be csv, ndjson or arrow")
+	}
+
+	offers := make([]string, len(downloadFormats))
+	for i, f := range downloadFormats {
+		offers[i] = f.contentType
+	}
+
+	accepted := c.Accepts(offers...)
+	for _, f := range downloadFormats {
+		if f.contentType == accepted {
+			return f, nil
+		}
+	}
+
+	return downloadFormat{}, fiber.NewError(fiber.StatusNotAcceptable, "accepted content types: "+strings.Join(offers, ", "))
+}
+
+// writeTo writes the rows of the download in its format.
+func (d downloadRequest) writeTo(w io.Writer) error {
+	var ow market_io.OhlcWriter
+	var err error
+
+	switch d.format.name {
+	case "csv":
+		ow, err = market_io.NewOhlcCsvWriter(w, market_io.CsvSettings{Columns: d.columns, TimeFormat: d.timeFormat})
+	case "ndjson":
+		ow, err = market_io.NewOhlcNdjsonWriter(w, market_io.CsvSettings{Columns: d.columns, TimeFormat: d.timeFormat})
+	case "arrow":
+		ow, err = market_io.NewOhlcArrowWriter(w, market_io.ArrowSettings{Columns: d.columns})
+	default:
+		err = fmt.Errorf("unsupported format: %v", d.format.name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.store != nil {
+		write := ow.Write
+
+		var resampler *market_dto.OhlcResampler
+		if d.resample {
+			resampler = market_dto.NewOhlcResampler(d.res, d.partial, ow.Write)
+			write = resampler.Add
+		}
+
+		if err := d.store.StreamOhlcRows(d.filter, write); err != nil {
+			return err
+		}
+
+		if resampler != nil {
+			if err := resampler.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ow.Close()
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/poolerpb"
+	"binance-pooler/pkg/providers/binance"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// number of candles which are read from the store at once by GetCandles
+var grpcCandlesPage int64 = 1000
+
+// grpcServer implements the Pooler service with the stores and helpers of
+// the http api.
+type grpcServer struct {
+	poolerpb.UnimplementedPoolerServer
+	*server
+}
+
+// NewGrpc returns the grpc server of the query api, which reads the data
+// from the stores of the app. All of the methods require the read scope
+// if the auth is enabled in the config. The jobs of the settings are not
+// used by the grpc api.
+func NewGrpc(app *core.App, settings ...Settings) *grpc.Server {
+	s := &grpcServer{server: &server{app: app, quotas: newQuotas()}}
+
+	if len(settings) == 1 && settings[0].quotas != nil {
+		s.quotas = settings[0].quotas
+	}
+
+	g := grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+
+	poolerpb.RegisterPoolerServer(g, s)
+	return g
+}
+
+func (s *grpcServer) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authorizeGrpc(ctx); err != nil {
+		return nil, grpcError(err)
+	}
+
+	res, err := handler(ctx, req)
+	return res, grpcError(err)
+}
+
+func (s *grpcServer) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorizeGrpc(ss.Context()); err != nil {
+		return grpcError(err)
+	}
+
+	return grpcError(handler(srv, ss))
+}
+
+// authorizeGrpc checks the api key of the call, which is read from the
+// authorization (Bearer) or x-api-key metadata, if the auth is enabled.
+func (s *grpcServer) authorizeGrpc(ctx context.Context) error {
+	if !s.app.Conf().Api.Auth {
+		return nil
+	}
+
+	var secret string
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get("authorization"); len(vals) > 0 {
+		secret, _ = strings.CutPrefix(vals[0], "Bearer ")
+	} else if vals := md.Get("x-api-key"); len(vals) > 0 {
+		secret = vals[0]
+	}
+
+	_, _, err := s.authorize(strings.TrimSpace(secret), auth_dto.ScopeRead)
+	return err
+}
+
+// grpcError converts the errors of the http helpers into the grpc status
+// errors with the matching code.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	code := codes.Internal
+
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		switch e.Code {
+		case fiber.StatusBadRequest:
+			code = codes.InvalidArgument
+		case fiber.StatusUnauthorized:
+			code = codes.Unauthenticated
+		case fiber.StatusForbidden:
+			code = codes.PermissionDenied
+		case fiber.StatusNotFound:
+			code = codes.NotFound
+		case fiber.StatusTooManyRequests:
+			code = codes.ResourceExhausted
+		}
+	}
+
+	return status.Error(code, err.Error())
+}
+
+func (s *grpcServer) ListAssets(ctx context.Context, req *poolerpb.ListAssetsRequest) (*poolerpb.ListAssetsResponse, error) {
+	m, err := s.market(req.Market)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := m.assets.GetAssets(market_dto.AssetFilter{
+		Source:       binance.Source,
+		Status:       req.Status,
+		BaseAsset:    strings.ToUpper(req.Base),
+		QuoteAsset:   strings.ToUpper(req.Quote),
+		ContractType: req.ContractType,
+		OrderTypes:   req.OrderTypes,
+		Search:       req.Q,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &poolerpb.ListAssetsResponse{Assets: make([]*poolerpb.Asset, len(docs))}
+	for i, doc := range docs {
+		res.Assets[i] = &poolerpb.Asset{
+			UpdatedAt:  timestamppb.New(doc.UpdatedAt),
+			Source:     doc.Source,
+			Symbol:     doc.Symbol,
+			Status:     doc.Status,
+			BaseAsset:  doc.BaseAsset,
+			QuoteAsset: doc.QuoteAsset,
+			OrderTypes: doc.OrderTypes,
+		}
+	}
+
+	return res, nil
+}
+
+// GetCandles reads the rows of the range in pages, which start after the
+// end of the last sent row, so that only one page is held in memory.
+func (s *grpcServer) GetCandles(req *poolerpb.GetCandlesRequest, stream grpc.ServerStreamingServer[poolerpb.Candle]) error {
+	symbol := strings.ToUpper(req.Symbol)
+	if symbol == "" {
+		return badRequest("symbol is required")
+	}
+
+	if req.Limit < 0 {
+		return badRequest("limit can't be negative")
+	}
+
+	res, err := parseResolution(req.Interval)
+	if err != nil {
+		return err
+	}
+
+	m, err := s.market(req.Market)
+	if err != nil {
+		return err
+	}
+
+	filter := market_dto.OhlcFilter{Symbol: symbol, From: protoTime(req.From), To: protoTime(req.To)}
+
+	for sent := int64(0); req.Limit == 0 || sent < req.Limit; {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+
+		filter.Limit = grpcCandlesPage
+		if req.Limit > 0 {
+			filter.Limit = min(filter.Limit, req.Limit-sent)
+		}
+
+		rows, err := s.queryOhlc(m, filter, res, req.Partial)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := stream.Send(protoCandle(row)); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(rows)) < filter.Limit {
+			return nil
+		}
+
+		sent += int64(len(rows))
+
+		last := rows[len(rows)-1]
+		filter.From = last.StartTime.Add(time.Duration(last.Interval) * time.Millisecond)
+	}
+
+	return nil
+}
+
+// SubscribeCandles sends the replayed rows and then the rows which are
+// pushed to the live feed, until the client cancels the call. Subscribers
+// which fall behind get the unavailable code and should resubscribe with
+// the since time set to the start of the last received row.
+func (s *grpcServer) SubscribeCandles(req *poolerpb.SubscribeCandlesRequest, stream grpc.ServerStreamingServer[poolerpb.Candle]) error {
+	live, err := s.newLiveRequest(req.Market, req.Symbols, req.Interval, protoTime(req.Since))
+	if err != nil {
+		return err
+	}
+
+	// subscribe before the replay, so that no rows are missed in between
+	sub := s.app.OhlcFeed().Subscribe(live.filter, liveBuffer)
+	defer sub.Close()
+
+	replay, err := live.replay()
+	if err != nil {
+		return err
+	}
+
+	for _, row := range replay {
+		if err := stream.Send(protoCandle(row)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case row, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "subscriber fell behind")
+			}
+			if err := stream.Send(protoCandle(row)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *grpcServer) GetCoverage(ctx context.Context, req *poolerpb.GetCoverageRequest) (*poolerpb.GetCoverageResponse, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	if symbol == "" {
+		return nil, badRequest("symbol is required")
+	}
+
+	m, err := s.market(req.Market)
+	if err != nil {
+		return nil, err
+	}
+
+	scraped, err := m.ohlc.GetCoverage(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	derived, err := m.derived.GetCoverage(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &poolerpb.GetCoverageResponse{}
+
+	for _, c := range scraped {
+		res.Coverage = append(res.Coverage, protoCoverage(c, false))
+	}
+	for _, c := range derived {
+		res.Coverage = append(res.Coverage, protoCoverage(c, true))
+	}
+
+	sort.SliceStable(res.Coverage, func(i, j int) bool {
+		return res.Coverage[i].Interval < res.Coverage[j].Interval
+	})
+
+	latest, err := m.latestRow(symbol, scraped)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest != nil {
+		res.Latest = protoCandle(*latest)
+	}
+
+	return res, nil
+}
+
+// protoTime returns the time of the timestamp, or the zero time if it's not set.
+func protoTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func protoCandle(row market_dto.OhlcRow) *poolerpb.Candle {
+	return &poolerpb.Candle{
+		Source:          row.Source,
+		Market:          row.Market,
+		Symbol:          row.Symbol,
+		Interval:        row.Interval,
+		StartTime:       timestamppb.New(row.StartTime),
+		Open:            row.Open,
+		High:            row.High,
+		Low:             row.Low,
+		Close:           row.Close,
+		Volume:          row.Volume,
+		BaseAssetVolume: row.BaseAssetVolume,
+		NumberOfTrades:  row.NumberOfTrades,
+		Revision:        row.Revision,
+	}
+}
+
+func protoCoverage(c market_dto.OhlcCoverage, derived bool) *poolerpb.Coverage {
+	return &poolerpb.Coverage{
+		Interval: c.Interval,
+		From:     timestamppb.New(c.From),
+		To:       timestamppb.New(c.To),
+		Count:    c.Count,
+		Derived:  derived,
+	}
+}
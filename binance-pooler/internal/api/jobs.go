@@ -0,0 +1,331 @@
+package api
+
+import (
+	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/jobs"
+	"binance-pooler/pkg/lib/timeset"
+	"binance-pooler/pkg/providers/binance"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tompston/syro"
+)
+
+// backfillTask is the name of the backfill tasks.
+const backfillTask = "backfill"
+
+// getKlines returns the function which requests the klines of the market.
+var getKlines = func(api binance.API, market string) binance.GetHistoryFunc {
+	if market == market_dto.MarketUsdm {
+		return api.GetFutureKline
+	}
+	return api.GetSpotKline
+}
+
+// JobsResponse is the body of the /jobs requests. If the jobs are not
+// scheduled by the process which serves the api, only the jobs in the cron
+// storage are returned and they can't be controlled.
+type JobsResponse struct {
+	Data       []jobs.JobState `json:"data"`
+	Controlled bool            `json:"controlled"`
+}
+
+// errNotControlled is returned if the jobs are scheduled by another process.
+var errNotControlled = fiber.NewError(fiber.StatusNotImplemented, "jobs are not scheduled by this process, serve the api with the pooler -api flag to control them")
+
+// jobError returns the error of the job controller with the matching status code.
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrJobRunning):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return err
+	}
+}
+
+// getJobs returns the registered jobs with their latest run and status.
+//
+//	GET /jobs
+func (s *server) getJobs(c *fiber.Ctx) error {
+	if s.jobs != nil {
+		states, err := s.jobs.Jobs()
+		if err != nil {
+			return err
+		}
+		return c.JSON(JobsResponse{Data: states, Controlled: true})
+	}
+
+	res := JobsResponse{Data: []jobs.JobState{}}
+
+	storage := s.app.CronStorage()
+	if storage == nil {
+		return c.JSON(res)
+	}
+
+	docs, err := storage.FindCronJobs()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		res.Data = append(res.Data, jobs.JobState{
+			Name:        doc.Name,
+			Schedule:    doc.Schedule,
+			Description: doc.Description,
+			Running:     doc.Status == string(syro.JobStatusRunning),
+			Stored:      &doc,
+		})
+	}
+
+	return c.JSON(res)
+}
+
+// getJob returns the state of the job.
+//
+//	GET /jobs/binance-spot-ohlc
+func (s *server) getJob(c *fiber.Ctx) error {
+	if s.jobs == nil {
+		return errNotControlled
+	}
+
+	state, err := s.jobs.Job(c.Params("name"))
+	if err != nil {
+		return jobError(err)
+	}
+
+	return c.JSON(state)
+}
+
+// triggerJob runs the job now, in the background.
+//
+//	POST /jobs/binance-spot-ohlc/trigger
+func (s *server) triggerJob(c *fiber.Ctx) error {
+	if s.jobs == nil {
+		return errNotControlled
+	}
+
+	if err := s.jobs.Trigger(c.Params("name")); err != nil {
+		return jobError(err)
+	}
+
+	return s.jobStatus(c, fiber.StatusAccepted)
+}
+
+// pauseJob skips the scheduled runs of the job until it's resumed.
+//
+//	POST /jobs/binance-spot-ohlc/pause
+func (s *server) pauseJob(c *fiber.Ctx) error {
+	if s.jobs == nil {
+		return errNotControlled
+	}
+
+	if err := s.jobs.Pause(c.Params("name")); err != nil {
+		return jobError(err)
+	}
+
+	return s.jobStatus(c, fiber.StatusOK)
+}
+
+// resumeJob continues the scheduled runs of the paused job.
+//
+//	POST /jobs/binance-spot-ohlc/resume
+func (s *server) resumeJob(c *fiber.Ctx) error {
+	if s.jobs == nil {
+		return errNotControlled
+	}
+
+	if err := s.jobs.Resume(c.Params("name")); err != nil {
+		return jobError(err)
+	}
+
+	return s.jobStatus(c, fiber.StatusOK)
+}
+
+func (s *server) jobStatus(c *fiber.Ctx, code int) error {
+	state, err := s.jobs.Job(c.Params("name"))
+	if err != nil {
+		return jobError(err)
+	}
+
+	return c.Status(code).JSON(state)
+}
+
+// IntervalScrapeState is the state of the rows of a symbol and interval.
+type IntervalScrapeState struct {
+	market_dto.OhlcCoverage
+	Lag     int64                          `json:"lag"` // Milliseconds between the end of the latest row and now
+	Attempt *binance_service.ScrapeAttempt `json:"attempt,omitempty"`
+}
+
+type SymbolScrapeState struct {
+	Symbol    string                `json:"symbol"`
+	Intervals []IntervalScrapeState `json:"intervals"`
+}
+
+type ScrapeStateResponse struct {
+	Data []SymbolScrapeState `json:"data"`
+}
+
+// getScrapeState returns the stored range and the lag of the scraped
+// intervals of the symbols, defaulting to the scraped top pairs. If the
+// api is served by the pooler process, the latest scrape attempts (and
+// their errors) are returned as well.
+//
+//	GET /scrape-state?market=spot&symbols=BTCUSDT,ETHUSDT
+func (s *server) getScrapeState(c *fiber.Ctx) error {
+	m, err := s.market(c.Query("market"))
+	if err != nil {
+		return err
+	}
+
+	symbols := binance.TopPairs
+	if param := c.Query("symbols"); param != "" {
+		symbols = strings.Split(strings.ToUpper(param), ",")
+	}
+
+	now := time.Now()
+	res := ScrapeStateResponse{Data: []SymbolScrapeState{}}
+
+	for _, symbol := range symbols {
+		coverage, err := m.ohlc.GetCoverage(symbol)
+		if err != nil {
+			return err
+		}
+
+		state := SymbolScrapeState{Symbol: symbol, Intervals: []IntervalScrapeState{}}
+		intervals := make(map[int64]int)
+
+		for _, cov := range coverage {
+			end := cov.To.Add(timeset.MilisToDuration(cov.Interval))
+			intervals[cov.Interval] = len(state.Intervals)
+			state.Intervals = append(state.Intervals, IntervalScrapeState{OhlcCoverage: cov, Lag: now.Sub(end).Milliseconds()})
+		}
+
+		if s.scrapeState != nil {
+			for _, attempt := range s.scrapeState.Attempts(m.ohlc.Name(), symbol) {
+				i, ok := intervals[attempt.Interval]
+				if !ok {
+					i = len(state.Intervals)
+					state.Intervals = append(state.Intervals, IntervalScrapeState{OhlcCoverage: market_dto.OhlcCoverage{Interval: attempt.Interval}})
+				}
+				state.Intervals[i].Attempt = &attempt
+			}
+		}
+
+		sort.SliceStable(state.Intervals, func(i, j int) bool {
+			return state.Intervals[i].Interval < state.Intervals[j].Interval
+		})
+
+		res.Data = append(res.Data, state)
+	}
+
+	return c.JSON(res)
+}
+
+// BackfillRequest is the body of the backfill requests. The times are
+// parsed like the time params (unix ms, RFC3339 or a date) and the to time
+// defaults to now.
+type BackfillRequest struct {
+	Market   string `json:"market"`
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// startBackfill requests the rows of the symbol in the range from the binance
+// api in the background. The progress is polled with the returned task id.
+//
+//	POST /backfills {"market":"spot","symbol":"BTCUSDT","interval":"1m","from":"2024-01-01","to":"2024-02-01"}
+func (s *server) startBackfill(c *fiber.Ctx) error {
+	var req BackfillRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest("invalid body: %v", err)
+	}
+
+	m, err := s.market(req.Market)
+	if err != nil {
+		return err
+	}
+
+	req.Symbol = strings.ToUpper(req.Symbol)
+	if req.Symbol == "" {
+		return badRequest("symbol is required")
+	}
+
+	tf, err := binance.ParseTimeframe(req.Interval)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	from, err := parseTime("from", req.From)
+	if err != nil {
+		return err
+	}
+
+	if from.IsZero() {
+		return badRequest("from is required")
+	}
+
+	to, err := parseTime("to", req.To)
+	if err != nil {
+		return err
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	if !from.Before(to) {
+		return badRequest("from has to be before to")
+	}
+
+	if _, err := m.assets.GetAsset(binance.Source, req.Symbol); err != nil {
+		if errors.Is(err, market_dto.ErrAssetNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "asset not found: "+req.Symbol)
+		}
+		return err
+	}
+
+	svc := binance_service.New(s.app, 1, []binance.Timeframe{tf})
+	klines := getKlines(binance.New().WithLake(s.app.RawLake()), m.market)
+
+	task, err := s.tasks.Start(backfillTask, req, func(progress jobs.Progress) error {
+		return svc.BackfillRange(m.ohlc, klines, req.Symbol, tf, from, to, progress)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(task)
+}
+
+type BackfillsResponse struct {
+	Data []jobs.Task `json:"data"`
+}
+
+// getBackfills returns the backfills started by this process, starting with
+// the latest one.
+//
+//	GET /backfills
+func (s *server) getBackfills(c *fiber.Ctx) error {
+	return c.JSON(BackfillsResponse{Data: s.tasks.List(backfillTask)})
+}
+
+// getBackfill returns the progress of the backfill.
+//
+//	GET /backfills/0123456789abcdef
+func (s *server) getBackfill(c *fiber.Ctx) error {
+	task, err := s.tasks.Get(c.Params("id"))
+	if err != nil || task.Name != backfillTask {
+		return fiber.NewError(fiber.StatusNotFound, "backfill not found: "+c.Params("id"))
+	}
+
+	return c.JSON(task)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/timeset"
+	"binance-pooler/pkg/providers/binance"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// number of rows which are buffered for a live subscriber. Subscribers
+	// which fall behind are disconnected and replay the missed rows.
+	liveBuffer = 1024
+	// max number of stored rows which are sent on (re)connect
+	maxReplayRows = 5000
+)
+
+// interval at which the idle live connections are checked
+var liveHeartbeat = 15 * time.Second
+
+// liveRequest holds the parsed params of a live subscription.
+type liveRequest struct {
+	filter market_dto.FeedFilter
+	store  market_dto.OhlcStore // store from which the rows are replayed
+	since  time.Time
+}
+
+// parseLiveRequest parses the params of the live endpoints. The symbols param
+// is a comma separated list of symbols, the interval param is a stored
+// interval. If the since param is set, the stored rows which start at or
+// after it are sent before the live rows.
+func (s *server) parseLiveRequest(c *fiber.Ctx) (*liveRequest, error) {
+	var symbols []string
+	if val := c.Query("symbols"); val != "" {
+		// the params are only valid in the handler, but the filter is used by the stream
+		symbols = strings.Split(strings.Clone(val), ",")
+	}
+
+	since, err := parseTime("since", c.Query("since"))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newLiveRequest(c.Query("market"), symbols, c.Query("interval"), since)
+}
+
+// newLiveRequest returns the subscription of the symbols (all of them if
+// empty) and interval (all of them if empty) of the market.
+func (s *server) newLiveRequest(market string, symbols []string, interval string, since time.Time) (*liveRequest, error) {
+	m, err := s.market(market)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &liveRequest{filter: market_dto.FeedFilter{Market: m.market}, store: m.ohlc, since: since}
+
+	for _, symbol := range symbols {
+		req.filter.Symbols = append(req.filter.Symbols, strings.ToUpper(symbol))
+	}
+
+	if interval != "" {
+		res, err := parseResolution(interval)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.IsCalendar() {
+			return nil, badRequest("calendar intervals are not supported by the live feed")
+		}
+
+		req.filter.Interval = res.Interval
+		req.store = m.derived
+		for _, tf := range binance.Timeframes {
+			if tf.Milis == res.Interval {
+				req.store = m.ohlc
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// replay returns the stored rows of the subscription which start at or
+// after the since time, sorted by the start time.
+func (req *liveRequest) replay() ([]market_dto.OhlcRow, error) {
+	if req.since.IsZero() {
+		return nil, nil
+	}
+
+	filter := market_dto.OhlcFilter{Market: req.filter.Market, Interval: req.filter.Interval, From: req.since, Limit: maxReplayRows}
+
+	if len(req.filter.Symbols) == 0 {
+		return req.store.GetOhlcRows(filter)
+	}
+
+	var docs []market_dto.OhlcRow
+	for _, symbol := range req.filter.Symbols {
+		filter.Symbol = symbol
+		rows, err := req.store.GetOhlcRows(filter)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, rows...)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].StartTime.Before(docs[j].StartTime) })
+	return docs, nil
+}
+
+// liveSse pushes the written rows as server-sent events. The id of the
+// events is the start time of the row in milliseconds, so that the clients
+// which reconnect with the Last-Event-ID header replay the rows from it.
+//
+//	GET /live/sse?market=spot&symbols=BTCUSDT,ETHUSDT&interval=1m&since=2024-01-01T00:00:00Z
+func (s *server) liveSse(c *fiber.Ctx) error {
+	req, err := s.parseLiveRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if lastId := c.Get("Last-Event-ID"); lastId != "" {
+		ms, err := strconv.ParseInt(lastId, 10, 64)
+		if err != nil {
+			return badRequest("invalid Last-Event-ID: %v", lastId)
+		}
+		req.since = timeset.UnixMillisToTime(ms)
+	}
+
+	// subscribe before the replay, so that no rows are missed in between
+	sub := s.app.OhlcFeed().Subscribe(req.filter, liveBuffer)
+
+	replay, err := req.replay()
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		send := func(row market_dto.OhlcRow) error {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "id: %d\nevent: candle\ndata: %s\n\n", row.StartTime.UnixMilli(), data)
+			return w.Flush()
+		}
+
+		for _, row := range replay {
+			if err := send(row); err != nil {
+				return
+			}
+		}
+
+		// the comment makes sure that the headers are sent if there are no rows
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(liveHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case row, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := send(row); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// liveWsUpgrade parses the params of the websocket subscription before the
+// connection is upgraded, so that the invalid requests get an error response.
+func (s *server) liveWsUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	req, err := s.parseLiveRequest(c)
+	if err != nil {
+		return err
+	}
+
+	c.Locals("live", req)
+	return c.Next()
+}
+
+// liveWs pushes the written rows as json messages over a websocket.
+//
+//	GET /live/ws?market=futures&symbols=BTCUSDT&interval=1m&since=1704067200000
+func (s *server) liveWs(conn *websocket.Conn) {
+	req := conn.Locals("live").(*liveRequest)
+
+	sub := s.app.OhlcFeed().Subscribe(req.filter, liveBuffer)
+	defer sub.Close()
+
+	replay, err := req.replay()
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		return
+	}
+
+	for _, row := range replay {
+		if err := conn.WriteJSON(row); err != nil {
+			return
+		}
+	}
+
+	// the messages of the client are only read to find out when it disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case row, ok := <-sub.C:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber fell behind"))
+				return
+			}
+			if err := conn.WriteJSON(row); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/jobs"
+	"binance-pooler/pkg/lib/timeset"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// Markets which can be queried with the market param
+const (
+	MarketSpot    = "spot"
+	MarketFutures = "futures"
+)
+
+type server struct {
+	app         *core.App
+	jobs        *jobs.Controller
+	scrapeState *binance_service.ScrapeState
+	tasks       *jobs.Tasks
+	quotas      *quotas
+}
+
+// Settings holds the optional dependencies of the api. The jobs and the
+// scrape state are only available if the api is served by the process
+// which runs the scheduler.
+type Settings struct {
+	Jobs        *jobs.Controller             // Controller of the scheduled jobs
+	ScrapeState *binance_service.ScrapeState // Latest scrape attempts of the symbols
+	Tasks       *jobs.Tasks                  // Tasks of the backfills (created if nil)
+	quotas      *quotas                      // Shared by the http and grpc apis of Serve
+}
+
+// New returns the http server of the query api, which reads the data
+// from the stores of the app.
+func New(app *core.App, settings ...Settings) *fiber.App {
+	s := &server{app: app, quotas: newQuotas()}
+
+	if len(settings) == 1 {
+		s.jobs = settings[0].Jobs
+		s.scrapeState = settings[0].ScrapeState
+		s.tasks = settings[0].Tasks
+
+		if settings[0].quotas != nil {
+			s.quotas = settings[0].quotas
+		}
+	}
+
+	if s.tasks == nil {
+		s.tasks = jobs.NewTasks()
+	}
+
+	api := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ErrorHandler:          errorHandler,
+	})
+
+	api.Use(cors.New(cors.Config{
+		AllowCredentials: false,
+		AllowOrigins:     "*", // NOTE: change this to a list of urls from which fetch requests are allowed
+	}))
+
+	api.Use(s.audit)
+
+	// scopes of the api keys which are required if the auth is enabled
+	read := s.requireScope(auth_dto.ScopeRead)
+	control := s.requireScope(auth_dto.ScopeJobs)
+
+	api.Get("/ohlc", read, s.getOhlc)
+	api.Get("/download", read, s.download)
+	api.Get("/assets", read, s.getAssets)
+	api.Get("/assets/:symbol", read, s.getAsset)
+	api.Get("/live/sse", read, s.liveSse)
+	api.Get("/live/ws", read, s.liveWsUpgrade, websocket.New(s.liveWs))
+
+	api.Get("/jobs", control, s.getJobs)
+	api.Get("/jobs/:name", control, s.getJob)
+	api.Post("/jobs/:name/trigger", control, s.triggerJob)
+	api.Post("/jobs/:name/pause", control, s.pauseJob)
+	api.Post("/jobs/:name/resume", control, s.resumeJob)
+	api.Get("/scrape-state", control, s.getScrapeState)
+	api.Post("/backfills", control, s.startBackfill)
+	api.Get("/backfills", control, s.getBackfills)
+	api.Get("/backfills/:id", control, s.getBackfill)
+
+	udfGroup := api.Group("/udf")
+	udfGroup.Get("/config", udf(read), udf(s.udfConfig))
+	udfGroup.Get("/symbols", udf(read), udf(s.udfSymbols))
+	udfGroup.Get("/search", udf(read), udf(s.udfSearch))
+	udfGroup.Get("/history", udf(read), udf(s.udfHistory))
+	udfGroup.Get("/time", udf(read), udf(s.udfTime))
+
+	api.Get("/openapi.json", s.getOpenApi)
+
+	return api
+}
+
+// Serve serves the http api and the grpc api on the ports of the [api]
+// config, depending on which of them are enabled. Blocks until one of them
+// fails.
+func Serve(app *core.App, settings ...Settings) error {
+	conf := app.Conf().Api
+	if conf.DisableHttp && conf.GrpcPort == 0 {
+		return fmt.Errorf("both the http and the grpc api are disabled in the config")
+	}
+
+	var set Settings
+	if len(settings) == 1 {
+		set = settings[0]
+	}
+
+	// the requests of both apis count against the same quotas
+	set.quotas = newQuotas()
+
+	errs := make(chan error, 2)
+
+	if !conf.DisableHttp {
+		addr := fmt.Sprintf("%v:%v", conf.Host, conf.Port)
+		log.Println("Starting HTTP server on " + addr)
+
+		go func() {
+			if err := New(app, set).Listen(addr); err != nil {
+				errs <- fmt.Errorf("failed to start HTTP server: %v", err)
+			}
+		}()
+	}
+
+	if conf.GrpcPort != 0 {
+		addr := fmt.Sprintf("%v:%v", conf.Host, conf.GrpcPort)
+		log.Println("Starting gRPC server on " + addr)
+
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %v: %v", addr, err)
+		}
+
+		go func() {
+			if err := NewGrpc(app, set).Serve(lis); err != nil {
+				errs <- fmt.Errorf("failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
+	return <-errs
+}
+
+// ErrorResponse is the body of the failed requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// errorHandler writes the errors of the handlers as json.
+func errorHandler(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code = e.Code
+	}
+
+	return c.Status(code).JSON(ErrorResponse{Error: err.Error()})
+}
+
+// badRequest returns an error which is written with the 400 status code.
+func badRequest(format string, a ...any) error {
+	return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf(format, a...))
+}
+
+// marketStores holds the stores of a market.
+type marketStores struct {
+	market  string // market of the rows (e.g. usdm for the futures)
+	assets  market_dto.AssetStore
+	ohlc    market_dto.OhlcStore // scraped timeframes
+	derived market_dto.OhlcStore // timeframes aggregated from the scraped rows
+}
+
+// market returns the stores of the market param, defaulting to spot.
+func (s *server) market(name string) (*marketStores, error) {
+	stores := s.app.Stores()
+
+	switch name {
+	case "", MarketSpot:
+		return &marketStores{market_dto.MarketSpot, stores.CryptoSpotAsset, stores.CryptoSpotOhlc, stores.CryptoSpotOhlcDerived}, nil
+	case MarketFutures:
+		return &marketStores{market_dto.MarketUsdm, stores.CryptoFuturesAsset, stores.CryptoFuturesOhlc, stores.CryptoFuturesOhlcDerived}, nil
+	default:
+		return nil, badRequest("unsupported market: %v", name)
+	}
+}
+
+// parseTime parses the time param, which can be a unix timestamp in
+// milliseconds, a RFC3339 time or a date (YYYY-MM-DD).
+func parseTime(key, val string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return timeset.UnixMillisToTime(ms), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, badRequest("invalid %v time: %v", key, val)
+}
+
+// parseResolution parses the interval param (e.g. 15m, 4h, 1d, 1w, 1M).
+func parseResolution(val string) (market_dto.Resolution, error) {
+	if val == "" {
+		return market_dto.Resolution{}, badRequest("interval is required")
+	}
+
+	res, err := market_dto.ParseResolution(val)
+	if err != nil {
+		return market_dto.Resolution{}, badRequest("%v", err)
+	}
+
+	return res, nil
+}
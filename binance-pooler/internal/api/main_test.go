@@ -0,0 +1,1069 @@
+package api
+
+import (
+	"binance-pooler/internal/pooler/binance_service"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/jobs"
+	"binance-pooler/pkg/market_io"
+	"binance-pooler/pkg/poolerpb"
+	"binance-pooler/pkg/providers/binance"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/robfig/cron/v3"
+	"github.com/tompston/syro"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestApp returns an app with the 1m rows of the first hour of 2024 in
+// both markets, with the futures rows closing at 2.
+func newTestApp(t *testing.T) *core.App {
+	t.Helper()
+
+	app := core.NewMemoryApp(nil)
+
+	for _, s := range []struct {
+		store market_dto.OhlcStore
+		close float64
+	}{
+		{app.Stores().CryptoSpotOhlc, 1.5},
+		{app.Stores().CryptoFuturesOhlc, 2},
+	} {
+		var rows []market_dto.OhlcRow
+		for i := 0; i < 60; i++ {
+			t1 := testStart.Add(time.Duration(i) * time.Minute)
+			row, err := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, s.close, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+
+		if _, err := s.store.UpsertOhlcRows(rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return app
+}
+
+// get requests the path and decodes the json body into the res.
+func get(t *testing.T, app *core.App, path string, res any) int {
+	t.Helper()
+
+	resp, err := New(app).Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		t.Fatalf("failed to decode the response of %v: %v", path, err)
+	}
+
+	return resp.StatusCode
+}
+
+func TestOhlc(t *testing.T) {
+	app := newTestApp(t)
+
+	t.Run("pagination", func(t *testing.T) {
+		var rows []market_dto.OhlcRow
+		path := "/ohlc?symbol=btcusdt&interval=1m&from=2024-01-01T00:10:00Z&to=2024-01-01T00:34:00Z&limit=10"
+
+		for page := 0; ; page++ {
+			var res OhlcResponse
+			if code := get(t, app, path, &res); code != http.StatusOK {
+				t.Fatalf("unexpected status: %v", code)
+			}
+
+			rows = append(rows, res.Data...)
+			if res.NextCursor == "" {
+				break
+			}
+
+			if page > 3 {
+				t.Fatal("too many pages")
+			}
+			path = "/ohlc?symbol=btcusdt&interval=1m&to=2024-01-01T00:34:00Z&limit=10&cursor=" + res.NextCursor
+		}
+
+		if len(rows) != 25 {
+			t.Fatalf("expected 25 rows, got %d", len(rows))
+		}
+
+		if !rows[0].StartTime.Equal(testStart.Add(10*time.Minute)) || !rows[24].StartTime.Equal(testStart.Add(34*time.Minute)) {
+			t.Fatalf("unexpected range: %v - %v", rows[0].StartTime, rows[24].StartTime)
+		}
+	})
+
+	t.Run("futures market", func(t *testing.T) {
+		var res OhlcResponse
+		get(t, app, "/ohlc?market=futures&symbol=BTCUSDT&interval=1m&limit=1", &res)
+
+		if len(res.Data) != 1 || res.Data[0].Close != 2 || res.NextCursor == "" {
+			t.Fatalf("unexpected response: %+v", res)
+		}
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		var res OhlcResponse
+		get(t, app, "/ohlc?symbol=BTCUSDT&interval=15m&from=2025-01-01", &res)
+
+		if res.Data == nil || len(res.Data) != 0 {
+			t.Fatalf("expected an empty list, got %+v", res.Data)
+		}
+	})
+
+	t.Run("resampling", func(t *testing.T) {
+		var res OhlcResponse
+		get(t, app, "/ohlc?symbol=BTCUSDT&interval=20m&from=2024-01-01T00:30:00Z&limit=1", &res)
+
+		// the range is aligned to the start of the bucket
+		if len(res.Data) != 1 || !res.Data[0].StartTime.Equal(testStart.Add(20*time.Minute)) || res.Data[0].Volume != 20 || res.NextCursor == "" {
+			t.Fatalf("unexpected resampled page: %+v", res)
+		}
+
+		var next OhlcResponse
+		get(t, app, "/ohlc?symbol=BTCUSDT&interval=20m&limit=1&cursor="+res.NextCursor, &next)
+		if len(next.Data) != 1 || !next.Data[0].StartTime.Equal(testStart.Add(40*time.Minute)) || next.NextCursor != "" {
+			t.Fatalf("unexpected next page: %+v", next)
+		}
+
+		// the week is not complete
+		get(t, app, "/ohlc?symbol=BTCUSDT&interval=1w", &res)
+		if len(res.Data) != 0 {
+			t.Fatalf("expected no complete weeks, got %+v", res.Data)
+		}
+
+		get(t, app, "/ohlc?market=futures&symbol=BTCUSDT&interval=1w&partial=true", &res)
+		if len(res.Data) != 1 || res.Data[0].Volume != 60 || res.Data[0].Close != 2 || res.Data[0].Interval != int64(7*24*time.Hour/time.Millisecond) {
+			t.Fatalf("unexpected partial week: %+v", res.Data)
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, path := range []string{
+			"/ohlc?interval=1m",
+			"/ohlc?symbol=BTCUSDT",
+			"/ohlc?symbol=BTCUSDT&interval=1m&market=options",
+			"/ohlc?symbol=BTCUSDT&interval=1m&from=yesterday",
+			"/ohlc?symbol=BTCUSDT&interval=1m&limit=0",
+			"/ohlc?symbol=BTCUSDT&interval=5M",
+			"/ohlc?symbol=BTCUSDT&interval=1m&cursor=%%%",
+		} {
+			var res ErrorResponse
+			if code := get(t, app, path, &res); code != http.StatusBadRequest || res.Error == "" {
+				t.Fatalf("expected a 400 error for %v, got %v %+v", path, code, res)
+			}
+		}
+	})
+}
+
+func TestDownload(t *testing.T) {
+	app := newTestApp(t)
+	api := New(app)
+
+	download := func(path string, header map[string]string) *http.Response {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, val := range header {
+			req.Header.Set(key, val)
+		}
+
+		resp, err := api.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		resp := download("/download?symbol=btcusdt&interval=1m&from=2024-01-01T00:10:00Z", nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(fiber.HeaderContentType) != "text/csv" {
+			t.Fatalf("unexpected response: %v %v", resp.StatusCode, resp.Header)
+		}
+
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		col := func(name string) int { return slices.Index(market_io.OhlcCsvColumns, name) }
+		if len(records) != 51 || records[0][col("start_time")] != "start_time" || records[1][col("start_time")] != "2024-01-01T00:10:00Z" ||
+			records[1][col("source")] != binance.Source || records[1][col("market")] != market_dto.MarketSpot {
+			t.Fatalf("unexpected csv: %v rows, %v", len(records), records[:2])
+		}
+	})
+
+	t.Run("gzipped ndjson", func(t *testing.T) {
+		resp := download("/download?symbol=BTCUSDT&interval=1m&columns=start_time,close&time_format=unix_ms",
+			map[string]string{"Accept": "application/x-ndjson", "Accept-Encoding": "gzip, deflate"})
+
+		if resp.Header.Get(fiber.HeaderContentEncoding) != "gzip" || resp.Header.Get(fiber.HeaderContentType) != "application/x-ndjson" {
+			t.Fatalf("unexpected headers: %v", resp.Header)
+		}
+
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := fmt.Sprintf(`{"start_time":%v,"close":1.5}`, testStart.UnixMilli()); len(lines) != 60 || lines[0] != expected {
+			t.Fatalf("unexpected lines: %v, %v", len(lines), lines[0])
+		}
+	})
+
+	t.Run("resampled arrow", func(t *testing.T) {
+		resp := download("/download?market=futures&symbol=BTCUSDT&interval=15m&format=arrow", map[string]string{"Accept": "text/csv"})
+		if resp.Header.Get(fiber.HeaderContentType) != "application/vnd.apache.arrow.stream" {
+			t.Fatalf("unexpected headers: %v", resp.Header)
+		}
+
+		r, err := ipc.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Release()
+
+		if !r.Next() {
+			t.Fatalf("expected a record batch: %v", r.Err())
+		}
+
+		rec := r.Record()
+		closes := rec.Column(slices.Index(market_io.OhlcCsvColumns, "close")).(*array.Float64)
+		volumes := rec.Column(slices.Index(market_io.OhlcCsvColumns, "volume")).(*array.Float64)
+
+		if rec.NumRows() != 4 || closes.Value(0) != 2 || volumes.Value(3) != 15 {
+			t.Fatalf("unexpected record: %v", rec)
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for path, code := range map[string]int{
+			"/download?interval=1m":                               http.StatusBadRequest,
+			"/download?symbol=BTCUSDT&interval=1m&columns=vwap":   http.StatusBadRequest,
+			"/download?symbol=BTCUSDT&interval=1m&format=xlsx":    http.StatusBadRequest,
+			"/download?symbol=BTCUSDT&interval=1m&time_format=ms": http.StatusBadRequest,
+		} {
+			if resp := download(path, nil); resp.StatusCode != code {
+				t.Fatalf("expected %v for %v, got %v", code, path, resp.StatusCode)
+			}
+		}
+
+		if resp := download("/download?symbol=BTCUSDT&interval=1m", map[string]string{"Accept": "application/json"}); resp.StatusCode != http.StatusNotAcceptable {
+			t.Fatalf("expected a 406 error, got %v", resp.StatusCode)
+		}
+	})
+}
+
+func TestAssets(t *testing.T) {
+	app := newTestApp(t)
+
+	assets := []market_dto.FuturesAsset{
+		{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT", OrderTypes: []string{"LIMIT", "MARKET"}},
+			Data: market_dto.FuturesAssetData{ContractType: "PERPETUAL"}},
+		{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "ETHBTC", Status: "BREAK", BaseAsset: "ETH", QuoteAsset: "BTC", OrderTypes: []string{"LIMIT"}},
+			Data: market_dto.FuturesAssetData{ContractType: "CURRENT_QUARTER"}},
+	}
+
+	if _, err := app.Stores().CryptoFuturesAsset.UpsertAssets(market_dto.AnyAssets(assets)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			symbols []string
+		}{
+			{"/assets?market=futures", []string{"BTCUSDT", "ETHBTC"}},
+			{"/assets?market=futures&q=btc", []string{"BTCUSDT", "ETHBTC"}},
+			{"/assets?market=futures&quote=btc", []string{"ETHBTC"}},
+			{"/assets?market=futures&status=TRADING&contract_type=PERPETUAL", []string{"BTCUSDT"}},
+			{"/assets?market=futures&order_types=LIMIT,MARKET", []string{"BTCUSDT"}},
+			{"/assets", []string{}},
+		}
+
+		for _, tt := range tests {
+			var res AssetsResponse
+			get(t, app, tt.path, &res)
+
+			symbols := []string{}
+			for _, doc := range res.Data {
+				symbols = append(symbols, doc.Symbol)
+			}
+
+			if !slices.Equal(symbols, tt.symbols) {
+				t.Fatalf("unexpected assets of %v: %v", tt.path, symbols)
+			}
+		}
+	})
+
+	t.Run("single asset", func(t *testing.T) {
+		var res AssetResponse
+		if code := get(t, app, "/assets/btcusdt?market=futures", &res); code != http.StatusOK {
+			t.Fatalf("unexpected status: %v", code)
+		}
+
+		data, ok := res.Data.(map[string]any)
+		if !ok || data["contract_type"] != "PERPETUAL" || res.BaseAsset != "BTC" {
+			t.Fatalf("unexpected asset: %+v", res)
+		}
+
+		if len(res.Coverage) != 1 || res.Coverage[0].Count != 60 {
+			t.Fatalf("unexpected coverage: %+v", res.Coverage)
+		}
+
+		if res.Latest == nil || !res.Latest.StartTime.Equal(testStart.Add(59*time.Minute)) {
+			t.Fatalf("unexpected latest row: %+v", res.Latest)
+		}
+
+		var errRes ErrorResponse
+		if code := get(t, app, "/assets/XRPUSDT?market=futures", &errRes); code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %v", code)
+		}
+	})
+}
+
+// serve starts the api on a random local port and returns its address.
+func serve(t *testing.T, app *core.App) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := New(app)
+	go server.Listener(ln)
+	t.Cleanup(func() { server.Shutdown() })
+
+	return ln.Addr().String()
+}
+
+func TestLive(t *testing.T) {
+	// closed connections are detected on the next heartbeat
+	liveHeartbeat = 100 * time.Millisecond
+
+	app := newTestApp(t)
+	addr := serve(t, app)
+
+	newRow := func(symbol string, i int) market_dto.OhlcRow {
+		t1 := testStart.Add(time.Duration(i) * time.Minute)
+		row, _ := market_dto.NewOhlcRow(symbol, t1, t1.Add(time.Minute), 1, 2, 0.5, 3, 1)
+		return *row
+	}
+
+	// waits until the subscription is registered, as the rows published
+	// before it are not received
+	publish := func(rows ...market_dto.OhlcRow) {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := app.Stores().CryptoSpotOhlc.UpsertOhlcRows(rows); err != nil {
+			t.Error(err)
+		}
+	}
+
+	t.Run("sse", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/live/sse?symbols=btcusdt&interval=1m", nil)
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(testStart.Add(58*time.Minute).UnixMilli(), 10))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("unexpected content type: %v", resp.Header.Get("Content-Type"))
+		}
+
+		go publish(newRow("ETHUSDT", 60), newRow("BTCUSDT", 60))
+
+		// 2 replayed rows and the live one
+		var ids []string
+		scanner := bufio.NewScanner(resp.Body)
+		for len(ids) < 3 && scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				ids = append(ids, id)
+			}
+		}
+
+		want := []string{"58", "59", "60"}
+		for i, id := range ids {
+			ms, _ := strconv.ParseInt(id, 10, 64)
+			if got := strconv.Itoa(int(time.UnixMilli(ms).Sub(testStart).Minutes())); got != want[i] {
+				t.Fatalf("unexpected event ids: %v", ids)
+			}
+		}
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		since := testStart.Add(59 * time.Minute).Format(time.RFC3339)
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/live/ws?symbols=BTCUSDT&since="+since, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		go publish(newRow("BTCUSDT", 61))
+
+		var rows []market_dto.OhlcRow
+		for len(rows) < 3 {
+			var row market_dto.OhlcRow
+			if err := conn.ReadJSON(&row); err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, row)
+		}
+
+		if !rows[0].StartTime.Equal(testStart.Add(59*time.Minute)) || !rows[2].StartTime.Equal(testStart.Add(61*time.Minute)) || rows[2].Close != 3 {
+			t.Fatalf("unexpected rows: %+v", rows)
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/live/sse?interval=1w")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %v", resp.StatusCode)
+		}
+
+		resp, err = http.Get("http://" + addr + "/live/ws")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUpgradeRequired {
+			t.Fatalf("expected 426, got %v", resp.StatusCode)
+		}
+	})
+}
+
+func TestUdf(t *testing.T) {
+	app := newTestApp(t)
+
+	asset := []market_dto.SpotAsset{{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT"}}}
+	if _, err := app.Stores().CryptoSpotAsset.UpsertAssets(market_dto.AnyAssets(asset)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := testStart.Unix()
+
+	t.Run("symbols", func(t *testing.T) {
+		var res UdfSymbol
+		if code := get(t, app, "/udf/symbols?symbol=BINANCE:btcusdt", &res); code != http.StatusOK {
+			t.Fatalf("expected 200, got %v", code)
+		}
+
+		if res.Ticker != "BINANCE:BTCUSDT" || res.Description != "BTC / USDT" || res.Pricescale != 100 {
+			t.Fatalf("unexpected symbol: %+v", res)
+		}
+
+		var e UdfError
+		for _, ticker := range []string{"BINANCE-FUTURES:BTCUSDT", "BINANCE:ETHUSDT", "KRAKEN:BTCUSDT"} {
+			if code := get(t, app, "/udf/symbols?symbol="+ticker, &e); code != http.StatusNotFound || e.S != "error" {
+				t.Fatalf("expected unknown %v, got %v %+v", ticker, code, e)
+			}
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		var res []UdfSearchResult
+		get(t, app, "/udf/search?query=btc", &res)
+		if len(res) != 1 || res[0].FullName != "BINANCE:BTCUSDT" {
+			t.Fatalf("unexpected results: %+v", res)
+		}
+
+		get(t, app, "/udf/search?query=btc&exchange=BINANCE-FUTURES", &res)
+		if len(res) != 0 {
+			t.Fatalf("unexpected results: %+v", res)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		tests := []struct {
+			query    string
+			status   string
+			times    []int64
+			nextTime int64
+		}{
+			{fmt.Sprintf("resolution=15&from=%v&to=%v", start, start+3600), "ok", []int64{start, start + 900, start + 1800, start + 2700}, 0},
+			{fmt.Sprintf("resolution=15&from=%v&to=%v", start+60, start+1800), "ok", []int64{start + 900}, 0},
+			{fmt.Sprintf("resolution=1D&from=%v&to=%v", start, start+86400), "ok", []int64{start}, 0},
+			{fmt.Sprintf("resolution=1&from=%v&to=%v&countback=3", start, start+7200), "ok", []int64{start + 57*60, start + 58*60, start + 59*60}, 0},
+			{fmt.Sprintf("resolution=1&to=%v&countback=3", start+7200), "ok", []int64{start + 57*60, start + 58*60, start + 59*60}, 0},
+			{fmt.Sprintf("resolution=60&to=%v&countback=2", start-3600), "no_data", nil, 0},
+			{fmt.Sprintf("resolution=60&from=%v&to=%v", start+7200, start+10800), "no_data", nil, start},
+			{fmt.Sprintf("resolution=60&from=%v&to=%v", start-7200, start-3600), "no_data", nil, 0},
+		}
+
+		for _, tt := range tests {
+			var res UdfHistory
+			if code := get(t, app, "/udf/history?symbol=BTCUSDT&"+tt.query, &res); code != http.StatusOK {
+				t.Fatalf("%v: expected 200, got %v", tt.query, code)
+			}
+
+			if res.S != tt.status || !slices.Equal(res.T, tt.times) || res.NextTime != tt.nextTime {
+				t.Fatalf("%v: unexpected history: %+v", tt.query, res)
+			}
+		}
+
+		var e UdfError
+		for _, query := range []string{
+			fmt.Sprintf("resolution=2W&from=%v&to=%v", start, start+60),
+			fmt.Sprintf("resolution=60&to=%v", start+60), // from is required without countback
+		} {
+			if code := get(t, app, "/udf/history?symbol=BTCUSDT&"+query, &e); code != http.StatusBadRequest || e.S != "error" {
+				t.Fatalf("%v: expected a bad request, got %v %+v", query, code, e)
+			}
+		}
+	})
+
+	t.Run("time", func(t *testing.T) {
+		var res int64
+		get(t, app, "/udf/time", &res)
+		if time.Since(time.Unix(res, 0)).Abs() > time.Minute {
+			t.Fatalf("unexpected time: %v", res)
+		}
+	})
+}
+
+// do sends the request with the json body to the api and decodes the json
+// response into the res.
+func do(t *testing.T, api *fiber.App, method, path string, body, res any) int {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		t.Fatalf("failed to decode the response of %v: %v", path, err)
+	}
+
+	return resp.StatusCode
+}
+
+func TestJobs(t *testing.T) {
+	app := newTestApp(t)
+
+	sched := syro.NewCronScheduler(cron.New(), "test")
+	if err := sched.Register(&syro.Job{Name: "noop", Schedule: "@every 1h", Func: func() error { return nil }}); err != nil {
+		t.Fatal(err)
+	}
+
+	state := binance_service.NewScrapeState()
+	api := New(app, Settings{Jobs: jobs.New(sched), ScrapeState: state})
+
+	t.Run("not controlled", func(t *testing.T) {
+		var res JobsResponse
+		if code := get(t, app, "/jobs", &res); code != http.StatusOK || res.Controlled || len(res.Data) != 0 {
+			t.Fatalf("unexpected response: %v %+v", code, res)
+		}
+
+		var e ErrorResponse
+		if code := get(t, app, "/jobs/noop", &e); code != http.StatusNotImplemented {
+			t.Fatalf("expected 501, got %v", code)
+		}
+	})
+
+	t.Run("control", func(t *testing.T) {
+		var job jobs.JobState
+		if code := do(t, api, http.MethodPost, "/jobs/noop/pause", nil, &job); code != http.StatusOK || !job.Paused {
+			t.Fatalf("unexpected response: %v %+v", code, job)
+		}
+
+		if code := do(t, api, http.MethodPost, "/jobs/noop/trigger", nil, &job); code != http.StatusAccepted || job.LastRun == nil || !job.LastRun.Manual {
+			t.Fatalf("unexpected response: %v %+v", code, job)
+		}
+
+		if code := do(t, api, http.MethodPost, "/jobs/noop/resume", nil, &job); code != http.StatusOK || job.Paused {
+			t.Fatalf("unexpected response: %v %+v", code, job)
+		}
+
+		var res JobsResponse
+		if do(t, api, http.MethodGet, "/jobs", nil, &res); !res.Controlled || len(res.Data) != 1 || res.Data[0].Name != "noop" {
+			t.Fatalf("unexpected response: %+v", res)
+		}
+
+		var e ErrorResponse
+		if code := do(t, api, http.MethodPost, "/jobs/missing/trigger", nil, &e); code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %v", code)
+		}
+	})
+
+	t.Run("scrape state", func(t *testing.T) {
+		var res ScrapeStateResponse
+		do(t, api, http.MethodGet, "/scrape-state?symbols=btcusdt,ethusdt", nil, &res)
+
+		if len(res.Data) != 2 || len(res.Data[0].Intervals) != 1 || len(res.Data[1].Intervals) != 0 {
+			t.Fatalf("unexpected response: %+v", res)
+		}
+
+		btc := res.Data[0].Intervals[0]
+		if btc.Interval != 60000 || btc.Count != 60 || !btc.To.Equal(testStart.Add(59*time.Minute)) || btc.Lag <= 0 || btc.Attempt != nil {
+			t.Fatalf("unexpected interval state: %+v", btc)
+		}
+	})
+
+	t.Run("backfill", func(t *testing.T) {
+		defer func(f func(binance.API, string) binance.GetHistoryFunc) { getKlines = f }(getKlines)
+
+		getKlines = func(binance.API, string) binance.GetHistoryFunc {
+			return func(symbol string, from, to time.Time, tf binance.Timeframe) ([]market_dto.OhlcRow, error) {
+				row, err := market_dto.NewOhlcRow(symbol, from, from.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+				if err != nil {
+					return nil, err
+				}
+				return []market_dto.OhlcRow{*row}, nil
+			}
+		}
+
+		assets := []market_dto.SpotAsset{{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "BTCUSDT"}}}
+		if _, err := app.Stores().CryptoSpotAsset.UpsertAssets(market_dto.AnyAssets(assets)); err != nil {
+			t.Fatal(err)
+		}
+
+		var e ErrorResponse
+		for _, req := range []BackfillRequest{
+			{Symbol: "BTCUSDT", Interval: "4h", From: "2023-12-31"},
+			{Symbol: "BTCUSDT", Interval: "1m", From: "2024-01-02", To: "2024-01-01"},
+			{Symbol: "BTCUSDT", Interval: "1m"},
+		} {
+			if code := do(t, api, http.MethodPost, "/backfills", req, &e); code != http.StatusBadRequest {
+				t.Fatalf("%+v: expected 400, got %v", req, code)
+			}
+		}
+
+		if code := do(t, api, http.MethodPost, "/backfills", BackfillRequest{Symbol: "ETHUSDT", Interval: "1m", From: "2023-12-31"}, &e); code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %v", code)
+		}
+
+		var task jobs.Task
+		if code := do(t, api, http.MethodPost, "/backfills", BackfillRequest{Symbol: "btcusdt", Interval: "1m", From: "2023-12-31", To: "2024-01-01"}, &task); code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %v", code)
+		}
+
+		for deadline := time.Now().Add(2 * time.Second); task.Status == jobs.TaskRunning && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			do(t, api, http.MethodGet, "/backfills/"+task.ID, nil, &task)
+		}
+
+		// a day of 1m rows is requested in chunks of 500 rows, with the first row of each chunk returned
+		if task.Status != jobs.TaskDone || task.Done != 3 || task.Total != 3 {
+			t.Fatalf("unexpected task: %+v", task)
+		}
+
+		var list BackfillsResponse
+		if do(t, api, http.MethodGet, "/backfills", nil, &list); len(list.Data) != 1 || list.Data[0].ID != task.ID {
+			t.Fatalf("unexpected list: %+v", list)
+		}
+
+		rows, err := app.Stores().CryptoSpotOhlc.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT", Interval: 60000, To: testStart.Add(-time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 3 || !rows[0].StartTime.Equal(testStart.AddDate(0, 0, -1)) {
+			t.Fatalf("unexpected rows: %+v", rows)
+		}
+	})
+}
+
+func TestAuth(t *testing.T) {
+	app := newTestApp(t)
+	app.Conf().Api.Auth = true
+	app.Conf().Api.Audit = true
+
+	newKey := func(name string, scopes []string, quota auth_dto.Quota) (*auth_dto.ApiKey, string) {
+		key, secret, err := auth_dto.NewApiKey(name, scopes, quota)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.Stores().ApiKeys.InsertKey(*key); err != nil {
+			t.Fatal(err)
+		}
+
+		return key, secret
+	}
+
+	reader, readSecret := newKey("reader", []string{auth_dto.ScopeRead}, auth_dto.Quota{Requests: 2, Window: time.Hour})
+	_, jobsSecret := newKey("jobs", []string{auth_dto.ScopeJobs}, auth_dto.Quota{})
+	revoked, revokedSecret := newKey("revoked", []string{auth_dto.ScopeRead}, auth_dto.Quota{})
+
+	if err := app.Stores().ApiKeys.RevokeKey(revoked.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	api := New(app)
+
+	request := func(path string, header map[string]string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := api.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp
+	}
+
+	tests := []struct {
+		path      string
+		header    map[string]string
+		code      int
+		remaining string
+	}{
+		{"/ohlc?symbol=BTCUSDT&interval=1m", nil, http.StatusUnauthorized, ""},
+		{"/ohlc?symbol=BTCUSDT&interval=1m", map[string]string{"X-Api-Key": "bpk_invalid"}, http.StatusUnauthorized, ""},
+		{"/ohlc?symbol=BTCUSDT&interval=1m", map[string]string{"X-Api-Key": revokedSecret}, http.StatusUnauthorized, ""},
+		{"/ohlc?symbol=BTCUSDT&interval=1m", map[string]string{"Authorization": "Bearer " + readSecret}, http.StatusOK, "1"},
+		{"/udf/time?api_key=" + readSecret, nil, http.StatusOK, "0"},
+		{"/ohlc?symbol=BTCUSDT&interval=1m", map[string]string{"X-Api-Key": readSecret}, http.StatusTooManyRequests, "0"},
+		{"/jobs", map[string]string{"X-Api-Key": readSecret}, http.StatusForbidden, ""},
+		{"/ohlc?symbol=BTCUSDT&interval=1m", map[string]string{"X-Api-Key": jobsSecret}, http.StatusForbidden, ""},
+		{"/jobs", map[string]string{"X-Api-Key": jobsSecret}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		resp := request(tt.path, tt.header)
+		if resp.StatusCode != tt.code || resp.Header.Get("X-RateLimit-Remaining") != tt.remaining {
+			t.Fatalf("%v %v: expected %v with %q remaining, got %v with %q", tt.path, tt.header, tt.code, tt.remaining, resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	logs, err := app.Stores().ApiAudit.GetAuditLogs(auth_dto.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != len(tests) {
+		t.Fatalf("expected %v audit logs, got %v", len(tests), len(logs))
+	}
+
+	// the latest log is the first one
+	udfLog := logs[len(tests)-5]
+	if udfLog.Path != "/udf/time" || udfLog.Query != "" || udfLog.KeyID != reader.ID || udfLog.Status != http.StatusOK {
+		t.Fatalf("unexpected audit log: %+v", udfLog)
+	}
+
+	if logs[len(tests)-1].KeyID != "" || logs[len(tests)-1].Status != http.StatusUnauthorized || logs[len(tests)-1].Query != "interval=1m&symbol=BTCUSDT" {
+		t.Fatalf("unexpected audit log: %+v", logs[len(tests)-1])
+	}
+}
+
+func TestGrpc(t *testing.T) {
+	// the ranges are read in several pages
+	grpcCandlesPage = 7
+
+	app := newTestApp(t)
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewGrpc(app)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := poolerpb.NewPoolerClient(conn)
+	ctx := context.Background()
+
+	// receive reads the candles of the stream until it ends or n are received
+	receive := func(stream grpc.ServerStreamingClient[poolerpb.Candle], n int) ([]*poolerpb.Candle, error) {
+		var candles []*poolerpb.Candle
+		for n == 0 || len(candles) < n {
+			candle, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return candles, err
+			}
+			candles = append(candles, candle)
+		}
+		return candles, nil
+	}
+
+	t.Run("candles", func(t *testing.T) {
+		tests := []struct {
+			req   *poolerpb.GetCandlesRequest
+			count int
+			first time.Time
+			close float64
+		}{
+			{&poolerpb.GetCandlesRequest{Symbol: "btcusdt", Interval: "1m"}, 60, testStart, 1.5},
+			{&poolerpb.GetCandlesRequest{Symbol: "BTCUSDT", Interval: "1m", From: timestamppb.New(testStart.Add(10 * time.Minute)), Limit: 25}, 25, testStart.Add(10 * time.Minute), 1.5},
+			{&poolerpb.GetCandlesRequest{Market: MarketFutures, Symbol: "BTCUSDT", Interval: "15m"}, 4, testStart, 2},
+			{&poolerpb.GetCandlesRequest{Symbol: "BTCUSDT", Interval: "1m", From: timestamppb.New(testStart.Add(time.Hour))}, 0, time.Time{}, 0},
+		}
+
+		for _, tt := range tests {
+			stream, err := client.GetCandles(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			candles, err := receive(stream, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(candles) != tt.count {
+				t.Fatalf("%v: expected %v candles, got %v", tt.req, tt.count, len(candles))
+			}
+
+			for i, c := range candles {
+				want := tt.first.Add(time.Duration(i) * time.Duration(c.Interval) * time.Millisecond)
+				if !c.StartTime.AsTime().Equal(want) || c.Close != tt.close {
+					t.Fatalf("%v: unexpected candle %v: %v", tt.req, i, c)
+				}
+			}
+		}
+
+		stream, err := client.GetCandles(ctx, &poolerpb.GetCandlesRequest{Symbol: "BTCUSDT", Interval: "7x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := receive(stream, 0); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", err)
+		}
+	})
+
+	t.Run("coverage", func(t *testing.T) {
+		res, err := client.GetCoverage(ctx, &poolerpb.GetCoverageRequest{Symbol: "BTCUSDT"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Coverage) != 1 || res.Coverage[0].Interval != time.Minute.Milliseconds() || res.Coverage[0].Count != 60 || res.Coverage[0].Derived {
+			t.Fatalf("unexpected coverage: %v", res.Coverage)
+		}
+
+		if res.Latest == nil || !res.Latest.StartTime.AsTime().Equal(testStart.Add(59*time.Minute)) {
+			t.Fatalf("unexpected latest candle: %v", res.Latest)
+		}
+
+		if _, err := client.GetCoverage(ctx, &poolerpb.GetCoverageRequest{Market: "options", Symbol: "BTCUSDT"}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", err)
+		}
+	})
+
+	t.Run("assets", func(t *testing.T) {
+		assets := []market_dto.SpotAsset{
+			{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT"}},
+			{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "ETHBTC", Status: "TRADING", BaseAsset: "ETH", QuoteAsset: "BTC"}},
+		}
+
+		if _, err := app.Stores().CryptoSpotAsset.UpsertAssets(market_dto.AnyAssets(assets)); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := client.ListAssets(ctx, &poolerpb.ListAssetsRequest{Quote: "usdt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Assets) != 1 || res.Assets[0].Symbol != "BTCUSDT" || res.Assets[0].BaseAsset != "BTC" {
+			t.Fatalf("unexpected assets: %v", res.Assets)
+		}
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := client.SubscribeCandles(ctx, &poolerpb.SubscribeCandlesRequest{
+			Symbols:  []string{"btcusdt"},
+			Interval: "1m",
+			Since:    timestamppb.New(testStart.Add(59 * time.Minute)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the replayed candle is received after the subscription is registered
+		candles, err := receive(stream, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t1 := testStart.Add(time.Hour)
+		for _, symbol := range []string{"ETHUSDT", "BTCUSDT"} {
+			row, _ := market_dto.NewOhlcRow(symbol, t1, t1.Add(time.Minute), 1, 2, 0.5, 3, 1)
+			if _, err := app.Stores().CryptoSpotOhlc.UpsertOhlcRows([]market_dto.OhlcRow{*row}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		live, err := receive(stream, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		candles = append(candles, live...)
+
+		if !candles[0].StartTime.AsTime().Equal(testStart.Add(59*time.Minute)) || candles[1].Symbol != "BTCUSDT" || candles[1].Close != 3 {
+			t.Fatalf("unexpected candles: %v", candles)
+		}
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		app.Conf().Api.Auth = true
+		defer func() { app.Conf().Api.Auth = false }()
+
+		key, secret, err := auth_dto.NewApiKey("grpc", []string{auth_dto.ScopeRead}, auth_dto.Quota{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.Stores().ApiKeys.InsertKey(*key); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.GetCoverage(ctx, &poolerpb.GetCoverageRequest{Symbol: "BTCUSDT"}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected Unauthenticated, got %v", err)
+		}
+
+		authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+secret)
+		if _, err := client.GetCoverage(authCtx, &poolerpb.GetCoverageRequest{Symbol: "BTCUSDT"}); err != nil {
+			t.Fatal(err)
+		}
+
+		stream, err := client.GetCandles(ctx, &poolerpb.GetCandlesRequest{Symbol: "BTCUSDT", Interval: "1m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := receive(stream, 0); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected Unauthenticated, got %v", err)
+		}
+	})
+}
+
+func TestOpenApi(t *testing.T) {
+	app := newTestApp(t)
+	api := New(app)
+
+	// the registered routes are documented, in both directions
+	documented := make(map[string]bool)
+	for _, e := range endpoints {
+		documented[e.method+" "+e.path] = true
+	}
+
+	registered := make(map[string]bool)
+	for _, route := range api.GetRoutes(true) {
+		if route.Method != http.MethodGet && route.Method != http.MethodPost {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		registered[key] = true
+
+		if !documented[key] {
+			t.Errorf("route %v is not in the OpenAPI document", key)
+		}
+	}
+
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("documented route %v is not registered", key)
+		}
+	}
+
+	var doc OpenApi
+	if code := get(t, app, "/openapi.json", &doc); code != http.StatusOK {
+		t.Fatalf("expected 200, got %v", code)
+	}
+
+	for _, name := range []string{"OhlcRow", "AssetBase", "UpsertLog", "OhlcResponse", "ErrorResponse"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Fatalf("schema %v is missing", name)
+		}
+	}
+
+	// the embedded fields are promoted and the pointers are nullable
+	row := doc.Components.Schemas["OhlcRow"]
+	for _, field := range []string{"start_time", "interval", "symbol", "o", "c", "bv"} {
+		if row.Properties[field] == nil {
+			t.Fatalf("field %v of OhlcRow is missing: %v", field, row.Properties)
+		}
+	}
+
+	if !row.Properties["bv"].Nullable || row.Properties["start_time"].Format != "date-time" {
+		t.Fatalf("unexpected fields: %+v %+v", row.Properties["bv"], row.Properties["start_time"])
+	}
+
+	op := doc.Paths["/assets/{symbol}"]["get"]
+	if op == nil || op.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/AssetResponse" || len(op.Security) == 0 {
+		t.Fatalf("unexpected operation: %+v", op)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/timeset"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultOhlcLimit = 1000
+	maxOhlcLimit     = 5000
+)
+
+// OhlcResponse is the body of the /ohlc requests. If there are more rows in
+// the requested range, the next page is requested with the cursor param set
+// to the NextCursor.
+type OhlcResponse struct {
+	Data       []market_dto.OhlcRow `json:"data"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// getOhlc returns the rows of the symbol and interval, sorted by the start time.
+// Intervals which are not stored (e.g. 4h, 1w or 1M) are resampled from the
+// finest stored interval. The trailing bucket of the resampled rows is only
+// returned if it's complete, unless the partial param is set to true.
+//
+//	GET /ohlc?market=spot&symbol=BTCUSDT&interval=15m&from=2024-01-01&to=2024-01-02&limit=500
+//	GET /ohlc?symbol=BTCUSDT&interval=1w&from=2024-01-01&partial=true
+func (s *server) getOhlc(c *fiber.Ctx) error {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" {
+		return badRequest("symbol is required")
+	}
+
+	res, err := parseResolution(c.Query("interval"))
+	if err != nil {
+		return err
+	}
+
+	m, err := s.market(c.Query("market"))
+	if err != nil {
+		return err
+	}
+
+	from, err := parseTime("from", c.Query("from"))
+	if err != nil {
+		return err
+	}
+
+	to, err := parseTime("to", c.Query("to"))
+	if err != nil {
+		return err
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if from, err = decodeCursor(cursor); err != nil {
+			return err
+		}
+	}
+
+	limit := c.QueryInt("limit", defaultOhlcLimit)
+	if limit <= 0 || limit > maxOhlcLimit {
+		return badRequest("limit must be between 1 and %v", maxOhlcLimit)
+	}
+
+	// an extra row is requested to find out if there is a next page
+	filter := market_dto.OhlcFilter{Symbol: symbol, From: from, To: to, Limit: int64(limit) + 1}
+
+	rows, err := s.queryOhlc(m, filter, res, c.QueryBool("partial"))
+	if err != nil {
+		return err
+	}
+
+	body := OhlcResponse{Data: rows}
+	if body.Data == nil {
+		body.Data = []market_dto.OhlcRow{}
+	}
+
+	if len(rows) > limit {
+		body.Data = rows[:limit]
+		body.NextCursor = encodeCursor(rows[limit].StartTime)
+	}
+
+	return c.JSON(body)
+}
+
+// queryOhlc returns the rows of the resolution which match the filter (see ohlcSource).
+func (s *server) queryOhlc(m *marketStores, filter market_dto.OhlcFilter, res market_dto.Resolution, partial bool) ([]market_dto.OhlcRow, error) {
+	store, filter, resample, err := s.ohlcSource(m, filter, res, partial)
+	if err != nil || store == nil {
+		return nil, err
+	}
+
+	if resample {
+		return store.ResampleOhlcRows(filter, res, partial)
+	}
+	return store.GetOhlcRows(filter)
+}
+
+// ohlcSource returns the store and the filter of the rows from which the
+// resolution is read. The scraped and derived intervals are read as they
+// are stored, the rest are resampled (resample is true) from the finest
+// scraped interval which divides the resolution. The derived rows only hold
+// complete buckets, so they are skipped if the partial buckets are requested.
+// Timeframes which can be scraped, but are not stored, are resampled as well.
+// The store is nil if there are no rows from which the resolution can be read.
+func (s *server) ohlcSource(m *marketStores, filter market_dto.OhlcFilter, res market_dto.Resolution, partial bool) (market_dto.OhlcStore, market_dto.OhlcFilter, bool, error) {
+	coverage, err := m.ohlc.GetCoverage(filter.Symbol)
+	if err != nil {
+		return nil, filter, false, err
+	}
+
+	if !res.IsCalendar() {
+		filter.Interval = res.Interval
+
+		for _, c := range coverage {
+			if c.Interval == res.Interval {
+				return m.ohlc, filter, false, nil
+			}
+		}
+
+		if !partial {
+			derived, err := m.derived.GetCoverage(filter.Symbol)
+			if err != nil {
+				return nil, filter, false, err
+			}
+
+			for _, c := range derived {
+				if c.Interval == res.Interval {
+					return m.derived, filter, false, nil
+				}
+			}
+		}
+	}
+
+	// the coverage is sorted by the interval, so the first match is the finest one
+	filter.Interval = 0
+	for _, c := range coverage {
+		if res.Divides(c.Interval) {
+			filter.Interval = c.Interval
+			break
+		}
+	}
+
+	if filter.Interval == 0 {
+		return nil, filter, false, nil
+	}
+
+	// the source rows cover the whole buckets in the range
+	if !filter.From.IsZero() {
+		filter.From = res.BucketStart(filter.From)
+	}
+	if !filter.To.IsZero() {
+		filter.To = res.BucketEnd(res.BucketStart(filter.To)).Add(-time.Millisecond)
+	}
+
+	return m.ohlc, filter, true, nil
+}
+
+// encodeCursor returns the cursor of the page which starts at the time.
+func encodeCursor(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.UnixMilli(), 10)))
+}
+
+func decodeCursor(cursor string) (time.Time, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, badRequest("invalid cursor")
+	}
+
+	ms, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return time.Time{}, badRequest("invalid cursor")
+	}
+
+	return timeset.UnixMillisToTime(ms), nil
+}
@@ -0,0 +1,471 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/jobs"
+	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/market_io"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenApi is the OpenAPI 3 document of the http api. Only the fields which
+// are used by the document are defined.
+type OpenApi struct {
+	Openapi    string                           `json:"openapi"`
+	Info       OpenApiInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components OpenApiComponents                `json:"components"`
+}
+
+type OpenApiInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenApiComponents struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // query, path or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a json schema of the OpenAPI document. The empty schema
+// matches any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// endpoint documents a route of the http api. The schemas of the body and
+// the response are built from the types of their values.
+type endpoint struct {
+	method       string
+	path         string // fiber path of the route (e.g. /assets/:symbol)
+	id           string // name of the handler
+	summary      string
+	tag          string
+	scope        string // scope of the api key, if the auth is enabled
+	params       []Parameter
+	body         any
+	status       int      // status of the successful response, defaults to 200
+	response     any      // nil if the response has no body
+	contentTypes []string // of the successful response, defaults to json
+}
+
+func queryParam(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+func requiredQueryParam(name, typ, description string) Parameter {
+	p := queryParam(name, typ, description)
+	p.Required = true
+	return p
+}
+
+func pathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+const timeParamDescription = "unix timestamp in milliseconds, RFC3339 time or date (YYYY-MM-DD)"
+
+var (
+	marketParam   = queryParam("market", "string", "spot (default) or futures")
+	intervalParam = queryParam("interval", "string", "e.g. 1m, 15m, 4h, 1d, 1w, 1M")
+	symbolsParam  = queryParam("symbols", "string", "comma separated symbols")
+	sinceParam    = queryParam("since", "string", "replay the stored rows which start at or after the time, "+timeParamDescription)
+)
+
+// endpoints documents the routes of the http api, in the order of New.
+var endpoints = []endpoint{
+	{method: http.MethodGet, path: "/ohlc", id: "getOhlc", tag: "ohlc", scope: auth_dto.ScopeRead,
+		summary: "Rows of the symbol and interval, resampled if the interval is not stored",
+		params: []Parameter{
+			marketParam,
+			requiredQueryParam("symbol", "string", ""),
+			requiredQueryParam("interval", "string", intervalParam.Description),
+			queryParam("from", "string", timeParamDescription),
+			queryParam("to", "string", timeParamDescription),
+			queryParam("cursor", "string", "next_cursor of the previous page"),
+			queryParam("limit", "integer", fmt.Sprintf("max number of rows, up to %v (default %v)", maxOhlcLimit, defaultOhlcLimit)),
+			queryParam("partial", "boolean", "include the incomplete trailing bucket of the resampled rows"),
+		},
+		response: OhlcResponse{}},
+	{method: http.MethodGet, path: "/download", id: "download", tag: "ohlc", scope: auth_dto.ScopeRead,
+		summary: "All of the rows of the range, streamed as csv, ndjson or arrow ipc, optionally gzipped",
+		params: []Parameter{
+			marketParam,
+			requiredQueryParam("symbol", "string", ""),
+			requiredQueryParam("interval", "string", intervalParam.Description),
+			queryParam("from", "string", timeParamDescription),
+			queryParam("to", "string", timeParamDescription),
+			queryParam("partial", "boolean", "include the incomplete trailing bucket of the resampled rows"),
+			queryParam("columns", "string", "comma separated columns, defaults to "+strings.Join(market_io.OhlcCsvColumns, ",")),
+			queryParam("format", "string", "csv, ndjson or arrow, overrides the Accept header"),
+			queryParam("time_format", "string", "rfc3339 (default) or unix_ms, of the csv and ndjson times"),
+		},
+		response: "", contentTypes: []string{"text/csv", "application/x-ndjson", "application/vnd.apache.arrow.stream"}},
+	{method: http.MethodGet, path: "/assets", id: "getAssets", tag: "assets", scope: auth_dto.ScopeRead,
+		summary: "Assets of the market which match the filters",
+		params: []Parameter{
+			marketParam,
+			queryParam("status", "string", "e.g. TRADING"),
+			queryParam("base", "string", "base asset"),
+			queryParam("quote", "string", "quote asset"),
+			queryParam("contract_type", "string", "contract type of the futures (e.g. PERPETUAL)"),
+			queryParam("order_types", "string", "comma separated order types which the assets have to support"),
+			queryParam("q", "string", "case insensitive part of the symbol"),
+		},
+		response: AssetsResponse{}},
+	{method: http.MethodGet, path: "/assets/:symbol", id: "getAsset", tag: "assets", scope: auth_dto.ScopeRead,
+		summary:  "Asset with its data, coverage and latest row",
+		params:   []Parameter{pathParam("symbol", ""), marketParam},
+		response: AssetResponse{}},
+	{method: http.MethodGet, path: "/live/sse", id: "liveSse", tag: "live", scope: auth_dto.ScopeRead,
+		summary: "Written rows as server-sent candle events, with the start time in milliseconds as the id",
+		params: []Parameter{
+			marketParam, symbolsParam, intervalParam, sinceParam,
+			{Name: "Last-Event-ID", In: "header", Description: "replay the rows from the id of the last received event", Schema: &Schema{Type: "string"}},
+		},
+		response: market_dto.OhlcRow{}, contentTypes: []string{"text/event-stream"}},
+	{method: http.MethodGet, path: "/live/ws", id: "liveWs", tag: "live", scope: auth_dto.ScopeRead,
+		summary: "Written rows as json messages over a websocket",
+		params:  []Parameter{marketParam, symbolsParam, intervalParam, sinceParam},
+		status:  http.StatusSwitchingProtocols},
+	{method: http.MethodGet, path: "/jobs", id: "getJobs", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "Registered jobs with their latest run and status",
+		response: JobsResponse{}},
+	{method: http.MethodGet, path: "/jobs/:name", id: "getJob", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "State of the job",
+		params:   []Parameter{pathParam("name", "")},
+		response: jobs.JobState{}},
+	{method: http.MethodPost, path: "/jobs/:name/trigger", id: "triggerJob", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "Run the job now, in the background",
+		params:   []Parameter{pathParam("name", "")},
+		status:   http.StatusAccepted,
+		response: jobs.JobState{}},
+	{method: http.MethodPost, path: "/jobs/:name/pause", id: "pauseJob", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "Skip the scheduled runs of the job until it's resumed",
+		params:   []Parameter{pathParam("name", "")},
+		response: jobs.JobState{}},
+	{method: http.MethodPost, path: "/jobs/:name/resume", id: "resumeJob", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "Continue the scheduled runs of the paused job",
+		params:   []Parameter{pathParam("name", "")},
+		response: jobs.JobState{}},
+	{method: http.MethodGet, path: "/scrape-state", id: "getScrapeState", tag: "jobs", scope: auth_dto.ScopeJobs,
+		summary:  "Stored range, lag and latest scrape attempts of the symbols",
+		params:   []Parameter{marketParam, symbolsParam},
+		response: ScrapeStateResponse{}},
+	{method: http.MethodPost, path: "/backfills", id: "startBackfill", tag: "backfills", scope: auth_dto.ScopeJobs,
+		summary:  "Request the rows of the symbol in the range from binance, in the background",
+		body:     BackfillRequest{},
+		status:   http.StatusAccepted,
+		response: jobs.Task{}},
+	{method: http.MethodGet, path: "/backfills", id: "getBackfills", tag: "backfills", scope: auth_dto.ScopeJobs,
+		summary:  "Backfills started by the process, starting with the latest one",
+		response: BackfillsResponse{}},
+	{method: http.MethodGet, path: "/backfills/:id", id: "getBackfill", tag: "backfills", scope: auth_dto.ScopeJobs,
+		summary:  "Progress of the backfill",
+		params:   []Parameter{pathParam("id", "")},
+		response: jobs.Task{}},
+	{method: http.MethodGet, path: "/udf/config", id: "udfConfig", tag: "udf", scope: auth_dto.ScopeRead,
+		summary:  "Features of the TradingView datafeed",
+		response: UdfConfig{}},
+	{method: http.MethodGet, path: "/udf/symbols", id: "udfSymbols", tag: "udf", scope: auth_dto.ScopeRead,
+		summary:  "Symbol info of the ticker",
+		params:   []Parameter{requiredQueryParam("symbol", "string", "ticker (e.g. BINANCE:BTCUSDT)")},
+		response: UdfSymbol{}},
+	{method: http.MethodGet, path: "/udf/search", id: "udfSearch", tag: "udf", scope: auth_dto.ScopeRead,
+		summary: "Assets of which the symbol, base or quote asset contain the query",
+		params: []Parameter{
+			queryParam("query", "string", ""),
+			queryParam("exchange", "string", UdfExchangeSpot+" or "+UdfExchangeFutures),
+			queryParam("type", "string", ""),
+			queryParam("limit", "integer", ""),
+		},
+		response: []UdfSearchResult{}},
+	{method: http.MethodGet, path: "/udf/history", id: "udfHistory", tag: "udf", scope: auth_dto.ScopeRead,
+		summary: "Bars of the ticker which start in the range [from, to)",
+		params: []Parameter{
+			requiredQueryParam("symbol", "string", "ticker (e.g. BINANCE:BTCUSDT)"),
+			requiredQueryParam("resolution", "string", "e.g. 1, 60, 1D, 1W, 1M"),
+			queryParam("from", "integer", "unix seconds, required unless countback is set"),
+			requiredQueryParam("to", "integer", "unix seconds"),
+			queryParam("countback", "integer", "number of bars before to, overrides from"),
+		},
+		response: UdfHistory{}},
+	{method: http.MethodGet, path: "/udf/time", id: "udfTime", tag: "udf", scope: auth_dto.ScopeRead,
+		summary:  "Server time in unix seconds",
+		response: int64(0), contentTypes: []string{fiber.MIMETextPlain}},
+	{method: http.MethodGet, path: "/openapi.json", id: "getOpenApi", tag: "docs",
+		summary:  "OpenAPI document of the api",
+		response: map[string]any{}},
+}
+
+// extraSchemas are the types which are not returned by the endpoints, but
+// are part of the document so that the clients share them. UpsertLog is the
+// result of the writes of the scraper, the backfills and the imports.
+var extraSchemas = []any{mongodb.UpsertLog{}}
+
+// security schemes of the api keys, which are required if the auth is enabled
+var securitySchemes = map[string]SecurityScheme{
+	"bearer":       {Type: "http", Scheme: "bearer", Description: "api key created with cmd/apikeys"},
+	"apiKeyHeader": {Type: "apiKey", In: "header", Name: "X-Api-Key"},
+	"apiKeyQuery":  {Type: "apiKey", In: "query", Name: apiKeyParam, Description: "for the clients which can't set the headers"},
+}
+
+// any of the schemes can be used
+var securityNames = []string{"bearer", "apiKeyHeader", "apiKeyQuery"}
+
+var fiberParam = regexp.MustCompile(`:(\w+)`)
+
+// NewOpenApi returns the OpenAPI document of the endpoints of the http api.
+func NewOpenApi() *OpenApi {
+	doc := &OpenApi{
+		Openapi: "3.0.3",
+		Info: OpenApiInfo{
+			Title:       "binance-pooler",
+			Version:     "1.0.0",
+			Description: "Query api of the ohlc rows and assets scraped from binance. The api keys are only required if the auth is enabled in the [api] config.",
+		},
+		Paths:      make(map[string]map[string]*Operation),
+		Components: OpenApiComponents{Schemas: make(map[string]*Schema), SecuritySchemes: securitySchemes},
+	}
+
+	schemas := schemaBuilder(doc.Components.Schemas)
+
+	for _, e := range endpoints {
+		op := &Operation{
+			OperationId: e.id,
+			Summary:     e.summary,
+			Tags:        []string{e.tag},
+			Parameters:  e.params,
+			Responses:   make(map[string]Response),
+		}
+
+		if e.scope != "" {
+			op.Summary += " (requires the " + e.scope + " scope)"
+			for _, name := range securityNames {
+				op.Security = append(op.Security, map[string][]string{name: {}})
+			}
+		}
+
+		if e.body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: schemas.of(reflect.TypeOf(e.body))}},
+			}
+		}
+
+		status, contentTypes := e.status, e.contentTypes
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if len(contentTypes) == 0 {
+			contentTypes = []string{fiber.MIMEApplicationJSON}
+		}
+
+		res := Response{Description: http.StatusText(status)}
+		if e.response != nil {
+			res.Content = make(map[string]MediaType, len(contentTypes))
+			for _, contentType := range contentTypes {
+				res.Content[contentType] = MediaType{Schema: schemas.of(reflect.TypeOf(e.response))}
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = res
+
+		// the udf errors are written in the format of the datafeed
+		errorBody := any(ErrorResponse{})
+		if e.tag == "udf" {
+			errorBody = UdfError{}
+		}
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: schemas.of(reflect.TypeOf(errorBody))}},
+		}
+
+		path := fiberParam.ReplaceAllString(e.path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*Operation)
+		}
+		doc.Paths[path][strings.ToLower(e.method)] = op
+	}
+
+	for _, v := range extraSchemas {
+		schemas.of(reflect.TypeOf(v))
+	}
+
+	return doc
+}
+
+// getOpenApi returns the OpenAPI document of the api.
+//
+//	GET /openapi.json
+func (s *server) getOpenApi(c *fiber.Ctx) error {
+	return c.JSON(NewOpenApi())
+}
+
+// schemaBuilder builds the schemas of the go types from their json tags.
+// The named structs are added to the components and referenced.
+type schemaBuilder map[string]*Schema
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func (b schemaBuilder) of(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.of(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.of(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+
+		name := t.Name()
+		if _, ok := b[name]; !ok {
+			// added before the fields, so that the recursive types are referenced
+			s := &Schema{}
+			b[name] = s
+			*s = *b.object(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// object returns the schema of the struct fields, as they are encoded by
+// encoding/json. The fields of the embedded structs without a json name
+// are promoted, the fields without omitempty are required.
+func (b schemaBuilder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := b.object(ft)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = b.of(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,498 @@
+package api
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/providers/binance"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Exchanges of the udf tickers (e.g. BINANCE:BTCUSDT), one for each market.
+const (
+	UdfExchangeSpot    = "BINANCE"
+	UdfExchangeFutures = "BINANCE-FUTURES"
+)
+
+const (
+	defaultUdfSearchLimit = 30
+	maxUdfPriceDecimals   = 8
+)
+
+// udfResolutions are the resolutions which are offered to the charts. Any
+// other resolution in the udf format can be requested as well.
+var udfResolutions = []string{"1", "5", "15", "30", "60", "240", "1D", "1W", "1M"}
+
+// UdfError is the body of the failed udf requests.
+type UdfError struct {
+	S      string `json:"s"` // always "error"
+	Errmsg string `json:"errmsg"`
+}
+
+// udf writes the errors of the handler in the udf format.
+func udf(h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := h(c)
+		if err == nil {
+			return nil
+		}
+
+		code := fiber.StatusInternalServerError
+
+		var e *fiber.Error
+		if errors.As(err, &e) {
+			code = e.Code
+		}
+
+		return c.Status(code).JSON(UdfError{S: "error", Errmsg: err.Error()})
+	}
+}
+
+type UdfExchange struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+	Desc  string `json:"desc"`
+}
+
+type UdfSymbolType struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// UdfConfig is the body of the /udf/config requests.
+type UdfConfig struct {
+	SupportedResolutions   []string        `json:"supported_resolutions"`
+	SupportsGroupRequest   bool            `json:"supports_group_request"`
+	SupportsMarks          bool            `json:"supports_marks"`
+	SupportsSearch         bool            `json:"supports_search"`
+	SupportsTimescaleMarks bool            `json:"supports_timescale_marks"`
+	SupportsTime           bool            `json:"supports_time"`
+	Exchanges              []UdfExchange   `json:"exchanges"`
+	SymbolsTypes           []UdfSymbolType `json:"symbols_types"`
+}
+
+// udfConfig returns the features of the datafeed.
+//
+//	GET /udf/config
+func (s *server) udfConfig(c *fiber.Ctx) error {
+	return c.JSON(UdfConfig{
+		SupportedResolutions: udfResolutions,
+		SupportsSearch:       true,
+		SupportsTime:         true,
+		Exchanges: []UdfExchange{
+			{Value: "", Name: "All Exchanges", Desc: ""},
+			{Value: UdfExchangeSpot, Name: UdfExchangeSpot, Desc: "Binance Spot"},
+			{Value: UdfExchangeFutures, Name: UdfExchangeFutures, Desc: "Binance USD-M Futures"},
+		},
+		SymbolsTypes: []UdfSymbolType{
+			{Name: "All types", Value: ""},
+			{Name: "Crypto", Value: "crypto"},
+		},
+	})
+}
+
+// udfTime returns the server time in unix seconds.
+//
+//	GET /udf/time
+func (s *server) udfTime(c *fiber.Ctx) error {
+	return c.SendString(strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+// UdfSymbol is the body of the /udf/symbols requests.
+type UdfSymbol struct {
+	Name                 string   `json:"name"`
+	Ticker               string   `json:"ticker"`
+	Description          string   `json:"description"`
+	Type                 string   `json:"type"`
+	Session              string   `json:"session"`
+	Exchange             string   `json:"exchange"`
+	ListedExchange       string   `json:"listed_exchange"`
+	Timezone             string   `json:"timezone"`
+	Format               string   `json:"format"`
+	Pricescale           int64    `json:"pricescale"`
+	Minmov               int64    `json:"minmov"`
+	HasIntraday          bool     `json:"has_intraday"`
+	HasDaily             bool     `json:"has_daily"`
+	HasWeeklyAndMonthly  bool     `json:"has_weekly_and_monthly"`
+	SupportedResolutions []string `json:"supported_resolutions"`
+	VolumePrecision      int      `json:"volume_precision"`
+	DataStatus           string   `json:"data_status"`
+}
+
+// udfSymbols returns the symbol info of the ticker. The price scale is
+// guessed from the decimals of the latest close price.
+//
+//	GET /udf/symbols?symbol=BINANCE:BTCUSDT
+func (s *server) udfSymbols(c *fiber.Ctx) error {
+	m, symbol, err := s.udfTicker(c.Query("symbol"))
+	if err != nil {
+		return err
+	}
+
+	asset, err := m.assets.GetAsset(binance.Source, symbol)
+	if errors.Is(err, market_dto.ErrAssetNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "unknown_symbol")
+	}
+	if err != nil {
+		return err
+	}
+
+	coverage, err := m.ohlc.GetCoverage(symbol)
+	if err != nil {
+		return err
+	}
+
+	latest, err := m.latestRow(symbol, coverage)
+	if err != nil {
+		return err
+	}
+
+	pricescale := int64(100)
+	if latest != nil {
+		pricescale = udfPricescale(latest.Close)
+	}
+
+	exchange := udfExchange(m.market)
+
+	return c.JSON(UdfSymbol{
+		Name:                 asset.AssetBase.Symbol,
+		Ticker:               exchange + ":" + asset.AssetBase.Symbol,
+		Description:          udfDescription(m.market, asset.AssetBase),
+		Type:                 "crypto",
+		Session:              "24x7",
+		Exchange:             exchange,
+		ListedExchange:       exchange,
+		Timezone:             "Etc/UTC",
+		Format:               "price",
+		Pricescale:           pricescale,
+		Minmov:               1,
+		HasIntraday:          true,
+		HasDaily:             true,
+		HasWeeklyAndMonthly:  true,
+		SupportedResolutions: udfResolutions,
+		VolumePrecision:      maxUdfPriceDecimals,
+		DataStatus:           "streaming",
+	})
+}
+
+// UdfSearchResult is an item of the /udf/search response.
+type UdfSearchResult struct {
+	Symbol      string `json:"symbol"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Exchange    string `json:"exchange"`
+	Ticker      string `json:"ticker"`
+	Type        string `json:"type"`
+}
+
+// udfSearch returns the assets of which the symbol, base or quote asset
+// contain the query, optionally only of the market of the exchange param.
+//
+//	GET /udf/search?query=btc&exchange=BINANCE&limit=30
+func (s *server) udfSearch(c *fiber.Ctx) error {
+	markets := []string{MarketSpot, MarketFutures}
+
+	switch exchange := strings.ToUpper(c.Query("exchange")); exchange {
+	case "":
+	case UdfExchangeSpot:
+		markets = []string{MarketSpot}
+	case UdfExchangeFutures:
+		markets = []string{MarketFutures}
+	default:
+		return badRequest("unsupported exchange: %v", exchange)
+	}
+
+	if typ := c.Query("type"); typ != "" && typ != "crypto" {
+		return c.JSON([]UdfSearchResult{})
+	}
+
+	limit := c.QueryInt("limit", defaultUdfSearchLimit)
+	if limit <= 0 {
+		return badRequest("limit must be positive")
+	}
+
+	results := []UdfSearchResult{}
+
+	for _, name := range markets {
+		m, err := s.market(name)
+		if err != nil {
+			return err
+		}
+
+		assets, err := m.assets.GetAssets(market_dto.AssetFilter{Source: binance.Source, Search: c.Query("query")})
+		if err != nil {
+			return err
+		}
+
+		exchange := udfExchange(m.market)
+		for _, asset := range assets {
+			if len(results) == limit {
+				return c.JSON(results)
+			}
+
+			results = append(results, UdfSearchResult{
+				Symbol:      asset.Symbol,
+				FullName:    exchange + ":" + asset.Symbol,
+				Description: udfDescription(m.market, asset),
+				Exchange:    exchange,
+				Ticker:      exchange + ":" + asset.Symbol,
+				Type:        "crypto",
+			})
+		}
+	}
+
+	return c.JSON(results)
+}
+
+// UdfHistory is the body of the /udf/history requests. If there are no bars
+// in the range, the status is no_data and NextTime holds the time of the
+// closest earlier bar, if there is one.
+type UdfHistory struct {
+	S        string    `json:"s"` // ok or no_data
+	T        []int64   `json:"t,omitempty"`
+	O        []float64 `json:"o,omitempty"`
+	H        []float64 `json:"h,omitempty"`
+	L        []float64 `json:"l,omitempty"`
+	C        []float64 `json:"c,omitempty"`
+	V        []float64 `json:"v,omitempty"`
+	NextTime int64     `json:"nextTime,omitempty"`
+}
+
+// udfHistory returns the bars of the ticker which start in the range [from,
+// to), in unix seconds. If countback is set, the from param is ignored (and
+// not required) and the latest countback bars before to are returned instead. The trailing
+// bar is returned even if it's incomplete, so that the chart can update it.
+//
+//	GET /udf/history?symbol=BINANCE:BTCUSDT&resolution=60&from=1704067200&to=1704153600&countback=24
+func (s *server) udfHistory(c *fiber.Ctx) error {
+	m, symbol, err := s.udfTicker(c.Query("symbol"))
+	if err != nil {
+		return err
+	}
+
+	res, err := parseUdfResolution(c.Query("resolution"))
+	if err != nil {
+		return err
+	}
+
+	to, err := parseUnixParam("to", c.Query("to"))
+	if err != nil {
+		return err
+	}
+
+	countback := c.QueryInt("countback")
+	if countback < 0 {
+		return badRequest("countback must not be negative")
+	}
+
+	// start of the requested bars, from which the next time is searched
+	start := to
+
+	var rows []market_dto.OhlcRow
+	if countback > 0 {
+		rows, err = s.udfLatestBars(m, symbol, res, to, countback)
+	} else {
+		if start, err = parseUnixParam("from", c.Query("from")); err != nil {
+			return err
+		}
+		rows, err = s.udfBars(m, symbol, res, start, to)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		prev, err := s.udfLatestBars(m, symbol, res, start, 1)
+		if err != nil {
+			return err
+		}
+
+		body := UdfHistory{S: "no_data"}
+		if len(prev) == 1 {
+			body.NextTime = prev[0].StartTime.Unix()
+		}
+
+		return c.JSON(body)
+	}
+
+	body := UdfHistory{S: "ok"}
+	for _, row := range rows {
+		body.T = append(body.T, row.StartTime.Unix())
+		body.O = append(body.O, row.Open)
+		body.H = append(body.H, row.High)
+		body.L = append(body.L, row.Low)
+		body.C = append(body.C, row.Close)
+		body.V = append(body.V, row.Volume)
+	}
+
+	return c.JSON(body)
+}
+
+// udfBars returns the bars which start in the range [from, to).
+func (s *server) udfBars(m *marketStores, symbol string, res market_dto.Resolution, from, to time.Time) ([]market_dto.OhlcRow, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	rows, err := s.queryOhlc(m, market_dto.OhlcFilter{Symbol: symbol, From: from, To: to.Add(-time.Millisecond)}, res, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// the range is extended to the whole buckets by the resampling
+	docs := rows[:0]
+	for _, row := range rows {
+		if !row.StartTime.Before(from) && row.StartTime.Before(to) {
+			docs = append(docs, row)
+		}
+	}
+
+	return docs, nil
+}
+
+// udfLatestBars returns up to n latest bars which start before the time. The
+// range is doubled until there are enough bars or it reaches the earliest
+// stored row.
+func (s *server) udfLatestBars(m *marketStores, symbol string, res market_dto.Resolution, to time.Time, n int) ([]market_dto.OhlcRow, error) {
+	coverage, err := m.ohlc.GetCoverage(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(coverage) == 0 {
+		return nil, nil
+	}
+
+	earliest := coverage[0].From
+	for _, c := range coverage {
+		if c.From.Before(earliest) {
+			earliest = c.From
+		}
+	}
+
+	start := res.BucketStart(to)
+	window := time.Duration(n) * res.BucketEnd(start).Sub(start)
+
+	for {
+		from := to.Add(-window)
+
+		rows, err := s.udfBars(m, symbol, res, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) >= n || !from.After(earliest) {
+			return rows[max(len(rows)-n, 0):], nil
+		}
+
+		window *= 2
+	}
+}
+
+// udfTicker returns the stores and the symbol of the ticker. Tickers without
+// an exchange prefix are spot symbols.
+func (s *server) udfTicker(ticker string) (*marketStores, string, error) {
+	if ticker == "" {
+		return nil, "", badRequest("symbol is required")
+	}
+
+	market := MarketSpot
+
+	exchange, symbol, found := strings.Cut(strings.ToUpper(ticker), ":")
+	if !found {
+		symbol = exchange
+	} else {
+		switch exchange {
+		case UdfExchangeSpot:
+		case UdfExchangeFutures:
+			market = MarketFutures
+		default:
+			return nil, "", fiber.NewError(fiber.StatusNotFound, "unknown_symbol")
+		}
+	}
+
+	m, err := s.market(market)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return m, symbol, nil
+}
+
+// udfExchange returns the exchange of the market of the rows.
+func udfExchange(market string) string {
+	if market == market_dto.MarketUsdm {
+		return UdfExchangeFutures
+	}
+	return UdfExchangeSpot
+}
+
+func udfDescription(market string, asset market_dto.AssetBase) string {
+	desc := asset.BaseAsset + " / " + asset.QuoteAsset
+	if market == market_dto.MarketUsdm {
+		desc += " Futures"
+	}
+	return desc
+}
+
+// udfPricescale returns the price scale which shows the decimals of the price.
+func udfPricescale(price float64) int64 {
+	decimals := 0
+	if _, frac, ok := strings.Cut(strconv.FormatFloat(price, 'f', -1, 64), "."); ok {
+		decimals = len(frac)
+	}
+
+	return int64(math.Pow10(min(max(decimals, 2), maxUdfPriceDecimals)))
+}
+
+// parseUdfResolution parses the udf resolutions, which are minutes without a
+// unit (e.g. 1, 15, 240) or days, weeks and months (e.g. D, 1D, 1W, 3M).
+func parseUdfResolution(val string) (market_dto.Resolution, error) {
+	if val == "" {
+		return market_dto.Resolution{}, badRequest("resolution is required")
+	}
+
+	count, unit := val, ""
+	if last := val[len(val)-1:]; strings.Contains("DWM", last) {
+		count, unit = val[:len(val)-1], last
+	}
+
+	if count == "" {
+		count = "1"
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return market_dto.Resolution{}, badRequest("invalid resolution: %v", val)
+	}
+
+	switch unit {
+	case "":
+		return market_dto.Resolution{Interval: (time.Duration(n) * time.Minute).Milliseconds()}, nil
+	case "D":
+		return market_dto.Resolution{Interval: (time.Duration(n) * 24 * time.Hour).Milliseconds()}, nil
+	case "W":
+		return parseResolution(fmt.Sprintf("%vw", n))
+	default:
+		return parseResolution(fmt.Sprintf("%vM", n))
+	}
+}
+
+// parseUnixParam parses the required time param in unix seconds.
+func parseUnixParam(key, val string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, badRequest("%v is required", key)
+	}
+
+	sec, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, badRequest("invalid %v time: %v", key, val)
+	}
+
+	return time.Unix(sec, 0).UTC(), nil
+}
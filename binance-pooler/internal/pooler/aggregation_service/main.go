@@ -0,0 +1,256 @@
+package aggregation_service
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/timeset"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+// DefaultTimeframes are the timeframes which are aggregated from the 1m rows.
+var DefaultTimeframes = []string{"5m", "15m", "1h", "4h", "1d"}
+
+// sourceInterval is the interval of the rows from which the timeframes are derived.
+const sourceInterval = int64(time.Minute / time.Millisecond)
+
+// maxSourceRows limits the number of source rows which are read at once.
+const maxSourceRows = 7 * 24 * 60
+
+type service struct {
+	app        *core.App
+	symbols    []string
+	timeframes []string
+	markets    []string
+	logger     syro.Logger
+	now        func() time.Time
+}
+
+// timeRange is a range of the buckets, which excludes the end time.
+type timeRange struct {
+	from time.Time
+	to   time.Time
+}
+
+// New returns the service which keeps the derived ohlc collections up to date
+// with the 1m rows of the symbols.
+func New(app *core.App, symbols []string, timeframes []string) *service {
+	return &service{
+		app:        app,
+		symbols:    symbols,
+		timeframes: timeframes,
+		logger:     app.Logger().WithEvent("aggregation"),
+		now:        time.Now,
+	}
+}
+
+// WithMarkets sets the markets (spot or futures) of which the rows are
+// aggregated. Defaults to both markets.
+func (s *service) WithMarkets(markets ...string) *service {
+	s.markets = markets
+	return s
+}
+
+func (s *service) AddJobs(sched *syro.CronScheduler) error {
+	intervals, err := parseTimeframes(s.timeframes)
+	if err != nil {
+		return err
+	}
+
+	stores := s.app.Stores()
+
+	markets := []struct {
+		name    string
+		source  market_dto.OhlcStore
+		derived market_dto.OhlcStore
+	}{
+		{"spot", stores.CryptoSpotOhlc, stores.CryptoSpotOhlcDerived},
+		{"futures", stores.CryptoFuturesOhlc, stores.CryptoFuturesOhlcDerived},
+	}
+
+	for _, m := range markets {
+		if len(s.markets) > 0 && !slices.Contains(s.markets, m.name) {
+			continue
+		}
+
+		source, derived := m.source, m.derived
+
+		if err := sched.Register(
+			&syro.Job{
+				Name:        fmt.Sprintf("%v-ohlc-aggregation", m.name),
+				Schedule:    "@every 1m",
+				Description: fmt.Sprintf("aggregate %v timeframes from the 1m rows of %v", s.timeframes, source.Name()),
+				Func: func() error {
+					for _, symbol := range s.symbols {
+						for _, interval := range intervals {
+							if err := s.aggregate(source, derived, symbol, interval); err != nil {
+								s.logger.Error(err.Error())
+								return err
+							}
+						}
+					}
+					return nil
+				},
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseTimeframes(timeframes []string) ([]int64, error) {
+	intervals := make([]int64, len(timeframes))
+	for i, tf := range timeframes {
+		d, err := timeset.ParseInterval(tf)
+		if err != nil {
+			return nil, err
+		}
+
+		interval := d.Milliseconds()
+		if interval <= sourceInterval || interval%sourceInterval != 0 {
+			return nil, fmt.Errorf("timeframe %v is not a multiple of 1m", tf)
+		}
+
+		// a bucket which does not divide a day would not be aligned to the calendar days
+		if day := int64(24 * time.Hour / time.Millisecond); interval < day && day%interval != 0 || interval > day && interval%day != 0 {
+			return nil, fmt.Errorf("timeframe %v is not aligned to the calendar days", tf)
+		}
+
+		intervals[i] = interval
+	}
+	return intervals, nil
+}
+
+// aggregate upserts the rows of the interval in the ranges which are missing
+// in the derived rows, and from the latest derived row (which is aggregated
+// again, in case the source rows were updated). Only the buckets which ended
+// and hold all of the 1m rows are written.
+func (s *service) aggregate(source, derived market_dto.OhlcStore, symbol string, interval int64) error {
+	now := s.now()
+
+	ranges, err := missingRanges(source, derived, symbol, interval, now)
+	if err != nil {
+		return err
+	}
+
+	var numUpserted int
+	for _, r := range ranges {
+		n, err := s.aggregateRange(source, derived, symbol, interval, r, now)
+		if err != nil {
+			return err
+		}
+		numUpserted += n
+	}
+
+	if numUpserted > 0 {
+		s.logger.Debug("aggregated ohlc rows", syro.LogFields{
+			"symbol":   symbol,
+			"interval": interval,
+			"coll":     derived.Name(),
+			"upserted": numUpserted,
+		})
+	}
+
+	return nil
+}
+
+// missingRanges returns the ranges of the buckets which are not in the derived
+// rows: the buckets before the first derived row and in the gaps between the
+// derived rows (a bucket is skipped while its 1m rows are incomplete, so they
+// are checked again after a gap fill or a backfill), and the buckets from the
+// latest derived row.
+func missingRanges(source, derived market_dto.OhlcStore, symbol string, interval int64, now time.Time) ([]timeRange, error) {
+	first, err := source.GetOhlcRows(market_dto.OhlcFilter{Symbol: symbol, Interval: sourceInterval, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(first) == 0 {
+		return nil, nil
+	}
+	from := market_dto.BucketStart(first[0].StartTime, interval)
+
+	coverage, err := derived.GetCoverage(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(coverage, func(c market_dto.OhlcCoverage) bool { return c.Interval == interval })
+	if i == -1 {
+		return []timeRange{{from, now}}, nil
+	}
+	c := coverage[i]
+
+	var ranges []timeRange
+	if from.Before(c.From) {
+		ranges = append(ranges, timeRange{from, c.From})
+	}
+
+	// the gaps are only searched if the count of the derived rows is off
+	if c.Count < c.To.Sub(c.From).Milliseconds()/interval+1 {
+		gaps, err := derived.FindGaps(symbol, interval)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, gap := range gaps[interval] {
+			ranges = append(ranges, timeRange{gap.StartOfGap, gap.EndOfGap})
+		}
+	}
+
+	return append(ranges, timeRange{c.To, now}), nil
+}
+
+// aggregateRange upserts the complete buckets of the range and returns the
+// number of upserted rows.
+func (s *service) aggregateRange(source, derived market_dto.OhlcStore, symbol string, interval int64, r timeRange, now time.Time) (int, error) {
+	window := timeset.MilisToDuration(interval * max(1, maxSourceRows*sourceInterval/interval))
+
+	var numUpserted int
+	for from := r.from; from.Before(r.to) && from.Before(now); from = from.Add(window) {
+		to := from.Add(window)
+		if to.After(r.to) {
+			to = r.to
+		}
+
+		rows, err := source.GetOhlcRows(market_dto.OhlcFilter{
+			Symbol:   symbol,
+			Interval: sourceInterval,
+			From:     from,
+			To:       to.Add(-time.Millisecond),
+		})
+		if err != nil {
+			return numUpserted, err
+		}
+
+		aggregated, err := market_dto.AggregateOhlcRows(rows, interval)
+		if err != nil {
+			return numUpserted, err
+		}
+
+		// the latest 1m row is updated until the minute ends, so the
+		// bucket is only complete once its end time has passed
+		var complete []market_dto.OhlcRow
+		for _, row := range aggregated {
+			if !row.StartTime.Add(timeset.MilisToDuration(interval)).After(now) {
+				complete = append(complete, row)
+			}
+		}
+
+		if len(complete) == 0 {
+			continue
+		}
+
+		if _, err := derived.UpsertOhlcRows(complete); err != nil {
+			return numUpserted, fmt.Errorf("%v:%v failed to upsert aggregated rows: %v", symbol, interval, err)
+		}
+		numUpserted += len(complete)
+	}
+
+	return numUpserted, nil
+}
@@ -0,0 +1,144 @@
+package aggregation_service
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"testing"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+func TestAggregation(t *testing.T) {
+	const hour = int64(time.Hour / time.Millisecond)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(2*time.Hour + 30*time.Minute)
+
+	source := market_dto.NewMemoryOhlcStore("crypto_spot_ohlc")
+	derived := market_dto.NewMemoryOhlcStore("crypto_spot_ohlc_derived")
+
+	var rows []market_dto.OhlcRow
+	for t1 := start; t1.Before(now); t1 = t1.Add(time.Minute) {
+		row, err := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.SetNumberOfTrades(2)
+		rows = append(rows, *row)
+	}
+
+	if _, err := source.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &service{logger: syro.NewConsoleLogger(nil), now: func() time.Time { return now }}
+
+	if err := s.aggregate(source, derived, "BTCUSDT", hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// the 3rd hour has not ended yet
+	docs, _ := derived.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT", Interval: hour})
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 complete buckets, got %d", len(docs))
+	}
+
+	if docs[1].Volume != 60 || *docs[1].NumberOfTrades != 120 || !docs[1].StartTime.Equal(start.Add(time.Hour)) {
+		t.Fatalf("unexpected aggregated row: %+v", docs[1])
+	}
+
+	// the next run continues from the latest derived row
+	s.now = func() time.Time { return now.Add(time.Hour) }
+	for t1 := now; t1.Before(now.Add(time.Hour)); t1 = t1.Add(time.Minute) {
+		row, _ := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 3, 0.5, 1.5, 1)
+		if _, err := source.UpsertOhlcRows([]market_dto.OhlcRow{*row}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.aggregate(source, derived, "BTCUSDT", hour); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, _ = derived.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT", Interval: hour})
+	if len(docs) != 3 || docs[2].High != 3 {
+		t.Fatalf("expected the 3rd bucket to be aggregated, got %+v", docs)
+	}
+
+	if _, err := parseTimeframes([]string{"7m"}); err == nil {
+		t.Fatal("expected an error for a timeframe which does not divide a day")
+	}
+}
+
+func TestAggregationMissingBuckets(t *testing.T) {
+	const hour = int64(time.Hour / time.Millisecond)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(4 * time.Hour)
+
+	source := market_dto.NewMemoryOhlcStore("crypto_spot_ohlc")
+	derived := market_dto.NewMemoryOhlcStore("crypto_spot_ohlc_derived")
+
+	upsert := func(from, to time.Time) {
+		t.Helper()
+
+		var rows []market_dto.OhlcRow
+		for t1 := from; t1.Before(to); t1 = t1.Add(time.Minute) {
+			row, err := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+
+		if _, err := source.UpsertOhlcRows(rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func() int {
+		t.Helper()
+
+		docs, err := derived.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT", Interval: hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(docs)
+	}
+
+	// the 2nd hour misses its last 1m row and the 1st hour is not stored yet
+	upsert(start.Add(time.Hour), start.Add(2*time.Hour-time.Minute))
+	upsert(start.Add(2*time.Hour), now)
+
+	s := &service{logger: syro.NewConsoleLogger(nil), now: func() time.Time { return now }}
+
+	if err := s.aggregate(source, derived, "BTCUSDT", hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 2 {
+		t.Fatalf("expected 2 complete buckets, got %d", n)
+	}
+
+	// the gap fill completes the skipped bucket between the derived rows
+	upsert(start.Add(2*time.Hour-time.Minute), start.Add(2*time.Hour))
+
+	if err := s.aggregate(source, derived, "BTCUSDT", hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 3 {
+		t.Fatalf("expected the gap to be aggregated, got %d buckets", n)
+	}
+
+	// the backfill adds the buckets before the first derived row
+	upsert(start, start.Add(time.Hour))
+
+	if err := s.aggregate(source, derived, "BTCUSDT", hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 4 {
+		t.Fatalf("expected the backfilled bucket to be aggregated, got %d buckets", n)
+	}
+}
@@ -1,14 +1,11 @@
 package binance_service
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-
 	"binance-pooler/pkg/core"
 	"binance-pooler/pkg/dto/market_dto"
 	"binance-pooler/pkg/lib/mongodb"
@@ -26,12 +23,13 @@ type service struct {
 	timeframes           []binance.Timeframe
 	requestSleepDuration time.Duration
 	debug                bool
+	state                *ScrapeState
 }
 
 func New(app *core.App, maxParallelRequests int, timeframes []binance.Timeframe) *service {
 	return &service{
 		maxParallelRequests: maxParallelRequests,
-		api:                 binance.New(),
+		api:                 binance.New().WithLake(app.RawLake()),
 		timeframes:          timeframes,
 		debug:               false,
 		app:                 app,
@@ -48,6 +46,12 @@ func (s *service) WithSleepDuration(d time.Duration) *service {
 	return s
 }
 
+// WithScrapeState records the scrape attempts of the symbols in the state.
+func (s *service) WithScrapeState(state *ScrapeState) *service {
+	s.state = state
+	return s
+}
+
 func (s *service) log() syro.Logger {
 	return s.app.Logger().WithEvent("binance")
 }
@@ -66,19 +70,19 @@ func (s *service) AddJobs(sched *syro.CronScheduler) error {
 			Name:     "binance-spot-ohlc",
 			Schedule: "@every 30s",
 			Func: func() error {
-				assetsColl := s.app.Db().CryptoSpotAssetColl()
-				historyColl := s.app.Db().CryptoSpotOhlcColl()
+				assetStore := s.app.Stores().CryptoSpotAsset
+				historyStore := s.app.Stores().CryptoSpotOhlc
 				getFunc := s.api.GetSpotKline
 
-				filter := bson.M{"source": binance.Source, "symbol": bson.M{"$in": binance.TopPairs}}
+				filter := market_dto.AssetFilter{Source: binance.Source, Symbols: binance.TopPairs}
 
-				assets, err := market_dto.GetAssets(assetsColl, filter, nil)
+				assets, err := assetStore.GetAssets(filter)
 				if err != nil {
 					s.log().Error(err.Error())
 					return err
 				}
 
-				if err := s.runOhlcScraper(assets, historyColl, getFunc, false); err != nil {
+				if err := s.runOhlcScraper(assets, historyStore, getFunc, false); err != nil {
 					s.log().Error(err.Error())
 					return err
 				}
@@ -90,24 +94,31 @@ func (s *service) AddJobs(sched *syro.CronScheduler) error {
 		return err
 	}
 
+	if s.app.Conf().Reconcile.LookbackDays > 0 {
+		job := s.reconcileJob("binance-spot-ohlc-reconcile", s.app.Stores().CryptoSpotAsset, s.app.Stores().CryptoSpotOhlc, s.api.GetSpotKline)
+		if err := sched.Register(job); err != nil {
+			return err
+		}
+	}
+
 	// if err := sched.Register(
 	// 	&syro.Job{
 	// 		Name:     "binance-futures-ohlc",
 	// 		Schedule: "@every 30s",
 	// 		Func: func() error {
-	// 			assetsColl := s.app.Db().CryptoFuturesAssetColl()
-	// 			historyColl := s.app.Db().CryptoFuturesOhlcColl()
+	// 			assetStore := s.app.Stores().CryptoFuturesAsset
+	// 			historyStore := s.app.Stores().CryptoFuturesOhlc
 	// 			getFunc := s.api.GetFutureKline
 
-	// 			filter := bson.M{"source": binance.Source, "symbol": bson.M{"$in": binance.TopPairs}}
+	// 			filter := market_dto.AssetFilter{Source: binance.Source, Symbols: binance.TopPairs}
 
-	// 			assets, err := market_dto.GetAssets(assetsColl, filter, nil)
+	// 			assets, err := assetStore.GetAssets(filter)
 	// 			if err != nil {
 	// 				s.log().Error(err.Error())
 	// 				return err
 	// 			}
 
-	// 			if err := s.runOhlcScraper(assets, historyColl, getFunc, false); err != nil {
+	// 			if err := s.runOhlcScraper(assets, historyStore, getFunc, false); err != nil {
 	// 				s.log().Error(err.Error())
 	// 				return err
 	// 			}
@@ -123,32 +134,31 @@ func (s *service) AddJobs(sched *syro.CronScheduler) error {
 }
 
 func (s *service) setupSpotAssets() error {
-	assetsColl := s.app.Db().CryptoSpotAssetColl()
+	assetStore := s.app.Stores().CryptoSpotAsset
 	getFunc := s.api.GetAllSpotAssets
-	return initializeAssets(s, assetsColl, getFunc)
+	return initializeAssets(s, assetStore, getFunc)
 }
 
 func (s *service) setupFuturesAssets() error {
-	assetsColl := s.app.Db().CryptoFuturesAssetColl()
+	assetStore := s.app.Stores().CryptoFuturesAsset
 	getFunc := s.api.GetAllFutureSymbols
-	return initializeAssets(s, assetsColl, getFunc)
+	return initializeAssets(s, assetStore, getFunc)
 }
 
-func initializeAssets[T any](s *service, assetsColl *mongo.Collection, getAssets binance.GetAssetsFunc[T]) error {
-	filter := bson.M{"source": binance.Source}
-	count, err := assetsColl.CountDocuments(context.Background(), filter)
+func initializeAssets[T any](s *service, assetStore market_dto.AssetStore, getAssets binance.GetAssetsFunc[T]) error {
+	count, err := assetStore.CountAssets(binance.Source)
 	if err != nil {
 		return err
 	}
 
 	if count == 0 {
-		s.log().Info("no assets found, scraping data", syro.LogFields{"collection": assetsColl.Name()})
+		s.log().Info("no assets found, scraping data", syro.LogFields{"collection": assetStore.Name()})
 		docs, err := getAssets()
 		if err != nil {
 			return err
 		}
 
-		upsertLog, err := market_dto.UpsertAssets(docs, assetsColl)
+		upsertLog, err := assetStore.UpsertAssets(market_dto.AnyAssets(docs))
 		if err != nil {
 			return err
 		}
@@ -157,16 +167,16 @@ func initializeAssets[T any](s *service, assetsColl *mongo.Collection, getAssets
 		return nil
 	}
 
-	s.log().Info(fmt.Sprintf("assets already exist in %v collection, skipping setup", assetsColl.Name()))
+	s.log().Info(fmt.Sprintf("assets already exist in %v collection, skipping setup", assetStore.Name()))
 	return nil
 }
 
-func (s *service) runOhlcScraper(assets []market_dto.AssetBase, coll *mongo.Collection, getHistoryFunc binance.GetHistoryFunc, fillgaps bool) error {
+func (s *service) runOhlcScraper(assets []market_dto.AssetBase, store market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc, fillgaps bool) error {
 
 	sem := make(chan struct{}, s.maxParallelRequests)
 	var wg sync.WaitGroup
 
-	s.log().Debug("running ohlc scraper", syro.LogFields{"num_assets": len(assets), "coll": coll.Name()})
+	s.log().Debug("running ohlc scraper", syro.LogFields{"num_assets": len(assets), "coll": store.Name()})
 
 	for _, asset := range assets {
 		sem <- struct{}{}
@@ -178,15 +188,20 @@ func (s *service) runOhlcScraper(assets []market_dto.AssetBase, coll *mongo.Coll
 
 			for _, tf := range s.timeframes {
 				time.Sleep(s.requestSleepDuration)
-				if fillgaps {
-					if err := s.fillGapsForSymbol(coll, getHistoryFunc, symbol, tf); err != nil {
-						s.log().Error(err.Error())
-					}
 
+				var err error
+				if fillgaps {
+					err = s.fillGapsForSymbol(store, getHistoryFunc, symbol, tf)
 				} else {
-					if err := s.scrapeOhlcForSymbol(coll, getHistoryFunc, symbol, tf); err != nil {
-						s.log().Error(err.Error())
-					}
+					err = s.scrapeOhlcForSymbol(store, getHistoryFunc, symbol, tf)
+				}
+
+				if err != nil {
+					s.log().Error(err.Error())
+				}
+
+				if s.state != nil {
+					s.state.record(store.Name(), symbol, tf.Milis, err)
 				}
 			}
 
@@ -198,9 +213,25 @@ func (s *service) runOhlcScraper(assets []market_dto.AssetBase, coll *mongo.Coll
 	return nil
 }
 
-func (s *service) fillGapsForSymbol(historyColl *mongo.Collection, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe) error {
-	filter := bson.M{"symbol": symbol, "interval": tf.Milis}
-	gaps, err := mongodb.FindGaps(historyColl, filter)
+// upsertOhlcRows validates the scraped rows before they are written. The rows
+// which fail the quality checks are moved to the quarantine store.
+func (s *service) upsertOhlcRows(store market_dto.OhlcStore, tf binance.Timeframe, docs []market_dto.OhlcRow) (*mongodb.UpsertLog, error) {
+	settings := market_dto.DefaultQualitySettings
+	settings.Interval = tf.Milis
+
+	upsertLog, res, err := market_dto.UpsertValidOhlcRows(store, s.app.Stores().OhlcQuarantine, docs, settings)
+
+	for _, report := range res.Reports {
+		if report.NumQuarantined > 0 {
+			s.log().Warn("quarantined ohlc rows", syro.LogFields{"coll": store.Name(), "interval": tf.Milis, "report": report})
+		}
+	}
+
+	return upsertLog, err
+}
+
+func (s *service) fillGapsForSymbol(historyStore market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe) error {
+	gaps, err := historyStore.FindGaps(symbol, tf.Milis)
 	if err != nil {
 		return err
 	}
@@ -242,7 +273,7 @@ func (s *service) fillGapsForSymbol(historyColl *mongo.Collection, getHistoryFun
 					return fmt.Errorf("%v:%v [%v -> %v] failed to get ohlc rows: %v", symbol, tf.UrlParam, chunk.From, chunk.To, err)
 				}
 
-				upsertLog, err := market_dto.UpsertOhlcRows(docs, historyColl)
+				upsertLog, err := s.upsertOhlcRows(historyStore, tf, docs)
 				if err != nil {
 					return err
 				}
@@ -255,23 +286,18 @@ func (s *service) fillGapsForSymbol(historyColl *mongo.Collection, getHistoryFun
 	return nil
 }
 
-func (s *service) scrapeOhlcForSymbol(historyColl *mongo.Collection, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe) error {
+func (s *service) scrapeOhlcForSymbol(historyStore market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe) error {
 	// defaultStart := time.Now().AddDate(-6, 0, 0)
 
 	defaultStart := time.Now().AddDate(-3, 0, 0)
 
-	filter := bson.M{
-		"interval": tf.Milis,
-		"symbol":   symbol,
-	}
-
 	now := time.Now()
-	latestTime, err := mongodb.FindLatestStartTime(defaultStart, historyColl, filter)
+	latestTime, err := historyStore.FindLatestStartTime(defaultStart, symbol, tf.Milis)
 	if err != nil {
 		return err
 	}
 
-	s.log().Debug("queried latest time", syro.LogFields{"dur_ms": time.Since(now).Milliseconds(), "filter": filter})
+	s.log().Debug("queried latest time", syro.LogFields{"dur_ms": time.Since(now).Milliseconds(), "symbol": symbol, "interval": tf.Milis})
 
 	if s.debug {
 		// if the latest start time is from the last x days, return nil
@@ -309,7 +335,7 @@ func (s *service) scrapeOhlcForSymbol(historyColl *mongo.Collection, getHistoryF
 			}
 
 			if len(docs) != 0 {
-				upsertLog, err := market_dto.UpsertOhlcRows(docs, historyColl)
+				upsertLog, err := s.upsertOhlcRows(historyStore, tf, docs)
 				if err != nil {
 					return fmt.Errorf("%v:%v failed to upsert ohlc rows: %v", symbol, tf.UrlParam, err)
 				}
@@ -337,7 +363,7 @@ func (s *service) scrapeOhlcForSymbol(historyColl *mongo.Collection, getHistoryF
 			return err
 		}
 
-		upsertLog, err := market_dto.UpsertOhlcRows(docs, historyColl)
+		upsertLog, err := s.upsertOhlcRows(historyStore, tf, docs)
 		if err != nil {
 			return fmt.Errorf("%v:%v failed to upsert ohlc rows: %v", symbol, tf.UrlParam, err)
 		}
@@ -347,9 +373,119 @@ func (s *service) scrapeOhlcForSymbol(historyColl *mongo.Collection, getHistoryF
 				"symbol":     symbol,
 				"resolution": tf.Milis / 60000,
 				"upsertLog":  upsertLog.String(),
-				"coll":       historyColl.Name(),
+				"coll":       historyStore.Name(),
 			})
 	}
 
 	return nil
 }
+
+// BackfillRange requests the rows of the symbol in the range from the api in
+// chunks and upserts them into the store. The progress is reported after
+// each chunk, if the progress func is not nil.
+func (s *service) BackfillRange(historyStore market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe, from, to time.Time, progress func(done, total int)) error {
+	chunks, err := timeset.ChunkTimeRange(from, to, timeset.MilisToDuration(tf.Milis), 500, 10)
+	if err != nil {
+		return err
+	}
+
+	for i, chunk := range chunks {
+		time.Sleep(s.requestSleepDuration)
+
+		docs, err := getHistoryFunc(symbol, chunk.From, chunk.To, tf)
+		if err != nil {
+			return fmt.Errorf("%v:%v [%v -> %v] failed to get ohlc rows: %v", symbol, tf.UrlParam, chunk.From, chunk.To, err)
+		}
+
+		if len(docs) != 0 {
+			upsertLog, err := s.upsertOhlcRows(historyStore, tf, docs)
+			if err != nil {
+				return fmt.Errorf("%v:%v failed to upsert ohlc rows: %v", symbol, tf.UrlParam, err)
+			}
+
+			s.log().Info("upserted backfill ohlc", syro.LogFields{"symbol": symbol, "chunk_idx": i, "num_chunks": len(chunks), "upsertLog": upsertLog.String()})
+		}
+
+		if progress != nil {
+			progress(i+1, len(chunks))
+		}
+	}
+
+	return nil
+}
+
+// BackfillFromArchive reads the kline archives of the symbol for the period and
+// upserts the rows into the store. The to time is inclusive. Monthly archives
+// are used for the months which ended by the to time and daily archives for
+// the rest. Archives which are not published (yet) are skipped.
+func (s *service) BackfillFromArchive(source binance.ArchiveSource, market binance.ArchiveMarket, historyStore market_dto.OhlcStore, symbol string, tf binance.Timeframe, from, to time.Time) error {
+	if from.After(to) {
+		return fmt.Errorf("from date is after to date")
+	}
+
+	upsert := func(period binance.ArchivePeriod, date time.Time) error {
+		docs, err := source.GetKlines(market, period, symbol, tf, date)
+		if err != nil {
+			return err
+		}
+
+		var rows []market_dto.OhlcRow
+		for _, doc := range docs {
+			if !doc.StartTime.Before(from) && !doc.StartTime.After(to) {
+				rows = append(rows, doc)
+			}
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		upsertLog, err := s.upsertOhlcRows(historyStore, tf, rows)
+		if err != nil {
+			return fmt.Errorf("%v:%v failed to upsert archive rows: %v", symbol, tf.UrlParam, err)
+		}
+
+		s.log().Info("upserted binance archive ohlc", syro.LogFields{
+			"symbol":    symbol,
+			"period":    period,
+			"date":      date,
+			"upsertLog": upsertLog.String(),
+		})
+
+		return nil
+	}
+
+	from, to = from.UTC(), to.UTC()
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for !month.After(to) {
+		nextMonth := month.AddDate(0, 1, 0)
+
+		if !nextMonth.Add(-time.Nanosecond).After(to) {
+			err := upsert(binance.ArchivePeriodMonthly, month)
+			if err == nil {
+				month = nextMonth
+				continue
+			}
+
+			if !errors.Is(err, binance.ErrArchiveNotFound) {
+				return err
+			}
+
+			s.log().Debug("monthly archive not found, using daily archives", syro.LogFields{"symbol": symbol, "month": month})
+		}
+
+		for day := month; day.Before(nextMonth) && !day.After(to); day = day.AddDate(0, 0, 1) {
+			if err := upsert(binance.ArchivePeriodDaily, day); err != nil {
+				if !errors.Is(err, binance.ErrArchiveNotFound) {
+					return err
+				}
+				s.log().Debug("daily archive not found", syro.LogFields{"symbol": symbol, "day": day})
+			}
+		}
+
+		month = nextMonth
+	}
+
+	return nil
+}
@@ -1,10 +1,16 @@
 package binance_service
 
 import (
+	"archive/zip"
 	"binance-pooler/pkg/core"
 	"binance-pooler/pkg/dto/market_dto"
 	"binance-pooler/pkg/providers/binance"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -61,7 +67,7 @@ func TestService(t *testing.T) {
 			binance.Timeframe15M,
 		})
 
-		historyColl := app.Db().CryptoSpotAssetColl()
+		historyStore := app.Stores().CryptoSpotOhlc
 		getFunc := s.api.GetSpotKline
 
 		// need to setup assets first, so that they can be found in the db
@@ -69,8 +75,205 @@ func TestService(t *testing.T) {
 			s.log().Error(err.Error())
 		}
 
-		if err := s.scrapeOhlcForSymbol(historyColl, getFunc, "BTCUSDT", binance.Timeframe15M); err != nil {
+		if err := s.scrapeOhlcForSymbol(historyStore, getFunc, "BTCUSDT", binance.Timeframe15M); err != nil {
 			t.Fatal(err)
 		}
 	})
 }
+
+func TestReconcile(t *testing.T) {
+	app := core.NewMemoryApp(nil)
+	s := New(app, 1, []binance.Timeframe{binance.Timeframe1H})
+
+	store := app.Stores().CryptoSpotOhlc
+	symbol := "BTCUSDT"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(9 * time.Hour)
+
+	newRows := func(t1, t2 time.Time) []market_dto.OhlcRow {
+		var rows []market_dto.OhlcRow
+		for ts := t1; !ts.After(t2); ts = ts.Add(time.Hour) {
+			row, err := market_dto.NewOhlcRow(symbol, ts, ts.Add(time.Hour), 100, 101, 99, 100, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+		return rows
+	}
+
+	stored := newRows(from, to)
+	stored = append(stored[:4], stored[5:]...) // row 4 is missing
+	if _, err := store.UpsertOhlcRows(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// the api returns the rows of the requested chunk, with a revised close of row 2
+	getFunc := func(symbol string, t1, t2 time.Time, tf binance.Timeframe) ([]market_dto.OhlcRow, error) {
+		rows := newRows(t1.Add(-time.Hour), t2.Add(time.Hour))
+		for i := range rows {
+			if rows[i].StartTime.Equal(from.Add(2 * time.Hour)) {
+				rows[i].Close = 100.5
+			}
+		}
+		return rows, nil
+	}
+
+	report, err := s.Reconcile(store, getFunc, symbol, binance.Timeframe1H, from, to, ReconcileSettings{Tolerance: 1e-9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.NumStored != 9 || report.NumFetched != 10 || report.NumMissing != 1 || report.NumChanged != 1 || report.Corrected {
+		t.Fatalf("unexpected report: %v", report.String())
+	}
+
+	rows, _ := store.GetOhlcRows(market_dto.OhlcFilter{Symbol: symbol, From: from, To: to})
+	if len(rows) != 9 {
+		t.Fatalf("expected the rows to be unchanged, got %v rows", len(rows))
+	}
+
+	report, err = s.Reconcile(store, getFunc, symbol, binance.Timeframe1H, from, to, ReconcileSettings{Correct: true, Tolerance: 1e-9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Corrected {
+		t.Fatalf("expected the rows to be corrected: %v", report.String())
+	}
+
+	report, err = s.Reconcile(store, getFunc, symbol, binance.Timeframe1H, from, to, ReconcileSettings{Tolerance: 1e-9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.HasDrift() {
+		t.Fatalf("expected no drift after the correction: %v", report.String())
+	}
+
+	reports, err := app.Stores().OhlcDrift.GetDriftReports(market_dto.DriftFilter{Source: binance.Source, Market: market_dto.MarketSpot, Symbol: symbol})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 3 || reports[0].HasDrift() || !reports[2].HasDrift() {
+		t.Fatalf("unexpected stored reports: %+v", reports)
+	}
+
+	// the reports of the other markets are kept apart
+	if reports, err := app.Stores().OhlcDrift.GetDriftReports(market_dto.DriftFilter{Source: binance.Source, Market: market_dto.MarketUsdm, Symbol: symbol}); err != nil || len(reports) != 0 {
+		t.Fatalf("expected no reports of the other market, got %v, %v", reports, err)
+	}
+}
+
+func TestBackfillRange(t *testing.T) {
+	app := core.NewMemoryApp(nil)
+	store := app.Stores().CryptoSpotOhlc
+	tf := binance.Timeframe1M
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(1000 * time.Minute)
+
+	// returns the 1m rows of the requested range
+	klines := func(symbol string, t1, t2 time.Time, tf binance.Timeframe) ([]market_dto.OhlcRow, error) {
+		var rows []market_dto.OhlcRow
+		for start := t1; start.Before(t2); start = start.Add(time.Minute) {
+			row, err := market_dto.NewOhlcRow(symbol, start, start.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, *row)
+		}
+		return rows, nil
+	}
+
+	var progress [][2]int
+	if err := New(app, 1, []binance.Timeframe{tf}).BackfillRange(store, klines, "BTCUSDT", tf, from, to, func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 3 || progress[2] != [2]int{3, 3} {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+
+	coverage, err := store.GetCoverage("BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(coverage) != 1 || !coverage[0].From.Equal(from) || coverage[0].Count != 1000 {
+		t.Fatalf("unexpected coverage: %+v", coverage)
+	}
+}
+
+func TestBackfillFromArchive(t *testing.T) {
+	app := core.NewMemoryApp(nil)
+	store := app.Stores().CryptoSpotOhlc
+	tf := binance.Timeframe1M
+	dir := t.TempDir()
+
+	// writes the daily archive with the first and the last minute of the day
+	writeArchive := func(day time.Time) {
+		var csv bytes.Buffer
+		for _, start := range []time.Time{day, day.Add(24*time.Hour - time.Minute)} {
+			ms := start.UnixMilli()
+			fmt.Fprintf(&csv, "%d,1,2,0.5,1.5,10,%d,15,3,5,7.5,0\n", ms, ms+tf.Milis-1)
+		}
+
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		f, err := zw.Create("klines.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(csv.Bytes())
+		zw.Close()
+
+		archivePath := filepath.Join(dir, filepath.FromSlash(binance.ArchivePath(binance.ArchiveMarketSpot, binance.ArchivePeriodDaily, "BTCUSDT", tf, day)))
+		hash := sha256.Sum256(archive.Bytes())
+
+		os.MkdirAll(filepath.Dir(archivePath), 0755)
+		os.WriteFile(archivePath, archive.Bytes(), 0644)
+		os.WriteFile(archivePath+".CHECKSUM", []byte(hex.EncodeToString(hash[:])+"  "+filepath.Base(archivePath)+"\n"), 0644)
+	}
+
+	day := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+	writeArchive(day)
+	writeArchive(day.AddDate(0, 0, 1))
+
+	source := binance.ArchiveSource{Dir: dir, Offline: true}
+	s := New(app, 1, []binance.Timeframe{tf})
+
+	count := func() int64 {
+		t.Helper()
+
+		coverage, err := store.GetCoverage("BTCUSDT")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(coverage) == 0 {
+			return 0
+		}
+		return coverage[0].Count
+	}
+
+	// the end of the day, as the -to date of the backfill command
+	if err := s.BackfillFromArchive(source, binance.ArchiveMarketSpot, store, "BTCUSDT", tf, day, day.AddDate(0, 0, 1).Add(-time.Nanosecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 2 {
+		t.Fatalf("expected the rows of the single day, got %d", n)
+	}
+
+	if err := s.BackfillFromArchive(source, binance.ArchiveMarketSpot, store, "BTCUSDT", tf, day, day.AddDate(0, 0, 2).Add(-time.Nanosecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 4 {
+		t.Fatalf("expected the rows of the last day of the month, got %d", n)
+	}
+}
@@ -0,0 +1,125 @@
+package binance_service
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/timeset"
+	"binance-pooler/pkg/providers/binance"
+	"fmt"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+// ReconcileSettings holds the settings of a reconciliation run.
+type ReconcileSettings struct {
+	Correct   bool    // Upsert the fetched rows which are missing or differ from the stored ones
+	Tolerance float64 // Relative tolerance of the compared values
+}
+
+// Reconcile fetches the stored period of the symbol again and compares the
+// rows with the stored ones. The drift report is written to the drift store.
+// The candle which has not closed yet is not compared.
+func (s *service) Reconcile(historyStore market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc, symbol string, tf binance.Timeframe, from, to time.Time, settings ReconcileSettings) (*market_dto.DriftReport, error) {
+	interval := timeset.MilisToDuration(tf.Milis)
+
+	if lastClosed := time.Now().Add(-interval); to.After(lastClosed) {
+		to = lastClosed
+	}
+
+	if from.After(to) {
+		return nil, fmt.Errorf("from date is after to date")
+	}
+
+	filter := market_dto.OhlcFilter{Symbol: symbol, Interval: tf.Milis, From: from, To: to}
+
+	stored, err := historyStore.GetOhlcRows(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := timeset.ChunkTimeRange(from, to, interval, 500, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	byTime := make(map[int64]market_dto.OhlcRow)
+	for _, chunk := range chunks {
+		time.Sleep(s.requestSleepDuration)
+
+		docs, err := getHistoryFunc(symbol, chunk.From, chunk.To, tf)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v [%v -> %v] failed to get ohlc rows: %v", symbol, tf.UrlParam, chunk.From, chunk.To, err)
+		}
+
+		for _, doc := range docs {
+			if !doc.StartTime.Before(from) && !doc.StartTime.After(to) {
+				byTime[doc.StartTime.UnixMilli()] = doc
+			}
+		}
+	}
+
+	fetched := make([]market_dto.OhlcRow, 0, len(byTime))
+	for _, doc := range byTime {
+		fetched = append(fetched, doc)
+	}
+
+	report, changed := market_dto.DiffOhlcRows(stored, fetched, settings.Tolerance)
+	report.Collection = historyStore.Name()
+	report.Symbol = symbol
+	report.Interval = tf.Milis
+	report.From = from.UTC()
+	report.To = to.UTC()
+	report.CreatedAt = time.Now().UTC()
+
+	if settings.Correct && len(changed) > 0 {
+		if _, err := s.upsertOhlcRows(historyStore, tf, changed); err != nil {
+			return nil, fmt.Errorf("%v:%v failed to upsert the corrected rows: %v", symbol, tf.UrlParam, err)
+		}
+		report.Corrected = true
+	}
+
+	if err := s.app.Stores().OhlcDrift.InsertDriftReport(report); err != nil {
+		return nil, fmt.Errorf("failed to store the drift report: %v", err)
+	}
+
+	if report.HasDrift() {
+		s.log().Warn("found ohlc drift", syro.LogFields{"report": report.String()})
+	}
+
+	return &report, nil
+}
+
+// reconcileJob returns the job which reconciles the last days of the stored
+// rows of the assets, using the [reconcile] settings of the config.
+func (s *service) reconcileJob(name string, assetStore market_dto.AssetStore, historyStore market_dto.OhlcStore, getHistoryFunc binance.GetHistoryFunc) *syro.Job {
+	conf := s.app.Conf().Reconcile
+
+	return &syro.Job{
+		Name:        name,
+		Schedule:    "@daily",
+		Description: fmt.Sprintf("compare the last %v days of %v with the api", conf.LookbackDays, historyStore.Name()),
+		Func: func() error {
+			filter := market_dto.AssetFilter{Source: binance.Source, Symbols: binance.TopPairs}
+
+			assets, err := assetStore.GetAssets(filter)
+			if err != nil {
+				s.log().Error(err.Error())
+				return err
+			}
+
+			to := time.Now().UTC()
+			from := to.AddDate(0, 0, -conf.LookbackDays).Truncate(24 * time.Hour)
+			settings := ReconcileSettings{Correct: conf.AutoCorrect, Tolerance: conf.Tolerance}
+
+			for _, asset := range assets {
+				for _, tf := range s.timeframes {
+					if _, err := s.Reconcile(historyStore, getHistoryFunc, asset.Symbol, tf, from, to, settings); err != nil {
+						s.log().Error(err.Error())
+					}
+				}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package binance_service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScrapeState holds the latest scrape attempts of the symbols, so that the
+// symbols which are stuck can be found without reading the logs.
+type ScrapeState struct {
+	mu       sync.Mutex
+	attempts map[scrapeKey]*ScrapeAttempt
+}
+
+type scrapeKey struct {
+	coll     string
+	symbol   string
+	interval int64
+}
+
+// ScrapeAttempt is the latest scrape of a symbol and interval in a collection.
+type ScrapeAttempt struct {
+	Coll        string     `json:"coll"`
+	Symbol      string     `json:"symbol"`
+	Interval    int64      `json:"interval"`
+	LastAttempt time.Time  `json:"last_attempt"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	NumErrors   int        `json:"num_errors"` // Number of failed attempts since the last success
+}
+
+func NewScrapeState() *ScrapeState {
+	return &ScrapeState{attempts: make(map[scrapeKey]*ScrapeAttempt)}
+}
+
+func (s *ScrapeState) record(coll, symbol string, interval int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := scrapeKey{coll, symbol, interval}
+	attempt, ok := s.attempts[k]
+	if !ok {
+		attempt = &ScrapeAttempt{Coll: coll, Symbol: symbol, Interval: interval}
+		s.attempts[k] = attempt
+	}
+
+	now := time.Now().UTC()
+	attempt.LastAttempt = now
+
+	if err != nil {
+		attempt.LastError = err.Error()
+		attempt.LastErrorAt = &now
+		attempt.NumErrors++
+		return
+	}
+
+	attempt.LastSuccess = &now
+	attempt.NumErrors = 0
+}
+
+// Attempts returns the attempts of the symbol in the collection, sorted by
+// the interval.
+func (s *ScrapeState) Attempts(coll, symbol string) []ScrapeAttempt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []ScrapeAttempt
+	for k, attempt := range s.attempts {
+		if k.coll == coll && k.symbol == symbol {
+			attempts = append(attempts, *attempt)
+		}
+	}
+
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].Interval < attempts[j].Interval })
+	return attempts
+}
@@ -0,0 +1,295 @@
+package retention_service
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/lib/timeset"
+	"fmt"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+type service struct {
+	app    *core.App
+	rules  []core.RetentionRule
+	logger syro.Logger
+}
+
+func New(app *core.App, rules []core.RetentionRule) *service {
+	return &service{
+		app:    app,
+		rules:  rules,
+		logger: app.Logger().WithEvent("retention"),
+	}
+}
+
+// policy is a validated retention rule, with the resolved store and intervals.
+type policy struct {
+	store        market_dto.OhlcStore
+	interval     int64 // ms
+	downsampleTo int64 // ms, 0 if the rows are not downsampled
+	keep         time.Duration
+	ttl          bool
+}
+
+func (p policy) String() string {
+	return fmt.Sprintf("%v:%v", p.store.Name(), p.interval)
+}
+
+// AddJobs creates the TTL indexes for the ttl rules and registers the job
+// which deletes the expired rows of the other rules.
+func (s *service) AddJobs(sched *syro.CronScheduler) error {
+	if len(s.rules) == 0 {
+		return nil
+	}
+
+	policies, err := newPolicies(s.rules, s.app.Stores().OhlcStores())
+	if err != nil {
+		return err
+	}
+
+	var expired []policy
+	for _, p := range policies {
+		mongoStore, ok := market_dto.UnwrapOhlcStore(p.store).(*market_dto.MongoOhlcStore)
+		if !p.ttl || !ok {
+			expired = append(expired, p)
+			continue
+		}
+
+		if err := createTTLIndex(mongoStore, p); err != nil {
+			return fmt.Errorf("failed to create ttl index for %v: %v", p, err)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	return sched.Register(
+		&syro.Job{
+			Name:        "ohlc-retention",
+			Schedule:    "@every 1h",
+			Description: "delete the ohlc rows which are older than the retention period",
+			Func: func() error {
+				for _, p := range expired {
+					if err := s.expire(p, time.Now()); err != nil {
+						s.logger.Error(err.Error())
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	)
+}
+
+// newPolicies validates the rules and matches them to the stores by name.
+func newPolicies(rules []core.RetentionRule, stores []market_dto.OhlcStore) ([]policy, error) {
+	var policies []policy
+
+	for _, rule := range rules {
+		var store market_dto.OhlcStore
+		for _, s := range stores {
+			if s.Name() == rule.Collection {
+				store = s
+			}
+		}
+
+		if store == nil {
+			return nil, fmt.Errorf("retention rule for unknown collection: %v", rule.Collection)
+		}
+
+		if rule.KeepDays <= 0 {
+			return nil, fmt.Errorf("retention rule for %v: keep_days must be greater than 0", rule.Collection)
+		}
+
+		interval, err := timeset.ParseInterval(rule.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("retention rule for %v: %v", rule.Collection, err)
+		}
+
+		p := policy{
+			store:    store,
+			interval: interval.Milliseconds(),
+			keep:     time.Duration(rule.KeepDays) * 24 * time.Hour,
+			ttl:      rule.TTL,
+		}
+
+		if rule.DownsampleTo != "" {
+			if rule.TTL {
+				return nil, fmt.Errorf("retention rule for %v: ttl can't be used with downsample_to", rule.Collection)
+			}
+
+			target, err := timeset.ParseInterval(rule.DownsampleTo)
+			if err != nil {
+				return nil, fmt.Errorf("retention rule for %v: %v", rule.Collection, err)
+			}
+
+			p.downsampleTo = target.Milliseconds()
+			if p.downsampleTo <= p.interval || p.downsampleTo%p.interval != 0 {
+				return nil, fmt.Errorf("retention rule for %v: %v is not a multiple of %v", rule.Collection, rule.DownsampleTo, rule.Interval)
+			}
+		}
+
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+// createTTLIndex creates the TTL index on the start time, which only applies
+// to the rows of the policy interval. If the keep_days of the rule changed,
+// the index is created again.
+func createTTLIndex(store *market_dto.MongoOhlcStore, p policy) error {
+	_, err := mongodb.NewIndexes().
+		AddIndex(market_dto.OhlcTTLIndex(p.interval, p.keep)).
+		Sync(store.Coll())
+	return err
+}
+
+// expire deletes the rows of the policy which are older than the retention
+// period. If the policy is downsampled, the rows are only deleted after the
+// rows of the higher interval exist.
+func (s *service) expire(p policy, now time.Time) error {
+	cutoff := now.Add(-p.keep)
+
+	if p.downsampleTo == 0 {
+		deleted, err := p.store.DeleteOhlcRows(market_dto.OhlcFilter{Interval: p.interval, To: cutoff.Add(-time.Millisecond)})
+		if err != nil {
+			return fmt.Errorf("%v: failed to delete expired rows: %v", p, err)
+		}
+
+		s.logger.Info("deleted expired ohlc rows", syro.LogFields{"policy": p.String(), "deleted": deleted})
+		return nil
+	}
+
+	return s.downsampleAndExpire(p, cutoff)
+}
+
+// downsampleAndExpire iterates over the expired rows in windows of at least a day.
+// For each window the missing rows of the higher interval are aggregated from
+// the expired rows, after which the expired rows of the symbols which are
+// fully covered by the higher interval are deleted. Rows of incomplete buckets
+// are kept.
+func (s *service) downsampleAndExpire(p policy, cutoff time.Time) error {
+	// only delete whole buckets of the higher interval
+	cutoff = market_dto.BucketStart(cutoff, p.downsampleTo)
+
+	first, err := p.store.GetOhlcRows(market_dto.OhlcFilter{Interval: p.interval, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(first) == 0 || !first[0].StartTime.Before(cutoff) {
+		return nil
+	}
+
+	window := time.Duration(p.downsampleTo) * time.Millisecond
+	for window < 24*time.Hour {
+		window += time.Duration(p.downsampleTo) * time.Millisecond
+	}
+
+	var deleted, aggregated int64
+
+	for from := market_dto.BucketStart(first[0].StartTime, p.downsampleTo); from.Before(cutoff); from = from.Add(window) {
+		to := from.Add(window)
+		if to.After(cutoff) {
+			to = cutoff
+		}
+
+		numAggregated, numDeleted, err := s.downsampleWindow(p, from, to.Add(-time.Millisecond))
+		if err != nil {
+			return fmt.Errorf("%v: failed to downsample %v -> %v: %v", p, from, to, err)
+		}
+
+		aggregated += numAggregated
+		deleted += numDeleted
+	}
+
+	s.logger.Info("downsampled and deleted expired ohlc rows", syro.LogFields{
+		"policy":     p.String(),
+		"aggregated": aggregated,
+		"deleted":    deleted,
+	})
+
+	return nil
+}
+
+func (s *service) downsampleWindow(p policy, from, to time.Time) (int64, int64, error) {
+	rows, err := p.store.GetOhlcRows(market_dto.OhlcFilter{Interval: p.interval, From: from, To: to})
+	if err != nil || len(rows) == 0 {
+		return 0, 0, err
+	}
+
+	existing, err := p.store.GetOhlcRows(market_dto.OhlcFilter{Interval: p.downsampleTo, From: from, To: to})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type bucketKey struct {
+		symbol string
+		start  int64
+	}
+
+	covered := make(map[bucketKey]bool)
+	for _, row := range existing {
+		covered[bucketKey{row.Symbol, row.StartTime.UnixMilli()}] = true
+	}
+
+	candidates, err := market_dto.AggregateOhlcRows(rows, p.downsampleTo)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var missing []market_dto.OhlcRow
+	for _, row := range candidates {
+		k := bucketKey{row.Symbol, row.StartTime.UnixMilli()}
+		if !covered[k] {
+			missing = append(missing, row)
+			covered[k] = true
+		}
+	}
+
+	if len(missing) > 0 {
+		if _, err := p.store.UpsertOhlcRows(missing); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	incomplete := make(map[string]bool)
+	var symbols []string
+	for _, row := range rows {
+		if _, ok := incomplete[row.Symbol]; !ok {
+			symbols = append(symbols, row.Symbol)
+			incomplete[row.Symbol] = false
+		}
+
+		start := market_dto.BucketStart(row.StartTime, p.downsampleTo).UnixMilli()
+		if !covered[bucketKey{row.Symbol, start}] {
+			incomplete[row.Symbol] = true
+		}
+	}
+
+	var deleted int64
+	for _, symbol := range symbols {
+		if incomplete[symbol] {
+			s.logger.Warn("keeping expired ohlc rows of incomplete buckets", syro.LogFields{
+				"policy": p.String(),
+				"symbol": symbol,
+				"from":   from,
+				"to":     to,
+			})
+			continue
+		}
+
+		n, err := p.store.DeleteOhlcRows(market_dto.OhlcFilter{Symbol: symbol, Interval: p.interval, From: from, To: to})
+		if err != nil {
+			return 0, 0, err
+		}
+		deleted += n
+	}
+
+	return int64(len(missing)), deleted, nil
+}
@@ -0,0 +1,122 @@
+package retention_service
+
+import (
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/market_dto"
+	"testing"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+func TestRetention(t *testing.T) {
+	const minute = int64(time.Minute / time.Millisecond)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	newStore := func(t *testing.T, symbols ...string) market_dto.OhlcStore {
+		store := market_dto.NewMemoryOhlcStore("crypto_spot_ohlc")
+
+		// 2 days of 1m rows, 1 of which is older than the retention period
+		var rows []market_dto.OhlcRow
+		for _, symbol := range symbols {
+			for start := now.AddDate(0, 0, -2); start.Before(now); start = start.Add(time.Minute) {
+				row, err := market_dto.NewOhlcRow(symbol, start, start.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rows = append(rows, *row)
+			}
+		}
+
+		if _, err := store.UpsertOhlcRows(rows); err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	s := &service{logger: syro.NewConsoleLogger(nil)}
+
+	t.Run("invalid rules", func(t *testing.T) {
+		stores := []market_dto.OhlcStore{newStore(t, "BTCUSDT")}
+
+		invalid := []core.RetentionRule{
+			{Collection: "unknown", Interval: "1m", KeepDays: 1},
+			{Collection: "crypto_spot_ohlc", Interval: "1m", KeepDays: 0},
+			{Collection: "crypto_spot_ohlc", Interval: "1m", KeepDays: 1, DownsampleTo: "15m", TTL: true},
+			{Collection: "crypto_spot_ohlc", Interval: "15m", KeepDays: 1, DownsampleTo: "20m"},
+		}
+
+		for _, rule := range invalid {
+			if _, err := newPolicies([]core.RetentionRule{rule}, stores); err == nil {
+				t.Fatalf("expected an error for %+v", rule)
+			}
+		}
+	})
+
+	t.Run("delete expired rows", func(t *testing.T) {
+		store := newStore(t, "BTCUSDT")
+
+		policies, err := newPolicies([]core.RetentionRule{{Collection: "crypto_spot_ohlc", Interval: "1m", KeepDays: 1}}, []market_dto.OhlcStore{store})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.expire(policies[0], now); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, _ := store.GetOhlcRows(market_dto.OhlcFilter{Interval: minute})
+		if len(rows) != 24*60 || !rows[0].StartTime.Equal(now.AddDate(0, 0, -1)) {
+			t.Fatalf("unexpected rows after expiry: %d", len(rows))
+		}
+	})
+
+	t.Run("downsample before expire", func(t *testing.T) {
+		store := newStore(t, "BTCUSDT", "ETHUSDT")
+
+		// remove a single row of the expired range, so that its bucket can't be aggregated
+		gap := now.AddDate(0, 0, -2).Add(time.Hour)
+		if _, err := store.DeleteOhlcRows(market_dto.OhlcFilter{Symbol: "ETHUSDT", From: gap, To: gap}); err != nil {
+			t.Fatal(err)
+		}
+
+		rule := core.RetentionRule{Collection: "crypto_spot_ohlc", Interval: "1m", KeepDays: 1, DownsampleTo: "15m"}
+		policies, err := newPolicies([]core.RetentionRule{rule}, []market_dto.OhlcStore{store})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.expire(policies[0], now); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+			downsampled, _ := store.GetOhlcRows(market_dto.OhlcFilter{Symbol: symbol, Interval: 15 * minute})
+			expected := 24 * 4
+			if symbol == "ETHUSDT" {
+				expected--
+			}
+
+			if len(downsampled) != expected {
+				t.Fatalf("%v: expected %d downsampled rows, got %d", symbol, expected, len(downsampled))
+			}
+
+			if downsampled[0].Volume != 15 || downsampled[0].High != 2 {
+				t.Fatalf("%v: unexpected downsampled row: %+v", symbol, downsampled[0])
+			}
+		}
+
+		btc, _ := store.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT", Interval: minute})
+		if len(btc) != 24*60 {
+			t.Fatalf("expected the expired BTCUSDT rows to be deleted, got %d rows", len(btc))
+		}
+
+		// the rows of the incomplete day are kept
+		eth, _ := store.GetOhlcRows(market_dto.OhlcFilter{Symbol: "ETHUSDT", Interval: minute})
+		if len(eth) != 2*24*60-1 {
+			t.Fatalf("expected the expired ETHUSDT rows to be kept, got %d rows", len(eth))
+		}
+	})
+}
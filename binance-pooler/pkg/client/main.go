@@ -0,0 +1,235 @@
+// Package client queries the http api of the pooler. The methods mirror
+// the query endpoints of the OpenAPI document, which is served at
+// /openapi.json, and return the types of the market_dto package.
+package client
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseUrl string
+	apiKey  string
+	http    *http.Client
+}
+
+// New returns a client of the api served at the base url (e.g. http://localhost:4444).
+func New(baseUrl string) Client {
+	return Client{baseUrl: strings.TrimSuffix(baseUrl, "/"), http: &http.Client{Timeout: time.Minute}}
+}
+
+// WithApiKey sends the key with each request, which is required if the auth
+// of the api is enabled.
+func (c Client) WithApiKey(key string) Client {
+	c.apiKey = key
+	return c
+}
+
+// WithHttpClient sends the requests with the http client (e.g. to change
+// the timeout or the transport).
+func (c Client) WithHttpClient(hc *http.Client) Client {
+	c.http = hc
+	return c
+}
+
+// Error is returned if the api responds with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pooler api error %v: %v", e.StatusCode, e.Message)
+}
+
+// get requests the path with the query and decodes the json body into the res.
+func (c Client) get(path string, query url.Values, res any) error {
+	u := c.baseUrl + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %v: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("failed to decode the response of %v: %v", path, err)
+	}
+
+	return nil
+}
+
+// OhlcQuery holds the params of the ohlc requests. Zero values are ignored.
+type OhlcQuery struct {
+	Market   string    // spot (default) or futures
+	Symbol   string    // Required
+	Interval string    // Required, e.g. 1m, 15m, 4h, 1d, 1w, 1M
+	From     time.Time // Inclusive
+	To       time.Time // Inclusive
+	Limit    int       // Max number of rows of a page, the api defaults to 1000
+	Partial  bool      // Include the incomplete trailing bucket of the resampled rows
+}
+
+func (q OhlcQuery) values() url.Values {
+	v := url.Values{}
+	v.Set("symbol", q.Symbol)
+	v.Set("interval", q.Interval)
+
+	if q.Market != "" {
+		v.Set("market", q.Market)
+	}
+	if !q.From.IsZero() {
+		v.Set("from", strconv.FormatInt(q.From.UnixMilli(), 10))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", strconv.FormatInt(q.To.UnixMilli(), 10))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Partial {
+		v.Set("partial", "true")
+	}
+
+	return v
+}
+
+// OhlcPage is a page of the ohlc rows. The next page is requested with the
+// NextCursor, which is empty on the last page.
+type OhlcPage struct {
+	Data       []market_dto.OhlcRow `json:"data"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// Ohlc returns the page of the rows which starts at the cursor, or at the
+// start of the query if the cursor is empty.
+//
+//	GET /ohlc
+func (c Client) Ohlc(q OhlcQuery, cursor string) (*OhlcPage, error) {
+	v := q.values()
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
+
+	var page OhlcPage
+	if err := c.get("/ohlc", v, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// OhlcRange returns all of the rows of the query, requesting the pages
+// until the last one.
+func (c Client) OhlcRange(q OhlcQuery) ([]market_dto.OhlcRow, error) {
+	var docs []market_dto.OhlcRow
+
+	cursor := ""
+	for {
+		page, err := c.Ohlc(q, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, page.Data...)
+
+		if page.NextCursor == "" {
+			return docs, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// AssetQuery holds the filters of the assets. Zero values are ignored.
+type AssetQuery struct {
+	Market       string // spot (default) or futures
+	Status       string
+	Base         string
+	Quote        string
+	ContractType string   // Contract type of the futures (e.g. PERPETUAL)
+	OrderTypes   []string // Assets which support all of the order types
+	Search       string   // Case insensitive part of the symbol
+}
+
+// Assets returns the assets which match the query.
+//
+//	GET /assets
+func (c Client) Assets(q AssetQuery) ([]market_dto.AssetBase, error) {
+	v := url.Values{}
+	for key, val := range map[string]string{
+		"market":        q.Market,
+		"status":        q.Status,
+		"base":          q.Base,
+		"quote":         q.Quote,
+		"contract_type": q.ContractType,
+		"order_types":   strings.Join(q.OrderTypes, ","),
+		"q":             q.Search,
+	} {
+		if val != "" {
+			v.Set(key, val)
+		}
+	}
+
+	var res struct {
+		Data []market_dto.AssetBase `json:"data"`
+	}
+
+	if err := c.get("/assets", v, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// Asset holds the stored asset info, the stored time range of each
+// interval and the latest row of the finest scraped interval.
+type Asset struct {
+	market_dto.AssetBase
+	Data     json.RawMessage           `json:"data"` // Market specific data of the asset
+	Coverage []market_dto.OhlcCoverage `json:"coverage"`
+	Latest   *market_dto.OhlcRow       `json:"latest"`
+}
+
+// Asset returns the asset of the market (spot if empty).
+//
+//	GET /assets/{symbol}
+func (c Client) Asset(market, symbol string) (*Asset, error) {
+	v := url.Values{}
+	if market != "" {
+		v.Set("market", market)
+	}
+
+	var res Asset
+	if err := c.get("/assets/"+url.PathEscape(symbol), v, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"binance-pooler/internal/api"
+	"binance-pooler/pkg/core"
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fiberTransport serves the requests of the client with the api in the
+// same process, without a listener.
+type fiberTransport struct {
+	api   *fiber.App
+	paths []string // paths of the requests
+}
+
+func (t *fiberTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.paths = append(t.paths, req.URL.Path)
+	return t.api.Test(req, -1)
+}
+
+func TestClient(t *testing.T) {
+	app := core.NewMemoryApp(nil)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var rows []market_dto.OhlcRow
+	for i := 0; i < 60; i++ {
+		t1 := start.Add(time.Duration(i) * time.Minute)
+		row, err := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, 1.5, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, *row)
+	}
+
+	if _, err := app.Stores().CryptoSpotOhlc.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	assets := []market_dto.SpotAsset{
+		{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT", OrderTypes: []string{"LIMIT"}},
+			Data: market_dto.SpotAssetData{QuotePrecision: 8}},
+		{AssetBase: market_dto.AssetBase{Source: "binance", Symbol: "ETHBTC", Status: "TRADING", BaseAsset: "ETH", QuoteAsset: "BTC"}},
+	}
+
+	if _, err := app.Stores().CryptoSpotAsset.UpsertAssets(market_dto.AnyAssets(assets)); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &fiberTransport{api: api.New(app)}
+	client := New("http://pooler").WithHttpClient(&http.Client{Transport: transport})
+
+	t.Run("ohlc", func(t *testing.T) {
+		q := OhlcQuery{Symbol: "BTCUSDT", Interval: "1m", From: start.Add(10 * time.Minute), Limit: 20}
+
+		page, err := client.Ohlc(q, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Data) != 20 || !page.Data[0].StartTime.Equal(q.From) || page.NextCursor == "" {
+			t.Fatalf("unexpected page: %v rows, cursor %q", len(page.Data), page.NextCursor)
+		}
+
+		docs, err := client.OhlcRange(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 50 || !docs[49].StartTime.Equal(start.Add(59*time.Minute)) {
+			t.Fatalf("unexpected rows: %v", len(docs))
+		}
+
+		docs, err = client.OhlcRange(OhlcQuery{Symbol: "BTCUSDT", Interval: "15m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 4 || docs[0].Interval != (15*time.Minute).Milliseconds() || docs[0].Close != 1.5 {
+			t.Fatalf("unexpected resampled rows: %+v", docs)
+		}
+	})
+
+	t.Run("assets", func(t *testing.T) {
+		docs, err := client.Assets(AssetQuery{Quote: "usdt", OrderTypes: []string{"LIMIT"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 1 || docs[0].Symbol != "BTCUSDT" {
+			t.Fatalf("unexpected assets: %+v", docs)
+		}
+
+		asset, err := client.Asset("", "btcusdt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if asset.Symbol != "BTCUSDT" || len(asset.Coverage) != 1 || asset.Coverage[0].Count != 60 || asset.Latest == nil || len(asset.Data) == 0 {
+			t.Fatalf("unexpected asset: %+v", asset)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := client.Asset("", "MISSING")
+
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound || e.Message == "" {
+			t.Fatalf("expected a 404 error, got %v", err)
+		}
+
+		if _, err := client.Ohlc(OhlcQuery{Symbol: "BTCUSDT"}, ""); !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected a 400 error, got %v", err)
+		}
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		app.Conf().Api.Auth = true
+		defer func() { app.Conf().Api.Auth = false }()
+
+		key, secret, err := auth_dto.NewApiKey("client", []string{auth_dto.ScopeRead}, auth_dto.Quota{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.Stores().ApiKeys.InsertKey(*key); err != nil {
+			t.Fatal(err)
+		}
+
+		var e *Error
+		if _, err := client.Assets(AssetQuery{}); !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected a 401 error, got %v", err)
+		}
+
+		if _, err := client.WithApiKey(secret).Assets(AssetQuery{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// the requested paths are documented in the OpenAPI document of the api
+	doc := api.NewOpenApi()
+	param := regexp.MustCompile(`\{\w+\}`)
+
+	for _, path := range transport.paths {
+		found := false
+		for p := range doc.Paths {
+			if regexp.MustCompile("^" + param.ReplaceAllString(p, `[^/]+`) + "$").MatchString(path) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Fatalf("path %v is not in the OpenAPI document", path)
+		}
+	}
+}
+
+// recordingTransport records the requests and responds with an empty object.
+type recordingTransport struct {
+	reqs []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.reqs = append(t.reqs, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+// TestClientOpenApi checks that the requests of every client method, with
+// all of the params set, match an operation of the OpenAPI document.
+func TestClientOpenApi(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ohlc := OhlcQuery{Market: "spot", Symbol: "BTCUSDT", Interval: "1h", From: t1, To: t1.Add(time.Hour), Limit: 10, Partial: true}
+	assets := AssetQuery{Market: "futures", Status: "TRADING", Base: "BTC", Quote: "USDT", ContractType: "PERPETUAL", OrderTypes: []string{"LIMIT"}, Search: "btc"}
+
+	calls := map[string]func(c Client) error{
+		"Ohlc":      func(c Client) error { _, err := c.Ohlc(ohlc, "cursor"); return err },
+		"OhlcRange": func(c Client) error { _, err := c.OhlcRange(ohlc); return err },
+		"Assets":    func(c Client) error { _, err := c.Assets(assets); return err },
+		"Asset":     func(c Client) error { _, err := c.Asset("spot", "BTCUSDT"); return err },
+	}
+
+	typ := reflect.TypeOf(Client{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		if name := typ.Method(i).Name; !strings.HasPrefix(name, "With") && calls[name] == nil {
+			t.Fatalf("the requests of the %v method are not checked", name)
+		}
+	}
+
+	doc := api.NewOpenApi()
+	param := regexp.MustCompile(`\{\w+\}`)
+
+	for name, call := range calls {
+		transport := &recordingTransport{}
+		if err := call(New("http://pooler").WithHttpClient(&http.Client{Transport: transport})); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		if len(transport.reqs) == 0 {
+			t.Fatalf("%v: no requests", name)
+		}
+
+		for _, req := range transport.reqs {
+			var op *api.Operation
+			for p, ops := range doc.Paths {
+				if regexp.MustCompile("^" + param.ReplaceAllString(p, `[^/]+`) + "$").MatchString(req.URL.Path) {
+					op = ops[strings.ToLower(req.Method)]
+				}
+			}
+
+			if op == nil {
+				t.Fatalf("%v: %v %v is not in the OpenAPI document", name, req.Method, req.URL.Path)
+			}
+
+			query := req.URL.Query()
+			documented := make(map[string]bool)
+
+			for _, p := range op.Parameters {
+				if p.In != "query" {
+					continue
+				}
+				documented[p.Name] = true
+
+				if p.Required && !query.Has(p.Name) {
+					t.Fatalf("%v: required param %v of %v is not sent", name, p.Name, op.OperationId)
+				}
+			}
+
+			for key := range query {
+				if !documented[key] {
+					t.Fatalf("%v: param %v is not documented for %v", name, key, op.OperationId)
+				}
+			}
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package core
 
 import (
+	"binance-pooler/pkg/dto/market_dto"
 	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/lib/rawlake"
+	"binance-pooler/pkg/lib/sqlite"
 	"context"
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/tompston/syro"
@@ -13,20 +18,37 @@ import (
 type App struct {
 	conf        *TomlConfig
 	db          *Db
+	sqlite      *sql.DB
+	stores      *Stores
 	cronStorage syro.CronStorage
 	logger      syro.Logger
+	rawLake     rawlake.Store
+	ohlcFeed    *market_dto.OhlcFeed
 }
 
+func (a *App) Conf() *TomlConfig             { return a.conf }
 func (a *App) Db() *Db                       { return a.db }
+func (a *App) Stores() *Stores               { return a.stores }
 func (a *App) CronStorage() syro.CronStorage { return a.cronStorage }
 func (a *App) Logger() syro.Logger           { return a.logger }
 
+// RawLake returns the store of the raw api responses. It's nil if the lake
+// is disabled in the config.
+func (a *App) RawLake() rawlake.Store { return a.rawLake }
+
+// OhlcFeed returns the feed to which the rows written by the app are published.
+func (a *App) OhlcFeed() *market_dto.OhlcFeed { return a.ohlcFeed }
+
 var Environment = &Env{
 	DefaultConfigPath: "./conf/config.dev.toml",
 	ConfigPathKey:     "GO_CONF_PATH",
 	TestModeKey:       "GO_TEST_MODE",
 	UseTestDbKey:      "GO_USE_TEST_DB",
 	IsProductionKey:   "GO_IS_PRODUCTION",
+	DryRunKey:         "GO_DRY_RUN",
+	MongoUriKey:       "GO_MONGO_URI",
+	MongoUsernameKey:  "GO_MONGO_USERNAME",
+	MongoPasswordKey:  "GO_MONGO_PASSWORD",
 }
 
 // New returns a new App struct with the specified configuration. If the
@@ -44,23 +66,40 @@ func NewApp(ctx context.Context, testing ...bool) (*App, error) {
 		return nil, fmt.Errorf("failed to load config file: %v", err)
 	}
 
-	name := "syro"
+	useTestDb := len(testing) == 1 && testing[0] || Environment.ShouldUseTestDb()
 
-	if len(testing) == 1 && testing[0] || Environment.ShouldUseTestDb() {
-		name = "test"
+	switch conf.StorageBackend() {
+	case StorageMongo:
+	case StorageSqlite:
+		return newSqliteApp(conf, useTestDb)
+	default:
+		return nil, fmt.Errorf("unsupported storage in config: %v", conf.Storage)
 	}
 
-	db, err := NewDb(conf.MongoUri, name)
+	db, err := NewMongoDb(conf, useTestDb)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to mongodb: %v", err)
+		return nil, err
 	}
 
 	dbName := db.DbName
 
 	fmt.Printf(" * using db: %v\n", dbName)
 
-	if err := SetupMongoIndexes(db); err != nil {
-		return nil, fmt.Errorf("failed to setup mongodb environment: %v", err)
+	stores := NewMongoStores(db, conf.Mongo.BulkInsert())
+
+	// the dry runs don't modify the stored rows. The markets of the rows are
+	// set before the indexes, as the unique index of the rows includes them.
+	if Environment.IsDryRun() {
+		fmt.Printf(" * dry run, market data is stored in memory\n")
+		stores = NewMemoryStores()
+	} else {
+		if err := MigrateOhlcMarkets(stores); err != nil {
+			return nil, fmt.Errorf("failed to migrate the ohlc collections: %v", err)
+		}
+
+		if err := SetupMongoIndexes(db); err != nil {
+			return nil, fmt.Errorf("failed to setup mongodb environment: %v", err)
+		}
 	}
 
 	cronStorage, err := syro.NewMongoCronStorage(
@@ -74,20 +113,129 @@ func NewApp(ctx context.Context, testing ...bool) (*App, error) {
 		return nil, fmt.Errorf("failed to create indexes for logs collection: %v", err)
 	}
 
+	var lake rawlake.Store
+	switch conf.RawLake.Backend {
+	case "":
+	case RawLakeDir:
+		lake, err = rawlake.NewDirStore(conf.RawLake.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the raw lake: %v", err)
+		}
+	case RawLakeGridfs:
+		lake, err = rawlake.NewGridfsStore(db.Conn().Database(dbName), "raw_lake")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the raw lake: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported raw lake backend in config: %v", conf.RawLake.Backend)
+	}
+
+	feed := market_dto.NewOhlcFeed()
+
 	return &App{
 		conf:        conf,
 		db:          db,
+		stores:      stores.WithFeed(feed),
 		logger:      logger,
 		cronStorage: cronStorage,
+		rawLake:     lake,
+		ohlcFeed:    feed,
 	}, nil
 }
 
-// Exit closes the MongoDB connection. It should be called with the
+// newSqliteApp returns an App which stores the market data in a sqlite database,
+// so that it can run without a mongodb instance. The logs are written to the
+// console and the status of the cron jobs is not stored. In test mode the
+// database file is prefixed with "test_".
+func newSqliteApp(conf *TomlConfig, useTestDb bool) (*App, error) {
+	path := conf.Sqlite.Path
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path is not set in the config")
+	}
+
+	if useTestDb {
+		path = filepath.Join(filepath.Dir(path), "test_"+filepath.Base(path))
+	}
+
+	conn, err := sqlite.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite db: %v", err)
+	}
+
+	fmt.Printf(" * using sqlite db: %v\n", path)
+
+	stores, err := NewSqliteStores(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to setup sqlite tables: %v", err)
+	}
+
+	if Environment.IsDryRun() {
+		fmt.Printf(" * dry run, market data is stored in memory\n")
+		stores = NewMemoryStores()
+	} else if err := MigrateOhlcMarkets(stores); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to migrate the sqlite tables: %v", err)
+	}
+
+	var lake rawlake.Store
+	switch conf.RawLake.Backend {
+	case "":
+	case RawLakeDir:
+		lake, err = rawlake.NewDirStore(conf.RawLake.Dir)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create the raw lake: %v", err)
+		}
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("raw lake backend %v is not supported with sqlite storage", conf.RawLake.Backend)
+	}
+
+	feed := market_dto.NewOhlcFeed()
+
+	return &App{
+		conf:     conf,
+		sqlite:   conn,
+		stores:   stores.WithFeed(feed),
+		logger:   syro.NewConsoleLogger(nil),
+		rawLake:  lake,
+		ohlcFeed: feed,
+	}, nil
+}
+
+// NewMemoryApp returns an App which keeps all of the data in memory and
+// writes the logs to the console. Used in tests which don't need a database.
+func NewMemoryApp(conf *TomlConfig) *App {
+	if conf == nil {
+		conf = &TomlConfig{}
+	}
+
+	feed := market_dto.NewOhlcFeed()
+
+	return &App{
+		conf:     conf,
+		stores:   NewMemoryStores().WithFeed(feed),
+		logger:   syro.NewConsoleLogger(nil),
+		ohlcFeed: feed,
+	}
+}
+
+// Exit closes the database connections. It should be called with the
 // defer keyword after the app.New function is called.
 func (app *App) Exit(ctx context.Context) error {
-	if err := app.Db().Conn().Disconnect(ctx); err != nil {
-		return fmt.Errorf("failed to close mongodb conn: %v", err)
+	if app.db != nil {
+		if err := app.db.Conn().Disconnect(ctx); err != nil {
+			return fmt.Errorf("failed to close mongodb conn: %v", err)
+		}
+	}
+
+	if app.sqlite != nil {
+		if err := app.sqlite.Close(); err != nil {
+			return fmt.Errorf("failed to close sqlite conn: %v", err)
+		}
 	}
+
 	return nil
 }
 
@@ -19,7 +19,7 @@ type Db struct {
 func (m *Db) Conn() *mongo.Client { return m.conn }
 
 // NewDb returns a new Db struct with the connection to the database and the db schema
-func NewDb(uri, dbName string) (*Db, error) {
+func NewDb(uri, dbName string, settings ...mongodb.ConnSettings) (*Db, error) {
 
 	if uri == "" {
 		return nil, fmt.Errorf("uri for the database is empty")
@@ -37,7 +37,7 @@ func NewDb(uri, dbName string) (*Db, error) {
 		return nil, err
 	}
 
-	conn, err := mongodb.New(uri)
+	conn, err := mongodb.New(uri, settings...)
 	if err != nil {
 		return nil, err
 	}
@@ -45,11 +45,36 @@ func NewDb(uri, dbName string) (*Db, error) {
 	return &Db{conn, colls, dbName}, nil
 }
 
+// NewMongoDb connects to the database of the config, without setting up
+// the indexes or the stores of the app. In test mode the "test" database
+// is used.
+func NewMongoDb(conf *TomlConfig, useTestDb bool) (*Db, error) {
+	name := conf.MongoDatabase()
+	if useTestDb {
+		name = "test"
+	}
+
+	uri, settings := conf.MongoConn()
+
+	db, err := NewDb(uri, name, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mongodb: %v", err)
+	}
+	return db, nil
+}
+
 type Collections struct {
 	CryptoSpotAsset,
 	CryptoSpotOhlc,
 	CryptoFuturesAsset,
 	CryptoFuturesOhlc,
+	CryptoSpotOhlcDerived,
+	CryptoFuturesOhlcDerived,
+	OhlcQuarantine,
+	OhlcDrift,
+	OhlcRevisions,
+	ApiKeys,
+	ApiAudit,
 	Logs string
 }
 
@@ -61,7 +86,15 @@ func NewCollections(name string) *Collections {
 		CryptoSpotOhlc:     "crypto_spot_ohlc",
 		CryptoFuturesAsset: "crypto_futures_asset",
 		CryptoFuturesOhlc:  "crypto_futures_ohlc",
-		Logs:               "logs",
+		// Higher timeframes, which are aggregated from the 1m rows
+		CryptoSpotOhlcDerived:    "crypto_spot_ohlc_derived",
+		CryptoFuturesOhlcDerived: "crypto_futures_ohlc_derived",
+		OhlcQuarantine:           "ohlc_quarantine", // Rows which failed the quality checks
+		OhlcDrift:                "ohlc_drift",      // Reports of the reconciliation runs
+		OhlcRevisions:            "ohlc_revisions",  // Replaced versions of the ohlc rows
+		ApiKeys:                  "api_keys",        // Hashed keys of the http api
+		ApiAudit:                 "api_audit",       // Requests to the http api
+		Logs:                     "logs",
 	}
 }
 
@@ -86,6 +119,34 @@ func (m *Db) CryptoFuturesOhlcColl() *mongo.Collection {
 	return m.Conn().Database(m.DbName).Collection(m.collections.CryptoFuturesOhlc)
 }
 
+func (m *Db) CryptoSpotOhlcDerivedColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.CryptoSpotOhlcDerived)
+}
+
+func (m *Db) CryptoFuturesOhlcDerivedColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.CryptoFuturesOhlcDerived)
+}
+
+func (m *Db) OhlcQuarantineColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.OhlcQuarantine)
+}
+
+func (m *Db) OhlcDriftColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.OhlcDrift)
+}
+
+func (m *Db) OhlcRevisionsColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.OhlcRevisions)
+}
+
+func (m *Db) ApiKeysColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.ApiKeys)
+}
+
+func (m *Db) ApiAuditColl() *mongo.Collection {
+	return m.Conn().Database(m.DbName).Collection(m.collections.ApiAudit)
+}
+
 // Collection to which all logs are written
 func (m *Db) LogsCollection() *mongo.Collection {
 	return m.coll(m.DbName, m.collections.Logs)
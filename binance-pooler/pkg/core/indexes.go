@@ -1,8 +1,12 @@
 package core
 
 import (
+	"binance-pooler/pkg/dto/auth_dto"
 	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/lib/timeset"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -22,6 +26,8 @@ func SetupMongoIndexes(db *Db) error {
 	olhcColls := []*mongo.Collection{
 		db.CryptoSpotOhlcColl(),
 		db.CryptoFuturesOhlcColl(),
+		db.CryptoSpotOhlcDerivedColl(),
+		db.CryptoFuturesOhlcDerivedColl(),
 	}
 
 	for _, coll := range olhcColls {
@@ -30,5 +36,93 @@ func SetupMongoIndexes(db *Db) error {
 		}
 	}
 
+	if err := market_dto.CreateQuarantineIndexes(db.OhlcQuarantineColl()); err != nil {
+		return fmt.Errorf("failed to create indexes for %v: %v", db.OhlcQuarantineColl().Name(), err)
+	}
+
+	if err := market_dto.CreateDriftIndexes(db.OhlcDriftColl()); err != nil {
+		return fmt.Errorf("failed to create indexes for %v: %v", db.OhlcDriftColl().Name(), err)
+	}
+
+	if err := market_dto.CreateRevisionIndexes(db.OhlcRevisionsColl()); err != nil {
+		return fmt.Errorf("failed to create indexes for %v: %v", db.OhlcRevisionsColl().Name(), err)
+	}
+
+	if err := auth_dto.CreateKeyIndexes(db.ApiKeysColl()); err != nil {
+		return fmt.Errorf("failed to create indexes for %v: %v", db.ApiKeysColl().Name(), err)
+	}
+
+	if err := auth_dto.CreateAuditIndexes(db.ApiAuditColl()); err != nil {
+		return fmt.Errorf("failed to create indexes for %v: %v", db.ApiAuditColl().Name(), err)
+	}
+
 	return nil
 }
+
+// SyncMongoIndexes compares the indexes of the market data collections with
+// the ones defined in the market_dto package and applies the difference.
+// The TTL indexes of the retention rules are a part of the ohlc indexes, so
+// that they are not dropped by the DropUnknown setting.
+func SyncMongoIndexes(db *Db, retention []RetentionRule, settings mongodb.SyncSettings) ([]mongodb.SyncReport, error) {
+	ohlcIndexes := func(coll *mongo.Collection) (*mongodb.IndexBuilder, error) {
+		indexes := market_dto.OhlcIndexes()
+
+		for _, rule := range retention {
+			if !rule.TTL || rule.Collection != coll.Name() {
+				continue
+			}
+
+			interval, err := timeset.ParseInterval(rule.Interval)
+			if err != nil {
+				return nil, fmt.Errorf("retention rule for %v: %v", rule.Collection, err)
+			}
+			indexes.AddIndex(market_dto.OhlcTTLIndex(interval.Milliseconds(), time.Duration(rule.KeepDays)*24*time.Hour))
+		}
+
+		return indexes, nil
+	}
+
+	colls := []struct {
+		coll    *mongo.Collection
+		indexes *mongodb.IndexBuilder
+		ohlc    bool
+	}{
+		{db.CryptoSpotAssetColl(), market_dto.AssetIndexes(), false},
+		{db.CryptoFuturesAssetColl(), market_dto.AssetIndexes(), false},
+		{db.CryptoSpotOhlcColl(), nil, true},
+		{db.CryptoFuturesOhlcColl(), nil, true},
+		{db.CryptoSpotOhlcDerivedColl(), nil, true},
+		{db.CryptoFuturesOhlcDerivedColl(), nil, true},
+		{db.OhlcQuarantineColl(), market_dto.QuarantineIndexes(), false},
+		{db.OhlcDriftColl(), market_dto.DriftIndexes(), false},
+		{db.OhlcRevisionsColl(), market_dto.RevisionIndexes(), false},
+		{db.ApiKeysColl(), auth_dto.KeyIndexes(), false},
+		{db.ApiAuditColl(), auth_dto.AuditIndexes(), false},
+	}
+
+	var reports []mongodb.SyncReport
+	for _, c := range colls {
+		if c.ohlc {
+			indexes, err := ohlcIndexes(c.coll)
+			if err != nil {
+				return nil, err
+			}
+			c.indexes = indexes
+
+			// the unique index of the ohlc rows can't be created with duplicates
+			if !settings.DryRun {
+				if _, err := market_dto.DeleteDuplicateOhlcRows(c.coll); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		report, err := c.indexes.Sync(c.coll, settings)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+
+	return reports, nil
+}
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"binance-pooler/pkg/lib/mongodb"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -14,6 +16,10 @@ type Env struct {
 	TestModeKey       string
 	UseTestDbKey      string
 	IsProductionKey   string
+	DryRunKey         string
+	MongoUriKey       string
+	MongoUsernameKey  string
+	MongoPasswordKey  string
 }
 
 // Return true if the IsProductionKey environment variable is set to "true".
@@ -22,6 +28,11 @@ func (e *Env) IsProduction() bool {
 	return envVar == "true"
 }
 
+// IsDryRun returns true if the DryRunKey environment variable is set to "true".
+func (e *Env) IsDryRun() bool {
+	return GetEnvVar(e.DryRunKey) == "true"
+}
+
 // GetConfigPath returns the path to the config file.
 func (e *Env) GetConfigPath(path ...string) string {
 	if len(path) == 1 {
@@ -52,13 +63,139 @@ func GetEnvVar(key string) string {
 	return ""
 }
 
+// Supported values of the storage key in the config file
+const (
+	StorageMongo  = "mongo"
+	StorageSqlite = "sqlite"
+)
+
 // Structure for the data that is stored in the toml config file
 type TomlConfig struct {
 	Api struct {
-		Host string `toml:"host"`
-		Port int    `toml:"port"`
+		Host         string `toml:"host"`
+		Port         int    `toml:"port"`
+		ChangeStream bool   `toml:"change_stream"` // Push the rows written by other processes to the live subscribers (needs a replica set)
+		Auth         bool   `toml:"auth"`          // Require an api key on each request (see cmd/apikeys)
+		Audit        bool   `toml:"audit"`         // Write each request to the audit log
+		GrpcPort     int    `toml:"grpc_port"`     // Serve the grpc api on this port of the host, 0 disables it
+		DisableHttp  bool   `toml:"disable_http"`  // Only serve the grpc api
 	} `toml:"api"`
-	MongoUri string `toml:"mongo_uri"`
+	MongoUri string      `toml:"mongo_uri"` // Deprecated, use the uri of the [mongo] section
+	Mongo    MongoConfig `toml:"mongo"`
+	Storage  string      `toml:"storage"` // Where the market data is stored, "mongo" (default) or "sqlite"
+	Sqlite   struct {
+		Path string `toml:"path"` // Path to the sqlite database file
+	} `toml:"sqlite"`
+	Retention []RetentionRule `toml:"retention"`
+	Reconcile struct {
+		LookbackDays int     `toml:"lookback_days"` // Number of days which are fetched again. 0 disables the job.
+		AutoCorrect  bool    `toml:"auto_correct"`  // Upsert the fetched rows which differ from the stored ones
+		Tolerance    float64 `toml:"tolerance"`     // Relative tolerance of the compared values
+	} `toml:"reconcile"`
+	BinanceArchive struct {
+		BaseUrl string `toml:"base_url"` // Url of the binance public data mirror
+		Dir     string `toml:"dir"`      // Local directory where the archives are stored
+	} `toml:"binance_archive"`
+	RawLake struct {
+		Backend string `toml:"backend"` // "dir" or "gridfs". Empty disables the lake.
+		Dir     string `toml:"dir"`     // Directory of the lake when the backend is "dir"
+	} `toml:"raw_lake"`
+}
+
+// Supported values of the backend key of the [raw_lake] section
+const (
+	RawLakeDir    = "dir"
+	RawLakeGridfs = "gridfs"
+)
+
+// StorageBackend returns the storage backend defined in the config, defaulting to mongo.
+func (c *TomlConfig) StorageBackend() string {
+	if c.Storage == "" {
+		return StorageMongo
+	}
+	return c.Storage
+}
+
+// DefaultMongoDatabase is the name of the database if it's not set in the config.
+const DefaultMongoDatabase = "syro"
+
+// MongoConfig holds the settings of the [mongo] section. Zero values are
+// ignored. The uri, username and password can be set with the env
+// variables, so that the secrets are not stored in the config file.
+type MongoConfig struct {
+	Uri                    string        `toml:"uri"`
+	Database               string        `toml:"database"`
+	Username               string        `toml:"username"`
+	Password               string        `toml:"password"`
+	MinPoolSize            uint64        `toml:"min_pool_size"`
+	MaxPoolSize            uint64        `toml:"max_pool_size"`
+	MaxConnIdleTime        time.Duration `toml:"max_conn_idle_time"`
+	ConnectTimeout         time.Duration `toml:"connect_timeout"`
+	SocketTimeout          time.Duration `toml:"socket_timeout"`
+	ServerSelectionTimeout time.Duration `toml:"server_selection_timeout"`
+	TlsCaFile              string        `toml:"tls_ca_file"`
+	TlsCertFile            string        `toml:"tls_cert_file"` // PEM file with the client certificate and key
+	ReadPreference         string        `toml:"read_preference"`
+	WriteConcern           string        `toml:"write_concern"`
+	BulkChunkSize          int           `toml:"bulk_chunk_size"`          // Rows of a bulk insert into an empty range (e.g. the initial backfill). Defaults to 1000.
+	BulkRelaxWriteConcern  bool          `toml:"bulk_relax_write_concern"` // Acknowledge the bulk inserts without waiting for the journal
+}
+
+// BulkInsert returns the settings of the bulk inserts of the ohlc rows.
+func (c MongoConfig) BulkInsert() mongodb.BulkInsertSettings {
+	settings := mongodb.BulkInsertSettings{ChunkSize: c.BulkChunkSize, RelaxWriteConcern: c.BulkRelaxWriteConcern}
+	if settings.ChunkSize <= 0 {
+		settings.ChunkSize = mongodb.DefaultBulkInsertSettings.ChunkSize
+	}
+	return settings
+}
+
+// MongoDatabase returns the name of the database defined in the config.
+func (c *TomlConfig) MongoDatabase() string {
+	if c.Mongo.Database == "" {
+		return DefaultMongoDatabase
+	}
+	return c.Mongo.Database
+}
+
+// MongoConn returns the uri and the settings of the mongodb connection,
+// with the values of the env variables taking precedence over the config.
+func (c *TomlConfig) MongoConn() (string, mongodb.ConnSettings) {
+	m := c.Mongo
+
+	uri := m.Uri
+	if uri == "" {
+		uri = c.MongoUri
+	}
+
+	if val := GetEnvVar(Environment.MongoUriKey); val != "" {
+		uri = val
+	}
+
+	settings := mongodb.ConnSettings{
+		Username:               m.Username,
+		Password:               m.Password,
+		MinPoolSize:            m.MinPoolSize,
+		MaxPoolSize:            m.MaxPoolSize,
+		MaxConnIdleTime:        m.MaxConnIdleTime,
+		ConnectTimeout:         m.ConnectTimeout,
+		SocketTimeout:          m.SocketTimeout,
+		ServerSelectionTimeout: m.ServerSelectionTimeout,
+		TlsCaFile:              m.TlsCaFile,
+		TlsCertFile:            m.TlsCertFile,
+		ReadPreference:         m.ReadPreference,
+		WriteConcern:           m.WriteConcern,
+	}
+
+	if val := GetEnvVar(Environment.MongoUsernameKey); val != "" {
+		settings.Username = val
+	}
+
+	if val := GetEnvVar(Environment.MongoPasswordKey); val != "" {
+		settings.Password = val
+	}
+
+	return uri, settings.WithDefaults(uri)
 }
 
 // NewConfig loads a toml config file with the specified path.
@@ -72,3 +209,13 @@ func NewConfig(path string) (*TomlConfig, error) {
 	err = toml.Unmarshal(file, &conf)
 	return &conf, err
 }
+
+// RetentionRule defines for how long the rows of an interval are kept in
+// the ohlc collection. Intervals without a rule are kept forever.
+type RetentionRule struct {
+	Collection   string `toml:"collection"`    // Name of the ohlc collection (e.g. crypto_spot_ohlc)
+	Interval     string `toml:"interval"`      // Interval of the expired rows (e.g. 1m)
+	KeepDays     int    `toml:"keep_days"`     // Rows older than this are deleted
+	DownsampleTo string `toml:"downsample_to"` // Optional. Interval which has to exist (or is aggregated) before the rows are deleted
+	TTL          bool   `toml:"ttl"`           // Expire the rows with a mongodb TTL index. Can't be used with downsample_to.
+}
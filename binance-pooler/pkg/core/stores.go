@@ -0,0 +1,224 @@
+package core
+
+import (
+	"binance-pooler/pkg/dto/auth_dto"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"binance-pooler/pkg/providers/binance"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Stores holds the storage implementations which are used by the
+// services to read and write the market data.
+type Stores struct {
+	CryptoSpotAsset    market_dto.AssetStore
+	CryptoSpotOhlc     market_dto.OhlcStore
+	CryptoFuturesAsset market_dto.AssetStore
+	CryptoFuturesOhlc  market_dto.OhlcStore
+	// Higher timeframes, which are aggregated from the 1m rows
+	CryptoSpotOhlcDerived    market_dto.OhlcStore
+	CryptoFuturesOhlcDerived market_dto.OhlcStore
+	// Rows which failed the quality checks
+	OhlcQuarantine market_dto.QuarantineStore
+	// Reports of the reconciliation runs
+	OhlcDrift market_dto.DriftStore
+	// Replaced versions of the ohlc rows
+	OhlcRevisions market_dto.RevisionStore
+	// Hashed keys and request logs of the http api
+	ApiKeys  auth_dto.KeyStore
+	ApiAudit auth_dto.AuditStore
+}
+
+// WithFeed publishes the rows which are written to the ohlc stores to the feed.
+func (s *Stores) WithFeed(feed *market_dto.OhlcFeed) *Stores {
+	s.CryptoSpotOhlc = market_dto.NewFeedOhlcStore(s.CryptoSpotOhlc, feed)
+	s.CryptoFuturesOhlc = market_dto.NewFeedOhlcStore(s.CryptoFuturesOhlc, feed)
+	s.CryptoSpotOhlcDerived = market_dto.NewFeedOhlcStore(s.CryptoSpotOhlcDerived, feed)
+	s.CryptoFuturesOhlcDerived = market_dto.NewFeedOhlcStore(s.CryptoFuturesOhlcDerived, feed)
+	return s
+}
+
+// ohlcMarket is the source and market of the rows of an ohlc store, which
+// are set on the rows written without them.
+type ohlcMarket struct {
+	store  market_dto.OhlcStore
+	source string
+	market string
+}
+
+func (s *Stores) ohlcMarkets() []ohlcMarket {
+	return []ohlcMarket{
+		{s.CryptoSpotOhlc, binance.Source, market_dto.MarketSpot},
+		{s.CryptoFuturesOhlc, binance.Source, market_dto.MarketUsdm},
+		{s.CryptoSpotOhlcDerived, binance.Source, market_dto.MarketSpot},
+		{s.CryptoFuturesOhlcDerived, binance.Source, market_dto.MarketUsdm},
+	}
+}
+
+// MigrateOhlcMarkets sets the source and market of the stored rows which
+// were written before the fields were added, based on the collection.
+func MigrateOhlcMarkets(stores *Stores) error {
+	for _, m := range stores.ohlcMarkets() {
+		updated, err := m.store.SetMissingMarket(m.source, m.market)
+		if err != nil {
+			return err
+		}
+
+		if updated > 0 {
+			fmt.Printf(" * set the market of %v rows in %v to %v/%v\n", updated, m.store.Name(), m.source, m.market)
+		}
+	}
+	return nil
+}
+
+// OhlcStores returns all of the ohlc stores.
+func (s *Stores) OhlcStores() []market_dto.OhlcStore {
+	return []market_dto.OhlcStore{
+		s.CryptoSpotOhlc,
+		s.CryptoFuturesOhlc,
+		s.CryptoSpotOhlcDerived,
+		s.CryptoFuturesOhlcDerived,
+	}
+}
+
+// NewMongoStores returns the stores which write to the collections of the db.
+// The optional settings are used by the bulk inserts of the ohlc rows.
+func NewMongoStores(db *Db, settings ...mongodb.BulkInsertSettings) *Stores {
+	bulk := mongodb.DefaultBulkInsertSettings
+	if len(settings) == 1 {
+		bulk = settings[0]
+	}
+
+	revisions := market_dto.NewMongoRevisionStore(db.OhlcRevisionsColl())
+	ohlc := func(coll *mongo.Collection, market string) market_dto.OhlcStore {
+		return market_dto.NewMongoOhlcStore(coll).WithRevisions(revisions).WithMarket(binance.Source, market).WithBulkInsert(bulk)
+	}
+
+	return &Stores{
+		CryptoSpotAsset:    market_dto.NewMongoAssetStore(db.CryptoSpotAssetColl()),
+		CryptoSpotOhlc:     ohlc(db.CryptoSpotOhlcColl(), market_dto.MarketSpot),
+		CryptoFuturesAsset: market_dto.NewMongoAssetStore(db.CryptoFuturesAssetColl()),
+		CryptoFuturesOhlc:  ohlc(db.CryptoFuturesOhlcColl(), market_dto.MarketUsdm),
+
+		CryptoSpotOhlcDerived:    ohlc(db.CryptoSpotOhlcDerivedColl(), market_dto.MarketSpot),
+		CryptoFuturesOhlcDerived: ohlc(db.CryptoFuturesOhlcDerivedColl(), market_dto.MarketUsdm),
+		OhlcQuarantine:           market_dto.NewMongoQuarantineStore(db.OhlcQuarantineColl()),
+		OhlcDrift:                market_dto.NewMongoDriftStore(db.OhlcDriftColl()),
+		OhlcRevisions:            revisions,
+		ApiKeys:                  auth_dto.NewMongoKeyStore(db.ApiKeysColl()),
+		ApiAudit:                 auth_dto.NewMongoAuditStore(db.ApiAuditColl()),
+	}
+}
+
+// NewMemoryStores returns stores which keep all of the data in memory,
+// using the same names as the mongodb collections.
+func NewMemoryStores() *Stores {
+	colls := NewCollections("")
+
+	revisions := market_dto.NewMemoryRevisionStore(colls.OhlcRevisions)
+	ohlc := func(name string, market string) market_dto.OhlcStore {
+		return market_dto.NewMemoryOhlcStore(name).WithRevisions(revisions).WithMarket(binance.Source, market)
+	}
+
+	return &Stores{
+		CryptoSpotAsset:    market_dto.NewMemoryAssetStore(colls.CryptoSpotAsset),
+		CryptoSpotOhlc:     ohlc(colls.CryptoSpotOhlc, market_dto.MarketSpot),
+		CryptoFuturesAsset: market_dto.NewMemoryAssetStore(colls.CryptoFuturesAsset),
+		CryptoFuturesOhlc:  ohlc(colls.CryptoFuturesOhlc, market_dto.MarketUsdm),
+
+		CryptoSpotOhlcDerived:    ohlc(colls.CryptoSpotOhlcDerived, market_dto.MarketSpot),
+		CryptoFuturesOhlcDerived: ohlc(colls.CryptoFuturesOhlcDerived, market_dto.MarketUsdm),
+		OhlcQuarantine:           market_dto.NewMemoryQuarantineStore(colls.OhlcQuarantine),
+		OhlcDrift:                market_dto.NewMemoryDriftStore(colls.OhlcDrift),
+		OhlcRevisions:            revisions,
+		ApiKeys:                  auth_dto.NewMemoryKeyStore(colls.ApiKeys),
+		ApiAudit:                 auth_dto.NewMemoryAuditStore(colls.ApiAudit),
+	}
+}
+
+// NewSqliteStores returns the stores which write to the tables of the sqlite
+// database, using the same names as the mongodb collections.
+func NewSqliteStores(conn *sql.DB) (*Stores, error) {
+	colls := NewCollections("")
+
+	spotAsset, err := market_dto.NewSqliteAssetStore(conn, colls.CryptoSpotAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := market_dto.NewSqliteRevisionStore(conn, colls.OhlcRevisions)
+	if err != nil {
+		return nil, err
+	}
+
+	ohlc := func(table string, market string) (market_dto.OhlcStore, error) {
+		store, err := market_dto.NewSqliteOhlcStore(conn, table)
+		if err != nil {
+			return nil, err
+		}
+		return store.WithRevisions(revisions).WithMarket(binance.Source, market), nil
+	}
+
+	spotOhlc, err := ohlc(colls.CryptoSpotOhlc, market_dto.MarketSpot)
+	if err != nil {
+		return nil, err
+	}
+
+	futuresAsset, err := market_dto.NewSqliteAssetStore(conn, colls.CryptoFuturesAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	futuresOhlc, err := ohlc(colls.CryptoFuturesOhlc, market_dto.MarketUsdm)
+	if err != nil {
+		return nil, err
+	}
+
+	spotOhlcDerived, err := ohlc(colls.CryptoSpotOhlcDerived, market_dto.MarketSpot)
+	if err != nil {
+		return nil, err
+	}
+
+	futuresOhlcDerived, err := ohlc(colls.CryptoFuturesOhlcDerived, market_dto.MarketUsdm)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantine, err := market_dto.NewSqliteQuarantineStore(conn, colls.OhlcQuarantine)
+	if err != nil {
+		return nil, err
+	}
+
+	drift, err := market_dto.NewSqliteDriftStore(conn, colls.OhlcDrift)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys, err := auth_dto.NewSqliteKeyStore(conn, colls.ApiKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	apiAudit, err := auth_dto.NewSqliteAuditStore(conn, colls.ApiAudit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stores{
+		CryptoSpotAsset:    spotAsset,
+		CryptoSpotOhlc:     spotOhlc,
+		CryptoFuturesAsset: futuresAsset,
+		CryptoFuturesOhlc:  futuresOhlc,
+
+		CryptoSpotOhlcDerived:    spotOhlcDerived,
+		CryptoFuturesOhlcDerived: futuresOhlcDerived,
+		OhlcQuarantine:           quarantine,
+		OhlcDrift:                drift,
+		OhlcRevisions:            revisions,
+		ApiKeys:                  apiKeys,
+		ApiAudit:                 apiAudit,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMongoBulkInsert(t *testing.T) {
+	app, cleanup := SetupTestEnvironment(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	coll := app.Db().TestCollection("crypto_spot_ohlc_bulk_test")
+
+	reset := func() {
+		t.Helper()
+
+		if err := coll.Drop(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := market_dto.CreateOhlcIndexes(coll); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer coll.Drop(ctx)
+
+	count := func() int64 {
+		t.Helper()
+
+		n, err := coll.CountDocuments(ctx, bson.M{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// rows of the minutes [from, to), with the first row written twice
+	newRows := func(from, to int) []market_dto.OhlcRow {
+		t.Helper()
+
+		var rows []market_dto.OhlcRow
+		for i := from; i < to; i++ {
+			start := t1.Add(time.Duration(i) * time.Minute)
+			row, err := market_dto.NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1, 2, 0.5, 1.5, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row.SetMarket("binance", market_dto.MarketSpot))
+		}
+		return append(rows, rows[0])
+	}
+
+	t.Run("empty range is inserted in chunks", func(t *testing.T) {
+		reset()
+
+		// the duplicate of the batch only fails the chunked inserts, as the
+		// bulk upserts write it over the first row
+		strict := mongodb.BulkInsertSettings{ChunkSize: 2, FailOnDuplicateKey: true}
+		if _, err := market_dto.UpsertOhlcRows(newRows(0, 5), coll, strict); err == nil {
+			t.Fatal("expected a duplicate key error")
+		}
+
+		// the sorted duplicate is in the first chunk, after which the insert stops
+		if n := count(); n != 1 {
+			t.Fatalf("expected 1 row of the first chunk, got %d", n)
+		}
+
+		reset()
+
+		relaxed := mongodb.BulkInsertSettings{ChunkSize: 2, RelaxWriteConcern: true}
+		if _, err := market_dto.UpsertOhlcRows(newRows(0, 5), coll, relaxed); err != nil {
+			t.Fatalf("expected the duplicate to be ignored, got %v", err)
+		}
+
+		if n := count(); n != 5 {
+			t.Fatalf("expected 5 rows, got %d", n)
+		}
+
+		// the stored range is upserted, so the duplicate does not fail
+		if _, err := market_dto.UpsertOhlcRows(newRows(3, 7), coll, strict); err != nil {
+			t.Fatalf("expected the bulk upsert of the stored range, got %v", err)
+		}
+
+		if n := count(); n != 7 {
+			t.Fatalf("expected 7 rows, got %d", n)
+		}
+	})
+
+	t.Run("existing rows", func(t *testing.T) {
+		reset()
+
+		if _, err := mongodb.InsertChunked(coll, newRows(0, 5)[:5]); err != nil {
+			t.Fatal(err)
+		}
+
+		// minutes 3 and 4 are stored already
+		inserted, err := mongodb.InsertChunked(coll, newRows(3, 8)[:5], mongodb.BulkInsertSettings{ChunkSize: 2})
+		if err != nil || inserted != 3 {
+			t.Fatalf("expected 3 inserted rows, got %d, %v", inserted, err)
+		}
+
+		if n := count(); n != 8 {
+			t.Fatalf("expected 8 rows, got %d", n)
+		}
+
+		inserted, err = mongodb.InsertChunked(coll, newRows(6, 10)[:4], mongodb.BulkInsertSettings{ChunkSize: 2, FailOnDuplicateKey: true})
+		if err == nil || inserted != 0 {
+			t.Fatalf("expected a duplicate key error of the first chunk, got %d, %v", inserted, err)
+		}
+
+		if n := count(); n != 8 {
+			t.Fatalf("expected the failed insert to stop after the first chunk, got %d rows", n)
+		}
+	})
+}
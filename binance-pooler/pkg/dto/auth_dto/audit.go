@@ -0,0 +1,52 @@
+package auth_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuditLog is a request to the api.
+type AuditLog struct {
+	Time     time.Time `json:"time" bson:"time"`
+	KeyID    string    `json:"key_id" bson:"key_id"` // Empty if the request did not have a valid key
+	KeyName  string    `json:"key_name" bson:"key_name"`
+	Method   string    `json:"method" bson:"method"`
+	Path     string    `json:"path" bson:"path"`
+	Query    string    `json:"query" bson:"query"` // Query string, without the api key
+	Status   int       `json:"status" bson:"status"`
+	Duration int64     `json:"dur_ms" bson:"dur_ms"`
+	Ip       string    `json:"ip" bson:"ip"`
+}
+
+// AuditFilter holds the optional parameters of the audit log queries.
+// Zero values are ignored.
+type AuditFilter struct {
+	KeyID string
+	From  time.Time
+	To    time.Time
+	Limit int64
+}
+
+func (f AuditFilter) matches(log AuditLog) bool {
+	if f.KeyID != "" && log.KeyID != f.KeyID {
+		return false
+	}
+	if !f.From.IsZero() && log.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && log.Time.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// AuditIndexes returns the indexes of the audit log collection.
+func AuditIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().Add("time").Add("key_id", "time")
+}
+
+func CreateAuditIndexes(coll *mongo.Collection) error {
+	return AuditIndexes().Create(coll)
+}
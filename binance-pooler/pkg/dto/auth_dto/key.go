@@ -0,0 +1,156 @@
+package auth_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ctx = context.Background()
+
+// Scopes of the api keys
+const (
+	ScopeRead = "read" // Query the market data
+	ScopeJobs = "jobs" // Control the jobs and start backfills
+)
+
+var Scopes = []string{ScopeRead, ScopeJobs}
+
+var ErrKeyNotFound = errors.New("api key not found")
+
+// keyPrefix is the prefix of the generated keys, so that leaked keys are
+// easy to recognize.
+const keyPrefix = "bpk_"
+
+// ApiKey holds the access rights of a key. Only the hash of the key is
+// stored, so the key itself is shown once, when it's created.
+type ApiKey struct {
+	ID        string     `json:"id" bson:"_id"`
+	Name      string     `json:"name" bson:"name"`
+	Hash      string     `json:"-" bson:"hash"`    // sha256 of the key
+	Hint      string     `json:"hint" bson:"hint"` // First characters of the key, to tell the keys apart
+	Scopes    []string   `json:"scopes" bson:"scopes"`
+	Quota     Quota      `json:"quota" bson:"quota"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// NewApiKey returns a new key with the scopes and the secret of the key,
+// which is used to authenticate the requests.
+func NewApiKey(name string, scopes []string, quota Quota) (*ApiKey, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("name of the key is required")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, "", fmt.Errorf("unknown scope: %v", scope)
+		}
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret = keyPrefix + secret
+
+	key := &ApiKey{
+		ID:        id,
+		Name:      name,
+		Hash:      HashKey(secret),
+		Hint:      secret[:len(keyPrefix)+6],
+		Scopes:    scopes,
+		Quota:     quota,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	return key, secret, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashKey returns the hash under which the key is stored. The keys are
+// random, so a fast hash is enough.
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (k ApiKey) HasScope(scope string) bool { return slices.Contains(k.Scopes, scope) }
+func (k ApiKey) Revoked() bool              { return k.RevokedAt != nil }
+
+// Quota limits the number of requests of a key in a fixed window. The quota
+// is disabled if the number of requests is 0.
+type Quota struct {
+	Requests int64         `json:"requests" bson:"requests"`
+	Window   time.Duration `json:"window" bson:"window"`
+}
+
+// ParseQuota parses the quota in the requests/window format (e.g. 1000/1h).
+// An empty string disables the quota.
+func ParseQuota(s string) (Quota, error) {
+	if s == "" {
+		return Quota{}, nil
+	}
+
+	requests, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Quota{}, fmt.Errorf("invalid quota: %v, expected requests/window (e.g. 1000/1h)", s)
+	}
+
+	n, err := strconv.ParseInt(requests, 10, 64)
+	if err != nil || n <= 0 {
+		return Quota{}, fmt.Errorf("invalid number of requests in quota: %v", s)
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Quota{}, fmt.Errorf("invalid window of quota: %v", s)
+	}
+
+	return Quota{Requests: n, Window: d}, nil
+}
+
+func (q Quota) Enabled() bool { return q.Requests > 0 && q.Window > 0 }
+
+func (q Quota) String() string {
+	if !q.Enabled() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%v/%v", q.Requests, q.Window)
+}
+
+// KeyIndexes returns the indexes of the api key collection.
+func KeyIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().AddUnique("hash")
+}
+
+func CreateKeyIndexes(coll *mongo.Collection) error {
+	return KeyIndexes().Create(coll)
+}
@@ -0,0 +1,23 @@
+package auth_dto
+
+import "time"
+
+// KeyStore holds the api keys.
+type KeyStore interface {
+	Name() string
+	InsertKey(key ApiKey) error
+	// GetKeyByHash returns the key with the hash, or ErrKeyNotFound.
+	GetKeyByHash(hash string) (*ApiKey, error)
+	// GetKeys returns all of the keys, including the revoked ones, sorted by the creation time.
+	GetKeys() ([]ApiKey, error)
+	// RevokeKey marks the key with the id as revoked, or returns ErrKeyNotFound.
+	RevokeKey(id string, at time.Time) error
+}
+
+// AuditStore holds the audit logs of the api requests.
+type AuditStore interface {
+	Name() string
+	InsertAuditLog(log AuditLog) error
+	// GetAuditLogs returns the matching logs, starting with the latest one.
+	GetAuditLogs(filter AuditFilter) ([]AuditLog, error)
+}
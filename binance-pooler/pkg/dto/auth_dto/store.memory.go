@@ -0,0 +1,114 @@
+package auth_dto
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryKeyStore is an in-memory implementation of the KeyStore interface.
+type MemoryKeyStore struct {
+	name string
+	mu   sync.RWMutex
+	keys []ApiKey
+}
+
+func NewMemoryKeyStore(name string) *MemoryKeyStore {
+	return &MemoryKeyStore{name: name}
+}
+
+func (s *MemoryKeyStore) Name() string { return s.name }
+
+func (s *MemoryKeyStore) InsertKey(key ApiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.ID == key.ID || k.Hash == key.Hash {
+			return fmt.Errorf("duplicate api key: %v", key.ID)
+		}
+	}
+
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *MemoryKeyStore) GetKeyByHash(hash string) (*ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return &k, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+func (s *MemoryKeyStore) GetKeys() ([]ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([]ApiKey, len(s.keys))
+	copy(docs, s.keys)
+
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].CreatedAt.Before(docs[j].CreatedAt) })
+	return docs, nil
+}
+
+func (s *MemoryKeyStore) RevokeKey(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID == id {
+			s.keys[i].RevokedAt = &at
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrKeyNotFound, id)
+}
+
+// MemoryAuditStore is an in-memory implementation of the AuditStore interface.
+type MemoryAuditStore struct {
+	name string
+	mu   sync.RWMutex
+	logs []AuditLog
+}
+
+func NewMemoryAuditStore(name string) *MemoryAuditStore {
+	return &MemoryAuditStore{name: name}
+}
+
+func (s *MemoryAuditStore) Name() string { return s.name }
+
+func (s *MemoryAuditStore) InsertAuditLog(log AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs = append(s.logs, log)
+	return nil
+}
+
+func (s *MemoryAuditStore) GetAuditLogs(filter AuditFilter) ([]AuditLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []AuditLog
+	for i := len(s.logs) - 1; i >= 0; i-- {
+		if filter.matches(s.logs[i]) {
+			docs = append(docs, s.logs[i])
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].Time.After(docs[j].Time) })
+
+	if filter.Limit > 0 && int64(len(docs)) > filter.Limit {
+		docs = docs[:filter.Limit]
+	}
+
+	return docs, nil
+}
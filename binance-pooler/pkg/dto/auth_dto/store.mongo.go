@@ -0,0 +1,104 @@
+package auth_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoKeyStore implements the KeyStore interface on top of a mongodb collection.
+type MongoKeyStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoKeyStore(coll *mongo.Collection) *MongoKeyStore {
+	return &MongoKeyStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoKeyStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoKeyStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoKeyStore) InsertKey(key ApiKey) error {
+	_, err := s.coll.InsertOne(ctx, key)
+	return err
+}
+
+func (s *MongoKeyStore) GetKeyByHash(hash string) (*ApiKey, error) {
+	var key ApiKey
+	err := mongodb.GetDocumentWithTypes(s.coll, bson.M{"hash": hash}, nil, &key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *MongoKeyStore) GetKeys() ([]ApiKey, error) {
+	var docs []ApiKey
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, bson.M{}, mongodb.OrderAscending("created_at"), &docs)
+	return docs, err
+}
+
+func (s *MongoKeyStore) RevokeKey(id string, at time.Time) error {
+	res, err := s.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: %v", ErrKeyNotFound, id)
+	}
+
+	return nil
+}
+
+// MongoAuditStore implements the AuditStore interface on top of a mongodb collection.
+type MongoAuditStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAuditStore(coll *mongo.Collection) *MongoAuditStore {
+	return &MongoAuditStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoAuditStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoAuditStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoAuditStore) InsertAuditLog(log AuditLog) error {
+	_, err := s.coll.InsertOne(ctx, log)
+	return err
+}
+
+func (s *MongoAuditStore) GetAuditLogs(filter AuditFilter) ([]AuditLog, error) {
+	query := bson.M{}
+	if filter.KeyID != "" {
+		query["key_id"] = filter.KeyID
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		timeFilter := bson.M{}
+		if !filter.From.IsZero() {
+			timeFilter["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			timeFilter["$lte"] = filter.To
+		}
+		query["time"] = timeFilter
+	}
+
+	opt := mongodb.OrderDescending("time")
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	var docs []AuditLog
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, query, opt, &docs)
+	return docs, err
+}
@@ -0,0 +1,221 @@
+package auth_dto
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SqliteKeyStore implements the KeyStore interface on top of a sqlite table.
+// The keys are stored as json, next to the columns which are queried.
+type SqliteKeyStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteKeyStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteKeyStore(db *sql.DB, table string) (*SqliteKeyStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		id         TEXT    NOT NULL PRIMARY KEY,
+		hash       TEXT    NOT NULL UNIQUE,
+		created_at INTEGER NOT NULL,
+		data       TEXT    NOT NULL
+	)`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	return &SqliteKeyStore{db: db, table: table}, nil
+}
+
+func (s *SqliteKeyStore) Name() string { return s.table }
+
+func (s *SqliteKeyStore) InsertKey(key ApiKey) error {
+	b, err := json.Marshal(sqliteKey{key, key.Hash})
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %q (id, hash, created_at, data) VALUES (?, ?, ?, ?)`, s.table)
+	_, err = s.db.Exec(query, key.ID, key.Hash, key.CreatedAt.UnixMilli(), string(b))
+	return err
+}
+
+// sqliteKey is the json of the stored keys, which includes the hash.
+type sqliteKey struct {
+	ApiKey
+	Hash string `json:"hash"`
+}
+
+func (s *SqliteKeyStore) scanKey(row interface{ Scan(...any) error }) (*ApiKey, error) {
+	var data string
+	if err := row.Scan(&data); err != nil {
+		return nil, err
+	}
+
+	var doc sqliteKey
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the api key: %v", err)
+	}
+
+	doc.ApiKey.Hash = doc.Hash
+	return &doc.ApiKey, nil
+}
+
+func (s *SqliteKeyStore) GetKeyByHash(hash string) (*ApiKey, error) {
+	query := fmt.Sprintf(`SELECT data FROM %q WHERE hash = ?`, s.table)
+
+	key, err := s.scanKey(s.db.QueryRow(query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, err
+}
+
+func (s *SqliteKeyStore) GetKeys() ([]ApiKey, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT data FROM %q ORDER BY created_at, rowid`, s.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []ApiKey
+	for rows.Next() {
+		key, err := s.scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *key)
+	}
+
+	return docs, rows.Err()
+}
+
+func (s *SqliteKeyStore) RevokeKey(id string, at time.Time) error {
+	query := fmt.Sprintf(`SELECT data FROM %q WHERE id = ?`, s.table)
+
+	key, err := s.scanKey(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %v", ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	key.RevokedAt = &at
+
+	b, err := json.Marshal(sqliteKey{*key, key.Hash})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`UPDATE %q SET data = ? WHERE id = ?`, s.table), string(b), id)
+	return err
+}
+
+// SqliteAuditStore implements the AuditStore interface on top of a sqlite
+// table. The logs are stored as json, next to the filtered columns.
+type SqliteAuditStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteAuditStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteAuditStore(db *sql.DB, table string) (*SqliteAuditStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		key_id TEXT    NOT NULL,
+		time   INTEGER NOT NULL,
+		data   TEXT    NOT NULL
+	)`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	return &SqliteAuditStore{db: db, table: table}, nil
+}
+
+func (s *SqliteAuditStore) Name() string { return s.table }
+
+func (s *SqliteAuditStore) InsertAuditLog(log AuditLog) error {
+	b, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %q (key_id, time, data) VALUES (?, ?, ?)`, s.table)
+	_, err = s.db.Exec(query, log.KeyID, log.Time.UnixMilli(), string(b))
+	return err
+}
+
+func (s *SqliteAuditStore) GetAuditLogs(filter AuditFilter) ([]AuditLog, error) {
+	var where []string
+	var args []any
+
+	if filter.KeyID != "" {
+		where = append(where, "key_id = ?")
+		args = append(args, filter.KeyID)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "time <= ?")
+		args = append(args, filter.To.UnixMilli())
+	}
+
+	query := fmt.Sprintf(`SELECT data FROM %q`, s.table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, rowid DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []AuditLog
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var log AuditLog
+		if err := json.Unmarshal([]byte(data), &log); err != nil {
+			return nil, fmt.Errorf("failed to decode the audit log: %v", err)
+		}
+		docs = append(docs, log)
+	}
+
+	return docs, rows.Err()
+}
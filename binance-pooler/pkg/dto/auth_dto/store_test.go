@@ -0,0 +1,158 @@
+package auth_dto
+
+import (
+	"binance-pooler/pkg/lib/sqlite"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStores(t *testing.T) {
+	t.Run("keys", func(t *testing.T) { testKeyStore(t, NewMemoryKeyStore("test_keys")) })
+	t.Run("audit", func(t *testing.T) { testAuditStore(t, NewMemoryAuditStore("test_audit")) })
+}
+
+func TestSqliteStores(t *testing.T) {
+	db, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	keys, err := NewSqliteKeyStore(db, "test_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audit, err := NewSqliteAuditStore(db, "test_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("keys", func(t *testing.T) { testKeyStore(t, keys) })
+	t.Run("audit", func(t *testing.T) { testAuditStore(t, audit) })
+}
+
+func TestApiKey(t *testing.T) {
+	key, secret, err := NewApiKey("test", []string{ScopeRead}, Quota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(secret, keyPrefix) || !strings.HasPrefix(secret, key.Hint) || key.Hash != HashKey(secret) || key.Hash == secret {
+		t.Fatalf("unexpected key: %+v, secret %v", key, secret)
+	}
+
+	if !key.HasScope(ScopeRead) || key.HasScope(ScopeJobs) {
+		t.Fatalf("unexpected scopes: %v", key.Scopes)
+	}
+
+	if _, _, err := NewApiKey("test", []string{"admin"}, Quota{}); err == nil {
+		t.Fatal("expected an error for an unknown scope")
+	}
+
+	tests := []struct {
+		val   string
+		quota Quota
+		ok    bool
+	}{
+		{"", Quota{}, true},
+		{"1000/1h", Quota{Requests: 1000, Window: time.Hour}, true},
+		{"10/1s", Quota{Requests: 10, Window: time.Second}, true},
+		{"1000", Quota{}, false},
+		{"0/1h", Quota{}, false},
+		{"10/day", Quota{}, false},
+	}
+
+	for _, tt := range tests {
+		quota, err := ParseQuota(tt.val)
+		if (err == nil) != tt.ok || quota != tt.quota {
+			t.Fatalf("%q: unexpected quota %+v, err %v", tt.val, quota, err)
+		}
+	}
+}
+
+// testKeyStore runs the same checks against any KeyStore implementation
+func testKeyStore(t *testing.T, store KeyStore) {
+	first, secret, err := NewApiKey("first", []string{ScopeRead, ScopeJobs}, Quota{Requests: 10, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _, err := NewApiKey("second", []string{ScopeRead}, Quota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+
+	for _, key := range []*ApiKey{first, second} {
+		if err := store.InsertKey(*key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.InsertKey(*first); err == nil {
+		t.Fatal("expected an error for a duplicate key")
+	}
+
+	key, err := store.GetKeyByHash(HashKey(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.ID != first.ID || key.Hash != first.Hash || key.Quota != first.Quota || !key.HasScope(ScopeJobs) || key.Revoked() {
+		t.Fatalf("unexpected key: %+v", key)
+	}
+
+	if _, err := store.GetKeyByHash(HashKey("missing")); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	if err := store.RevokeKey(first.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RevokeKey("missing", time.Now()); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	keys, err := store.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != first.ID || !keys[0].Revoked() || keys[1].Revoked() {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+}
+
+// testAuditStore runs the same checks against any AuditStore implementation
+func testAuditStore(t *testing.T, store AuditStore) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, keyID := range []string{"a", "b", "a", ""} {
+		log := AuditLog{Time: t1.Add(time.Duration(i) * time.Minute), KeyID: keyID, Method: "GET", Path: "/ohlc", Status: 200}
+		if err := store.InsertAuditLog(log); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, err := store.GetAuditLogs(AuditFilter{KeyID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 2 || !logs[0].Time.Equal(t1.Add(2*time.Minute)) || logs[0].Path != "/ohlc" {
+		t.Fatalf("unexpected logs: %+v", logs)
+	}
+
+	logs, err = store.GetAuditLogs(AuditFilter{From: t1.Add(time.Minute), Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 2 || logs[0].KeyID != "" || logs[1].KeyID != "a" {
+		t.Fatalf("unexpected logs: %+v", logs)
+	}
+}
@@ -0,0 +1,115 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AggregateOhlcRows builds rows of the target interval from rows of a lower
+// interval. The buckets are aligned to the unix epoch (so 15m buckets start at
+// :00, :15, ...). Volumes and the number of trades are summed. Only complete
+// buckets, which hold all of the lower interval rows, are returned.
+func AggregateOhlcRows(rows []OhlcRow, interval int64) ([]OhlcRow, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be greater than 0")
+	}
+
+	type bucketKey struct {
+		source string
+		market string
+		symbol string
+		start  int64
+	}
+
+	sorted := make([]OhlcRow, len(rows))
+	copy(sorted, rows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	buckets := make(map[bucketKey][]OhlcRow)
+	var keys []bucketKey
+
+	for _, row := range sorted {
+		if row.Interval <= 0 || interval%row.Interval != 0 {
+			return nil, fmt.Errorf("interval of %v is not a multiple of the row interval %v", interval, row.Interval)
+		}
+
+		ms := row.StartTime.UnixMilli()
+		k := bucketKey{row.Source, row.Market, row.Symbol, ms - ms%interval}
+		if _, ok := buckets[k]; !ok {
+			keys = append(keys, k)
+		}
+		buckets[k] = append(buckets[k], row)
+	}
+
+	var out []OhlcRow
+	for _, k := range keys {
+		bucket := buckets[k]
+		if int64(len(bucket))*bucket[0].Interval != interval {
+			continue
+		}
+
+		out = append(out, mergeOhlcRows(time.UnixMilli(k.start).UTC(), interval, bucket))
+	}
+
+	return out, nil
+}
+
+// BucketStart returns the start of the epoch aligned bucket of the interval,
+// in which the time is. For the intervals which divide a day (e.g. 15m, 4h,
+// 1d) the buckets are aligned to the UTC calendar days.
+func BucketStart(t time.Time, interval int64) time.Time {
+	ms := t.UnixMilli()
+	return time.UnixMilli(ms - ms%interval).UTC()
+}
+
+// mergeOhlcRows merges the sorted rows of a series into a single row of the bucket.
+func mergeOhlcRows(start time.Time, interval int64, rows []OhlcRow) OhlcRow {
+	merged := OhlcRow{
+		TimeseriesFields: mongodb.TimeseriesFields{StartTime: start, Interval: interval},
+		Source:           rows[0].Source,
+		Market:           rows[0].Market,
+		Symbol:           rows[0].Symbol,
+		OHLC: OHLC{
+			Open:  rows[0].Open,
+			High:  rows[0].High,
+			Low:   rows[0].Low,
+			Close: rows[len(rows)-1].Close,
+		},
+	}
+
+	var baseVol float64
+	var numTrades int64
+	hasBaseVol, hasNumTrades := true, true
+
+	for _, row := range rows {
+		merged.High = max(merged.High, row.High)
+		merged.Low = min(merged.Low, row.Low)
+		merged.Volume += row.Volume
+
+		if row.BaseAssetVolume != nil {
+			baseVol += *row.BaseAssetVolume
+		} else {
+			hasBaseVol = false
+		}
+
+		if row.NumberOfTrades != nil {
+			numTrades += *row.NumberOfTrades
+		} else {
+			hasNumTrades = false
+		}
+	}
+
+	if hasBaseVol {
+		merged.SetBaseAssetVolume(baseVol)
+	}
+
+	if hasNumTrades {
+		merged.SetNumberOfTrades(numTrades)
+	}
+
+	return merged
+}
@@ -11,8 +11,8 @@ import (
 )
 
 type Asset[T any] struct {
-	AssetBase AssetBase `json:",inline" bson:",inline"` // Fields shared between spot and futures assets
-	Data      T         `json:"data" bson:"data"`       // Asset specific data
+	AssetBase AssetBase `json:",inline" bson:",inline"`     // Fields shared between spot and futures assets
+	Data      T         `json:"data" bson:"data,omitempty"` // Asset specific data (omitted if nil, so that upserts without it keep the stored data)
 }
 
 type AssetBase struct {
@@ -25,8 +25,13 @@ type AssetBase struct {
 	OrderTypes []string  `json:"order_types" bson:"order_types"`
 }
 
+// AssetIndexes returns the indexes of the asset collections.
+func AssetIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().Add("symbol").Add("source", "status")
+}
+
 func CreateAssetIndexes(coll *mongo.Collection) error {
-	return mongodb.NewIndexes().Add("symbol").Add("source", "status").Create(coll)
+	return AssetIndexes().Create(coll)
 }
 
 type SpotAsset = Asset[SpotAssetData]
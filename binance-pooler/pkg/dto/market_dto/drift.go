@@ -0,0 +1,157 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxDriftDiffs limits the number of field differences stored in a report.
+const maxDriftDiffs = 100
+
+// OhlcDiff is a field of the stored row, which differs from the fetched one.
+type OhlcDiff struct {
+	StartTime time.Time `json:"start_time" bson:"start_time"`
+	Field     string    `json:"field" bson:"field"`
+	Stored    float64   `json:"stored" bson:"stored"`
+	Fetched   float64   `json:"fetched" bson:"fetched"`
+}
+
+// DriftReport holds the differences between the stored rows of a symbol and
+// interval and the rows which were fetched again from the source.
+type DriftReport struct {
+	Collection string     `json:"collection" bson:"collection"`
+	Source     string     `json:"source" bson:"source"`
+	Market     string     `json:"market" bson:"market"`
+	Symbol     string     `json:"symbol" bson:"symbol"`
+	Interval   int64      `json:"interval" bson:"interval"`
+	From       time.Time  `json:"from" bson:"from"`
+	To         time.Time  `json:"to" bson:"to"`
+	NumStored  int        `json:"num_stored" bson:"num_stored"`
+	NumFetched int        `json:"num_fetched" bson:"num_fetched"`
+	NumMissing int        `json:"num_missing" bson:"num_missing"` // Fetched rows which are not stored
+	NumExtra   int        `json:"num_extra" bson:"num_extra"`     // Stored rows which were not fetched
+	NumChanged int        `json:"num_changed" bson:"num_changed"` // Rows with at least one different field
+	Diffs      []OhlcDiff `json:"diffs" bson:"diffs"`             // Capped at 100 differences
+	Corrected  bool       `json:"corrected" bson:"corrected"`     // True if the changed and missing rows were upserted
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// HasDrift returns true if the stored rows differ from the fetched ones.
+func (r DriftReport) HasDrift() bool {
+	return r.NumMissing > 0 || r.NumExtra > 0 || r.NumChanged > 0
+}
+
+func (r DriftReport) String() string {
+	return fmt.Sprintf("%v %v:%v [%v -> %v] stored: %v, fetched: %v, missing: %v, extra: %v, changed: %v, corrected: %v",
+		r.Collection, r.Symbol, r.Interval, r.From.Format(time.DateTime), r.To.Format(time.DateTime),
+		r.NumStored, r.NumFetched, r.NumMissing, r.NumExtra, r.NumChanged, r.Corrected)
+}
+
+// DiffOhlcRows compares the stored rows of a symbol and interval with the
+// fetched ones, by the start time. The values are compared with the relative
+// tolerance. The returned rows are the fetched rows which are missing or
+// differ from the stored ones. The source and market of the report are the
+// ones of the stored rows, or of the fetched rows if none are stored.
+func DiffOhlcRows(stored, fetched []OhlcRow, tolerance float64) (DriftReport, []OhlcRow) {
+	report := DriftReport{NumStored: len(stored), NumFetched: len(fetched)}
+
+	if len(stored) > 0 {
+		report.Source, report.Market = rowsMarket(stored)
+	} else if len(fetched) > 0 {
+		report.Source, report.Market = rowsMarket(fetched)
+	}
+
+	byTime := make(map[int64]OhlcRow, len(stored))
+	for _, row := range stored {
+		byTime[row.StartTime.UnixMilli()] = row
+	}
+
+	sorted := make([]OhlcRow, len(fetched))
+	copy(sorted, fetched)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	var changed []OhlcRow
+	seen := make(map[int64]bool, len(sorted))
+
+	for _, row := range sorted {
+		key := row.StartTime.UnixMilli()
+		seen[key] = true
+
+		curr, ok := byTime[key]
+		if !ok {
+			report.NumMissing++
+			changed = append(changed, row)
+			continue
+		}
+
+		diffs := diffOhlcFields(curr, row, tolerance)
+		if len(diffs) == 0 {
+			continue
+		}
+
+		report.NumChanged++
+		changed = append(changed, row)
+
+		for _, d := range diffs {
+			if len(report.Diffs) < maxDriftDiffs {
+				report.Diffs = append(report.Diffs, d)
+			}
+		}
+	}
+
+	for key := range byTime {
+		if !seen[key] {
+			report.NumExtra++
+		}
+	}
+
+	return report, changed
+}
+
+type ohlcField struct {
+	name            string
+	stored, fetched float64
+}
+
+func diffOhlcFields(stored, fetched OhlcRow, tolerance float64) []OhlcDiff {
+	fields := []ohlcField{
+		{"o", stored.Open, fetched.Open},
+		{"h", stored.High, fetched.High},
+		{"l", stored.Low, fetched.Low},
+		{"c", stored.Close, fetched.Close},
+		{"v", stored.Volume, fetched.Volume},
+	}
+
+	if stored.BaseAssetVolume != nil && fetched.BaseAssetVolume != nil {
+		fields = append(fields, ohlcField{"bv", *stored.BaseAssetVolume, *fetched.BaseAssetVolume})
+	}
+
+	if stored.NumberOfTrades != nil && fetched.NumberOfTrades != nil {
+		fields = append(fields, ohlcField{"n", float64(*stored.NumberOfTrades), float64(*fetched.NumberOfTrades)})
+	}
+
+	var diffs []OhlcDiff
+	for _, f := range fields {
+		if math.Abs(f.stored-f.fetched) > tolerance*math.Max(math.Abs(f.stored), math.Abs(f.fetched)) {
+			diffs = append(diffs, OhlcDiff{StartTime: stored.StartTime, Field: f.name, Stored: f.stored, Fetched: f.fetched})
+		}
+	}
+
+	return diffs
+}
+
+// DriftIndexes returns the indexes of the drift report collection.
+func DriftIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().Add("source", "market", "collection", "symbol", "interval", "created_at")
+}
+
+func CreateDriftIndexes(coll *mongo.Collection) error {
+	return DriftIndexes().Create(coll)
+}
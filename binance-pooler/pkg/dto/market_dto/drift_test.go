@@ -0,0 +1,68 @@
+package market_dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffOhlcRows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newRows := func(n int) []OhlcRow {
+		var rows []OhlcRow
+		for i := 0; i < n; i++ {
+			t1 := start.Add(time.Duration(i) * time.Minute)
+			row, err := NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 100, 101, 99, 100, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+		return rows
+	}
+
+	stored := newRows(5)
+	fetched := newRows(6)
+
+	stored = append(stored[:1], stored[2:]...)     // row 1 is missing
+	fetched[3].Close, fetched[3].Volume = 100.5, 2 // row 3 differs in 2 fields
+	fetched[4].Close = 100 + 1e-12                 // within the tolerance
+	fetched = fetched[1:]                          // row 0 is extra
+
+	report, changed := DiffOhlcRows(stored, fetched, 1e-9)
+
+	if report.NumStored != 4 || report.NumFetched != 5 {
+		t.Fatalf("unexpected counts: %v", report.String())
+	}
+
+	// rows 1 and 5 are missing
+	if report.NumMissing != 2 || report.NumExtra != 1 || report.NumChanged != 1 {
+		t.Fatalf("unexpected drift: %v", report.String())
+	}
+
+	if !report.HasDrift() {
+		t.Fatal("expected drift")
+	}
+
+	if len(report.Diffs) != 2 || report.Diffs[0].Field != "c" || report.Diffs[1].Field != "v" {
+		t.Fatalf("unexpected diffs: %+v", report.Diffs)
+	}
+
+	if len(changed) != 3 || !changed[0].StartTime.Equal(start.Add(time.Minute)) || !changed[1].StartTime.Equal(start.Add(3*time.Minute)) {
+		t.Fatalf("unexpected changed rows: %+v", changed)
+	}
+
+	report, changed = DiffOhlcRows(stored, stored, 0)
+	if report.HasDrift() || len(changed) != 0 {
+		t.Fatalf("expected no drift, got %v", report.String())
+	}
+
+	// the report has the source and market of the stored rows
+	for i := range stored {
+		stored[i].SetMarket("binance", MarketSpot)
+	}
+
+	if report, _ = DiffOhlcRows(stored, fetched, 0); report.Source != "binance" || report.Market != MarketSpot {
+		t.Fatalf("unexpected market of the report: %v %v", report.Source, report.Market)
+	}
+}
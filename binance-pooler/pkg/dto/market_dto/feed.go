@@ -0,0 +1,185 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OhlcFeed fans out the written ohlc rows to the live subscribers.
+type OhlcFeed struct {
+	mu   sync.Mutex
+	subs map[*OhlcSubscription]struct{}
+}
+
+func NewOhlcFeed() *OhlcFeed {
+	return &OhlcFeed{subs: make(map[*OhlcSubscription]struct{})}
+}
+
+// FeedFilter holds the optional parameters of a subscription. Zero values
+// are ignored.
+type FeedFilter struct {
+	Source   string
+	Market   string
+	Symbols  []string
+	Interval int64
+}
+
+func (f FeedFilter) matches(row OhlcRow) bool {
+	if f.Source != "" && row.Source != f.Source {
+		return false
+	}
+
+	if f.Market != "" && row.Market != f.Market {
+		return false
+	}
+
+	if len(f.Symbols) > 0 && !slices.Contains(f.Symbols, row.Symbol) {
+		return false
+	}
+
+	if f.Interval != 0 && row.Interval != f.Interval {
+		return false
+	}
+
+	return true
+}
+
+// OhlcSubscription receives the published rows which match its filter. If
+// the subscriber does not keep up and the buffer fills up, the subscription
+// is closed, so that the subscriber can reconnect and replay the missed rows.
+type OhlcSubscription struct {
+	C      <-chan OhlcRow
+	c      chan OhlcRow
+	filter FeedFilter
+	feed   *OhlcFeed
+	closed bool
+}
+
+// Subscribe returns a subscription to the rows which match the filter, with
+// a channel which holds up to buffer rows.
+func (f *OhlcFeed) Subscribe(filter FeedFilter, buffer int) *OhlcSubscription {
+	c := make(chan OhlcRow, buffer)
+	sub := &OhlcSubscription{C: c, c: c, filter: filter, feed: f}
+
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	return sub
+}
+
+// Close removes the subscription from the feed and closes its channel.
+func (s *OhlcSubscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.close()
+}
+
+func (s *OhlcSubscription) close() {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	delete(s.feed.subs, s)
+	close(s.c)
+}
+
+// Publish sends the rows to the matching subscribers without blocking.
+func (f *OhlcFeed) Publish(rows []OhlcRow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		for _, row := range rows {
+			if !sub.filter.matches(row) {
+				continue
+			}
+
+			select {
+			case sub.c <- row:
+			default:
+				sub.close()
+			}
+
+			if sub.closed {
+				break
+			}
+		}
+	}
+}
+
+// FeedOhlcStore publishes the rows which are written to the store to the feed.
+type FeedOhlcStore struct {
+	OhlcStore
+	feed *OhlcFeed
+}
+
+func NewFeedOhlcStore(store OhlcStore, feed *OhlcFeed) *FeedOhlcStore {
+	return &FeedOhlcStore{OhlcStore: store, feed: feed}
+}
+
+// Unwrap returns the store to which the rows are written.
+func (s *FeedOhlcStore) Unwrap() OhlcStore { return s.OhlcStore }
+
+// UnwrapOhlcStore returns the innermost store of the wrapping stores (e.g.
+// FeedOhlcStore), so that the implementation specific methods can be used.
+func UnwrapOhlcStore(store OhlcStore) OhlcStore {
+	for {
+		w, ok := store.(interface{ Unwrap() OhlcStore })
+		if !ok {
+			return store
+		}
+		store = w.Unwrap()
+	}
+}
+
+func (s *FeedOhlcStore) UpsertOhlcRows(data []OhlcRow) (*mongodb.UpsertLog, error) {
+	log, err := s.OhlcStore.UpsertOhlcRows(data)
+	if err == nil {
+		s.feed.Publish(data)
+	}
+	return log, err
+}
+
+// WatchOhlcCollection publishes the rows which are inserted or updated in the
+// collection by any process, until the context is done. Change streams are
+// only available on replica sets.
+func WatchOhlcCollection(ctx context.Context, coll *mongo.Collection, feed *OhlcFeed) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}}}}},
+	}
+
+	stream, err := coll.Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return fmt.Errorf("failed to watch the %v collection: %v", coll.Name(), err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event struct {
+			FullDocument *OhlcRow `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return fmt.Errorf("failed to decode a change of the %v collection: %v", coll.Name(), err)
+		}
+
+		// the document can be deleted before the update is looked up
+		if event.FullDocument != nil {
+			feed.Publish([]OhlcRow{*event.FullDocument})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil
+	}
+
+	return stream.Err()
+}
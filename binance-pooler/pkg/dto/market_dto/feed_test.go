@@ -0,0 +1,47 @@
+package market_dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOhlcFeed(t *testing.T) {
+	feed := NewOhlcFeed()
+	store := NewFeedOhlcStore(NewMemoryOhlcStore("test_ohlc").WithMarket("binance", MarketSpot), feed)
+
+	btc := feed.Subscribe(FeedFilter{Market: MarketSpot, Symbols: []string{"BTCUSDT"}}, 10)
+	defer btc.Close()
+
+	slow := feed.Subscribe(FeedFilter{}, 1)
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows []OhlcRow
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "BTCUSDT"} {
+		row, _ := NewOhlcRow(symbol, t1, t1.Add(time.Minute), 1, 2, 0.5, 1.5, 10)
+		rows = append(rows, *row)
+		t1 = t1.Add(time.Minute)
+	}
+
+	if _, err := store.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(btc.C) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(btc.C))
+	}
+
+	if row := <-btc.C; row.Symbol != "BTCUSDT" || row.Market != MarketSpot {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+
+	// the slow subscriber is closed after the buffer fills up
+	<-slow.C
+	if _, ok := <-slow.C; ok {
+		t.Fatal("expected the slow subscription to be closed")
+	}
+	slow.Close()
+
+	if _, ok := UnwrapOhlcStore(store).(*MemoryOhlcStore); !ok {
+		t.Fatal("expected the memory store to be unwrapped")
+	}
+}
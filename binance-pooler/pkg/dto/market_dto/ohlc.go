@@ -9,6 +9,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var ctx = context.Background()
@@ -26,14 +27,30 @@ func NewOHLC(open, high, low, close, volume float64) *OHLC {
 	return &OHLC{Open: open, High: high, Low: low, Close: close, Volume: volume}
 }
 
-// timeseries data stored in the db
+// Markets of the ohlc rows
+const (
+	MarketSpot    = "spot"
+	MarketUsdm    = "usdm"  // USD margined futures
+	MarketCoinm   = "coinm" // Coin margined futures
+	MarketOptions = "options"
+)
+
+// timeseries data stored in the db. Rows are identified by the source,
+// market, symbol, interval and start time.
 type OhlcRow struct {
 	mongodb.TimeseriesFields `bson:",inline"`
+	Source                   string `json:"source" bson:"source"` // Exchange of the row (e.g. binance)
+	Market                   string `json:"market" bson:"market"` // One of the Market consts
 	Symbol                   string `json:"symbol" bson:"symbol"`
 	OHLC                     `bson:",inline"`
 	// Optional fields that are not always available
 	BaseAssetVolume *float64 `json:"bv" bson:"bv"`
 	NumberOfTrades  *int64   `json:"n" bson:"n"`
+	// Provenance of the values. The stores set the fetch time to the time of
+	// the write if it's zero and keep it when the values don't change.
+	FetchedAt time.Time `json:"fetched_at" bson:"fetched_at,omitempty"` // Time at which the values were fetched
+	Endpoint  string    `json:"endpoint" bson:"endpoint,omitempty"`     // Source endpoint of the values
+	Revision  int64     `json:"rev" bson:"rev"`                         // Number of corrections of the values
 }
 
 func NewOhlcRow(symbol string, startTime, endTime time.Time, open, high, low, close, volume float64) (*OhlcRow, error) {
@@ -56,15 +73,193 @@ func NewOhlcRow(symbol string, startTime, endTime time.Time, open, high, low, cl
 func (r *OhlcRow) SetBaseAssetVolume(vol float64) { r.BaseAssetVolume = &vol }
 func (r *OhlcRow) SetNumberOfTrades(num int64)    { r.NumberOfTrades = &num }
 
-func CreateOhlcIndexes(coll *mongo.Collection) error {
+// SetMarket sets the source and market of the row and returns it.
+func (r *OhlcRow) SetMarket(source, market string) *OhlcRow {
+	r.Source, r.Market = source, market
+	return r
+}
+
+// setDefaultMarket sets the source and market of the rows which don't have them.
+func setDefaultMarket(data []OhlcRow, source, market string) {
+	for i := range data {
+		if data[i].Source == "" {
+			data[i].Source = source
+		}
+		if data[i].Market == "" {
+			data[i].Market = market
+		}
+	}
+}
+
+type ohlcKey struct {
+	source    string
+	market    string
+	symbol    string
+	interval  int64
+	startTime int64
+}
+
+func (r OhlcRow) key() ohlcKey {
+	return ohlcKey{r.Source, r.Market, r.Symbol, r.Interval, r.StartTime.UnixMilli()}
+}
+
+// fields which identify an ohlc row
+var ohlcKeyFields = []string{"source", "market", "symbol", "interval", mongodb.START_TIME}
+
+// OhlcIndexes returns the indexes of the ohlc collections. The unique index
+// of the row keys makes the concurrent bulk inserts of the same rows fail
+// with duplicate key errors, which are ignored.
+func OhlcIndexes() *mongodb.IndexBuilder {
 	return mongodb.TimeseriesIndexes().
 		Add("symbol").
 		// Add(mongodb.START_TIME, "symbol", "interval").
 		Add("symbol", "interval", mongodb.START_TIME).
-		Create(coll)
+		AddUnique(ohlcKeyFields...)
+}
+
+// OhlcTTLIndex returns the TTL index of a retention rule, which expires the
+// rows of the interval once their start time is older than keep.
+func OhlcTTLIndex(interval int64, keep time.Duration) mongodb.Index {
+	return mongodb.Index{
+		Name:          fmt.Sprintf("ttl_%v_%v", mongodb.START_TIME, interval),
+		Keys:          []mongodb.IndexKey{mongodb.Asc(mongodb.START_TIME)},
+		ExpireAfter:   keep,
+		PartialFilter: bson.M{mongodb.INTERVAL: interval},
+	}
+}
+
+// CreateOhlcIndexes creates the indexes of the ohlc collection. The older
+// versions created the index of the row keys without the unique constraint,
+// so it's dropped, together with the duplicate rows, before it is created.
+// The source and market of the rows have to be set first (see SetMissingMarket).
+func CreateOhlcIndexes(coll *mongo.Collection) error {
+	if err := migrateOhlcKeyIndex(coll); err != nil {
+		return err
+	}
+	return OhlcIndexes().Create(coll)
+}
+
+func migrateOhlcKeyIndex(coll *mongo.Collection) error {
+	keys := make([]mongodb.IndexKey, len(ohlcKeyFields))
+	for i, field := range ohlcKeyFields {
+		keys[i] = mongodb.Desc(field)
+	}
+	name := mongodb.Index{Keys: keys}.IndexName()
+
+	indexes, err := mongodb.AvailableIndexes(coll)
+	if err != nil {
+		return fmt.Errorf("failed to list the indexes of %v: %v", coll.Name(), err)
+	}
+
+	exists := false
+	for _, idx := range indexes {
+		if idx["name"] == name {
+			if unique, _ := idx["unique"].(bool); unique {
+				return nil
+			}
+			exists = true
+		}
+	}
+
+	deleted, err := DeleteDuplicateOhlcRows(coll)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		fmt.Printf(" * deleted %v duplicate rows from %v\n", deleted, coll.Name())
+	}
+
+	if exists {
+		if err := mongodb.DeleteIndex(coll, name); err != nil {
+			return fmt.Errorf("failed to drop the %v index of %v: %v", name, coll.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// ohlcDuplicate is a stored version of a row which is written more than once.
+type ohlcDuplicate struct {
+	Id        any       `bson:"id"`
+	FetchedAt time.Time `bson:"fetched_at"`
+	Revision  int64     `bson:"rev"`
 }
 
-func UpsertOhlcRows(data []OhlcRow, coll *mongo.Collection) (*mongodb.UpsertLog, error) {
+// DeleteDuplicateOhlcRows deletes the rows which have the same keys as
+// another row, keeping the latest fetched one (or the one with the most
+// revisions), and returns the number of deleted rows.
+func DeleteDuplicateOhlcRows(coll *mongo.Collection) (int64, error) {
+	key := bson.M{}
+	for _, field := range ohlcKeyFields {
+		key[field] = "$" + field
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   key,
+			"rows":  bson.M{"$push": bson.M{"id": "$_id", "fetched_at": "$fetched_at", "rev": "$rev"}},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	cur, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the duplicate rows of %v: %v", coll.Name(), err)
+	}
+	defer cur.Close(ctx)
+
+	var ids []any
+	for cur.Next(ctx) {
+		var doc struct {
+			Rows []ohlcDuplicate `bson:"rows"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return 0, err
+		}
+		ids = append(ids, staleOhlcDuplicates(doc.Rows)...)
+	}
+	if err := cur.Err(); err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for len(ids) > 0 {
+		n := min(len(ids), 1000)
+		res, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids[:n]}})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete the duplicate rows of %v: %v", coll.Name(), err)
+		}
+		deleted += res.DeletedCount
+		ids = ids[n:]
+	}
+
+	return deleted, nil
+}
+
+// staleOhlcDuplicates returns the ids of the duplicates, except the latest one.
+func staleOhlcDuplicates(rows []ohlcDuplicate) []any {
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].FetchedAt.Equal(rows[j].FetchedAt) {
+			return rows[i].FetchedAt.After(rows[j].FetchedAt)
+		}
+		return rows[i].Revision > rows[j].Revision
+	})
+
+	ids := make([]any, 0, len(rows))
+	for _, row := range rows[1:] {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
+
+// UpsertOhlcRows writes the rows into the collection. If the collection holds no
+// rows for the symbols and intervals in the time range of the data (e.g. on
+// the initial backfill), the rows are written with chunked unordered
+// inserts, which are a lot faster than the per-row upserts. The optional
+// settings are passed down to the bulk insert.
+func UpsertOhlcRows(data []OhlcRow, coll *mongo.Collection, settings ...mongodb.BulkInsertSettings) (*mongodb.UpsertLog, error) {
 	start := time.Now()
 
 	if len(data) == 0 {
@@ -76,18 +271,101 @@ func UpsertOhlcRows(data []OhlcRow, coll *mongo.Collection) (*mongodb.UpsertLog,
 		return data[i].StartTime.Before(data[j].StartTime)
 	})
 
-	var models []mongo.WriteModel
 	for _, row := range data {
 		if row.Symbol == "" {
 			return nil, fmt.Errorf("symbol is empty")
 		}
+	}
+
+	empty, err := ohlcRangeIsEmpty(data, coll)
+	if err != nil {
+		return nil, err
+	}
+
+	if empty {
+		_, err := mongodb.InsertChunked(coll, data, settings...)
+		log := mongodb.NewUpsertLog(coll, data[0].StartTime, data[len(data)-1].StartTime, len(data), start)
+		return log, err
+	}
 
-		filter := bson.M{"symbol": row.Symbol, mongodb.START_TIME: row.StartTime, "interval": row.Interval}
+	var models []mongo.WriteModel
+	for _, row := range data {
+		filter := bson.M{"source": row.Source, "market": row.Market, "symbol": row.Symbol, mongodb.START_TIME: row.StartTime, "interval": row.Interval}
 		update := bson.M{"$set": row}
 		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
 	}
 
-	_, err := coll.BulkWrite(ctx, models)
+	_, err = coll.BulkWrite(ctx, models)
 	log := mongodb.NewUpsertLog(coll, data[0].StartTime, data[len(data)-1].StartTime, len(data), start)
 	return log, err
 }
+
+// ohlcRanges returns the filters of the series (source, market, symbol and
+// interval) of the sorted data, which cover the time range of their rows.
+func ohlcRanges(data []OhlcRow) []OhlcFilter {
+	type key struct {
+		source   string
+		market   string
+		symbol   string
+		interval int64
+	}
+
+	index := make(map[key]int)
+	var ranges []OhlcFilter
+
+	// the data is sorted, so the first row of a key holds the earliest start time
+	for _, row := range data {
+		k := key{row.Source, row.Market, row.Symbol, row.Interval}
+		i, ok := index[k]
+		if !ok {
+			i = len(ranges)
+			index[k] = i
+			ranges = append(ranges, OhlcFilter{Source: row.Source, Market: row.Market, Symbol: row.Symbol, Interval: row.Interval, From: row.StartTime})
+		}
+		ranges[i].To = row.StartTime
+	}
+
+	return ranges
+}
+
+// ohlcRangeFilter returns the query which matches the rows of the series of
+// the sorted data, in the time range covered by them.
+func ohlcRangeFilter(data []OhlcRow) bson.M {
+	var or []bson.M
+	for _, r := range ohlcRanges(data) {
+		filter := mongodb.TimeRangeFilter(mongodb.START_TIME, r.From, r.To)
+		filter["source"] = r.Source
+		filter["market"] = r.Market
+		filter["symbol"] = r.Symbol
+		filter["interval"] = r.Interval
+		or = append(or, filter)
+	}
+
+	return bson.M{"$or": or}
+}
+
+// ohlcRangeIsEmpty returns true if the collection does not hold any rows for the
+// series of the data, in the time range covered by them.
+func ohlcRangeIsEmpty(data []OhlcRow, coll *mongo.Collection) (bool, error) {
+	count, err := coll.CountDocuments(ctx, ohlcRangeFilter(data), options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to check coverage of the %v collection: %v", coll.Name(), err)
+	}
+
+	return count == 0, nil
+}
+
+// findOhlcRows returns the stored rows of the series of the sorted data, in the time range covered by them.
+func findOhlcRows(data []OhlcRow, coll *mongo.Collection) (map[ohlcKey]OhlcRow, error) {
+	var docs []OhlcRow
+	if err := mongodb.GetAllDocumentsWithTypes(coll, ohlcRangeFilter(data), options.Find(), &docs); err != nil {
+		return nil, fmt.Errorf("failed to get the stored rows of the %v collection: %v", coll.Name(), err)
+	}
+
+	rows := make(map[ohlcKey]OhlcRow, len(docs))
+	for _, row := range docs {
+		rows[row.key()] = row
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,254 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reasons for which the rows fail the quality checks
+const (
+	QualityHighBelowOpenClose  = "high_below_open_close"
+	QualityLowAboveOpenClose   = "low_above_open_close"
+	QualityNegativeVolume      = "negative_volume"
+	QualityNonPositivePrice    = "non_positive_price"
+	QualityIntervalMismatch    = "interval_mismatch"
+	QualityMisalignedStartTime = "misaligned_start_time"
+	QualityPriceJump           = "price_jump"
+)
+
+// QualitySettings holds the optional settings of the ValidateOhlcRows function.
+type QualitySettings struct {
+	Interval       int64   // Expected interval of the rows. 0 skips the check.
+	MaxJumpSigma   float64 // Spikes with close to close returns over N standard deviations are rejected. 0 skips the check.
+	MinJumpSamples int     // Minimum number of returns of a symbol, before the price jumps are checked.
+}
+
+var DefaultQualitySettings = QualitySettings{
+	MaxJumpSigma:   10,
+	MinJumpSamples: 30,
+}
+
+// QuarantinedRow is a row which failed the quality checks, stored with
+// the reasons of the failure.
+type QuarantinedRow struct {
+	OhlcRow       `bson:",inline"`
+	Collection    string    `json:"collection" bson:"collection"` // Collection into which the row was written
+	Reasons       []string  `json:"reasons" bson:"reasons"`
+	QuarantinedAt time.Time `json:"quarantined_at" bson:"quarantined_at"`
+}
+
+// QuarantineIndexes returns the indexes of the quarantine collection.
+func QuarantineIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().
+		Add("source", "market", "symbol", "interval", mongodb.START_TIME).
+		Add("collection", "quarantined_at")
+}
+
+func CreateQuarantineIndexes(coll *mongo.Collection) error {
+	return QuarantineIndexes().Create(coll)
+}
+
+// QualityReport holds the number of rejected rows of a symbol.
+type QualityReport struct {
+	Symbol         string         `json:"symbol"`
+	NumRows        int            `json:"num_rows"`
+	NumQuarantined int            `json:"num_quarantined"`
+	Reasons        map[string]int `json:"reasons"` // Number of rows per reason
+}
+
+// QualityResult holds the rows which passed and failed the quality checks.
+type QualityResult struct {
+	Valid       []OhlcRow
+	Quarantined []QuarantinedRow
+	Reports     []QualityReport // Sorted by the symbol
+}
+
+// ValidateOhlcRows checks the rows for impossible values (e.g. the high being
+// lower than the close), unexpected intervals and start times which are not
+// aligned to the interval. The closes of the rows which pass these checks are
+// compared with the neighbouring rows of the symbol, to find price spikes which
+// are more than MaxJumpSigma standard deviations from the median return.
+func ValidateOhlcRows(rows []OhlcRow, settings ...QualitySettings) QualityResult {
+	opt := DefaultQualitySettings
+	if len(settings) == 1 {
+		opt = settings[0]
+	}
+
+	bySymbol := make(map[string][]OhlcRow)
+	for _, row := range rows {
+		bySymbol[row.Symbol] = append(bySymbol[row.Symbol], row)
+	}
+
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	now := time.Now().UTC()
+	var res QualityResult
+
+	for _, symbol := range symbols {
+		data := bySymbol[symbol]
+		sort.Slice(data, func(i, j int) bool {
+			return data[i].StartTime.Before(data[j].StartTime)
+		})
+
+		reasons := make([][]string, len(data))
+		for i, row := range data {
+			reasons[i] = checkOhlcRow(row, opt.Interval)
+		}
+
+		checkPriceJumps(data, reasons, opt)
+
+		report := QualityReport{Symbol: symbol, NumRows: len(data), Reasons: map[string]int{}}
+		for i, row := range data {
+			if len(reasons[i]) == 0 {
+				res.Valid = append(res.Valid, row)
+				continue
+			}
+
+			report.NumQuarantined++
+			for _, reason := range reasons[i] {
+				report.Reasons[reason]++
+			}
+
+			res.Quarantined = append(res.Quarantined, QuarantinedRow{OhlcRow: row, Reasons: reasons[i], QuarantinedAt: now})
+		}
+
+		res.Reports = append(res.Reports, report)
+	}
+
+	return res
+}
+
+// checkOhlcRow returns the reasons for which the row fails the checks, which
+// don't depend on the other rows.
+func checkOhlcRow(row OhlcRow, interval int64) []string {
+	var reasons []string
+
+	if row.Open <= 0 || row.High <= 0 || row.Low <= 0 || row.Close <= 0 {
+		reasons = append(reasons, QualityNonPositivePrice)
+	}
+
+	if row.High < max(row.Open, row.Close) {
+		reasons = append(reasons, QualityHighBelowOpenClose)
+	}
+
+	if row.Low > min(row.Open, row.Close) {
+		reasons = append(reasons, QualityLowAboveOpenClose)
+	}
+
+	if row.Volume < 0 || row.BaseAssetVolume != nil && *row.BaseAssetVolume < 0 {
+		reasons = append(reasons, QualityNegativeVolume)
+	}
+
+	if interval != 0 && row.Interval != interval {
+		reasons = append(reasons, QualityIntervalMismatch)
+	}
+
+	if row.Interval <= 0 || row.StartTime.UnixMilli()%row.Interval != 0 {
+		reasons = append(reasons, QualityMisalignedStartTime)
+	}
+
+	return reasons
+}
+
+// checkPriceJumps appends the price jump reason to the rows, which are isolated
+// spikes: the log return from the previous close and the return to the next
+// close are both outliers and the next close reverts the jump. Level shifts (where the
+// price stays at the new level) are not rejected. The standard deviation is
+// estimated from the median absolute deviation of the returns, so that the
+// spikes don't inflate it. The rows are sorted.
+func checkPriceJumps(data []OhlcRow, reasons [][]string, opt QualitySettings) {
+	if opt.MaxJumpSigma <= 0 {
+		return
+	}
+
+	// indexes of the rows which passed the other checks
+	var idx []int
+	for i := range data {
+		if len(reasons[i]) == 0 {
+			idx = append(idx, i)
+		}
+	}
+
+	if len(idx) < 3 || len(idx)-1 < opt.MinJumpSamples {
+		return
+	}
+
+	returns := make([]float64, len(idx)-1)
+	for i := 1; i < len(idx); i++ {
+		returns[i-1] = math.Log(data[idx[i]].Close / data[idx[i-1]].Close)
+	}
+
+	med := median(returns)
+	deviations := make([]float64, len(returns))
+	for i, r := range returns {
+		deviations[i] = math.Abs(r - med)
+	}
+
+	// 1.4826 scales the MAD to the standard deviation of a normal distribution
+	sigma := 1.4826 * median(deviations)
+	if sigma == 0 {
+		return
+	}
+
+	limit := opt.MaxJumpSigma * sigma
+	for i := 1; i < len(returns); i++ {
+		in, out := returns[i-1]-med, returns[i]-med
+		// the next close has to revert at least half of the jump
+		reverted := (in > 0) != (out > 0) && math.Abs(in+out) < math.Abs(in)/2
+		if math.Abs(in) > limit && math.Abs(out) > limit && reverted {
+			row := idx[i]
+			reasons[row] = append(reasons[row], QualityPriceJump)
+		}
+	}
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// UpsertValidOhlcRows validates the rows and upserts the ones which passed the
+// checks into the store. The rejected rows are written to the quarantine
+// store, if it's not nil. The returned log is nil if all of the rows were
+// rejected.
+func UpsertValidOhlcRows(store OhlcStore, quarantine QuarantineStore, data []OhlcRow, settings ...QualitySettings) (*mongodb.UpsertLog, QualityResult, error) {
+	if len(data) == 0 {
+		upsertLog, err := store.UpsertOhlcRows(data)
+		return upsertLog, QualityResult{}, err
+	}
+
+	res := ValidateOhlcRows(data, settings...)
+
+	if len(res.Quarantined) > 0 && quarantine != nil {
+		for i := range res.Quarantined {
+			res.Quarantined[i].Collection = store.Name()
+		}
+
+		if err := quarantine.QuarantineOhlcRows(res.Quarantined); err != nil {
+			return nil, res, fmt.Errorf("failed to quarantine ohlc rows: %v", err)
+		}
+	}
+
+	if len(res.Valid) == 0 {
+		return nil, res, nil
+	}
+
+	upsertLog, err := store.UpsertOhlcRows(res.Valid)
+	return upsertLog, res, err
+}
@@ -0,0 +1,89 @@
+package market_dto
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateOhlcRows(t *testing.T) {
+	const minute = int64(time.Minute / time.Millisecond)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var rows []OhlcRow
+	for i := 0; i < 60; i++ {
+		t1 := start.Add(time.Duration(i) * time.Minute)
+		// small oscillation around 100
+		price := 100 + float64(i%5)*0.1
+		row, err := NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), price, price+0.5, price-0.5, price, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, *row)
+	}
+
+	rows[10].High = rows[10].Close - 1                       // high below the close
+	rows[20].Volume = -1                                     // negative volume
+	rows[30].StartTime = rows[30].StartTime.Add(time.Second) // not aligned to the minute
+	rows[40].Close, rows[40].High = 150, 150                 // isolated spike
+
+	// level shift, which should not be rejected
+	for i := 50; i < 60; i++ {
+		rows[i].Open, rows[i].High, rows[i].Low, rows[i].Close = 130, 131, 129, 130
+	}
+
+	other, _ := NewOhlcRow("ETHUSDT", start, start.Add(15*time.Minute), 1, 2, 0.5, 1.5, 1)
+	rows = append(rows, *other)
+
+	res := ValidateOhlcRows(rows, QualitySettings{Interval: minute, MaxJumpSigma: 10, MinJumpSamples: 30})
+
+	if len(res.Reports) != 2 || res.Reports[0].Symbol != "BTCUSDT" || res.Reports[1].Symbol != "ETHUSDT" {
+		t.Fatalf("unexpected reports: %+v", res.Reports)
+	}
+
+	btc := res.Reports[0]
+	expected := map[string]int{
+		QualityHighBelowOpenClose:  1,
+		QualityNegativeVolume:      1,
+		QualityMisalignedStartTime: 1,
+		QualityPriceJump:           1,
+	}
+
+	if btc.NumRows != 60 || btc.NumQuarantined != 4 {
+		t.Fatalf("unexpected btc report: %+v", btc)
+	}
+
+	for reason, num := range expected {
+		if btc.Reasons[reason] != num {
+			t.Fatalf("expected %d rows with %v, got %+v", num, reason, btc.Reasons)
+		}
+	}
+
+	if eth := res.Reports[1]; eth.NumQuarantined != 1 || eth.Reasons[QualityIntervalMismatch] != 1 {
+		t.Fatalf("unexpected eth report: %+v", eth)
+	}
+
+	if len(res.Valid) != 56 {
+		t.Fatalf("expected 56 valid rows, got %d", len(res.Valid))
+	}
+
+	t.Run("quarantine on upsert", func(t *testing.T) {
+		store := NewMemoryOhlcStore("crypto_spot_ohlc")
+		quarantine := NewMemoryQuarantineStore("ohlc_quarantine")
+
+		if _, _, err := UpsertValidOhlcRows(store, quarantine, rows, QualitySettings{Interval: minute}); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := store.GetOhlcRows(OhlcFilter{})
+		if len(stored) != 57 {
+			t.Fatalf("expected 57 stored rows, got %d", len(stored))
+		}
+
+		docs, _ := quarantine.GetQuarantinedRows(OhlcFilter{Symbol: "BTCUSDT"})
+		if len(docs) != 3 || docs[0].Collection != "crypto_spot_ohlc" || !slices.Contains(docs[0].Reasons, QualityHighBelowOpenClose) {
+			t.Fatalf("unexpected quarantined rows: %+v", docs)
+		}
+	})
+}
@@ -0,0 +1,318 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/timeset"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar units of the resolutions
+const (
+	CalendarWeek  = "week"  // Weeks starting on Monday (UTC)
+	CalendarMonth = "month" // Calendar months (UTC)
+)
+
+// Resolution is the target interval of resampled rows. Calendar resolutions
+// (weeks and months) don't have a fixed length, so they are defined with a
+// unit and a count instead of an interval.
+type Resolution struct {
+	Interval int64  // Fixed interval in milliseconds, aligned to the unix epoch
+	Unit     string // CalendarWeek or CalendarMonth
+	Count    int    // Number of calendar units in a bucket (e.g. 3 for quarters)
+}
+
+// ParseResolution parses the fixed intervals (e.g. 15m, 4h, 1d) and the
+// calendar weeks and months (1w, 1M, 3M). Months have to divide a year, so
+// that the buckets are aligned to the calendar year, and only single weeks
+// are supported.
+func ParseResolution(s string) (Resolution, error) {
+	if count, ok := strings.CutSuffix(s, "M"); ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 || 12%n != 0 {
+			return Resolution{}, fmt.Errorf("invalid resolution: %v", s)
+		}
+		return Resolution{Unit: CalendarMonth, Count: n}, nil
+	}
+
+	if s == "1w" {
+		return Resolution{Unit: CalendarWeek, Count: 1}, nil
+	}
+
+	d, err := timeset.ParseInterval(s)
+	if err != nil {
+		return Resolution{}, fmt.Errorf("invalid resolution: %v", s)
+	}
+
+	return Resolution{Interval: d.Milliseconds()}, nil
+}
+
+func (r Resolution) IsCalendar() bool { return r.Unit != "" }
+
+// BucketStart returns the start of the bucket in which the time is.
+func (r Resolution) BucketStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	switch r.Unit {
+	case CalendarWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7 // days since monday
+		return day.AddDate(0, 0, -offset)
+	case CalendarMonth:
+		month := int(t.Month()) - 1
+		return time.Date(t.Year(), time.Month(month-month%r.Count+1), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return BucketStart(t, r.Interval)
+	}
+}
+
+// BucketEnd returns the end (exclusive) of the bucket which starts at the time.
+func (r Resolution) BucketEnd(start time.Time) time.Time {
+	switch r.Unit {
+	case CalendarWeek:
+		return start.AddDate(0, 0, 7*r.Count)
+	case CalendarMonth:
+		return start.AddDate(0, r.Count, 0)
+	default:
+		return start.Add(timeset.MilisToDuration(r.Interval))
+	}
+}
+
+// Divides returns true if the buckets of the resolution can be built from
+// rows of the interval.
+func (r Resolution) Divides(interval int64) bool {
+	if interval <= 0 {
+		return false
+	}
+
+	if r.IsCalendar() {
+		return int64(24*time.Hour/time.Millisecond)%interval == 0
+	}
+
+	return r.Interval%interval == 0
+}
+
+// resampledRow is a resampled bucket with the start time of its latest source row.
+type resampledRow struct {
+	row  OhlcRow
+	last time.Time
+}
+
+// ResampleOhlcRows merges the rows into the buckets of the resolution. The
+// interval of the resampled rows is the length of their bucket. The trailing
+// bucket of a series, which does not hold the rows up to its end (e.g. the
+// current day), is only returned if partial is true.
+func ResampleOhlcRows(rows []OhlcRow, res Resolution, partial bool) ([]OhlcRow, error) {
+	type bucketKey struct {
+		source string
+		market string
+		symbol string
+		start  int64
+	}
+
+	sorted := make([]OhlcRow, len(rows))
+	copy(sorted, rows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	var srcInterval int64
+	buckets := make(map[bucketKey][]OhlcRow)
+	var keys []bucketKey
+
+	for _, row := range sorted {
+		if !res.Divides(row.Interval) {
+			return nil, fmt.Errorf("resolution %+v can't be built from rows of the %v interval", res, row.Interval)
+		}
+		srcInterval = row.Interval
+
+		k := bucketKey{row.Source, row.Market, row.Symbol, res.BucketStart(row.StartTime).UnixMilli()}
+		if _, ok := buckets[k]; !ok {
+			keys = append(keys, k)
+		}
+		buckets[k] = append(buckets[k], row)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].start < keys[j].start })
+
+	resampled := make([]resampledRow, len(keys))
+	for i, k := range keys {
+		bucket := buckets[k]
+		start := time.UnixMilli(k.start).UTC()
+		interval := res.BucketEnd(start).Sub(start).Milliseconds()
+
+		resampled[i] = resampledRow{mergeOhlcRows(start, interval, bucket), bucket[len(bucket)-1].StartTime}
+	}
+
+	return trimPartialBuckets(resampled, srcInterval, partial), nil
+}
+
+// trimPartialBuckets returns the rows of the sorted buckets. Unless partial is
+// true, the trailing bucket of each series is left out if its latest source
+// row ends before the end of the bucket.
+func trimPartialBuckets(buckets []resampledRow, srcInterval int64, partial bool) []OhlcRow {
+	type seriesKey struct {
+		source string
+		market string
+		symbol string
+	}
+
+	trailing := make(map[seriesKey]int)
+	for i, b := range buckets {
+		trailing[seriesKey{b.row.Source, b.row.Market, b.row.Symbol}] = i
+	}
+
+	docs := make([]OhlcRow, 0, len(buckets))
+	for i, b := range buckets {
+		if !partial && trailing[seriesKey{b.row.Source, b.row.Market, b.row.Symbol}] == i {
+			end := b.row.StartTime.Add(timeset.MilisToDuration(b.row.Interval))
+			if b.last.Add(timeset.MilisToDuration(srcInterval)).Before(end) {
+				continue
+			}
+		}
+		docs = append(docs, b.row)
+	}
+
+	return docs
+}
+
+// resampleStoredRows resamples the rows of the store in memory. Used by the
+// stores which can't aggregate the rows in the database.
+func resampleStoredRows(store OhlcStore, filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error) {
+	if filter.Interval == 0 {
+		return nil, fmt.Errorf("interval of the source rows is required")
+	}
+
+	return resampleWindows(store, filter, res, partial, func(filter OhlcFilter, partial bool) ([]OhlcRow, error) {
+		limit := filter.Limit
+		filter.Limit = 0
+
+		rows, err := store.GetOhlcRows(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		docs, err := ResampleOhlcRows(rows, res, partial)
+		if err != nil {
+			return nil, err
+		}
+
+		if limit > 0 && int64(len(docs)) > limit {
+			docs = docs[:limit]
+		}
+		return docs, nil
+	})
+}
+
+// resampleWindows calls the resample fn with the windows of the limit buckets
+// (plus the bucket which shows if the last one is the trailing bucket), so
+// that only the source rows of the returned buckets are read. Each window
+// starts at the first row after the previous one, which skips the gaps. The
+// partial flag only applies to the last window, as the buckets of the others
+// are followed by stored rows.
+func resampleWindows(store OhlcStore, filter OhlcFilter, res Resolution, partial bool, resample func(OhlcFilter, bool) ([]OhlcRow, error)) ([]OhlcRow, error) {
+	if filter.Limit <= 0 {
+		return resample(filter, partial)
+	}
+
+	// first returns the start time of the first source row in the range
+	first := func(from time.Time) (time.Time, bool, error) {
+		next := filter
+		next.From, next.Limit = from, 1
+
+		rows, err := store.GetOhlcRows(next)
+		if err != nil || len(rows) == 0 {
+			return time.Time{}, false, err
+		}
+		return rows[0].StartTime, true, nil
+	}
+
+	start, ok, err := first(filter.From)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var docs []OhlcRow
+	for ok && int64(len(docs)) < filter.Limit {
+		window := filter
+		window.Limit = filter.Limit - int64(len(docs))
+		window.From = res.BucketStart(start)
+
+		end := window.From
+		for i := int64(0); i <= window.Limit; i++ {
+			end = res.BucketEnd(end)
+		}
+
+		window.To = end.Add(-time.Millisecond)
+		if !filter.To.IsZero() && filter.To.Before(window.To) {
+			window.To = filter.To
+		}
+
+		if start, ok, err = first(window.To.Add(time.Millisecond)); err != nil {
+			return nil, err
+		}
+
+		rows, err := resample(window, partial || ok)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, rows...)
+	}
+
+	return docs, nil
+}
+
+// OhlcResampler resamples the sorted rows of a series one bucket at a time,
+// so that large ranges don't have to be held in memory. The merged rows are
+// passed to the fn once the rows of the next bucket are added.
+type OhlcResampler struct {
+	res     Resolution
+	partial bool
+	fn      func(OhlcRow) error
+	start   time.Time // start of the current bucket
+	bucket  []OhlcRow
+}
+
+// NewOhlcResampler returns a resampler which passes the rows of the resolution
+// to the fn. The partial flag applies to the trailing bucket (see ResampleOhlcRows).
+func NewOhlcResampler(res Resolution, partial bool, fn func(OhlcRow) error) *OhlcResampler {
+	return &OhlcResampler{res: res, partial: partial, fn: fn}
+}
+
+// Add adds the row to its bucket. The rows have to be added in the order of
+// their start time.
+func (r *OhlcResampler) Add(row OhlcRow) error {
+	start := r.res.BucketStart(row.StartTime)
+	if len(r.bucket) > 0 && !start.Equal(r.start) {
+		// only the trailing bucket can be partial
+		if err := r.flush(true); err != nil {
+			return err
+		}
+	}
+
+	r.start = start
+	r.bucket = append(r.bucket, row)
+	return nil
+}
+
+// Close passes the trailing bucket to the fn.
+func (r *OhlcResampler) Close() error {
+	return r.flush(r.partial)
+}
+
+func (r *OhlcResampler) flush(partial bool) error {
+	docs, err := ResampleOhlcRows(r.bucket, r.res, partial)
+	if err != nil {
+		return err
+	}
+	r.bucket = r.bucket[:0]
+
+	for _, doc := range docs {
+		if err := r.fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
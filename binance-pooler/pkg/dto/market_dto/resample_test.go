@@ -0,0 +1,159 @@
+package market_dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolution(t *testing.T) {
+	tests := []struct {
+		res   string
+		t     time.Time
+		start time.Time
+		end   time.Time
+	}{
+		{"4h", time.Date(2024, 1, 3, 5, 30, 0, 0, time.UTC), time.Date(2024, 1, 3, 4, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)},
+		{"1w", time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"1w", time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"1M", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"3M", time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		res, err := ParseResolution(tt.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := res.BucketStart(tt.t)
+		if !start.Equal(tt.start) || !res.BucketEnd(start).Equal(tt.end) {
+			t.Fatalf("unexpected bucket of %v at %v: %v - %v", tt.res, tt.t, start, res.BucketEnd(start))
+		}
+	}
+
+	for _, s := range []string{"", "5M", "2w", "0h", "1y"} {
+		if _, err := ParseResolution(s); err == nil {
+			t.Fatalf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestOhlcResampler(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 2.5 hours of 15m rows
+	var rows []OhlcRow
+	for i := 0; i < 10; i++ {
+		start := t1.Add(time.Duration(i) * 15 * time.Minute)
+		row, err := NewOhlcRow("BTCUSDT", start, start.Add(15*time.Minute), float64(i), float64(i+2), float64(i-1), float64(i+1), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, *row)
+	}
+
+	res := Resolution{Interval: time.Hour.Milliseconds()}
+
+	for _, partial := range []bool{false, true} {
+		var docs []OhlcRow
+		r := NewOhlcResampler(res, partial, func(row OhlcRow) error {
+			docs = append(docs, row)
+			return nil
+		})
+
+		for _, row := range rows {
+			if err := r.Add(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := ResampleOhlcRows(rows, res, partial)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != len(expected) {
+			t.Fatalf("expected %v rows with partial %v, got %v", len(expected), partial, len(docs))
+		}
+
+		for i := range docs {
+			if docs[i].Open != expected[i].Open || docs[i].Close != expected[i].Close || docs[i].Volume != expected[i].Volume {
+				t.Fatalf("unexpected row %v: %+v", i, docs[i])
+			}
+		}
+	}
+}
+
+// countingStore counts the rows which are read from the store.
+type countingStore struct {
+	OhlcStore
+	read int
+}
+
+func (s *countingStore) GetOhlcRows(filter OhlcFilter) ([]OhlcRow, error) {
+	rows, err := s.OhlcStore.GetOhlcRows(filter)
+	s.read += len(rows)
+	return rows, err
+}
+
+func TestResampleWindows(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 15m rows of the hours 0, 1, 5, 6, 7 and the first half of the hour 8
+	var rows []OhlcRow
+	for _, hour := range []int{0, 1, 5, 6, 7, 8} {
+		for i := 0; i < 4; i++ {
+			if hour == 8 && i == 2 {
+				break
+			}
+			start := t1.Add(time.Duration(hour)*time.Hour + time.Duration(i)*15*time.Minute)
+			row, err := NewOhlcRow("BTCUSDT", start, start.Add(15*time.Minute), 1, 2, 0, 1, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+	}
+
+	store := &countingStore{OhlcStore: NewMemoryOhlcStore("test")}
+	if _, err := store.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	res := Resolution{Interval: time.Hour.Milliseconds()}
+	filter := OhlcFilter{Symbol: "BTCUSDT", Interval: 15 * 60_000, Limit: 3}
+
+	docs, err := resampleStoredRows(store, filter, res, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 3 || !docs[2].StartTime.Equal(t1.Add(5*time.Hour)) {
+		t.Fatalf("unexpected resampled rows: %+v", docs)
+	}
+
+	// the rows after the last returned bucket (and the extra one) are not read
+	if store.read > 20 {
+		t.Fatalf("expected the rows of the limited buckets to be read, got %v rows", store.read)
+	}
+
+	// the buckets before the gaps are not trailing
+	for _, partial := range []bool{false, true} {
+		filter.Limit = 10
+		docs, err := resampleStoredRows(store, filter, res, partial)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := 5
+		if partial {
+			expected = 6
+		}
+		if len(docs) != expected || docs[0].Volume != 4 {
+			t.Fatalf("expected %v rows with partial %v, got %+v", expected, partial, docs)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OhlcRevision is a previous version of a stored row, which was replaced by
+// a write with different values.
+type OhlcRevision struct {
+	OhlcRow    `bson:",inline"`
+	Collection string    `json:"collection" bson:"collection"` // Collection of the replaced row
+	RevisedAt  time.Time `json:"revised_at" bson:"revised_at"` // Fetch time of the values which replaced the row
+}
+
+// RevisionIndexes returns the indexes of the revisions collection. The rows
+// are keyed by the source and market first, like the ohlc rows.
+func RevisionIndexes() *mongodb.IndexBuilder {
+	return mongodb.NewIndexes().
+		Add("source", "market", "collection", "symbol", "interval", mongodb.START_TIME).
+		Add("revised_at")
+}
+
+func CreateRevisionIndexes(coll *mongo.Collection) error {
+	return RevisionIndexes().Create(coll)
+}
+
+// reviseOhlcRows sets the provenance fields of the rows which are about to
+// be written over the existing ones and returns the replaced versions.
+//
+//   - new rows get the revision 0
+//   - rows with the same values keep the fetch time, endpoint and revision of
+//     the stored row, so that the fetch time is the time at which the values
+//     were first seen
+//   - rows with different values replace the stored row and increment the
+//     revision. Stored rows which were fetched before the candle closed are
+//     overwritten without a revision, as the updates of an open candle are
+//     not corrections.
+//   - rows which were fetched before the stored row keep the stored values
+func reviseOhlcRows(collection string, existing map[ohlcKey]OhlcRow, data []OhlcRow, now time.Time) []OhlcRevision {
+	var revisions []OhlcRevision
+
+	for i := range data {
+		row := &data[i]
+		if row.FetchedAt.IsZero() {
+			row.FetchedAt = now
+		}
+
+		curr, ok := existing[row.key()]
+		if !ok {
+			row.Revision = 0
+			continue
+		}
+
+		if len(diffOhlcFields(curr, *row, 0)) == 0 {
+			row.FetchedAt, row.Revision = curr.FetchedAt, curr.Revision
+			if row.Endpoint == "" || curr.Endpoint != "" {
+				row.Endpoint = curr.Endpoint
+			}
+			// rows written before the provenance fields were added
+			if row.FetchedAt.IsZero() {
+				row.FetchedAt = now
+			}
+			continue
+		}
+
+		// responses which are older than the stored values (e.g. when the
+		// collections are rebuilt from the raw lake) don't replace them
+		if row.FetchedAt.Before(curr.FetchedAt) {
+			*row = curr
+			continue
+		}
+
+		closedAt := curr.StartTime.Add(time.Duration(curr.Interval) * time.Millisecond)
+		if !curr.FetchedAt.IsZero() && curr.FetchedAt.Before(closedAt) {
+			row.Revision = curr.Revision
+			continue
+		}
+
+		row.Revision = curr.Revision + 1
+		revisions = append(revisions, OhlcRevision{OhlcRow: curr, Collection: collection, RevisedAt: row.FetchedAt})
+	}
+
+	return revisions
+}
+
+// GetOhlcRowsAsOf returns the rows of the store which match the filter, as
+// they were stored at the given time. Replaced rows are read from the
+// revisions. Rows which were first fetched after the time are left out, as
+// are rows whose value at the time was an update of an open candle. Rows
+// written before the fetch time was recorded are treated as always known.
+func GetOhlcRowsAsOf(store OhlcStore, revisions RevisionStore, filter OhlcFilter, asOf time.Time) ([]OhlcRow, error) {
+	limit := filter.Limit
+	filter.Limit = 0
+
+	rows, err := store.GetOhlcRows(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	revs, err := revisions.GetOhlcRevisions(store.Name(), filter.withMarket(rowsMarket(rows)))
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[ohlcKey][]OhlcRevision)
+	for _, rev := range revs {
+		k := rev.key()
+		byKey[k] = append(byKey[k], rev)
+	}
+
+	var docs []OhlcRow
+	for _, row := range rows {
+		if !row.FetchedAt.After(asOf) {
+			docs = append(docs, row)
+			continue
+		}
+
+		// the version which was stored at the time is the first one replaced after it
+		history := byKey[row.key()]
+		sort.Slice(history, func(i, j int) bool {
+			return history[i].RevisedAt.Before(history[j].RevisedAt)
+		})
+
+		for _, rev := range history {
+			if rev.RevisedAt.After(asOf) {
+				if !rev.FetchedAt.After(asOf) {
+					docs = append(docs, rev.OhlcRow)
+				}
+				break
+			}
+		}
+	}
+
+	if limit > 0 && int64(len(docs)) > limit {
+		docs = docs[:limit]
+	}
+
+	return docs, nil
+}
+
+// rowsMarket returns the source and market of the rows, or empty strings if
+// the rows don't share them.
+func rowsMarket(rows []OhlcRow) (string, string) {
+	source, market := rows[0].Source, rows[0].Market
+	for _, row := range rows {
+		if row.Source != source || row.Market != market {
+			return "", ""
+		}
+	}
+	return source, market
+}
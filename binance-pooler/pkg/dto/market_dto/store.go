@@ -0,0 +1,156 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"errors"
+	"time"
+)
+
+// OhlcStore defines the methods used by the services to read and
+// write ohlc timeseries data, so that the scrapers are not tied
+// to a specific database.
+type OhlcStore interface {
+	// Name returns the name of the underlying table / collection
+	Name() string
+	// UpsertOhlcRows inserts or updates the rows, identified by the symbol, interval and start time
+	UpsertOhlcRows(data []OhlcRow) (*mongodb.UpsertLog, error)
+	// FindLatestStartTime returns the latest start time for the symbol and interval, or
+	// the defaultStart if there are no rows.
+	FindLatestStartTime(defaultStart time.Time, symbol string, interval int64) (time.Time, error)
+	// FindGaps returns the gaps in the stored rows of the symbol and interval
+	FindGaps(symbol string, interval int64) (map[int64][]mongodb.GapInfo, error)
+	// GetOhlcRows returns the rows which match the filter, sorted by the start time
+	GetOhlcRows(filter OhlcFilter) ([]OhlcRow, error)
+	// StreamOhlcRows calls the fn with each row which matches the filter, sorted
+	// by the start time, without loading all of the rows into memory. It stops
+	// at the first error of the fn, which is returned.
+	StreamOhlcRows(filter OhlcFilter, fn func(OhlcRow) error) error
+	// DeleteOhlcRows deletes the rows which match the filter and returns the number of deleted rows
+	DeleteOhlcRows(filter OhlcFilter) (int64, error)
+	// SetMissingMarket sets the source and market of the stored rows which don't
+	// have them and returns the number of updated rows
+	SetMissingMarket(source, market string) (int64, error)
+	// GetCoverage returns the stored time range of each interval of the symbol,
+	// sorted by the interval
+	GetCoverage(symbol string) ([]OhlcCoverage, error)
+	// ResampleOhlcRows merges the rows which match the filter into the buckets
+	// of the resolution (see ResampleOhlcRows). The interval of the filter is the
+	// interval of the source rows and the limit is the number of buckets.
+	ResampleOhlcRows(filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error)
+}
+
+// AssetStore defines the methods used by the services to read
+// and write the asset info.
+type AssetStore interface {
+	// Name returns the name of the underlying table / collection
+	Name() string
+	// UpsertAssets inserts or updates the assets, identified by the symbol and source
+	UpsertAssets(data []Asset[any]) (*mongodb.UpsertLog, error)
+	// CountAssets returns the number of stored assets for the source
+	CountAssets(source string) (int64, error)
+	// GetAssets returns the assets which match the filter
+	GetAssets(filter AssetFilter) ([]AssetBase, error)
+	// GetAsset returns the asset with its data, or ErrAssetNotFound
+	GetAsset(source, symbol string) (*Asset[any], error)
+}
+
+var ErrAssetNotFound = errors.New("asset not found")
+
+// QuarantineStore holds the ohlc rows which failed the quality checks.
+// Rows are appended, so the same row can be quarantined more than once.
+type QuarantineStore interface {
+	// Name returns the name of the underlying table / collection
+	Name() string
+	// QuarantineOhlcRows stores the rejected rows
+	QuarantineOhlcRows(data []QuarantinedRow) error
+	// GetQuarantinedRows returns the rows which match the filter, sorted by the start time
+	GetQuarantinedRows(filter OhlcFilter) ([]QuarantinedRow, error)
+}
+
+// DriftStore holds the reports of the reconciliation runs.
+type DriftStore interface {
+	// Name returns the name of the underlying table / collection
+	Name() string
+	// InsertDriftReport stores the report
+	InsertDriftReport(report DriftReport) error
+	// GetDriftReports returns the reports which match the filter, newest first
+	GetDriftReports(filter DriftFilter) ([]DriftReport, error)
+}
+
+// RevisionStore holds the previous versions of the ohlc rows, which were
+// replaced by writes with different values.
+type RevisionStore interface {
+	// Name returns the name of the underlying table / collection
+	Name() string
+	// InsertOhlcRevisions stores the replaced rows
+	InsertOhlcRevisions(data []OhlcRevision) error
+	// GetOhlcRevisions returns the replaced rows of the collection which match
+	// the filter, sorted by the start time and the revision time
+	GetOhlcRevisions(collection string, filter OhlcFilter) ([]OhlcRevision, error)
+}
+
+// OhlcFilter holds the optional parameters for querying ohlc rows. Zero
+// values are ignored.
+type OhlcFilter struct {
+	Source   string
+	Market   string
+	Symbol   string
+	Interval int64
+	From     time.Time // inclusive
+	To       time.Time // inclusive
+	Limit    int64     // ignored when deleting rows
+}
+
+// withMarket sets the source and market of the filter to the ones of the
+// store, unless they are set, so that the rows of the other venues which
+// share the collection are not read.
+func (f OhlcFilter) withMarket(source, market string) OhlcFilter {
+	if f.Source == "" {
+		f.Source = source
+	}
+	if f.Market == "" {
+		f.Market = market
+	}
+	return f
+}
+
+// OhlcCoverage is the stored time range of an interval of a symbol.
+type OhlcCoverage struct {
+	Interval int64     `json:"interval"`
+	From     time.Time `json:"from"` // Start time of the first row
+	To       time.Time `json:"to"`   // Start time of the latest row
+	Count    int64     `json:"count"`
+}
+
+// AssetFilter holds the optional parameters for querying assets. Zero
+// values are ignored.
+type AssetFilter struct {
+	Source       string
+	Symbols      []string
+	Status       string
+	BaseAsset    string
+	QuoteAsset   string
+	ContractType string   // Contract type of the futures assets (e.g. PERPETUAL)
+	OrderTypes   []string // Assets which support all of the order types
+	Search       string   // Case insensitive substring of the symbol
+}
+
+// AnyAssets converts the typed assets into the format accepted by the AssetStore.
+func AnyAssets[T any](data []Asset[T]) []Asset[any] {
+	assets := make([]Asset[any], len(data))
+	for i, row := range data {
+		assets[i] = Asset[any]{AssetBase: row.AssetBase, Data: row.Data}
+	}
+	return assets
+}
+
+// DriftFilter holds the optional parameters for querying drift reports. Zero
+// values are ignored.
+type DriftFilter struct {
+	Collection string
+	Source     string
+	Market     string
+	Symbol     string
+	Interval   int64
+	Limit      int64
+}
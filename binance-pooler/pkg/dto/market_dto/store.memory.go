@@ -0,0 +1,559 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDbName is used in the upsert logs of the in-memory stores.
+const memoryDbName = "memory"
+
+// MemoryOhlcStore is a thread safe, in-memory implementation of the
+// OhlcStore interface, used for tests and dry runs.
+type MemoryOhlcStore struct {
+	name      string
+	mu        sync.RWMutex
+	rows      map[ohlcKey]OhlcRow
+	revisions RevisionStore
+	source    string
+	market    string
+}
+
+func NewMemoryOhlcStore(name string) *MemoryOhlcStore {
+	return &MemoryOhlcStore{name: name, rows: make(map[ohlcKey]OhlcRow)}
+}
+
+// WithRevisions stores the replaced versions of the rows in the store.
+func (s *MemoryOhlcStore) WithRevisions(revisions RevisionStore) *MemoryOhlcStore {
+	s.revisions = revisions
+	return s
+}
+
+// WithMarket sets the source and market of the rows written without them.
+func (s *MemoryOhlcStore) WithMarket(source, market string) *MemoryOhlcStore {
+	s.source, s.market = source, market
+	return s
+}
+
+func (s *MemoryOhlcStore) Name() string { return s.name }
+
+func (s *MemoryOhlcStore) UpsertOhlcRows(data []OhlcRow) (*mongodb.UpsertLog, error) {
+	start := time.Now()
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to upsert")
+	}
+
+	setDefaultMarket(data, s.source, s.market)
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].StartTime.Before(data[j].StartTime)
+	})
+
+	for _, row := range data {
+		if row.Symbol == "" {
+			return nil, fmt.Errorf("symbol is empty")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions := reviseOhlcRows(s.name, s.rows, data, start.UTC())
+
+	for _, row := range data {
+		s.rows[row.key()] = row
+	}
+
+	if len(revisions) > 0 && s.revisions != nil {
+		if err := s.revisions.InsertOhlcRevisions(revisions); err != nil {
+			return nil, fmt.Errorf("failed to store ohlc revisions: %v", err)
+		}
+	}
+
+	return &mongodb.UpsertLog{
+		DbName:          memoryDbName,
+		CollectionName:  s.name,
+		FirstStartTime:  data[0].StartTime,
+		LastStartTime:   data[len(data)-1].StartTime,
+		NumUpsertedRows: len(data),
+		ElapsedTime:     time.Since(start).Seconds(),
+	}, nil
+}
+
+func (s *MemoryOhlcStore) FindLatestStartTime(defaultStart time.Time, symbol string, interval int64) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter := OhlcFilter{Symbol: symbol, Interval: interval}.withMarket(s.source, s.market)
+
+	var latest time.Time
+	for _, row := range s.rows {
+		if filter.matches(row) && row.StartTime.After(latest) {
+			latest = row.StartTime
+		}
+	}
+
+	if latest.IsZero() {
+		return defaultStart, nil
+	}
+
+	return latest, nil
+}
+
+func (s *MemoryOhlcStore) FindGaps(symbol string, interval int64) (map[int64][]mongodb.GapInfo, error) {
+	rows, err := s.GetOhlcRows(OhlcFilter{Symbol: symbol, Interval: interval})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]mongodb.TimeseriesFields, len(rows))
+	for i, row := range rows {
+		records[i] = row.TimeseriesFields
+	}
+
+	gapsMap := make(map[int64][]mongodb.GapInfo)
+	if gaps := mongodb.FindGapsInSeries(records); len(gaps) > 0 {
+		gapsMap[interval] = gaps
+	}
+
+	return gapsMap, nil
+}
+
+func (s *MemoryOhlcStore) GetOhlcRows(filter OhlcFilter) ([]OhlcRow, error) {
+	filter = filter.withMarket(s.source, s.market)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []OhlcRow
+	for _, row := range s.rows {
+		if filter.matches(row) {
+			docs = append(docs, row)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].StartTime.Before(docs[j].StartTime)
+	})
+
+	if filter.Limit > 0 && int64(len(docs)) > filter.Limit {
+		docs = docs[:filter.Limit]
+	}
+
+	return docs, nil
+}
+
+func (s *MemoryOhlcStore) StreamOhlcRows(filter OhlcFilter, fn func(OhlcRow) error) error {
+	docs, err := s.GetOhlcRows(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range docs {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryOhlcStore) DeleteOhlcRows(filter OhlcFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter = filter.withMarket(s.source, s.market)
+
+	var deleted int64
+	for k, row := range s.rows {
+		if filter.matches(row) {
+			delete(s.rows, k)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *MemoryOhlcStore) SetMissingMarket(source, market string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated int64
+	for k, row := range s.rows {
+		if row.Source != "" && row.Market != "" {
+			continue
+		}
+
+		rows := []OhlcRow{row}
+		setDefaultMarket(rows, source, market)
+
+		delete(s.rows, k)
+		s.rows[rows[0].key()] = rows[0]
+		updated++
+	}
+
+	return updated, nil
+}
+
+func (s *MemoryOhlcStore) ResampleOhlcRows(filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error) {
+	return resampleStoredRows(s, filter, res, partial)
+}
+
+func (s *MemoryOhlcStore) GetCoverage(symbol string) ([]OhlcCoverage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter := OhlcFilter{Symbol: symbol}.withMarket(s.source, s.market)
+
+	byInterval := make(map[int64]*OhlcCoverage)
+	for _, row := range s.rows {
+		if !filter.matches(row) {
+			continue
+		}
+
+		c, ok := byInterval[row.Interval]
+		if !ok {
+			c = &OhlcCoverage{Interval: row.Interval, From: row.StartTime, To: row.StartTime}
+			byInterval[row.Interval] = c
+		}
+
+		if row.StartTime.Before(c.From) {
+			c.From = row.StartTime
+		}
+		if row.StartTime.After(c.To) {
+			c.To = row.StartTime
+		}
+		c.Count++
+	}
+
+	var coverage []OhlcCoverage
+	for _, c := range byInterval {
+		coverage = append(coverage, *c)
+	}
+
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Interval < coverage[j].Interval })
+	return coverage, nil
+}
+
+// matches mirrors the query created by ohlcFilterToBson for in-memory rows.
+func (f OhlcFilter) matches(row OhlcRow) bool {
+	if f.Source != "" && row.Source != f.Source {
+		return false
+	}
+
+	if f.Market != "" && row.Market != f.Market {
+		return false
+	}
+
+	if f.Symbol != "" && row.Symbol != f.Symbol {
+		return false
+	}
+
+	if f.Interval != 0 && row.Interval != f.Interval {
+		return false
+	}
+
+	if !f.From.IsZero() && row.StartTime.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && row.StartTime.After(f.To) {
+		return false
+	}
+
+	return true
+}
+
+type assetKey struct {
+	source string
+	symbol string
+}
+
+// MemoryAssetStore is a thread safe, in-memory implementation of the
+// AssetStore interface, used for tests and dry runs.
+type MemoryAssetStore struct {
+	name   string
+	mu     sync.RWMutex
+	assets map[assetKey]Asset[any]
+}
+
+func NewMemoryAssetStore(name string) *MemoryAssetStore {
+	return &MemoryAssetStore{name: name, assets: make(map[assetKey]Asset[any])}
+}
+
+func (s *MemoryAssetStore) Name() string { return s.name }
+
+func (s *MemoryAssetStore) UpsertAssets(data []Asset[any]) (*mongodb.UpsertLog, error) {
+	start := time.Now()
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to upsert")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, row := range data {
+		if row.AssetBase.Symbol == "" || row.AssetBase.Source == "" {
+			return nil, fmt.Errorf("symbol or source is empty")
+		}
+
+		key := assetKey{row.AssetBase.Source, row.AssetBase.Symbol}
+		if existing, ok := s.assets[key]; ok && row.Data == nil {
+			row.Data = existing.Data
+		}
+
+		s.assets[key] = row
+	}
+
+	return &mongodb.UpsertLog{
+		DbName:          memoryDbName,
+		CollectionName:  s.name,
+		NumUpsertedRows: len(data),
+		ElapsedTime:     time.Since(start).Seconds(),
+	}, nil
+}
+
+func (s *MemoryAssetStore) CountAssets(source string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for k := range s.assets {
+		if k.source == source {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *MemoryAssetStore) GetAssets(filter AssetFilter) ([]AssetBase, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []AssetBase
+	for _, asset := range s.assets {
+		if filter.matches(asset) {
+			docs = append(docs, asset.AssetBase)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Symbol < docs[j].Symbol })
+	return docs, nil
+}
+
+func (s *MemoryAssetStore) GetAsset(source, symbol string) (*Asset[any], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	asset, ok := s.assets[assetKey{source, symbol}]
+	if !ok {
+		return nil, ErrAssetNotFound
+	}
+
+	return &asset, nil
+}
+
+// matches mirrors the query created by assetFilterToBson for in-memory assets.
+func (f AssetFilter) matches(asset Asset[any]) bool {
+	base := asset.AssetBase
+
+	if f.Source != "" && base.Source != f.Source {
+		return false
+	}
+
+	if len(f.Symbols) > 0 && !slices.Contains(f.Symbols, base.Symbol) {
+		return false
+	}
+
+	if f.Search != "" && !strings.Contains(strings.ToLower(base.Symbol), strings.ToLower(f.Search)) {
+		return false
+	}
+
+	if f.Status != "" && base.Status != f.Status {
+		return false
+	}
+
+	if f.BaseAsset != "" && base.BaseAsset != f.BaseAsset {
+		return false
+	}
+
+	if f.QuoteAsset != "" && base.QuoteAsset != f.QuoteAsset {
+		return false
+	}
+
+	if f.ContractType != "" && assetContractType(asset.Data) != f.ContractType {
+		return false
+	}
+
+	for _, orderType := range f.OrderTypes {
+		if !slices.Contains(base.OrderTypes, orderType) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// assetContractType returns the contract_type field of the asset data, which
+// is only set for the futures assets.
+func assetContractType(data any) string {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+
+	var fields struct {
+		ContractType string `json:"contract_type"`
+	}
+	json.Unmarshal(b, &fields)
+	return fields.ContractType
+}
+
+// MemoryQuarantineStore is an in-memory implementation of the QuarantineStore interface.
+type MemoryQuarantineStore struct {
+	name string
+	mu   sync.RWMutex
+	rows []QuarantinedRow
+}
+
+func NewMemoryQuarantineStore(name string) *MemoryQuarantineStore {
+	return &MemoryQuarantineStore{name: name}
+}
+
+func (s *MemoryQuarantineStore) Name() string { return s.name }
+
+func (s *MemoryQuarantineStore) QuarantineOhlcRows(data []QuarantinedRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rows = append(s.rows, data...)
+	return nil
+}
+
+func (s *MemoryQuarantineStore) GetQuarantinedRows(filter OhlcFilter) ([]QuarantinedRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []QuarantinedRow
+	for _, row := range s.rows {
+		if filter.matches(row.OhlcRow) {
+			docs = append(docs, row)
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].StartTime.Before(docs[j].StartTime)
+	})
+
+	if filter.Limit > 0 && int64(len(docs)) > filter.Limit {
+		docs = docs[:filter.Limit]
+	}
+
+	return docs, nil
+}
+
+// MemoryDriftStore is an in-memory implementation of the DriftStore interface.
+type MemoryDriftStore struct {
+	name    string
+	mu      sync.RWMutex
+	reports []DriftReport
+}
+
+func NewMemoryDriftStore(name string) *MemoryDriftStore {
+	return &MemoryDriftStore{name: name}
+}
+
+func (s *MemoryDriftStore) Name() string { return s.name }
+
+func (s *MemoryDriftStore) InsertDriftReport(report DriftReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reports = append(s.reports, report)
+	return nil
+}
+
+func (s *MemoryDriftStore) GetDriftReports(filter DriftFilter) ([]DriftReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []DriftReport
+	for i := len(s.reports) - 1; i >= 0; i-- {
+		r := s.reports[i]
+		if filter.matches(r) {
+			docs = append(docs, r)
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].CreatedAt.After(docs[j].CreatedAt)
+	})
+
+	if filter.Limit > 0 && int64(len(docs)) > filter.Limit {
+		docs = docs[:filter.Limit]
+	}
+
+	return docs, nil
+}
+
+func (f DriftFilter) matches(r DriftReport) bool {
+	return (f.Collection == "" || r.Collection == f.Collection) &&
+		(f.Source == "" || r.Source == f.Source) &&
+		(f.Market == "" || r.Market == f.Market) &&
+		(f.Symbol == "" || r.Symbol == f.Symbol) &&
+		(f.Interval == 0 || r.Interval == f.Interval)
+}
+
+// MemoryRevisionStore is an in-memory implementation of the RevisionStore interface.
+type MemoryRevisionStore struct {
+	name string
+	mu   sync.RWMutex
+	rows []OhlcRevision
+}
+
+func NewMemoryRevisionStore(name string) *MemoryRevisionStore {
+	return &MemoryRevisionStore{name: name}
+}
+
+func (s *MemoryRevisionStore) Name() string { return s.name }
+
+func (s *MemoryRevisionStore) InsertOhlcRevisions(data []OhlcRevision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rows = append(s.rows, data...)
+	return nil
+}
+
+func (s *MemoryRevisionStore) GetOhlcRevisions(collection string, filter OhlcFilter) ([]OhlcRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []OhlcRevision
+	for _, row := range s.rows {
+		if row.Collection == collection && filter.matches(row.OhlcRow) {
+			docs = append(docs, row)
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if !docs[i].StartTime.Equal(docs[j].StartTime) {
+			return docs[i].StartTime.Before(docs[j].StartTime)
+		}
+		return docs[i].RevisedAt.Before(docs[j].RevisedAt)
+	})
+
+	if filter.Limit > 0 && int64(len(docs)) > filter.Limit {
+		docs = docs[:filter.Limit]
+	}
+
+	return docs, nil
+}
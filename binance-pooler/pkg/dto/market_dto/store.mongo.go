@@ -0,0 +1,548 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOhlcStore implements the OhlcStore interface on top of a mongodb collection.
+type MongoOhlcStore struct {
+	coll      *mongo.Collection
+	revisions RevisionStore
+	source    string
+	market    string
+	bulk      mongodb.BulkInsertSettings
+}
+
+func NewMongoOhlcStore(coll *mongo.Collection) *MongoOhlcStore {
+	return &MongoOhlcStore{coll: coll, bulk: mongodb.DefaultBulkInsertSettings}
+}
+
+// WithRevisions stores the replaced versions of the rows in the store.
+func (s *MongoOhlcStore) WithRevisions(revisions RevisionStore) *MongoOhlcStore {
+	s.revisions = revisions
+	return s
+}
+
+// WithMarket sets the source and market of the rows written without them.
+func (s *MongoOhlcStore) WithMarket(source, market string) *MongoOhlcStore {
+	s.source, s.market = source, market
+	return s
+}
+
+// WithBulkInsert sets the settings of the bulk inserts of the rows into the
+// empty ranges. The default chunk size is kept if it's not set.
+func (s *MongoOhlcStore) WithBulkInsert(settings mongodb.BulkInsertSettings) *MongoOhlcStore {
+	if settings.ChunkSize <= 0 {
+		settings.ChunkSize = mongodb.DefaultBulkInsertSettings.ChunkSize
+	}
+	s.bulk = settings
+	return s
+}
+
+// Coll returns the underlying collection
+func (s *MongoOhlcStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoOhlcStore) Name() string            { return s.coll.Name() }
+
+// UpsertOhlcRows reads the stored rows in the range of the data, to set the
+// provenance fields before the upsert. The replaced versions are stored after
+// the upsert, so that a failed upsert leaves no revisions of unreplaced rows.
+func (s *MongoOhlcStore) UpsertOhlcRows(data []OhlcRow) (*mongodb.UpsertLog, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to upsert")
+	}
+
+	setDefaultMarket(data, s.source, s.market)
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].StartTime.Before(data[j].StartTime)
+	})
+
+	existing, err := findOhlcRows(data, s.coll)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := reviseOhlcRows(s.coll.Name(), existing, data, time.Now().UTC())
+
+	upsertLog, err := UpsertOhlcRows(data, s.coll, s.bulk)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) > 0 && s.revisions != nil {
+		if err := s.revisions.InsertOhlcRevisions(revisions); err != nil {
+			return nil, fmt.Errorf("failed to store ohlc revisions: %v", err)
+		}
+	}
+
+	return upsertLog, nil
+}
+
+func (s *MongoOhlcStore) FindLatestStartTime(defaultStart time.Time, symbol string, interval int64) (time.Time, error) {
+	filter := OhlcFilter{Symbol: symbol, Interval: interval}.withMarket(s.source, s.market)
+	return mongodb.FindLatestStartTime(defaultStart, s.coll, ohlcFilterToBson(filter))
+}
+
+func (s *MongoOhlcStore) FindGaps(symbol string, interval int64) (map[int64][]mongodb.GapInfo, error) {
+	filter := OhlcFilter{Symbol: symbol, Interval: interval}.withMarket(s.source, s.market)
+	return mongodb.FindGaps(s.coll, ohlcFilterToBson(filter))
+}
+
+func (s *MongoOhlcStore) GetOhlcRows(filter OhlcFilter) ([]OhlcRow, error) {
+	filter = filter.withMarket(s.source, s.market)
+
+	opt := mongodb.OrderAscending(mongodb.START_TIME)
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	var docs []OhlcRow
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, ohlcFilterToBson(filter), opt, &docs)
+	return docs, err
+}
+
+func (s *MongoOhlcStore) StreamOhlcRows(filter OhlcFilter, fn func(OhlcRow) error) error {
+	filter = filter.withMarket(s.source, s.market)
+
+	opt := mongodb.OrderAscending(mongodb.START_TIME).SetBatchSize(1000)
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	cursor, err := s.coll.Find(ctx, ohlcFilterToBson(filter), opt)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var row OhlcRow
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (s *MongoOhlcStore) DeleteOhlcRows(filter OhlcFilter) (int64, error) {
+	res, err := s.coll.DeleteMany(ctx, ohlcFilterToBson(filter.withMarket(s.source, s.market)))
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *MongoOhlcStore) SetMissingMarket(source, market string) (int64, error) {
+	missing := bson.M{"$in": bson.A{nil, ""}}
+	filter := bson.M{"$or": bson.A{bson.M{"source": missing}, bson.M{"market": missing}}}
+
+	// keep the values which are already set
+	keep := func(field, value string) bson.M {
+		return bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$" + field, ""}}, ""}}, value, "$" + field}}
+	}
+
+	update := bson.A{bson.M{"$set": bson.M{"source": keep("source", source), "market": keep("market", market)}}}
+
+	res, err := s.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set the market of the %v collection: %v", s.coll.Name(), err)
+	}
+	return res.ModifiedCount, nil
+}
+
+func (s *MongoOhlcStore) GetCoverage(symbol string) ([]OhlcCoverage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: ohlcFilterToBson(OhlcFilter{Symbol: symbol}.withMarket(s.source, s.market))}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$interval",
+			"from":  bson.M{"$min": "$" + mongodb.START_TIME},
+			"to":    bson.M{"$max": "$" + mongodb.START_TIME},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cur, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the coverage of %v in the %v collection: %v", symbol, s.coll.Name(), err)
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		Interval int64     `bson:"_id"`
+		From     time.Time `bson:"from"`
+		To       time.Time `bson:"to"`
+		Count    int64     `bson:"count"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	coverage := make([]OhlcCoverage, len(docs))
+	for i, doc := range docs {
+		coverage[i] = OhlcCoverage{Interval: doc.Interval, From: doc.From.UTC(), To: doc.To.UTC(), Count: doc.Count}
+	}
+
+	return coverage, nil
+}
+
+// ResampleOhlcRows groups the rows into the buckets with an aggregation, so
+// that only the resampled rows are read from the database. The calendar
+// buckets are built with $dateTrunc, which needs mongodb 5.0.
+func (s *MongoOhlcStore) ResampleOhlcRows(filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error) {
+	if filter.Interval == 0 {
+		return nil, fmt.Errorf("interval of the source rows is required")
+	}
+	filter = filter.withMarket(s.source, s.market)
+
+	if !res.Divides(filter.Interval) {
+		return nil, fmt.Errorf("resolution %+v can't be built from rows of the %v interval", res, filter.Interval)
+	}
+
+	return resampleWindows(s, filter, res, partial, func(filter OhlcFilter, partial bool) ([]OhlcRow, error) {
+		return s.aggregateOhlcRows(filter, res, partial)
+	})
+}
+
+// aggregateOhlcRows runs the resampling aggregation on the rows of the filter.
+func (s *MongoOhlcStore) aggregateOhlcRows(filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error) {
+	startTime := "$" + mongodb.START_TIME
+
+	var bucket bson.M
+	switch res.Unit {
+	case CalendarWeek:
+		bucket = bson.M{"$dateTrunc": bson.M{"date": startTime, "unit": "week", "binSize": res.Count, "startOfWeek": "monday"}}
+	case CalendarMonth:
+		bucket = bson.M{"$dateTrunc": bson.M{"date": startTime, "unit": "month", "binSize": res.Count}}
+	default:
+		ms := bson.M{"$toLong": startTime}
+		bucket = bson.M{"$toDate": bson.M{"$subtract": bson.A{ms, bson.M{"$mod": bson.A{ms, res.Interval}}}}}
+	}
+
+	// counts of the rows which hold the optional fields
+	isNumber := func(field string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$isNumber": "$" + field}, 1, 0}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: ohlcFilterToBson(filter)}},
+		{{Key: "$sort", Value: bson.M{mongodb.START_TIME: 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"source": "$source", "market": "$market", "symbol": "$symbol", "start": bucket},
+			"o":      bson.M{"$first": "$o"},
+			"h":      bson.M{"$max": "$h"},
+			"l":      bson.M{"$min": "$l"},
+			"c":      bson.M{"$last": "$c"},
+			"v":      bson.M{"$sum": "$v"},
+			"bv":     bson.M{"$sum": "$bv"},
+			"n":      bson.M{"$sum": "$n"},
+			"num_bv": isNumber("bv"),
+			"num_n":  isNumber("n"),
+			"count":  bson.M{"$sum": 1},
+			"last":   bson.M{"$max": startTime},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.start", Value: 1}, {Key: "_id.source", Value: 1}, {Key: "_id.market", Value: 1}}}},
+	}
+
+	// an extra bucket shows if the last returned one is the trailing bucket
+	if filter.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: filter.Limit + 1}})
+	}
+
+	cur, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resample the rows of the %v collection: %v", s.coll.Name(), err)
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		Id struct {
+			Source string    `bson:"source"`
+			Market string    `bson:"market"`
+			Symbol string    `bson:"symbol"`
+			Start  time.Time `bson:"start"`
+		} `bson:"_id"`
+		OHLC            `bson:",inline"`
+		BaseAssetVolume float64   `bson:"bv"`
+		NumberOfTrades  int64     `bson:"n"`
+		NumBaseVolume   int64     `bson:"num_bv"`
+		NumTrades       int64     `bson:"num_n"`
+		Count           int64     `bson:"count"`
+		Last            time.Time `bson:"last"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]resampledRow, len(docs))
+	for i, doc := range docs {
+		start := doc.Id.Start.UTC()
+		row := OhlcRow{
+			TimeseriesFields: mongodb.TimeseriesFields{StartTime: start, Interval: res.BucketEnd(start).Sub(start).Milliseconds()},
+			Source:           doc.Id.Source,
+			Market:           doc.Id.Market,
+			Symbol:           doc.Id.Symbol,
+			OHLC:             doc.OHLC,
+		}
+
+		if doc.NumBaseVolume == doc.Count {
+			row.SetBaseAssetVolume(doc.BaseAssetVolume)
+		}
+		if doc.NumTrades == doc.Count {
+			row.SetNumberOfTrades(doc.NumberOfTrades)
+		}
+
+		buckets[i] = resampledRow{row, doc.Last.UTC()}
+	}
+
+	if filter.Limit > 0 && int64(len(buckets)) > filter.Limit {
+		return trimPartialBuckets(buckets[:filter.Limit], filter.Interval, true), nil
+	}
+
+	return trimPartialBuckets(buckets, filter.Interval, partial), nil
+}
+
+func ohlcFilterToBson(filter OhlcFilter) bson.M {
+	query := bson.M{}
+
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+
+	if filter.Market != "" {
+		query["market"] = filter.Market
+	}
+
+	if filter.Symbol != "" {
+		query["symbol"] = filter.Symbol
+	}
+
+	if filter.Interval != 0 {
+		query["interval"] = filter.Interval
+	}
+
+	timeFilter := bson.M{}
+	if !filter.From.IsZero() {
+		timeFilter["$gte"] = filter.From.UTC()
+	}
+	if !filter.To.IsZero() {
+		timeFilter["$lte"] = filter.To.UTC()
+	}
+	if len(timeFilter) > 0 {
+		query[mongodb.START_TIME] = timeFilter
+	}
+
+	return query
+}
+
+// MongoQuarantineStore implements the QuarantineStore interface on top of a mongodb collection.
+type MongoQuarantineStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoQuarantineStore(coll *mongo.Collection) *MongoQuarantineStore {
+	return &MongoQuarantineStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoQuarantineStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoQuarantineStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoQuarantineStore) QuarantineOhlcRows(data []QuarantinedRow) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := mongodb.InsertChunked(s.coll, data)
+	return err
+}
+
+func (s *MongoQuarantineStore) GetQuarantinedRows(filter OhlcFilter) ([]QuarantinedRow, error) {
+	opt := mongodb.OrderAscending(mongodb.START_TIME)
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	var docs []QuarantinedRow
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, ohlcFilterToBson(filter), opt, &docs)
+	return docs, err
+}
+
+// MongoRevisionStore implements the RevisionStore interface on top of a mongodb collection.
+type MongoRevisionStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRevisionStore(coll *mongo.Collection) *MongoRevisionStore {
+	return &MongoRevisionStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoRevisionStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoRevisionStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoRevisionStore) InsertOhlcRevisions(data []OhlcRevision) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := mongodb.InsertChunked(s.coll, data)
+	return err
+}
+
+func (s *MongoRevisionStore) GetOhlcRevisions(collection string, filter OhlcFilter) ([]OhlcRevision, error) {
+	query := ohlcFilterToBson(filter)
+	query["collection"] = collection
+
+	opt := options.Find().SetSort(bson.D{{Key: mongodb.START_TIME, Value: 1}, {Key: "revised_at", Value: 1}})
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	var docs []OhlcRevision
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, query, opt, &docs)
+	return docs, err
+}
+
+// MongoDriftStore implements the DriftStore interface on top of a mongodb collection.
+type MongoDriftStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoDriftStore(coll *mongo.Collection) *MongoDriftStore {
+	return &MongoDriftStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoDriftStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoDriftStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoDriftStore) InsertDriftReport(report DriftReport) error {
+	_, err := s.coll.InsertOne(ctx, report)
+	return err
+}
+
+func (s *MongoDriftStore) GetDriftReports(filter DriftFilter) ([]DriftReport, error) {
+	query := bson.M{}
+	if filter.Collection != "" {
+		query["collection"] = filter.Collection
+	}
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+	if filter.Market != "" {
+		query["market"] = filter.Market
+	}
+	if filter.Symbol != "" {
+		query["symbol"] = filter.Symbol
+	}
+	if filter.Interval != 0 {
+		query["interval"] = filter.Interval
+	}
+
+	opt := mongodb.OrderDescending("created_at")
+	if filter.Limit > 0 {
+		opt.SetLimit(filter.Limit)
+	}
+
+	var docs []DriftReport
+	err := mongodb.GetAllDocumentsWithTypes(s.coll, query, opt, &docs)
+	return docs, err
+}
+
+// MongoAssetStore implements the AssetStore interface on top of a mongodb collection.
+type MongoAssetStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAssetStore(coll *mongo.Collection) *MongoAssetStore {
+	return &MongoAssetStore{coll: coll}
+}
+
+// Coll returns the underlying collection
+func (s *MongoAssetStore) Coll() *mongo.Collection { return s.coll }
+func (s *MongoAssetStore) Name() string            { return s.coll.Name() }
+
+func (s *MongoAssetStore) UpsertAssets(data []Asset[any]) (*mongodb.UpsertLog, error) {
+	return UpsertAssets(data, s.coll)
+}
+
+func (s *MongoAssetStore) CountAssets(source string) (int64, error) {
+	return s.coll.CountDocuments(ctx, bson.M{"source": source})
+}
+
+func (s *MongoAssetStore) GetAssets(filter AssetFilter) ([]AssetBase, error) {
+	return GetAssets(s.coll, assetFilterToBson(filter), options.Find().SetSort(bson.M{"symbol": 1}))
+}
+
+func (s *MongoAssetStore) GetAsset(source, symbol string) (*Asset[any], error) {
+	var doc Asset[bson.M]
+	err := s.coll.FindOne(ctx, bson.M{"source": source, "symbol": symbol}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %v from the %v collection: %v", symbol, s.coll.Name(), err)
+	}
+
+	asset := &Asset[any]{AssetBase: doc.AssetBase}
+	if doc.Data != nil {
+		asset.Data = doc.Data
+	}
+
+	return asset, nil
+}
+
+func assetFilterToBson(filter AssetFilter) bson.M {
+	query := bson.M{}
+
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+
+	symbol := bson.M{}
+	if len(filter.Symbols) > 0 {
+		symbol["$in"] = filter.Symbols
+	}
+	if filter.Search != "" {
+		symbol["$regex"] = regexp.QuoteMeta(filter.Search)
+		symbol["$options"] = "i"
+	}
+	if len(symbol) > 0 {
+		query["symbol"] = symbol
+	}
+
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	if filter.BaseAsset != "" {
+		query["base_asset"] = filter.BaseAsset
+	}
+
+	if filter.QuoteAsset != "" {
+		query["quote_asset"] = filter.QuoteAsset
+	}
+
+	if filter.ContractType != "" {
+		query["data.contract_type"] = filter.ContractType
+	}
+
+	if len(filter.OrderTypes) > 0 {
+		query["order_types"] = bson.M{"$all": filter.OrderTypes}
+	}
+
+	return query
+}
@@ -0,0 +1,1095 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/mongodb"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sqliteDbName is used in the upsert logs of the sqlite stores.
+const sqliteDbName = "sqlite"
+
+// SqliteOhlcStore implements the OhlcStore interface on top of a sqlite table.
+// Time values are stored as unix milliseconds.
+type SqliteOhlcStore struct {
+	db        *sql.DB
+	table     string
+	revisions RevisionStore
+	source    string
+	market    string
+}
+
+// NewSqliteOhlcStore returns a new store for the table, creating it if it does
+// not exist. Tables created by older versions are migrated to the current
+// layout, with an empty source and market of the existing rows.
+func NewSqliteOhlcStore(db *sql.DB, table string) (*SqliteOhlcStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	if _, err := db.Exec(sqliteOhlcSchema(table)); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	// tables created before the provenance and market columns were added
+	if err := addSqliteColumns(db, table, map[string]string{
+		"fetched_at": "INTEGER",
+		"endpoint":   "TEXT",
+		"rev":        "INTEGER NOT NULL DEFAULT 0",
+		"source":     "TEXT NOT NULL DEFAULT ''",
+		"market":     "TEXT NOT NULL DEFAULT ''",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := migrateSqliteOhlcKey(db, table); err != nil {
+		return nil, fmt.Errorf("failed to migrate the %v table: %v", table, err)
+	}
+
+	index := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (symbol, interval, start_time)`, table+"_symbol_interval_start_time", table)
+	if _, err := db.Exec(index); err != nil {
+		return nil, fmt.Errorf("failed to create the index of the %v table: %v", table, err)
+	}
+
+	return &SqliteOhlcStore{db: db, table: table}, nil
+}
+
+func sqliteOhlcSchema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		source     TEXT    NOT NULL DEFAULT '',
+		market     TEXT    NOT NULL DEFAULT '',
+		symbol     TEXT    NOT NULL,
+		interval   INTEGER NOT NULL,
+		start_time INTEGER NOT NULL,
+		o          REAL    NOT NULL,
+		h          REAL    NOT NULL,
+		l          REAL    NOT NULL,
+		c          REAL    NOT NULL,
+		v          REAL    NOT NULL,
+		bv         REAL,
+		n          INTEGER,
+		fetched_at INTEGER,
+		endpoint   TEXT,
+		rev        INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (source, market, symbol, interval, start_time)
+	) WITHOUT ROWID`, table)
+}
+
+// migrateSqliteOhlcKey copies the rows of a table, whose primary key does not
+// include the source and market, into a table with the current layout.
+func migrateSqliteOhlcKey(db *sql.DB, table string) error {
+	var numKeys int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM pragma_table_info(%q) WHERE pk > 0 AND name IN ('source', 'market')`, table)
+	if err := db.QueryRow(query).Scan(&numKeys); err != nil {
+		return err
+	}
+
+	if numKeys == 2 {
+		return nil
+	}
+
+	const columns = "source, market, symbol, interval, start_time, o, h, l, c, v, bv, n, fetched_at, endpoint, rev"
+	tmp := table + "_migration"
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		fmt.Sprintf(`DROP TABLE IF EXISTS %q`, tmp),
+		sqliteOhlcSchema(tmp),
+		fmt.Sprintf(`INSERT INTO %q (%v) SELECT %v FROM %q`, tmp, columns, columns, table),
+		fmt.Sprintf(`DROP TABLE %q`, table),
+		fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, tmp, table),
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addSqliteColumns adds the columns which don't exist in the table.
+func addSqliteColumns(db *sql.DB, table string, columns map[string]string) error {
+	rows, err := db.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info(%q)`, table))
+	if err != nil {
+		return fmt.Errorf("failed to get the columns of the %v table: %v", table, err)
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %q ADD COLUMN %q %v`, table, name, columns[name])); err != nil {
+			return fmt.Errorf("failed to add the %v column to the %v table: %v", name, table, err)
+		}
+	}
+
+	return nil
+}
+
+// replaceSqliteIndex creates the index of the columns, after the columns were
+// added to the old tables, and drops the old index with the suffix, if any.
+func replaceSqliteIndex(db *sql.DB, table, oldSuffix string, columns ...string) error {
+	var stmts []string
+	if oldSuffix != "" {
+		stmts = append(stmts, fmt.Sprintf(`DROP INDEX IF EXISTS %q`, table+oldSuffix))
+	}
+
+	name := table + "_" + strings.Join(columns, "_")
+	stmts = append(stmts, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (%v)`, name, table, strings.Join(columns, ", ")))
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create the index of the %v table: %v", table, err)
+		}
+	}
+
+	return nil
+}
+
+// WithRevisions stores the replaced versions of the rows in the store.
+func (s *SqliteOhlcStore) WithRevisions(revisions RevisionStore) *SqliteOhlcStore {
+	s.revisions = revisions
+	return s
+}
+
+// WithMarket sets the source and market of the rows written without them.
+func (s *SqliteOhlcStore) WithMarket(source, market string) *SqliteOhlcStore {
+	s.source, s.market = source, market
+	return s
+}
+
+func (s *SqliteOhlcStore) Name() string { return s.table }
+
+func (s *SqliteOhlcStore) UpsertOhlcRows(data []OhlcRow) (*mongodb.UpsertLog, error) {
+	start := time.Now()
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to upsert")
+	}
+
+	setDefaultMarket(data, s.source, s.market)
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].StartTime.Before(data[j].StartTime)
+	})
+
+	for _, row := range data {
+		if row.Symbol == "" {
+			return nil, fmt.Errorf("symbol is empty")
+		}
+	}
+
+	// the db has a single connection, so the stored rows are read before the transaction
+	existing := make(map[ohlcKey]OhlcRow)
+	for _, filter := range ohlcRanges(data) {
+		rows, err := s.GetOhlcRows(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			existing[row.key()] = row
+		}
+	}
+
+	revisions := reviseOhlcRows(s.table, existing, data, start.UTC())
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT INTO %q (source, market, symbol, interval, start_time, o, h, l, c, v, bv, n, fetched_at, endpoint, rev)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, market, symbol, interval, start_time) DO UPDATE SET
+			o = excluded.o, h = excluded.h, l = excluded.l, c = excluded.c,
+			v = excluded.v, bv = excluded.bv, n = excluded.n,
+			fetched_at = excluded.fetched_at, endpoint = excluded.endpoint, rev = excluded.rev`, s.table)
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, row := range data {
+		if _, err := stmt.Exec(row.Source, row.Market, row.Symbol, row.Interval, row.StartTime.UnixMilli(),
+			row.Open, row.High, row.Low, row.Close, row.Volume,
+			row.BaseAssetVolume, row.NumberOfTrades,
+			row.FetchedAt.UnixMilli(), row.Endpoint, row.Revision); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// the revisions are stored once the rows were replaced
+	if len(revisions) > 0 && s.revisions != nil {
+		if err := s.revisions.InsertOhlcRevisions(revisions); err != nil {
+			return nil, fmt.Errorf("failed to store ohlc revisions: %v", err)
+		}
+	}
+
+	return &mongodb.UpsertLog{
+		DbName:          sqliteDbName,
+		CollectionName:  s.table,
+		FirstStartTime:  data[0].StartTime,
+		LastStartTime:   data[len(data)-1].StartTime,
+		NumUpsertedRows: len(data),
+		ElapsedTime:     time.Since(start).Seconds(),
+	}, nil
+}
+
+func (s *SqliteOhlcStore) FindLatestStartTime(defaultStart time.Time, symbol string, interval int64) (time.Time, error) {
+	where, args := ohlcFilterToSql(OhlcFilter{Symbol: symbol, Interval: interval}.withMarket(s.source, s.market))
+	query := fmt.Sprintf(`SELECT MAX(start_time) FROM %q`, s.table) + where
+
+	var latest sql.NullInt64
+	if err := s.db.QueryRow(query, args...).Scan(&latest); err != nil {
+		return time.Time{}, fmt.Errorf("could not get the last row from the %v table: %v", s.table, err)
+	}
+
+	if !latest.Valid {
+		return defaultStart, nil
+	}
+
+	return time.UnixMilli(latest.Int64).UTC(), nil
+}
+
+func (s *SqliteOhlcStore) FindGaps(symbol string, interval int64) (map[int64][]mongodb.GapInfo, error) {
+	where, args := ohlcFilterToSql(OhlcFilter{Symbol: symbol, Interval: interval}.withMarket(s.source, s.market))
+	query := fmt.Sprintf(`SELECT start_time FROM %q`, s.table) + where + " ORDER BY start_time"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []mongodb.TimeseriesFields
+	for rows.Next() {
+		var startTime int64
+		if err := rows.Scan(&startTime); err != nil {
+			return nil, err
+		}
+
+		records = append(records, mongodb.TimeseriesFields{
+			StartTime: time.UnixMilli(startTime).UTC(),
+			Interval:  interval,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	gapsMap := make(map[int64][]mongodb.GapInfo)
+	if gaps := mongodb.FindGapsInSeries(records); len(gaps) > 0 {
+		gapsMap[interval] = gaps
+	}
+
+	return gapsMap, nil
+}
+
+func (s *SqliteOhlcStore) GetOhlcRows(filter OhlcFilter) ([]OhlcRow, error) {
+	var docs []OhlcRow
+	err := s.StreamOhlcRows(filter, func(row OhlcRow) error {
+		docs = append(docs, row)
+		return nil
+	})
+	return docs, err
+}
+
+// number of rows which are read at once by StreamOhlcRows
+var sqliteOhlcPage int64 = 1000
+
+// StreamOhlcRows reads the rows in pages, which are keyed by the last row of
+// the previous page. The single connection of the db is released between
+// the pages, so the writes are not blocked while the fn handles the rows
+// (e.g. while they are sent to a slow client).
+func (s *SqliteOhlcStore) StreamOhlcRows(filter OhlcFilter, fn func(OhlcRow) error) error {
+	filter = filter.withMarket(s.source, s.market)
+
+	var last *OhlcRow
+	for sent := int64(0); filter.Limit == 0 || sent < filter.Limit; {
+		limit := sqliteOhlcPage
+		if filter.Limit > 0 {
+			limit = min(limit, filter.Limit-sent)
+		}
+
+		page, err := s.readOhlcPage(filter, last, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range page {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(page)) < limit {
+			return nil
+		}
+
+		sent += int64(len(page))
+		last = &page[len(page)-1]
+	}
+
+	return nil
+}
+
+// readOhlcPage returns the rows which follow the after row (or the first rows
+// if it's nil), sorted by the start time and the primary key.
+func (s *SqliteOhlcStore) readOhlcPage(filter OhlcFilter, after *OhlcRow, limit int64) ([]OhlcRow, error) {
+	where, args := ohlcFilterToSql(filter)
+
+	if after != nil {
+		cond := "(start_time, source, market, symbol, interval) > (?, ?, ?, ?, ?)"
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+		args = append(args, after.StartTime.UnixMilli(), after.Source, after.Market, after.Symbol, after.Interval)
+	}
+
+	query := fmt.Sprintf(`SELECT source, market, symbol, interval, start_time, o, h, l, c, v, bv, n, fetched_at, endpoint, rev FROM %q`, s.table) + where
+	query += " ORDER BY start_time, source, market, symbol, interval LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make([]OhlcRow, 0, limit)
+	for rows.Next() {
+		var row OhlcRow
+		var startTime int64
+		var baseVol sql.NullFloat64
+		var numTrades, fetchedAt sql.NullInt64
+		var endpoint sql.NullString
+
+		if err := rows.Scan(&row.Source, &row.Market, &row.Symbol, &row.Interval, &startTime,
+			&row.Open, &row.High, &row.Low, &row.Close, &row.Volume,
+			&baseVol, &numTrades, &fetchedAt, &endpoint, &row.Revision); err != nil {
+			return nil, err
+		}
+
+		row.StartTime = time.UnixMilli(startTime).UTC()
+		row.Endpoint = endpoint.String
+		if fetchedAt.Valid {
+			row.FetchedAt = time.UnixMilli(fetchedAt.Int64).UTC()
+		}
+		if baseVol.Valid {
+			row.SetBaseAssetVolume(baseVol.Float64)
+		}
+		if numTrades.Valid {
+			row.SetNumberOfTrades(numTrades.Int64)
+		}
+
+		docs = append(docs, row)
+	}
+
+	return docs, rows.Err()
+}
+
+func (s *SqliteOhlcStore) DeleteOhlcRows(filter OhlcFilter) (int64, error) {
+	where, args := ohlcFilterToSql(filter.withMarket(s.source, s.market))
+
+	res, err := s.db.Exec(fmt.Sprintf(`DELETE FROM %q`, s.table)+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SqliteOhlcStore) SetMissingMarket(source, market string) (int64, error) {
+	query := fmt.Sprintf(`UPDATE %q SET
+		source = CASE WHEN source = '' THEN ? ELSE source END,
+		market = CASE WHEN market = '' THEN ? ELSE market END
+		WHERE source = '' OR market = ''`, s.table)
+
+	res, err := s.db.Exec(query, source, market)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set the market of the %v table: %v", s.table, err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *SqliteOhlcStore) ResampleOhlcRows(filter OhlcFilter, res Resolution, partial bool) ([]OhlcRow, error) {
+	return resampleStoredRows(s, filter, res, partial)
+}
+
+func (s *SqliteOhlcStore) GetCoverage(symbol string) ([]OhlcCoverage, error) {
+	where, args := ohlcFilterToSql(OhlcFilter{Symbol: symbol}.withMarket(s.source, s.market))
+	query := fmt.Sprintf(`SELECT interval, MIN(start_time), MAX(start_time), COUNT(*) FROM %q`, s.table) +
+		where + " GROUP BY interval ORDER BY interval"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the coverage of %v in the %v table: %v", symbol, s.table, err)
+	}
+	defer rows.Close()
+
+	var coverage []OhlcCoverage
+	for rows.Next() {
+		var c OhlcCoverage
+		var from, to int64
+		if err := rows.Scan(&c.Interval, &from, &to, &c.Count); err != nil {
+			return nil, err
+		}
+
+		c.From, c.To = time.UnixMilli(from).UTC(), time.UnixMilli(to).UTC()
+		coverage = append(coverage, c)
+	}
+
+	return coverage, rows.Err()
+}
+
+// ohlcFilterToSql returns the WHERE clause (with a leading space) and
+// the arguments which mirror the query created by ohlcFilterToBson.
+func ohlcFilterToSql(filter OhlcFilter) (string, []any) {
+	var where []string
+	var args []any
+
+	if filter.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, filter.Source)
+	}
+
+	if filter.Market != "" {
+		where = append(where, "market = ?")
+		args = append(args, filter.Market)
+	}
+
+	if filter.Symbol != "" {
+		where = append(where, "symbol = ?")
+		args = append(args, filter.Symbol)
+	}
+
+	if filter.Interval != 0 {
+		where = append(where, "interval = ?")
+		args = append(args, filter.Interval)
+	}
+
+	if !filter.From.IsZero() {
+		where = append(where, "start_time >= ?")
+		args = append(args, filter.From.UnixMilli())
+	}
+
+	if !filter.To.IsZero() {
+		where = append(where, "start_time <= ?")
+		args = append(args, filter.To.UnixMilli())
+	}
+
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// SqliteAssetStore implements the AssetStore interface on top of a sqlite table.
+// The order types and the asset specific data are stored as json.
+type SqliteAssetStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteAssetStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteAssetStore(db *sql.DB, table string) (*SqliteAssetStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		source      TEXT    NOT NULL,
+		symbol      TEXT    NOT NULL,
+		updated_at  INTEGER NOT NULL,
+		status      TEXT    NOT NULL,
+		base_asset  TEXT    NOT NULL,
+		quote_asset TEXT    NOT NULL,
+		order_types TEXT    NOT NULL,
+		data        TEXT    NOT NULL,
+		PRIMARY KEY (source, symbol)
+	) WITHOUT ROWID`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	return &SqliteAssetStore{db: db, table: table}, nil
+}
+
+func (s *SqliteAssetStore) Name() string { return s.table }
+
+func (s *SqliteAssetStore) UpsertAssets(data []Asset[any]) (*mongodb.UpsertLog, error) {
+	start := time.Now()
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to upsert")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT INTO %q (source, symbol, updated_at, status, base_asset, quote_asset, order_types, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, symbol) DO UPDATE SET
+			updated_at = excluded.updated_at, status = excluded.status,
+			base_asset = excluded.base_asset, quote_asset = excluded.quote_asset,
+			order_types = excluded.order_types,
+			data = CASE WHEN excluded.data = 'null' THEN data ELSE excluded.data END`, s.table)
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, row := range data {
+		base := row.AssetBase
+		if base.Symbol == "" || base.Source == "" {
+			return nil, fmt.Errorf("symbol or source is empty")
+		}
+
+		orderTypes, err := json.Marshal(base.OrderTypes)
+		if err != nil {
+			return nil, err
+		}
+
+		assetData, err := json.Marshal(row.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := stmt.Exec(base.Source, base.Symbol, base.UpdatedAt.UnixMilli(), base.Status,
+			base.BaseAsset, base.QuoteAsset, string(orderTypes), string(assetData)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &mongodb.UpsertLog{
+		DbName:          sqliteDbName,
+		CollectionName:  s.table,
+		NumUpsertedRows: len(data),
+		ElapsedTime:     time.Since(start).Seconds(),
+	}, nil
+}
+
+func (s *SqliteAssetStore) CountAssets(source string) (int64, error) {
+	var count int64
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %q WHERE source = ?`, s.table)
+	err := s.db.QueryRow(query, source).Scan(&count)
+	return count, err
+}
+
+func (s *SqliteAssetStore) GetAssets(filter AssetFilter) ([]AssetBase, error) {
+	where, args := assetFilterToSql(filter)
+
+	query := fmt.Sprintf(`SELECT source, symbol, updated_at, status, base_asset, quote_asset, order_types FROM %q`, s.table)
+	query += where + " ORDER BY symbol"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []AssetBase
+	for rows.Next() {
+		var doc AssetBase
+		var updatedAt int64
+		var orderTypes string
+
+		if err := rows.Scan(&doc.Source, &doc.Symbol, &updatedAt, &doc.Status,
+			&doc.BaseAsset, &doc.QuoteAsset, &orderTypes); err != nil {
+			return nil, err
+		}
+
+		doc.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+		if err := json.Unmarshal([]byte(orderTypes), &doc.OrderTypes); err != nil {
+			return nil, fmt.Errorf("failed to parse order types of %v: %v", doc.Symbol, err)
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+func (s *SqliteAssetStore) GetAsset(source, symbol string) (*Asset[any], error) {
+	query := fmt.Sprintf(`SELECT updated_at, status, base_asset, quote_asset, order_types, data FROM %q
+		WHERE source = ? AND symbol = ?`, s.table)
+
+	asset := &Asset[any]{AssetBase: AssetBase{Source: source, Symbol: symbol}}
+	base := &asset.AssetBase
+	var updatedAt int64
+	var orderTypes, data string
+
+	err := s.db.QueryRow(query, source, symbol).Scan(&updatedAt, &base.Status, &base.BaseAsset, &base.QuoteAsset, &orderTypes, &data)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	if err := json.Unmarshal([]byte(orderTypes), &base.OrderTypes); err != nil {
+		return nil, fmt.Errorf("failed to parse order types of %v: %v", symbol, err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse data of %v: %v", symbol, err)
+	}
+	if fields != nil {
+		asset.Data = fields
+	}
+
+	return asset, nil
+}
+
+// assetFilterToSql returns the WHERE clause (with a leading space) and
+// the arguments which mirror the query created by assetFilterToBson.
+func assetFilterToSql(filter AssetFilter) (string, []any) {
+	var where []string
+	var args []any
+
+	add := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+
+	if filter.Source != "" {
+		add("source = ?", filter.Source)
+	}
+
+	if len(filter.Symbols) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Symbols)), ", ")
+		where = append(where, fmt.Sprintf("symbol IN (%v)", placeholders))
+		for _, symbol := range filter.Symbols {
+			args = append(args, symbol)
+		}
+	}
+
+	if filter.Search != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Search)
+		add(`symbol LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+	}
+
+	if filter.Status != "" {
+		add("status = ?", filter.Status)
+	}
+
+	if filter.BaseAsset != "" {
+		add("base_asset = ?", filter.BaseAsset)
+	}
+
+	if filter.QuoteAsset != "" {
+		add("quote_asset = ?", filter.QuoteAsset)
+	}
+
+	if filter.ContractType != "" {
+		add("json_extract(data, '$.contract_type') = ?", filter.ContractType)
+	}
+
+	for _, orderType := range filter.OrderTypes {
+		add("EXISTS (SELECT 1 FROM json_each(order_types) WHERE value = ?)", orderType)
+	}
+
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// SqliteQuarantineStore implements the QuarantineStore interface on top of a
+// sqlite table. The rows are stored as json, next to the filtered columns.
+type SqliteQuarantineStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteQuarantineStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteQuarantineStore(db *sql.DB, table string) (*SqliteQuarantineStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		source     TEXT    NOT NULL DEFAULT '',
+		market     TEXT    NOT NULL DEFAULT '',
+		symbol     TEXT    NOT NULL,
+		interval   INTEGER NOT NULL,
+		start_time INTEGER NOT NULL,
+		data       TEXT    NOT NULL
+	)`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	if err := addSqliteColumns(db, table, map[string]string{
+		"source": "TEXT NOT NULL DEFAULT ''",
+		"market": "TEXT NOT NULL DEFAULT ''",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := replaceSqliteIndex(db, table, "_symbol_interval_start_time", "source", "market", "symbol", "interval", "start_time"); err != nil {
+		return nil, err
+	}
+
+	return &SqliteQuarantineStore{db: db, table: table}, nil
+}
+
+func (s *SqliteQuarantineStore) Name() string { return s.table }
+
+func (s *SqliteQuarantineStore) QuarantineOhlcRows(data []QuarantinedRow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %q (source, market, symbol, interval, start_time, data) VALUES (?, ?, ?, ?, ?, ?)`, s.table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range data {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(row.Source, row.Market, row.Symbol, row.Interval, row.StartTime.UnixMilli(), string(b)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqliteQuarantineStore) GetQuarantinedRows(filter OhlcFilter) ([]QuarantinedRow, error) {
+	where, args := ohlcFilterToSql(filter)
+
+	query := fmt.Sprintf(`SELECT data FROM %q`, s.table) + where + " ORDER BY start_time, rowid"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []QuarantinedRow
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var row QuarantinedRow
+		if err := json.Unmarshal([]byte(data), &row); err != nil {
+			return nil, err
+		}
+		docs = append(docs, row)
+	}
+
+	return docs, rows.Err()
+}
+
+// SqliteDriftStore implements the DriftStore interface on top of a sqlite
+// table. The reports are stored as json, next to the filtered columns.
+type SqliteDriftStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteDriftStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteDriftStore(db *sql.DB, table string) (*SqliteDriftStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		collection TEXT    NOT NULL,
+		source     TEXT    NOT NULL DEFAULT '',
+		market     TEXT    NOT NULL DEFAULT '',
+		symbol     TEXT    NOT NULL,
+		interval   INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		data       TEXT    NOT NULL
+	)`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	if err := addSqliteColumns(db, table, map[string]string{
+		"source": "TEXT NOT NULL DEFAULT ''",
+		"market": "TEXT NOT NULL DEFAULT ''",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := replaceSqliteIndex(db, table, "", "source", "market", "collection", "symbol", "interval", "created_at"); err != nil {
+		return nil, err
+	}
+
+	return &SqliteDriftStore{db: db, table: table}, nil
+}
+
+func (s *SqliteDriftStore) Name() string { return s.table }
+
+func (s *SqliteDriftStore) InsertDriftReport(report DriftReport) error {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %q (collection, source, market, symbol, interval, created_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)`, s.table)
+	_, err = s.db.Exec(query, report.Collection, report.Source, report.Market, report.Symbol, report.Interval, report.CreatedAt.UnixMilli(), string(b))
+	return err
+}
+
+func (s *SqliteDriftStore) GetDriftReports(filter DriftFilter) ([]DriftReport, error) {
+	var where []string
+	var args []any
+
+	if filter.Collection != "" {
+		where = append(where, "collection = ?")
+		args = append(args, filter.Collection)
+	}
+	if filter.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.Market != "" {
+		where = append(where, "market = ?")
+		args = append(args, filter.Market)
+	}
+	if filter.Symbol != "" {
+		where = append(where, "symbol = ?")
+		args = append(args, filter.Symbol)
+	}
+	if filter.Interval != 0 {
+		where = append(where, "interval = ?")
+		args = append(args, filter.Interval)
+	}
+
+	query := fmt.Sprintf(`SELECT data FROM %q`, s.table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, rowid DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []DriftReport
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var report DriftReport
+		if err := json.Unmarshal([]byte(data), &report); err != nil {
+			return nil, err
+		}
+		docs = append(docs, report)
+	}
+
+	return docs, rows.Err()
+}
+
+// SqliteRevisionStore implements the RevisionStore interface on top of a
+// sqlite table. The rows are stored as json, next to the filtered columns.
+type SqliteRevisionStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSqliteRevisionStore returns a new store for the table, creating it if it does not exist.
+func NewSqliteRevisionStore(db *sql.DB, table string) (*SqliteRevisionStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if table == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+		collection TEXT    NOT NULL,
+		source     TEXT    NOT NULL DEFAULT '',
+		market     TEXT    NOT NULL DEFAULT '',
+		symbol     TEXT    NOT NULL,
+		interval   INTEGER NOT NULL,
+		start_time INTEGER NOT NULL,
+		revised_at INTEGER NOT NULL,
+		data       TEXT    NOT NULL
+	)`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to create the %v table: %v", table, err)
+	}
+
+	if err := addSqliteColumns(db, table, map[string]string{
+		"source": "TEXT NOT NULL DEFAULT ''",
+		"market": "TEXT NOT NULL DEFAULT ''",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := replaceSqliteIndex(db, table, "_collection_symbol_interval_start_time", "source", "market", "collection", "symbol", "interval", "start_time"); err != nil {
+		return nil, err
+	}
+
+	return &SqliteRevisionStore{db: db, table: table}, nil
+}
+
+func (s *SqliteRevisionStore) Name() string { return s.table }
+
+func (s *SqliteRevisionStore) InsertOhlcRevisions(data []OhlcRevision) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT INTO %q (collection, source, market, symbol, interval, start_time, revised_at, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, s.table)
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range data {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(row.Collection, row.Source, row.Market, row.Symbol, row.Interval, row.StartTime.UnixMilli(), row.RevisedAt.UnixMilli(), string(b)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqliteRevisionStore) GetOhlcRevisions(collection string, filter OhlcFilter) ([]OhlcRevision, error) {
+	where, args := ohlcFilterToSql(filter)
+	if where == "" {
+		where = " WHERE collection = ?"
+	} else {
+		where += " AND collection = ?"
+	}
+	args = append(args, collection)
+
+	query := fmt.Sprintf(`SELECT data FROM %q`, s.table) + where + " ORDER BY start_time, revised_at, rowid"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []OhlcRevision
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var row OhlcRevision
+		if err := json.Unmarshal([]byte(data), &row); err != nil {
+			return nil, err
+		}
+		docs = append(docs, row)
+	}
+
+	return docs, rows.Err()
+}
@@ -0,0 +1,590 @@
+package market_dto
+
+import (
+	"binance-pooler/pkg/lib/sqlite"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryStores(t *testing.T) {
+	t.Run("ohlc", func(t *testing.T) { testOhlcStore(t, NewMemoryOhlcStore("test_ohlc")) })
+	t.Run("assets", func(t *testing.T) { testAssetStore(t, NewMemoryAssetStore("test_assets")) })
+	t.Run("revisions", func(t *testing.T) {
+		revisions := NewMemoryRevisionStore("test_revisions")
+		testRevisions(t, NewMemoryOhlcStore("test_revised_ohlc").WithRevisions(revisions), revisions)
+	})
+	t.Run("markets", func(t *testing.T) { testMarkets(t, NewMemoryOhlcStore("test_market_ohlc")) })
+}
+
+func TestSqliteStores(t *testing.T) {
+	db, err := sqlite.New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ohlcStore, err := NewSqliteOhlcStore(db, "test_ohlc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assetStore, err := NewSqliteAssetStore(db, "test_assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := NewSqliteRevisionStore(db, "test_revisions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// table created before the provenance columns were added
+	if _, err := db.Exec(`CREATE TABLE test_revised_ohlc (
+		symbol TEXT NOT NULL, interval INTEGER NOT NULL, start_time INTEGER NOT NULL,
+		o REAL NOT NULL, h REAL NOT NULL, l REAL NOT NULL, c REAL NOT NULL, v REAL NOT NULL,
+		bv REAL, n INTEGER, PRIMARY KEY (symbol, interval, start_time)) WITHOUT ROWID`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO test_revised_ohlc (symbol, interval, start_time, o, h, l, c, v)
+		VALUES ('ETHUSDT', 60000, 0, 1, 1, 1, 1, 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	revisedStore, err := NewSqliteOhlcStore(db, "test_revised_ohlc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if legacy, err := revisedStore.GetOhlcRows(OhlcFilter{Symbol: "ETHUSDT"}); err != nil || len(legacy) != 1 {
+		t.Fatalf("expected the migrated row, got %v, %v", legacy, err)
+	}
+
+	marketStore, err := NewSqliteOhlcStore(db, "test_market_ohlc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// small pages, so that the streamed rows cross the page boundaries
+	defer func(page int64) { sqliteOhlcPage = page }(sqliteOhlcPage)
+	sqliteOhlcPage = 2
+
+	t.Run("ohlc", func(t *testing.T) { testOhlcStore(t, ohlcStore) })
+	t.Run("assets", func(t *testing.T) { testAssetStore(t, assetStore) })
+	t.Run("revisions", func(t *testing.T) { testRevisions(t, revisedStore.WithRevisions(revisions), revisions) })
+	t.Run("markets", func(t *testing.T) { testMarkets(t, marketStore) })
+
+	t.Run("shared table", func(t *testing.T) {
+		spot, err := NewSqliteOhlcStore(db, "test_shared_ohlc")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other, err := NewSqliteOhlcStore(db, "test_shared_ohlc")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testSharedStore(t, spot.WithMarket("binance", MarketSpot), other.WithMarket("other", MarketSpot))
+	})
+
+	t.Run("failed upsert", func(t *testing.T) {
+		store, err := NewSqliteOhlcStore(db, "test_failed_ohlc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.WithRevisions(revisions)
+
+		t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		row, err := NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, 1.5, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.FetchedAt = t1.AddDate(0, 0, 1)
+
+		if _, err := store.UpsertOhlcRows([]OhlcRow{*row}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Exec(`CREATE TRIGGER test_failed_ohlc_update BEFORE UPDATE ON test_failed_ohlc
+			BEGIN SELECT RAISE(ABORT, 'update failed'); END`); err != nil {
+			t.Fatal(err)
+		}
+
+		// the replaced version is not stored if the row was not replaced
+		row.Close, row.FetchedAt = 1.6, t1.AddDate(0, 0, 2)
+		if _, err := store.UpsertOhlcRows([]OhlcRow{*row}); err == nil {
+			t.Fatal("expected the upsert to fail")
+		}
+
+		if revs, err := revisions.GetOhlcRevisions(store.Name(), OhlcFilter{Symbol: "BTCUSDT"}); err != nil || len(revs) != 0 {
+			t.Fatalf("expected no revisions, got %v, %v", revs, err)
+		}
+	})
+
+	t.Run("write while streaming", func(t *testing.T) {
+		// the db has a single connection, which is released between the pages
+		err := ohlcStore.StreamOhlcRows(OhlcFilter{Symbol: "BTCUSDT"}, func(row OhlcRow) error {
+			row.Close = 3
+			_, err := ohlcStore.UpsertOhlcRows([]OhlcRow{row})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// testOhlcStore runs the same checks against any OhlcStore implementation
+func testOhlcStore(t *testing.T, store OhlcStore) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var rows []OhlcRow
+	for i := 0; i < 10; i++ {
+		// leave a gap at the 5th row
+		if i == 5 {
+			continue
+		}
+
+		start := t1.Add(time.Duration(i) * time.Minute)
+		row, err := NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1, 2, 0.5, 1.5, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.SetNumberOfTrades(int64(i))
+		rows = append(rows, *row)
+	}
+
+	if _, err := store.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("upserts are idempotent", func(t *testing.T) {
+		if _, err := store.UpsertOhlcRows(rows[:3]); err != nil {
+			t.Fatal(err)
+		}
+
+		docs, err := store.GetOhlcRows(OhlcFilter{Symbol: "BTCUSDT"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 9 {
+			t.Fatalf("expected 9 rows, got %d", len(docs))
+		}
+	})
+
+	t.Run("stream rows", func(t *testing.T) {
+		var docs []OhlcRow
+		err := store.StreamOhlcRows(OhlcFilter{Symbol: "BTCUSDT", From: t1.Add(2 * time.Minute)}, func(row OhlcRow) error {
+			docs = append(docs, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 7 || !docs[0].StartTime.Equal(t1.Add(2*time.Minute)) || !docs[6].StartTime.Equal(t1.Add(9*time.Minute)) {
+			t.Fatalf("unexpected streamed rows: %v", len(docs))
+		}
+
+		stop := errors.New("stop")
+		count := 0
+		err = store.StreamOhlcRows(OhlcFilter{Symbol: "BTCUSDT"}, func(row OhlcRow) error {
+			if count++; count == 3 {
+				return stop
+			}
+			return nil
+		})
+		if err != stop || count != 3 {
+			t.Fatalf("expected the stream to stop at the 3rd row, got %v after %v rows", err, count)
+		}
+	})
+
+	t.Run("latest start time", func(t *testing.T) {
+		defaultStart := t1.AddDate(-1, 0, 0)
+
+		latest, err := store.FindLatestStartTime(defaultStart, "BTCUSDT", time.Minute.Milliseconds())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !latest.Equal(t1.Add(9 * time.Minute)) {
+			t.Fatalf("unexpected latest start time: %v", latest)
+		}
+
+		latest, err = store.FindLatestStartTime(defaultStart, "ETHUSDT", time.Minute.Milliseconds())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !latest.Equal(defaultStart) {
+			t.Fatalf("expected the default start time, got %v", latest)
+		}
+	})
+
+	t.Run("gaps", func(t *testing.T) {
+		interval := time.Minute.Milliseconds()
+		gaps, err := store.FindGaps("BTCUSDT", interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(gaps[interval]) != 1 {
+			t.Fatalf("expected 1 gap, got %v", gaps)
+		}
+
+		if gap := gaps[interval][0]; !gap.StartOfGap.Equal(t1.Add(5 * time.Minute)) {
+			t.Fatalf("unexpected gap: %v", gap.String())
+		}
+	})
+
+	t.Run("range query", func(t *testing.T) {
+		docs, err := store.GetOhlcRows(OhlcFilter{
+			Symbol: "BTCUSDT",
+			From:   t1.Add(2 * time.Minute),
+			To:     t1.Add(7 * time.Minute),
+			Limit:  3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 3 || !docs[0].StartTime.Equal(t1.Add(2*time.Minute)) {
+			t.Fatalf("unexpected range query result: %v", docs)
+		}
+
+		if docs[0].NumberOfTrades == nil || *docs[0].NumberOfTrades != 2 || docs[0].BaseAssetVolume != nil {
+			t.Fatalf("optional fields were not stored correctly: %+v", docs[0])
+		}
+	})
+
+	t.Run("resample", func(t *testing.T) {
+		filter := OhlcFilter{Symbol: "BTCUSDT", Interval: 60_000}
+
+		docs, err := store.ResampleOhlcRows(filter, Resolution{Interval: 5 * 60_000}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 2 || docs[0].Volume != 50 || docs[1].Volume != 40 || *docs[1].NumberOfTrades != 6+7+8+9 {
+			t.Fatalf("unexpected resampled rows: %+v", docs)
+		}
+
+		// the hour is not complete
+		docs, _ = store.ResampleOhlcRows(filter, Resolution{Interval: 60 * 60_000}, false)
+		if len(docs) != 0 {
+			t.Fatalf("expected no complete buckets, got %+v", docs)
+		}
+
+		docs, _ = store.ResampleOhlcRows(filter, Resolution{Unit: CalendarMonth, Count: 1}, true)
+		if len(docs) != 1 || docs[0].Volume != 90 || docs[0].Interval != int64(31*24*time.Hour/time.Millisecond) {
+			t.Fatalf("unexpected partial bucket: %+v", docs)
+		}
+	})
+
+	t.Run("coverage", func(t *testing.T) {
+		coverage, err := store.GetCoverage("BTCUSDT")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(coverage) != 1 || coverage[0].Count != 9 || !coverage[0].From.Equal(t1) || !coverage[0].To.Equal(t1.Add(9*time.Minute)) {
+			t.Fatalf("unexpected coverage: %+v", coverage)
+		}
+	})
+}
+
+// testAssetStore runs the same checks against any AssetStore implementation
+func testAssetStore(t *testing.T, store AssetStore) {
+	assets := []FuturesAsset{
+		{AssetBase: AssetBase{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT", OrderTypes: []string{"LIMIT", "MARKET"}},
+			Data: FuturesAssetData{ContractType: "PERPETUAL"}},
+		{AssetBase: AssetBase{Source: "binance", Symbol: "ETHUSDT", Status: "TRADING", BaseAsset: "ETH", QuoteAsset: "USDT", OrderTypes: []string{"LIMIT"}},
+			Data: FuturesAssetData{ContractType: "CURRENT_QUARTER"}},
+		{AssetBase: AssetBase{Source: "other", Symbol: "BTCUSDT"}},
+	}
+
+	if _, err := store.UpsertAssets(AnyAssets(assets)); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := store.CountAssets("binance")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Fatalf("expected 2 assets, got %d", count)
+	}
+
+	docs, err := store.GetAssets(AssetFilter{Source: "binance", Symbols: []string{"ETHUSDT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 1 || docs[0].Symbol != "ETHUSDT" {
+		t.Fatalf("unexpected assets: %v", docs)
+	}
+
+	filters := []struct {
+		filter  AssetFilter
+		symbols []string
+	}{
+		{AssetFilter{Source: "binance", Search: "usd"}, []string{"BTCUSDT", "ETHUSDT"}},
+		{AssetFilter{Search: "btc"}, []string{"BTCUSDT", "BTCUSDT"}},
+		{AssetFilter{Status: "TRADING", QuoteAsset: "USDT", BaseAsset: "ETH"}, []string{"ETHUSDT"}},
+		{AssetFilter{ContractType: "PERPETUAL"}, []string{"BTCUSDT"}},
+		{AssetFilter{OrderTypes: []string{"LIMIT", "MARKET"}}, []string{"BTCUSDT"}},
+		{AssetFilter{Search: "%"}, nil},
+	}
+
+	for _, tt := range filters {
+		docs, err := store.GetAssets(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var symbols []string
+		for _, doc := range docs {
+			symbols = append(symbols, doc.Symbol)
+		}
+
+		if !slices.Equal(symbols, tt.symbols) {
+			t.Fatalf("unexpected assets for %+v: %v", tt.filter, symbols)
+		}
+	}
+
+	asset, err := store.GetAsset("binance", "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if asset.AssetBase.BaseAsset != "BTC" || assetContractType(asset.Data) != "PERPETUAL" {
+		t.Fatalf("unexpected asset: %+v", asset)
+	}
+
+	if _, err := store.GetAsset("binance", "XRPUSDT"); err != ErrAssetNotFound {
+		t.Fatalf("expected ErrAssetNotFound, got %v", err)
+	}
+}
+
+// testRevisions checks the provenance fields and the as of queries of an
+// OhlcStore which stores the replaced rows in the revision store.
+func testRevisions(t *testing.T, store OhlcStore, revisions RevisionStore) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetched1 := t1.AddDate(0, 0, 1)
+	fetched2 := t1.AddDate(0, 0, 2)
+
+	newRow := func(i int, close float64, fetchedAt time.Time) OhlcRow {
+		start := t1.Add(time.Duration(i) * time.Minute)
+		row, err := NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1, 2, 0.5, close, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.FetchedAt = fetchedAt
+		row.Endpoint = "test"
+		return *row
+	}
+
+	// the 2nd row is fetched while the candle is open
+	open := t1.Add(time.Minute + 30*time.Second)
+	if _, err := store.UpsertOhlcRows([]OhlcRow{newRow(0, 1.5, fetched1), newRow(1, 1.5, open)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.UpsertOhlcRows([]OhlcRow{newRow(0, 1.6, fetched2), newRow(1, 1.6, fetched2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// same values, so the fetch time is kept
+	if _, err := store.UpsertOhlcRows([]OhlcRow{newRow(0, 1.6, fetched2.Add(time.Hour))}); err != nil {
+		t.Fatal(err)
+	}
+
+	// older responses (e.g. reprocessed from the raw lake) don't replace the values
+	if _, err := store.UpsertOhlcRows([]OhlcRow{newRow(0, 1.5, fetched1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := store.GetOhlcRows(OhlcFilter{Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 2 || docs[0].Revision != 1 || docs[1].Revision != 0 {
+		t.Fatalf("unexpected revisions: %+v", docs)
+	}
+
+	if !docs[0].FetchedAt.Equal(fetched2) || docs[0].Endpoint != "test" {
+		t.Fatalf("unexpected provenance: %v %v", docs[0].FetchedAt, docs[0].Endpoint)
+	}
+
+	revs, err := revisions.GetOhlcRevisions(store.Name(), OhlcFilter{Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 1 || revs[0].Close != 1.5 || !revs[0].RevisedAt.Equal(fetched2) {
+		t.Fatalf("unexpected stored revisions: %+v", revs)
+	}
+
+	tests := []struct {
+		asOf   time.Time
+		closes []float64
+	}{
+		{t1, nil},
+		{fetched1.Add(time.Hour), []float64{1.5}},
+		{fetched2, []float64{1.6, 1.6}},
+	}
+
+	for _, tt := range tests {
+		rows, err := GetOhlcRowsAsOf(store, revisions, OhlcFilter{Symbol: "BTCUSDT"}, tt.asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != len(tt.closes) {
+			t.Fatalf("as of %v: expected %v rows, got %v", tt.asOf, len(tt.closes), len(rows))
+		}
+
+		for i, row := range rows {
+			if row.Close != tt.closes[i] {
+				t.Fatalf("as of %v: expected close %v, got %v", tt.asOf, tt.closes[i], row.Close)
+			}
+		}
+	}
+}
+
+// testMarkets checks that the rows of different markets don't collide and
+// that the rows written without a market can be migrated.
+func testMarkets(t *testing.T, store OhlcStore) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newRow := func(source, market string, close float64) OhlcRow {
+		row, err := NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1, 2, 0.5, close, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *row.SetMarket(source, market)
+	}
+
+	rows := []OhlcRow{
+		newRow("binance", MarketSpot, 1.1),
+		newRow("binance", MarketUsdm, 1.2),
+		newRow("", "", 1.3),
+	}
+
+	if _, err := store.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := store.GetOhlcRows(OhlcFilter{Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 rows, got %v", len(docs))
+	}
+
+	docs, err = store.GetOhlcRows(OhlcFilter{Source: "binance", Market: MarketUsdm})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 1 || docs[0].Close != 1.2 {
+		t.Fatalf("unexpected usdm rows: %+v", docs)
+	}
+
+	updated, err := store.SetMissingMarket("other", MarketCoinm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated != 1 {
+		t.Fatalf("expected 1 updated row, got %v", updated)
+	}
+
+	docs, err = store.GetOhlcRows(OhlcFilter{Source: "other", Market: MarketCoinm})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 1 || docs[0].Close != 1.3 {
+		t.Fatalf("unexpected migrated rows: %+v", docs)
+	}
+}
+
+// testSharedStore checks that the stores of two venues, which share the
+// same collection, only read their own rows.
+func testSharedStore(t *testing.T, store, other OhlcStore) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newRows := func(minutes ...int) []OhlcRow {
+		var rows []OhlcRow
+		for _, i := range minutes {
+			start := t1.Add(time.Duration(i) * time.Minute)
+			row, err := NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1, 2, 0.5, 1.5, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, *row)
+		}
+		return rows
+	}
+
+	// the other venue fills the gap and has a later row
+	if _, err := store.UpsertOhlcRows(newRows(0, 1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.UpsertOhlcRows(newRows(2, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := store.FindLatestStartTime(time.Time{}, "BTCUSDT", 60_000)
+	if err != nil || !latest.Equal(t1.Add(3*time.Minute)) {
+		t.Fatalf("unexpected latest start time: %v, %v", latest, err)
+	}
+
+	gaps, err := store.FindGaps("BTCUSDT", 60_000)
+	if err != nil || len(gaps[60_000]) != 1 {
+		t.Fatalf("expected the gap at the 2nd minute, got %v, %v", gaps, err)
+	}
+
+	coverage, err := store.GetCoverage("BTCUSDT")
+	if err != nil || len(coverage) != 1 || coverage[0].Count != 3 {
+		t.Fatalf("unexpected coverage: %+v, %v", coverage, err)
+	}
+
+	docs, err := store.GetOhlcRows(OhlcFilter{Symbol: "BTCUSDT"})
+	if err != nil || len(docs) != 3 {
+		t.Fatalf("expected 3 rows, got %v, %v", len(docs), err)
+	}
+
+	if deleted, err := other.DeleteOhlcRows(OhlcFilter{Symbol: "BTCUSDT"}); err != nil || deleted != 2 {
+		t.Fatalf("expected 2 deleted rows, got %v, %v", deleted, err)
+	}
+}
+
+func TestStaleOhlcDuplicates(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := []ohlcDuplicate{
+		{Id: 1, FetchedAt: t1},
+		{Id: 2, FetchedAt: t1.Add(time.Hour)},
+		{Id: 3, FetchedAt: t1.Add(time.Hour), Revision: 1},
+	}
+
+	ids := staleOhlcDuplicates(rows)
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Fatalf("expected the latest revision to be kept, got %v", ids)
+	}
+
+	indexes := OhlcIndexes().Indexes()
+	if opt := indexes[len(indexes)-1].Options; opt.Unique == nil || !*opt.Unique {
+		t.Fatalf("expected the index of the row keys to be unique")
+	}
+}
@@ -0,0 +1,279 @@
+// Package jobs controls the jobs of a syro scheduler at runtime (triggering,
+// pausing and resuming them) and tracks the progress of ad-hoc tasks.
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// JobStatusSkipped is the status of the job in the cron storage after a
+// scheduled run was skipped, because the job was paused.
+const JobStatusSkipped syro.JobStatus = "skipped"
+
+// Controller wraps the functions of the registered jobs, so that the jobs
+// can be paused and triggered outside of their schedule. A job never runs
+// twice at the same time, no matter how it was started.
+type Controller struct {
+	sched *syro.CronScheduler
+	jobs  map[string]*jobState
+}
+
+type jobState struct {
+	job        *syro.Job
+	fn         func() error // function of the job before it was wrapped
+	onComplete func(error)  // OnComplete of the job before it was wrapped
+	mu         sync.Mutex
+	paused     bool
+	running    bool
+	skip       syro.JobStatus // status to write after the last scheduled run was skipped
+	lastRun    *JobRun
+	numRuns    int
+	numFails   int
+	numSkips   int
+}
+
+// JobRun is the latest run of a job in the current process.
+type JobRun struct {
+	Manual     bool       `json:"manual"` // started with Trigger instead of the schedule
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// JobState is the state of a registered job.
+type JobState struct {
+	Name        string        `json:"name"`
+	Schedule    string        `json:"schedule"`
+	Description string        `json:"description"`
+	Paused      bool          `json:"paused"`
+	Running     bool          `json:"running"`
+	NumRuns     int           `json:"num_runs"`
+	NumFails    int           `json:"num_fails"`
+	NumSkips    int           `json:"num_skips"` // scheduled runs which were skipped
+	LastRun     *JobRun       `json:"last_run,omitempty"`
+	Stored      *syro.CronJob `json:"stored,omitempty"` // status of the job in the cron storage
+}
+
+// New wraps the jobs which are registered in the scheduler. Has to be called
+// after the jobs are registered and before the scheduler is started.
+func New(sched *syro.CronScheduler) *Controller {
+	c := &Controller{sched: sched, jobs: make(map[string]*jobState)}
+
+	for _, job := range sched.Jobs {
+		st := &jobState{job: job, fn: job.Func, onComplete: job.OnComplete}
+		c.jobs[job.Name] = st
+
+		// the scheduler calls the function of the job pointer on each run
+		job.Func = func() error {
+			if !st.start(false) {
+				return nil
+			}
+			return st.finish(st.fn())
+		}
+
+		// the scheduler marks the run as done before the OnComplete, so the
+		// status of the skipped runs is replaced there
+		job.OnComplete = func(err error) {
+			if status := st.takeSkip(); status != "" {
+				c.register(st, status, nil)
+				return
+			}
+
+			if st.onComplete != nil {
+				st.onComplete(err)
+			}
+		}
+	}
+
+	return c
+}
+
+// register writes the status of the job to the cron storage, if there is one.
+func (c *Controller) register(st *jobState, status syro.JobStatus, err error) {
+	if storage, _ := c.sched.Storage(); storage != nil {
+		storage.RegisterJob(c.sched.Source, st.job.Name, st.job.Schedule, st.job.Description, status, err)
+	}
+}
+
+// start marks the job as running. Returns false if the job is running or if
+// the scheduled run of a paused job should be skipped.
+func (st *jobState) start(manual bool) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.running || (st.paused && !manual) {
+		if !manual {
+			// the run which is in progress may have been triggered, so its
+			// status is written again instead of the skipped one
+			st.skip = JobStatusSkipped
+			if st.running {
+				st.skip = syro.JobStatusRunning
+			}
+			st.numSkips++
+		}
+		return false
+	}
+
+	st.running = true
+	st.numRuns++
+	st.lastRun = &JobRun{Manual: manual, StartedAt: time.Now().UTC()}
+	return true
+}
+
+// takeSkip returns the status to write if the last scheduled run was
+// skipped, or an empty status, and resets it.
+func (st *jobState) takeSkip() syro.JobStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	status := st.skip
+	st.skip = ""
+	return status
+}
+
+func (st *jobState) finish(err error) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	finished := time.Now().UTC()
+	st.running = false
+	st.lastRun.FinishedAt = &finished
+
+	if err != nil {
+		st.numFails++
+		st.lastRun.Error = err.Error()
+	}
+
+	return err
+}
+
+func (st *jobState) state() JobState {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	state := JobState{
+		Name:        st.job.Name,
+		Schedule:    st.job.Schedule,
+		Description: st.job.Description,
+		Paused:      st.paused,
+		Running:     st.running,
+		NumRuns:     st.numRuns,
+		NumFails:    st.numFails,
+		NumSkips:    st.numSkips,
+	}
+
+	if st.lastRun != nil {
+		run := *st.lastRun
+		state.LastRun = &run
+	}
+
+	return state
+}
+
+func (c *Controller) job(name string) (*jobState, error) {
+	st, ok := c.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrJobNotFound, name)
+	}
+	return st, nil
+}
+
+// Jobs returns the state of the jobs in the order of registration, with the
+// status of the job in the cron storage of the scheduler, if there is one.
+func (c *Controller) Jobs() ([]JobState, error) {
+	stored := make(map[string]syro.CronJob)
+
+	if storage, err := c.sched.Storage(); err == nil {
+		docs, err := storage.FindCronJobs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the stored cron jobs: %v", err)
+		}
+
+		for _, doc := range docs {
+			if doc.Source == c.sched.Source {
+				stored[doc.Name] = doc
+			}
+		}
+	}
+
+	states := make([]JobState, 0, len(c.sched.Jobs))
+	for _, job := range c.sched.Jobs {
+		state := c.jobs[job.Name].state()
+		if doc, ok := stored[job.Name]; ok {
+			state.Stored = &doc
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// Job returns the state of the job.
+func (c *Controller) Job(name string) (JobState, error) {
+	st, err := c.job(name)
+	if err != nil {
+		return JobState{}, err
+	}
+	return st.state(), nil
+}
+
+// Trigger runs the job in the background, even if it's paused. The status
+// of the run is written to the cron storage and the OnComplete of the job is
+// called, like for the scheduled runs.
+func (c *Controller) Trigger(name string) error {
+	st, err := c.job(name)
+	if err != nil {
+		return err
+	}
+
+	if !st.start(true) {
+		return fmt.Errorf("%w: %v", ErrJobRunning, name)
+	}
+
+	go func() {
+		c.register(st, syro.JobStatusRunning, nil)
+
+		err := st.finish(st.fn())
+		c.register(st, syro.JobStatusDone, err)
+
+		if st.onComplete != nil {
+			st.onComplete(err)
+		}
+	}()
+
+	return nil
+}
+
+// Pause skips the scheduled runs of the job until it's resumed. A run which
+// is in progress is not stopped.
+func (c *Controller) Pause(name string) error {
+	return c.setPaused(name, true)
+}
+
+// Resume continues the scheduled runs of the paused job.
+func (c *Controller) Resume(name string) error {
+	return c.setPaused(name, false)
+}
+
+func (c *Controller) setPaused(name string, paused bool) error {
+	st, err := c.job(name)
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	st.paused = paused
+	st.mu.Unlock()
+
+	return nil
+}
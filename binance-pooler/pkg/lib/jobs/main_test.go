@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/tompston/syro"
+)
+
+// waitFor polls the condition until it's true or the timeout is reached.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatal("condition not met before the timeout")
+}
+
+// memoryStorage keeps the latest status of the jobs.
+type memoryStorage struct {
+	mu     sync.Mutex
+	status map[string]syro.JobStatus
+}
+
+func (s *memoryStorage) FindCronJobs() ([]syro.CronJob, error) { return nil, nil }
+
+func (s *memoryStorage) RegisterJob(source, name, sched, descr string, status syro.JobStatus, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status[name] = status
+	return nil
+}
+
+func (s *memoryStorage) SetStatusForJobs(source string, status syro.JobStatus) error { return nil }
+
+func (s *memoryStorage) get(name string) syro.JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status[name]
+}
+
+func TestController(t *testing.T) {
+	storage := &memoryStorage{status: make(map[string]syro.JobStatus)}
+	sched := syro.NewCronScheduler(cron.New(), "test").WithStorage(storage)
+
+	var runs, completed atomic.Int32
+	release := make(chan struct{})
+
+	if err := sched.Register(&syro.Job{
+		Name:     "job",
+		Schedule: "@every 1h",
+		Func: func() error {
+			runs.Add(1)
+			<-release
+			return errors.New("failed")
+		},
+		OnComplete: func(err error) {
+			if err != nil {
+				completed.Add(1)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(sched)
+
+	if err := c.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+
+	t.Run("trigger", func(t *testing.T) {
+		if err := c.Trigger("job"); err != nil {
+			t.Fatal(err)
+		}
+
+		waitFor(t, func() bool { return runs.Load() == 1 })
+
+		// neither a manual nor a scheduled run starts while the job is running
+		if err := c.Trigger("job"); !errors.Is(err, ErrJobRunning) {
+			t.Fatalf("expected ErrJobRunning, got %v", err)
+		}
+
+		if err := sched.Jobs[0].Func(); err != nil || runs.Load() != 1 {
+			t.Fatalf("expected the scheduled run to be skipped, got %v runs, err %v", runs.Load(), err)
+		}
+		sched.Jobs[0].OnComplete(nil)
+
+		// the triggered run is still in progress
+		if status := storage.get("job"); status != syro.JobStatusRunning {
+			t.Fatalf("expected the running status, got %v", status)
+		}
+
+		release <- struct{}{}
+
+		waitFor(t, func() bool {
+			state, _ := c.Job("job")
+			return !state.Running && storage.get("job") == syro.JobStatusDone && completed.Load() == 1
+		})
+
+		state, err := c.Job("job")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state.NumRuns != 1 || state.NumFails != 1 || !state.LastRun.Manual || state.LastRun.Error != "failed" || state.LastRun.FinishedAt == nil {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		if err := c.Pause("job"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sched.Jobs[0].Func(); err != nil || runs.Load() != 1 {
+			t.Fatalf("expected the paused run to be skipped, got %v runs, err %v", runs.Load(), err)
+		}
+		sched.Jobs[0].OnComplete(nil)
+
+		if status := storage.get("job"); status != JobStatusSkipped {
+			t.Fatalf("expected the skipped status, got %v", status)
+		}
+
+		if err := c.Resume("job"); err != nil {
+			t.Fatal(err)
+		}
+
+		go func() { release <- struct{}{} }()
+
+		storage.RegisterJob("test", "job", "", "", syro.JobStatusDone, nil)
+
+		err := sched.Jobs[0].Func()
+		if err == nil || runs.Load() != 2 {
+			t.Fatalf("expected the resumed job to run, got %v runs, err %v", runs.Load(), err)
+		}
+		sched.Jobs[0].OnComplete(err)
+
+		if status := storage.get("job"); status != syro.JobStatusDone || completed.Load() != 2 {
+			t.Fatalf("expected the status of the completed run to be kept, got %v (%v completed)", status, completed.Load())
+		}
+
+		states, err := c.Jobs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(states) != 1 || states[0].Paused || states[0].NumRuns != 2 || states[0].NumSkips != 2 || states[0].LastRun.Manual {
+			t.Fatalf("unexpected states: %+v", states)
+		}
+	})
+}
+
+func TestTasks(t *testing.T) {
+	tasks := NewTasks()
+
+	done, err := tasks.Start("backfill", nil, func(progress Progress) error {
+		progress(1, 2)
+		progress(2, 2)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := tasks.Start("other", nil, func(progress Progress) error {
+		return errors.New("failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		t1, _ := tasks.Get(done.ID)
+		t2, _ := tasks.Get(failed.ID)
+		return t1.Status != TaskRunning && t2.Status != TaskRunning
+	})
+
+	if task, _ := tasks.Get(done.ID); task.Status != TaskDone || task.Done != 2 || task.Total != 2 || task.FinishedAt == nil {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	if task, _ := tasks.Get(failed.ID); task.Status != TaskFailed || task.Error != "failed" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	if list := tasks.List("backfill"); len(list) != 1 || list[0].ID != done.ID {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if _, err := tasks.Get("missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// Status of the tasks
+const (
+	TaskRunning = "running"
+	TaskDone    = "done"
+	TaskFailed  = "failed"
+)
+
+// Task is an ad-hoc run of a function in the background (e.g. a backfill of
+// a symbol), of which the progress can be polled.
+type Task struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Params     any        `json:"params"`
+	Status     string     `json:"status"`
+	Done       int        `json:"done"`  // Number of finished steps
+	Total      int        `json:"total"` // Number of steps, if known
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Progress is called by the function of the task after each step.
+type Progress func(done, total int)
+
+// Tasks holds the tasks which were started in the current process.
+type Tasks struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+func NewTasks() *Tasks {
+	return &Tasks{tasks: make(map[string]*Task)}
+}
+
+// Start runs the function in the background and returns the started task.
+func (t *Tasks) Start(name string, params any, fn func(progress Progress) error) (Task, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Task{}, fmt.Errorf("failed to generate the task id: %v", err)
+	}
+
+	task := &Task{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Params:    params,
+		Status:    TaskRunning,
+		StartedAt: time.Now().UTC(),
+	}
+
+	t.mu.Lock()
+	t.tasks[task.ID] = task
+	started := *task
+	t.mu.Unlock()
+
+	go func() {
+		err := fn(func(done, total int) {
+			t.mu.Lock()
+			task.Done, task.Total = done, total
+			t.mu.Unlock()
+		})
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		finished := time.Now().UTC()
+		task.FinishedAt = &finished
+		task.Status = TaskDone
+
+		if err != nil {
+			task.Status = TaskFailed
+			task.Error = err.Error()
+		}
+	}()
+
+	return started, nil
+}
+
+// Get returns the task with the id.
+func (t *Tasks) Get(id string) (Task, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	task, ok := t.tasks[id]
+	if !ok {
+		return Task{}, fmt.Errorf("%w: %v", ErrTaskNotFound, id)
+	}
+
+	return *task, nil
+}
+
+// List returns the tasks with the name (or all of them, if the name is
+// empty), starting with the latest one.
+func (t *Tasks) List(name string) []Task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tasks := []Task{}
+	for _, task := range t.tasks {
+		if name == "" || task.Name == name {
+			tasks = append(tasks, *task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].StartedAt.After(tasks[j].StartedAt) })
+	return tasks
+}
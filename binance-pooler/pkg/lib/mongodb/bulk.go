@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// BulkInsertSettings controls how InsertChunked writes documents into a collection.
+type BulkInsertSettings struct {
+	ChunkSize          int  // max number of documents sent in a single InsertMany call
+	RelaxWriteConcern  bool // acknowledge writes from the primary only, without waiting for the journal
+	FailOnDuplicateKey bool // by default duplicate key errors are ignored, so that re-inserting the same rows is safe
+}
+
+// DefaultBulkInsertSettings are used when no settings are passed to InsertChunked.
+var DefaultBulkInsertSettings = BulkInsertSettings{ChunkSize: 1000}
+
+// InsertChunked writes the docs into the collection using unordered InsertMany
+// calls of at most ChunkSize documents. Unordered writes let the server
+// continue after a failed document, so a chunk which partially overlaps
+// existing rows (with a unique index) still inserts the remaining rows.
+// Returns the number of inserted documents.
+func InsertChunked[T any](coll *mongo.Collection, docs []T, settings ...BulkInsertSettings) (int, error) {
+	if coll == nil {
+		return 0, fmt.Errorf("coll is nil")
+	}
+
+	conf := DefaultBulkInsertSettings
+	if len(settings) == 1 {
+		conf = settings[0]
+	}
+
+	if conf.ChunkSize <= 0 {
+		return 0, fmt.Errorf("chunk size must be greater than 0")
+	}
+
+	if wc := bulkWriteConcern(conf); wc != nil {
+		clone, err := coll.Clone(options.Collection().SetWriteConcern(wc))
+		if err != nil {
+			return 0, fmt.Errorf("failed to clone %v collection: %v", coll.Name(), err)
+		}
+		coll = clone
+	}
+
+	opt := options.InsertMany().SetOrdered(false)
+	inserted := 0
+
+	for _, chunk := range chunkSlice(docs, conf.ChunkSize) {
+		batch := make([]any, len(chunk))
+		for i := range chunk {
+			batch[i] = chunk[i]
+		}
+
+		if _, err := coll.InsertMany(context.Background(), batch, opt); err != nil {
+			numDuplicates, ok := duplicateKeyErrors(err)
+			if !ok || conf.FailOnDuplicateKey {
+				return inserted, err
+			}
+
+			inserted += len(batch) - numDuplicates
+			continue
+		}
+
+		inserted += len(batch)
+	}
+
+	return inserted, nil
+}
+
+// bulkWriteConcern returns the write concern of the inserts, or nil if the
+// write concern of the collection is kept.
+func bulkWriteConcern(conf BulkInsertSettings) *writeconcern.WriteConcern {
+	if !conf.RelaxWriteConcern {
+		return nil
+	}
+
+	journal := false
+	return &writeconcern.WriteConcern{W: 1, Journal: &journal}
+}
+
+// chunkSlice splits the slice into consecutive chunks of at most size elements.
+func chunkSlice[T any](data []T, size int) [][]T {
+	if size <= 0 || len(data) == 0 {
+		return nil
+	}
+
+	chunks := make([][]T, 0, (len(data)+size-1)/size)
+	for start := 0; start < len(data); start += size {
+		end := min(start+size, len(data))
+		chunks = append(chunks, data[start:end])
+	}
+
+	return chunks
+}
+
+// duplicateKeyErrors returns the number of failed writes of an unordered
+// bulk insert and true, if every one of them was caused by a duplicate key.
+func duplicateKeyErrors(err error) (int, bool) {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) {
+		return 0, false
+	}
+
+	if bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return 0, false
+	}
+
+	for _, we := range bwe.WriteErrors {
+		if we.Code != duplicateKeyCode {
+			return 0, false
+		}
+	}
+
+	return len(bwe.WriteErrors), true
+}
+
+const duplicateKeyCode = 11000
@@ -0,0 +1,31 @@
+package mongodb
+
+import "testing"
+
+func TestChunkSlice(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5, 6, 7}
+
+	chunks := chunkSlice(data, 3)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+
+	if len(chunks[2]) != 1 || chunks[2][0] != 7 {
+		t.Fatalf("expected the last chunk to hold the remainder, got %v", chunks[2])
+	}
+
+	if chunks := chunkSlice([]int{}, 3); chunks != nil {
+		t.Fatalf("expected no chunks for empty input, got %v", chunks)
+	}
+}
+
+func TestBulkWriteConcern(t *testing.T) {
+	if wc := bulkWriteConcern(BulkInsertSettings{ChunkSize: 10}); wc != nil {
+		t.Fatalf("expected the write concern of the collection to be kept, got %+v", wc)
+	}
+
+	wc := bulkWriteConcern(BulkInsertSettings{ChunkSize: 10, RelaxWriteConcern: true})
+	if wc == nil || wc.W != 1 || wc.Journal == nil || *wc.Journal {
+		t.Fatalf("expected the primary only write concern without the journal, got %+v", wc)
+	}
+}
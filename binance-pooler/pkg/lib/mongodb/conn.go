@@ -4,25 +4,58 @@ package mongodb
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
+// ConnSettings holds the optional settings of the connection. Zero values
+// are ignored, so the defaults of the driver (or the uri) are used.
+type ConnSettings struct {
+	Username               string
+	Password               string
+	MinPoolSize            uint64
+	MaxPoolSize            uint64
+	MaxConnIdleTime        time.Duration
+	ConnectTimeout         time.Duration
+	SocketTimeout          time.Duration
+	ServerSelectionTimeout time.Duration
+	TlsCaFile              string // PEM file with the certificate authorities
+	TlsCertFile            string // PEM file with the client certificate and private key
+	ReadPreference         string // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	WriteConcern           string // "majority" or the number of nodes
+}
+
+// DefaultConnSettings are used if no settings are passed to New.
+var DefaultConnSettings = ConnSettings{
+	MaxPoolSize:     20,               // Set the maximum number of connections in the connection pool
+	MaxConnIdleTime: 10 * time.Minute, // Close idle connections after the specified time
+}
+
 // New returns a new Db struct with the connection to the database and the db schema
-func New(uri string) (*mongo.Client, error) {
+func New(uri string, settings ...ConnSettings) (*mongo.Client, error) {
 
 	if uri == "" {
 		return nil, fmt.Errorf("uri for mongodb connection is empty")
 	}
 
-	opt := options.Client().
-		SetMaxPoolSize(20).                  // Set the maximum number of connections in the connection pool
-		SetMaxConnIdleTime(10 * time.Minute) // Close idle connections after the specified time
+	s := ConnSettings{}.WithDefaults(uri)
+	if len(settings) == 1 {
+		s = settings[0]
+	}
 
-	opt.ApplyURI(uri)
+	opt := options.Client().ApplyURI(uri)
+	if err := s.apply(opt); err != nil {
+		return nil, err
+	}
 
 	conn, err := mongo.Connect(context.Background(), opt)
 	if err != nil {
@@ -36,6 +69,138 @@ func New(uri string) (*mongo.Client, error) {
 	return conn, nil
 }
 
+// WithDefaults returns the settings with the values of the DefaultConnSettings
+// which are not set in the settings or in the uri.
+func (s ConnSettings) WithDefaults(uri string) ConnSettings {
+	opt := options.Client().ApplyURI(uri)
+
+	if s.MaxPoolSize == 0 && opt.MaxPoolSize == nil {
+		s.MaxPoolSize = DefaultConnSettings.MaxPoolSize
+	}
+
+	if s.MaxConnIdleTime == 0 && opt.MaxConnIdleTime == nil {
+		s.MaxConnIdleTime = DefaultConnSettings.MaxConnIdleTime
+	}
+
+	return s
+}
+
+// apply sets the non zero settings on the client options. The credentials
+// of the uri are kept, unless they are overridden.
+func (s ConnSettings) apply(opt *options.ClientOptions) error {
+	if s.Username != "" || s.Password != "" {
+		var cred options.Credential
+		if opt.Auth != nil {
+			cred = *opt.Auth
+		}
+
+		if s.Username != "" {
+			cred.Username = s.Username
+		}
+
+		if s.Password != "" {
+			cred.Password = s.Password
+			cred.PasswordSet = true
+		}
+		opt.SetAuth(cred)
+	}
+
+	if s.MinPoolSize > 0 {
+		opt.SetMinPoolSize(s.MinPoolSize)
+	}
+
+	if s.MaxPoolSize > 0 {
+		opt.SetMaxPoolSize(s.MaxPoolSize)
+	}
+
+	if s.MaxConnIdleTime > 0 {
+		opt.SetMaxConnIdleTime(s.MaxConnIdleTime)
+	}
+
+	if s.ConnectTimeout > 0 {
+		opt.SetConnectTimeout(s.ConnectTimeout)
+	}
+
+	if s.SocketTimeout > 0 {
+		opt.SetSocketTimeout(s.SocketTimeout)
+	}
+
+	if s.ServerSelectionTimeout > 0 {
+		opt.SetServerSelectionTimeout(s.ServerSelectionTimeout)
+	}
+
+	if s.TlsCaFile != "" || s.TlsCertFile != "" {
+		tlsConf, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		opt.SetTLSConfig(tlsConf)
+	}
+
+	if s.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(s.ReadPreference)
+		if err != nil {
+			return fmt.Errorf("invalid read preference: %v", err)
+		}
+
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return fmt.Errorf("invalid read preference: %v", err)
+		}
+		opt.SetReadPreference(rp)
+	}
+
+	if s.WriteConcern != "" {
+		wc, err := parseWriteConcern(s.WriteConcern)
+		if err != nil {
+			return err
+		}
+		opt.SetWriteConcern(wc)
+	}
+
+	return nil
+}
+
+func (s ConnSettings) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if s.TlsCaFile != "" {
+		pem, err := os.ReadFile(s.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", s.TlsCaFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if s.TlsCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TlsCertFile, s.TlsCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls cert file: %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+func parseWriteConcern(val string) (*writeconcern.WriteConcern, error) {
+	if val == "majority" {
+		return writeconcern.Majority(), nil
+	}
+
+	w, err := strconv.Atoi(val)
+	if err != nil || w < 0 {
+		return nil, fmt.Errorf("invalid write concern: %v", val)
+	}
+
+	return &writeconcern.WriteConcern{W: w}, nil
+}
+
 func Coll(conn *mongo.Client, dbName, collName string) *mongo.Collection {
 	return conn.Database(dbName).Collection(collName)
 }
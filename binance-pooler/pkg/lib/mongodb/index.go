@@ -3,54 +3,132 @@ package mongodb
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// IndexKey is a single key of an index, with the sort direction
+// (1 for ascending, -1 for descending).
+type IndexKey struct {
+	Field     string
+	Direction int
+}
+
+func Asc(field string) IndexKey  { return IndexKey{Field: field, Direction: 1} }
+func Desc(field string) IndexKey { return IndexKey{Field: field, Direction: -1} }
+
+// Index describes an index of a collection. Zero values of the optional
+// fields are ignored.
+type Index struct {
+	Keys          []IndexKey
+	Name          string        // Optional. Defaults to the name generated by mongodb (e.g. symbol_-1_interval_-1).
+	Unique        bool          // Optional.
+	ExpireAfter   time.Duration // Optional. Creates a TTL index, which requires a single date key.
+	PartialFilter bson.M        // Optional. Only the matching documents are indexed.
+	Collation     *options.Collation
+}
+
+// IndexName returns the name of the index, which is used to compare it
+// with the existing indexes.
+func (idx Index) IndexName() string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+
+	parts := make([]string, len(idx.Keys))
+	for i, key := range idx.Keys {
+		parts[i] = fmt.Sprintf("%v_%d", key.Field, key.Direction)
+	}
+	return strings.Join(parts, "_")
+}
+
+// Model converts the index into the model accepted by the driver.
+func (idx Index) Model() mongo.IndexModel {
+	keys := bson.D{}
+	for _, key := range idx.Keys {
+		keys = append(keys, bson.E{Key: key.Field, Value: key.Direction})
+	}
+
+	opt := options.Index().SetName(idx.IndexName())
+
+	if idx.Unique {
+		opt.SetUnique(true)
+	}
+
+	if idx.ExpireAfter > 0 {
+		opt.SetExpireAfterSeconds(int32(idx.ExpireAfter.Seconds()))
+	}
+
+	if len(idx.PartialFilter) > 0 {
+		opt.SetPartialFilterExpression(idx.PartialFilter)
+	}
+
+	if idx.Collation != nil {
+		opt.SetCollation(idx.Collation)
+	}
+
+	return mongo.IndexModel{Keys: keys, Options: opt}
+}
+
 // IndexBuilder is a helper for creating indexes for a MongoDB collection
 // in a more reusable way.
 type IndexBuilder struct {
-	indexes []mongo.IndexModel
+	indexes []Index
 }
 
 // Indexes returns the indexes that have been added to the IndexBuilder
-func (ib *IndexBuilder) Indexes() []mongo.IndexModel { return ib.indexes }
+func (ib *IndexBuilder) Indexes() []mongo.IndexModel {
+	models := make([]mongo.IndexModel, len(ib.indexes))
+	for i, idx := range ib.indexes {
+		models[i] = idx.Model()
+	}
+	return models
+}
 
 // NewIndexes creates a new IndexBuilder for a given collection.
 func NewIndexes() *IndexBuilder { return &IndexBuilder{} }
 
+// AddIndex adds an index with the specified keys and options.
+func (ib *IndexBuilder) AddIndex(idx Index) *IndexBuilder {
+	ib.indexes = append(ib.indexes, idx)
+	return ib
+}
+
 // Add adds a new index to the IndexBuilder. It supports both single and compound
 // indexes. All indexes are created in descending order.
 func (ib *IndexBuilder) Add(keys ...string) *IndexBuilder {
-	indexKeys := bson.D{}
-	for _, key := range keys {
-		indexKeys = append(indexKeys, bson.E{Key: key, Value: -1})
-	}
-	indexModel := mongo.IndexModel{Keys: indexKeys}
-	ib.indexes = append(ib.indexes, indexModel)
-	return ib
+	return ib.AddIndex(Index{Keys: descKeys(keys)})
 }
 
 // AddUnique is adapted to enforce a unique constraint on a combination of fields.
 func (ib *IndexBuilder) AddUnique(keys ...string) *IndexBuilder {
-	// Building the compound index keys
-	compoundKeys := bson.D{} // Using bson.D to maintain the order of keys
-	for _, key := range keys {
-		compoundKeys = append(compoundKeys, bson.E{Key: key, Value: -1})
-	}
+	return ib.AddIndex(Index{Keys: descKeys(keys), Unique: true})
+}
 
-	// Creating the index model with the compound keys
-	indexModel := mongo.IndexModel{
-		Keys:    compoundKeys,
-		Options: options.Index().SetUnique(true),
+// AddTTL adds a TTL index on the date field, so that mongodb deletes the documents
+// once the value of the field is older than expireAfter. The optional partial
+// filter limits the expiry to the matching documents. The name is required,
+// because the key can also be a part of a regular index.
+func (ib *IndexBuilder) AddTTL(name, key string, expireAfter time.Duration, partialFilter ...bson.M) *IndexBuilder {
+	idx := Index{Name: name, Keys: []IndexKey{Asc(key)}, ExpireAfter: expireAfter}
+	if len(partialFilter) == 1 {
+		idx.PartialFilter = partialFilter[0]
 	}
+	return ib.AddIndex(idx)
+}
 
-	// Adding the index model to the indexes slice
-	ib.indexes = append(ib.indexes, indexModel)
-
-	return ib
+func descKeys(keys []string) []IndexKey {
+	out := make([]IndexKey, len(keys))
+	for i, key := range keys {
+		out[i] = Desc(key)
+	}
+	return out
 }
 
 // Create creates all the indexes that have been added to the IndexBuilder.
@@ -67,10 +145,209 @@ func (ib *IndexBuilder) Create(coll *mongo.Collection) error {
 		return fmt.Errorf("no indexes to create")
 	}
 
-	_, err := coll.Indexes().CreateMany(context.Background(), ib.indexes)
+	_, err := coll.Indexes().CreateMany(context.Background(), ib.Indexes())
 	return err
 }
 
+// SyncSettings holds the optional settings of the IndexBuilder.Sync method.
+type SyncSettings struct {
+	DropUnknown bool // Drop the existing indexes which are not in the builder (except _id_).
+	DryRun      bool // Only report the changes, without applying them.
+}
+
+// SyncReport lists the names of the indexes which were (or would be, in a dry
+// run) changed by the IndexBuilder.Sync method.
+type SyncReport struct {
+	Collection string   `json:"collection"`
+	DryRun     bool     `json:"dry_run"`
+	Created    []string `json:"created"`
+	Recreated  []string `json:"recreated"` // The existing index had the same name, but different options.
+	Dropped    []string `json:"dropped"`
+	Unchanged  []string `json:"unchanged"`
+}
+
+func (r SyncReport) String() string {
+	return fmt.Sprintf("coll: %v, dry run: %v, created: %v, recreated: %v, dropped: %v, unchanged: %v",
+		r.Collection, r.DryRun, r.Created, r.Recreated, r.Dropped, r.Unchanged)
+}
+
+// Sync compares the indexes of the builder with the existing indexes of the
+// collection (by name) and creates the missing ones. Existing indexes with
+// different options are dropped and created again. Unknown indexes are only
+// dropped if the DropUnknown setting is set.
+func (ib *IndexBuilder) Sync(coll *mongo.Collection, settings ...SyncSettings) (*SyncReport, error) {
+	if coll == nil {
+		return nil, fmt.Errorf("coll is nil")
+	}
+
+	var opt SyncSettings
+	if len(settings) == 1 {
+		opt = settings[0]
+	}
+
+	existing, err := listIndexes(coll)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of %v: %v", coll.Name(), err)
+	}
+
+	report := diffIndexes(ib.indexes, existing, opt.DropUnknown)
+	report.Collection = coll.Name()
+	report.DryRun = opt.DryRun
+
+	if opt.DryRun {
+		return &report, nil
+	}
+
+	for _, name := range slices.Concat(report.Dropped, report.Recreated) {
+		if err := DeleteIndex(coll, name); err != nil {
+			return nil, fmt.Errorf("failed to drop index %v of %v: %v", name, coll.Name(), err)
+		}
+	}
+
+	var models []mongo.IndexModel
+	for _, idx := range ib.indexes {
+		name := idx.IndexName()
+		if slices.Contains(report.Created, name) || slices.Contains(report.Recreated, name) {
+			models = append(models, idx.Model())
+		}
+	}
+
+	if len(models) > 0 {
+		if _, err := coll.Indexes().CreateMany(context.Background(), models); err != nil {
+			return nil, fmt.Errorf("failed to create indexes of %v: %v", coll.Name(), err)
+		}
+	}
+
+	return &report, nil
+}
+
+// existingIndex holds the fields of the listIndexes output which are compared
+// with the desired indexes.
+type existingIndex struct {
+	Name               string  `bson:"name"`
+	Key                bson.D  `bson:"key"`
+	Unique             bool    `bson:"unique"`
+	ExpireAfterSeconds *int32  `bson:"expireAfterSeconds"`
+	PartialFilter      bson.M  `bson:"partialFilterExpression"`
+	Collation          *bson.M `bson:"collation"`
+}
+
+func listIndexes(coll *mongo.Collection) ([]existingIndex, error) {
+	cur, err := coll.Indexes().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var indexes []existingIndex
+	err = cur.All(context.Background(), &indexes)
+	return indexes, err
+}
+
+func diffIndexes(desired []Index, existing []existingIndex, dropUnknown bool) SyncReport {
+	report := SyncReport{}
+
+	byName := make(map[string]existingIndex)
+	for _, idx := range existing {
+		byName[idx.Name] = idx
+	}
+
+	wanted := make(map[string]bool)
+	for _, idx := range desired {
+		name := idx.IndexName()
+		wanted[name] = true
+
+		curr, ok := byName[name]
+		switch {
+		case !ok:
+			report.Created = append(report.Created, name)
+		case indexMatches(idx, curr):
+			report.Unchanged = append(report.Unchanged, name)
+		default:
+			report.Recreated = append(report.Recreated, name)
+		}
+	}
+
+	if dropUnknown {
+		for _, idx := range existing {
+			if idx.Name != "_id_" && !wanted[idx.Name] {
+				report.Dropped = append(report.Dropped, idx.Name)
+			}
+		}
+	}
+
+	return report
+}
+
+// indexMatches returns true if the existing index has the same keys and options.
+func indexMatches(idx Index, curr existingIndex) bool {
+	if len(idx.Keys) != len(curr.Key) {
+		return false
+	}
+
+	for i, key := range idx.Keys {
+		if curr.Key[i].Key != key.Field || toInt(curr.Key[i].Value) != key.Direction {
+			return false
+		}
+	}
+
+	if idx.Unique != curr.Unique {
+		return false
+	}
+
+	expireAfter := int32(idx.ExpireAfter.Seconds())
+	if (idx.ExpireAfter > 0) != (curr.ExpireAfterSeconds != nil) ||
+		curr.ExpireAfterSeconds != nil && *curr.ExpireAfterSeconds != expireAfter {
+		return false
+	}
+
+	if len(idx.PartialFilter) > 0 || len(curr.PartialFilter) > 0 {
+		// round trip the filter, so that the value types match the decoded ones
+		b, err := bson.Marshal(idx.PartialFilter)
+		if err != nil {
+			return false
+		}
+
+		var filter bson.M
+		if err := bson.Unmarshal(b, &filter); err != nil || !reflect.DeepEqual(filter, curr.PartialFilter) {
+			return false
+		}
+	}
+
+	if (idx.Collation != nil) != (curr.Collation != nil) {
+		return false
+	}
+
+	// the server fills in the defaults of the collation, so only the
+	// locale and strength are compared
+	if idx.Collation != nil {
+		c := *curr.Collation
+		if c["locale"] != idx.Collation.Locale {
+			return false
+		}
+
+		if idx.Collation.Strength != 0 && toInt(c["strength"]) != idx.Collation.Strength {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
 // AvailableIndexes returns all the indexes created for the collection.
 func AvailableIndexes(coll *mongo.Collection) ([]bson.M, error) {
 	indexesCursor, err := coll.Indexes().List(context.Background())
@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	ttl := int32(3600)
+
+	existing := []existingIndex{
+		{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Name: "symbol_-1", Key: bson.D{{Key: "symbol", Value: int32(-1)}}},
+		{Name: "symbol_-1_interval_-1", Key: bson.D{{Key: "symbol", Value: int32(-1)}, {Key: "interval", Value: int32(-1)}}},
+		{
+			Name:               "ttl_start_time",
+			Key:                bson.D{{Key: "start_time", Value: int32(1)}},
+			ExpireAfterSeconds: &ttl,
+			PartialFilter:      bson.M{"interval": int64(60000)},
+		},
+		{Name: "unknown_1", Key: bson.D{{Key: "unknown", Value: int32(1)}}},
+	}
+
+	desired := NewIndexes().
+		Add("symbol").
+		// same name, but the direction of the second key differs
+		AddIndex(Index{Keys: []IndexKey{Desc("symbol"), Asc("interval")}, Name: "symbol_-1_interval_-1"}).
+		AddTTL("ttl_start_time", "start_time", time.Hour, bson.M{"interval": int64(60000)}).
+		AddIndex(Index{Keys: []IndexKey{Asc("source"), Desc("status")}, Unique: true})
+
+	report := diffIndexes(desired.indexes, existing, false)
+
+	if !slices.Equal(report.Unchanged, []string{"symbol_-1", "ttl_start_time"}) {
+		t.Fatalf("unexpected unchanged indexes: %v", report.Unchanged)
+	}
+
+	if !slices.Equal(report.Recreated, []string{"symbol_-1_interval_-1"}) {
+		t.Fatalf("unexpected recreated indexes: %v", report.Recreated)
+	}
+
+	if !slices.Equal(report.Created, []string{"source_1_status_-1"}) {
+		t.Fatalf("unexpected created indexes: %v", report.Created)
+	}
+
+	if len(report.Dropped) != 0 {
+		t.Fatalf("expected no dropped indexes, got %v", report.Dropped)
+	}
+
+	report = diffIndexes(desired.indexes, existing, true)
+	if !slices.Equal(report.Dropped, []string{"unknown_1"}) {
+		t.Fatalf("unexpected dropped indexes: %v", report.Dropped)
+	}
+
+	// a changed ttl requires the index to be created again
+	changed := NewIndexes().AddTTL("ttl_start_time", "start_time", 2*time.Hour, bson.M{"interval": int64(60000)})
+	if report := diffIndexes(changed.indexes, existing, false); !slices.Equal(report.Recreated, []string{"ttl_start_time"}) {
+		t.Fatalf("expected the ttl index to be recreated, got %+v", report)
+	}
+}
@@ -60,7 +60,9 @@ func (g GapInfo) String() string {
 	return g.StartOfGap.Format("2006-01-02 15:04:05") + " - " + g.EndOfGap.Format("2006-01-02 15:04:05")
 }
 
-func findGapsInIntervalGroup(records []TimeseriesFields) []GapInfo {
+// FindGapsInSeries returns the gaps between consecutive records, which are
+// expected to be sorted by the start time and share the same interval.
+func FindGapsInSeries(records []TimeseriesFields) []GapInfo {
 	var gaps []GapInfo
 	for i := 0; i < len(records)-1; i++ {
 		currStartTime := records[i].StartTime
@@ -135,7 +137,7 @@ func FindGaps(coll *mongo.Collection, customFilter ...bson.M) (map[int64][]GapIn
 		}
 		cursor.Close(ctx)
 
-		gaps := findGapsInIntervalGroup(rec)
+		gaps := FindGapsInSeries(rec)
 		if len(gaps) > 0 {
 			gapsMap[interval] = gaps
 		}
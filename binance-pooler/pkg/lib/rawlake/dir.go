@@ -0,0 +1,184 @@
+package rawlake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirStore stores the records as gzip files in a directory, using the layout
+//
+//	<dir>/<endpoint>/<yyyy-mm-dd>/<fetched at unix ms>_<params hash>.gz
+//
+// The endpoint and the query params are also written to the gzip header, so
+// each file holds the full key of the record.
+type DirStore struct {
+	dir string
+}
+
+func NewDirStore(dir string) (*DirStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("raw lake dir is empty")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the raw lake dir: %v", err)
+	}
+
+	return &DirStore{dir: dir}, nil
+}
+
+// endpointDir returns the name of the directory of the endpoint, e.g.
+// api.binance.com_api_v3_klines
+func endpointDir(endpoint string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	return strings.NewReplacer("/", "_", ":", "_", "?", "_").Replace(strings.Trim(name, "/"))
+}
+
+func (s *DirStore) Put(rec Record) error {
+	fetchedAt := rec.FetchedAt.UTC()
+	hash := sha1.Sum([]byte(rec.Params.Encode()))
+
+	name := fmt.Sprintf("%d_%v.gz", fetchedAt.UnixMilli(), hex.EncodeToString(hash[:4]))
+	dir := filepath.Join(s.dir, endpointDir(rec.Endpoint), fetchedAt.Format(time.DateOnly))
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create the raw lake dir: %v", err)
+	}
+
+	data, err := compressWithKey(rec)
+	if err != nil {
+		return fmt.Errorf("failed to compress the body: %v", err)
+	}
+
+	// write to a temp file first, so that Walk never reads a partial file
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *DirStore) Walk(filter Filter, fn func(rec Record) error) error {
+	type file struct {
+		path      string
+		fetchedAt int64
+	}
+
+	// the endpoint is matched by the directory of the file
+	timeFilter := Filter{From: filter.From, To: filter.To}
+
+	var files []file
+	err := filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(path, ".gz") {
+			return nil
+		}
+
+		prefix, _, _ := strings.Cut(d.Name(), "_")
+		ms, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil
+		}
+
+		if timeFilter.matches("", time.UnixMilli(ms)) {
+			files = append(files, file{path, ms})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the raw lake files: %v", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].fetchedAt != files[j].fetchedAt {
+			return files[i].fetchedAt < files[j].fetchedAt
+		}
+		return files[i].path < files[j].path
+	})
+
+	for _, f := range files {
+		if filter.Endpoint != "" && filepath.Base(filepath.Dir(filepath.Dir(f.path))) != endpointDir(filter.Endpoint) {
+			continue
+		}
+
+		rec, err := readRecord(f.path, f.fetchedAt)
+		if err != nil {
+			return err
+		}
+
+		if !filter.matches(rec.Endpoint, rec.FetchedAt) {
+			continue
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressWithKey compresses the body, writing the endpoint into the name and
+// the query params into the comment of the gzip header.
+func compressWithKey(rec Record) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = rec.Endpoint
+	zw.Comment = rec.Params.Encode()
+	zw.ModTime = rec.FetchedAt
+
+	if _, err := zw.Write(rec.Body); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func readRecord(path string, fetchedAt int64) (Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Record{}, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	defer zr.Close()
+
+	params, err := url.ParseQuery(zr.Comment)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to parse the params of %v: %v", path, err)
+	}
+
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+
+	return Record{
+		Endpoint:  zr.Name,
+		Params:    params,
+		FetchedAt: time.UnixMilli(fetchedAt).UTC(),
+		Body:      body,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package rawlake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridfsStore stores the gzip compressed records in a gridfs bucket. The key
+// of the record is stored in the metadata of the file.
+type GridfsStore struct {
+	bucket *gridfs.Bucket
+}
+
+type gridfsMetadata struct {
+	Endpoint  string    `bson:"endpoint"`
+	Params    string    `bson:"params"` // Encoded query params
+	FetchedAt time.Time `bson:"fetched_at"`
+}
+
+type gridfsFile struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Metadata gridfsMetadata     `bson:"metadata"`
+}
+
+// NewGridfsStore returns a store which writes to the bucket of the db,
+// creating the index on the metadata of the files.
+func NewGridfsStore(db *mongo.Database, bucketName string) (*GridfsStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the gridfs bucket: %v", err)
+	}
+
+	index := mongo.IndexModel{Keys: bson.D{{Key: "metadata.endpoint", Value: 1}, {Key: "metadata.fetched_at", Value: 1}}}
+	if _, err := bucket.GetFilesCollection().Indexes().CreateOne(context.Background(), index); err != nil {
+		return nil, fmt.Errorf("failed to create the index of the %v bucket: %v", bucketName, err)
+	}
+
+	return &GridfsStore{bucket: bucket}, nil
+}
+
+func (s *GridfsStore) Put(rec Record) error {
+	data, err := compress(rec.Body)
+	if err != nil {
+		return fmt.Errorf("failed to compress the body: %v", err)
+	}
+
+	metadata := gridfsMetadata{Endpoint: rec.Endpoint, Params: rec.Params.Encode(), FetchedAt: rec.FetchedAt.UTC()}
+	name := fmt.Sprintf("%v_%d.gz", endpointDir(rec.Endpoint), rec.FetchedAt.UnixMilli())
+
+	_, err = s.bucket.UploadFromStream(name, bytes.NewReader(data), options.GridFSUpload().SetMetadata(metadata))
+	return err
+}
+
+func (s *GridfsStore) Walk(filter Filter, fn func(rec Record) error) error {
+	query := bson.M{}
+	if filter.Endpoint != "" {
+		query["metadata.endpoint"] = filter.Endpoint
+	}
+
+	timeFilter := bson.M{}
+	if !filter.From.IsZero() {
+		timeFilter["$gte"] = filter.From.UTC()
+	}
+	if !filter.To.IsZero() {
+		timeFilter["$lte"] = filter.To.UTC()
+	}
+	if len(timeFilter) > 0 {
+		query["metadata.fetched_at"] = timeFilter
+	}
+
+	ctx := context.Background()
+	cur, err := s.bucket.Find(query, options.GridFSFind().SetSort(bson.D{{Key: "metadata.fetched_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var file gridfsFile
+		if err := cur.Decode(&file); err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if _, err := s.bucket.DownloadToStream(file.ID, &buf); err != nil {
+			return fmt.Errorf("failed to download %v: %v", file.ID.Hex(), err)
+		}
+
+		body, err := decompress(&buf)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %v: %v", file.ID.Hex(), err)
+		}
+
+		params, err := url.ParseQuery(file.Metadata.Params)
+		if err != nil {
+			return fmt.Errorf("failed to parse the params of %v: %v", file.ID.Hex(), err)
+		}
+
+		rec := Record{
+			Endpoint:  file.Metadata.Endpoint,
+			Params:    params,
+			FetchedAt: file.Metadata.FetchedAt.UTC(),
+			Body:      body,
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
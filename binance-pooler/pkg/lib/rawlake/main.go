@@ -0,0 +1,67 @@
+// Package rawlake stores the raw response bodies of the scraped apis, so that
+// the parsed data can be rebuilt without requesting the apis again.
+package rawlake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Record is a response body, keyed by the endpoint and the query params of
+// the request and the time at which the response was received.
+type Record struct {
+	Endpoint  string     // Url of the request, without the query params
+	Params    url.Values // Query params of the request
+	FetchedAt time.Time
+	Body      []byte // Uncompressed body of the response
+}
+
+// Filter holds the optional parameters for reading the records. Zero values
+// are ignored.
+type Filter struct {
+	Endpoint string
+	From     time.Time // inclusive
+	To       time.Time // inclusive
+}
+
+func (f Filter) matches(endpoint string, fetchedAt time.Time) bool {
+	return (f.Endpoint == "" || endpoint == f.Endpoint) &&
+		(f.From.IsZero() || !fetchedAt.Before(f.From)) &&
+		(f.To.IsZero() || !fetchedAt.After(f.To))
+}
+
+// Store defines the methods for writing and reading the raw responses.
+type Store interface {
+	// Put stores the compressed body of the record
+	Put(rec Record) error
+	// Walk calls the function for each of the records which match the
+	// filter, ordered by the fetch time. Walk stops at the first error.
+	Walk(filter Filter, fn func(rec Record) error) error
+}
+
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(r io.Reader) ([]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
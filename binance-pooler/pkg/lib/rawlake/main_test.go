@@ -0,0 +1,53 @@
+package rawlake
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDirStore(t *testing.T) {
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	const klines = "https://api.binance.com/api/v3/klines"
+
+	records := []Record{
+		{Endpoint: klines, Params: url.Values{"symbol": {"ETHUSDT"}}, FetchedAt: t1.Add(time.Hour), Body: []byte(`[[2]]`)},
+		{Endpoint: klines, Params: url.Values{"symbol": {"BTCUSDT"}}, FetchedAt: t1, Body: []byte(`[[1]]`)},
+		{Endpoint: "https://api.binance.com/api/v3/exchangeInfo", FetchedAt: t1.AddDate(0, 0, 1), Body: []byte(`{}`)},
+	}
+
+	for _, rec := range records {
+		if err := store.Put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(filter Filter) []Record {
+		var out []Record
+		if err := store.Walk(filter, func(rec Record) error {
+			out = append(out, rec)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	all := walk(Filter{})
+	if len(all) != 3 || string(all[0].Body) != `[[1]]` || string(all[1].Body) != `[[2]]` {
+		t.Fatalf("expected the records ordered by the fetch time, got %+v", all)
+	}
+
+	if all[0].Endpoint != klines || all[0].Params.Get("symbol") != "BTCUSDT" || !all[0].FetchedAt.Equal(t1) {
+		t.Fatalf("unexpected key of the record: %v %v %v", all[0].Endpoint, all[0].Params, all[0].FetchedAt)
+	}
+
+	if got := walk(Filter{Endpoint: klines, From: t1.Add(time.Minute)}); len(got) != 1 || got[0].Params.Get("symbol") != "ETHUSDT" {
+		t.Fatalf("unexpected filtered records: %+v", got)
+	}
+}
@@ -0,0 +1,44 @@
+// Package sqlite contains functions related to connecting to an
+// embedded sqlite database, used for single node deployments.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// New opens (or creates) the sqlite database at the given path, with the
+// WAL journal mode enabled so that reads don't block the writes.
+func New(path string) (*sql.DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path for the sqlite database is empty")
+	}
+
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create dir for the sqlite database: %v", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%v?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)", path)
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer at a time, so a single connection avoids
+	// SQLITE_BUSY errors when the scrapers write in parallel.
+	conn.SetMaxOpenConns(1)
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping sqlite: %v", err)
+	}
+
+	return conn, nil
+}
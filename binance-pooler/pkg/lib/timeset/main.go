@@ -2,6 +2,8 @@ package timeset
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -59,3 +61,23 @@ func ChunkTimeRange(from, to time.Time, interval time.Duration, maxReqPeriods, o
 
 	return chunks, nil
 }
+
+// ParseInterval parses the interval strings used in the config files (e.g.
+// "1m", "15m", "4h", "1d"). On top of the time.ParseDuration units, the "d"
+// suffix is supported for days.
+func ParseInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid interval: %v", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid interval: %v", s)
+	}
+
+	return d, nil
+}
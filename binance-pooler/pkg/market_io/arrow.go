@@ -0,0 +1,156 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// ArrowSettings holds the optional settings for writing arrow streams.
+type ArrowSettings struct {
+	Columns   []string // Columns which are written, in order. Defaults to all columns.
+	BatchSize int      // Number of rows of a record batch. Defaults to 10000.
+}
+
+// types of the ohlc columns in the arrow schema
+var ohlcArrowTypes = map[string]arrow.DataType{
+	"source":            arrow.BinaryTypes.String,
+	"market":            arrow.BinaryTypes.String,
+	"symbol":            arrow.BinaryTypes.String,
+	"interval":          arrow.PrimitiveTypes.Int64,
+	"start_time":        arrow.FixedWidthTypes.Timestamp_ms,
+	"open":              arrow.PrimitiveTypes.Float64,
+	"high":              arrow.PrimitiveTypes.Float64,
+	"low":               arrow.PrimitiveTypes.Float64,
+	"close":             arrow.PrimitiveTypes.Float64,
+	"volume":            arrow.PrimitiveTypes.Float64,
+	"base_asset_volume": arrow.PrimitiveTypes.Float64,
+	"number_of_trades":  arrow.PrimitiveTypes.Int64,
+}
+
+// OhlcArrowWriter writes the ohlc rows in the arrow ipc stream format, which
+// can be read directly by pyarrow, pandas or polars. The rows are written in
+// record batches of the batch size.
+type OhlcArrowWriter struct {
+	w         *ipc.Writer
+	b         *array.RecordBuilder
+	cols      []string
+	batchSize int
+	n         int // number of rows in the current batch
+}
+
+// NewOhlcArrowWriter returns a writer of the arrow stream. The schema is
+// written with the first batch, or on Close if there are no rows.
+func NewOhlcArrowWriter(w io.Writer, settings ...ArrowSettings) (*OhlcArrowWriter, error) {
+	conf := ArrowSettings{}
+	if len(settings) == 1 {
+		conf = settings[0]
+	}
+
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 10000
+	}
+
+	cols, err := CsvSettings{Columns: conf.Columns}.columns(OhlcCsvColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]arrow.Field, len(cols))
+	for i, col := range cols {
+		fields[i] = arrow.Field{
+			Name:     col,
+			Type:     ohlcArrowTypes[col],
+			Nullable: col == "base_asset_volume" || col == "number_of_trades",
+		}
+	}
+
+	schema := arrow.NewSchema(fields, nil)
+	mem := memory.NewGoAllocator()
+
+	return &OhlcArrowWriter{
+		w:         ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem)),
+		b:         array.NewRecordBuilder(mem, schema),
+		cols:      cols,
+		batchSize: conf.BatchSize,
+	}, nil
+}
+
+func (w *OhlcArrowWriter) Write(row market_dto.OhlcRow) error {
+	for i, col := range w.cols {
+		switch b := w.b.Field(i).(type) {
+		case *array.StringBuilder:
+			b.Append(ohlcCsvValue(row, col, CsvSettings{}))
+		case *array.TimestampBuilder:
+			b.Append(arrow.Timestamp(row.StartTime.UnixMilli()))
+		case *array.Int64Builder:
+			switch {
+			case col == "interval":
+				b.Append(row.Interval)
+			case row.NumberOfTrades != nil:
+				b.Append(*row.NumberOfTrades)
+			default:
+				b.AppendNull()
+			}
+		case *array.Float64Builder:
+			if v, ok := ohlcFloat(row, col); ok {
+				b.Append(v)
+			} else {
+				b.AppendNull()
+			}
+		default:
+			return fmt.Errorf("unsupported arrow column: %v", col)
+		}
+	}
+
+	if w.n++; w.n == w.batchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func ohlcFloat(row market_dto.OhlcRow, col string) (float64, bool) {
+	switch col {
+	case "open":
+		return row.Open, true
+	case "high":
+		return row.High, true
+	case "low":
+		return row.Low, true
+	case "close":
+		return row.Close, true
+	case "volume":
+		return row.Volume, true
+	case "base_asset_volume":
+		if row.BaseAssetVolume != nil {
+			return *row.BaseAssetVolume, true
+		}
+	}
+	return 0, false
+}
+
+// flush writes the rows of the current batch.
+func (w *OhlcArrowWriter) flush() error {
+	rec := w.b.NewRecord()
+	defer rec.Release()
+
+	w.n = 0
+	return w.w.Write(rec)
+}
+
+// Close writes the remaining rows and the end of the stream.
+func (w *OhlcArrowWriter) Close() error {
+	defer w.b.Release()
+
+	if w.n > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	return w.w.Close()
+}
@@ -0,0 +1,96 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+func TestOhlcArrow(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w, err := NewOhlcArrowWriter(&buf, ArrowSettings{Columns: []string{"start_time", "close", "number_of_trades"}, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		start := t1.Add(time.Duration(i) * time.Minute)
+		row, err := market_dto.NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1, 2, 0.5, float64(i), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			row.SetNumberOfTrades(int64(i))
+		}
+
+		if err := w.Write(*row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	if fields := r.Schema().Fields(); len(fields) != 3 || fields[0].Name != "start_time" || !fields[2].Nullable {
+		t.Fatalf("unexpected schema: %v", r.Schema())
+	}
+
+	batches, i := 0, 0
+	for r.Next() {
+		rec := r.Record()
+		batches++
+
+		times := rec.Column(0).(*array.Timestamp)
+		closes := rec.Column(1).(*array.Float64)
+		trades := rec.Column(2).(*array.Int64)
+
+		for j := 0; j < int(rec.NumRows()); j, i = j+1, i+1 {
+			if times.Value(j) != arrow.Timestamp(t1.Add(time.Duration(i)*time.Minute).UnixMilli()) || closes.Value(j) != float64(i) {
+				t.Fatalf("unexpected row %v", i)
+			}
+
+			if trades.IsNull(j) != (i%2 == 1) {
+				t.Fatalf("unexpected number of trades of row %v", i)
+			}
+		}
+	}
+
+	if batches != 3 || i != 5 {
+		t.Fatalf("expected 5 rows in 3 batches, got %v in %v", i, batches)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewOhlcArrowWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := ipc.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Release()
+
+		if len(r.Schema().Fields()) != len(OhlcCsvColumns) || r.Next() {
+			t.Fatal("expected the schema without rows")
+		}
+	})
+}
@@ -0,0 +1,439 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/mongodb"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormatUnixMilli can be used as the CsvSettings.TimeFormat to write
+// and read the time values as unix milliseconds.
+const TimeFormatUnixMilli = "unix_ms"
+
+// CsvSettings holds the optional settings for reading and writing csv files.
+type CsvSettings struct {
+	TimeFormat string   // Layout of the time values. Defaults to time.RFC3339.
+	Columns    []string // Columns which are written, in order. Defaults to all columns.
+	Separator  rune     // Defaults to a comma.
+}
+
+func (s CsvSettings) timeFormat() string {
+	if s.TimeFormat == "" {
+		return time.RFC3339
+	}
+	return s.TimeFormat
+}
+
+func (s CsvSettings) formatTime(t time.Time) string {
+	if s.timeFormat() == TimeFormatUnixMilli {
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.UTC().Format(s.timeFormat())
+}
+
+func (s CsvSettings) parseTime(val string) (time.Time, error) {
+	if s.timeFormat() == TimeFormatUnixMilli {
+		ms, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Parse(s.timeFormat(), val)
+}
+
+func (s CsvSettings) columns(all []string) ([]string, error) {
+	if len(s.Columns) == 0 {
+		return all, nil
+	}
+
+	for _, col := range s.Columns {
+		if !slices.Contains(all, col) {
+			return nil, fmt.Errorf("unknown column: %v", col)
+		}
+	}
+	return s.Columns, nil
+}
+
+func (s CsvSettings) writer(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if s.Separator != 0 {
+		cw.Comma = s.Separator
+	}
+	return cw
+}
+
+func (s CsvSettings) reader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if s.Separator != 0 {
+		cr.Comma = s.Separator
+	}
+	return cr
+}
+
+// Columns of the ohlc csv files, which are also the columns of the ndjson
+// and arrow exports
+var OhlcCsvColumns = []string{
+	"source",
+	"market",
+	"symbol",
+	"interval",
+	"start_time",
+	"open",
+	"high",
+	"low",
+	"close",
+	"volume",
+	"base_asset_volume",
+	"number_of_trades",
+}
+
+// CheckOhlcColumns returns an error if any of the columns is not one of the
+// OhlcCsvColumns.
+func CheckOhlcColumns(cols []string) error {
+	_, err := CsvSettings{Columns: cols}.columns(OhlcCsvColumns)
+	return err
+}
+
+// columns which are required to create a new ohlc row on import
+var requiredOhlcCsvColumns = []string{"symbol", "interval", "start_time", "open", "high", "low", "close", "volume"}
+
+// WriteOhlcCsv writes the rows as csv, including the header.
+func WriteOhlcCsv(w io.Writer, rows []market_dto.OhlcRow, settings ...CsvSettings) error {
+	ow, err := NewOhlcCsvWriter(w, settings...)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := ow.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return ow.Close()
+}
+
+// OhlcCsvWriter writes the ohlc rows as csv, one at a time.
+type OhlcCsvWriter struct {
+	cw     *csv.Writer
+	conf   CsvSettings
+	cols   []string
+	record []string
+}
+
+// NewOhlcCsvWriter returns a writer of the csv rows and writes the header.
+func NewOhlcCsvWriter(w io.Writer, settings ...CsvSettings) (*OhlcCsvWriter, error) {
+	conf := csvSettings(settings)
+
+	cols, err := conf.columns(OhlcCsvColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := conf.writer(w)
+	if err := cw.Write(cols); err != nil {
+		return nil, err
+	}
+
+	return &OhlcCsvWriter{cw: cw, conf: conf, cols: cols, record: make([]string, len(cols))}, nil
+}
+
+func (w *OhlcCsvWriter) Write(row market_dto.OhlcRow) error {
+	for i, col := range w.cols {
+		w.record[i] = ohlcCsvValue(row, col, w.conf)
+	}
+	return w.cw.Write(w.record)
+}
+
+// Close flushes the buffered rows.
+func (w *OhlcCsvWriter) Close() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+func ohlcCsvValue(row market_dto.OhlcRow, col string, conf CsvSettings) string {
+	switch col {
+	case "source":
+		return row.Source
+	case "market":
+		return row.Market
+	case "symbol":
+		return row.Symbol
+	case "interval":
+		return strconv.FormatInt(row.Interval, 10)
+	case "start_time":
+		return conf.formatTime(row.StartTime)
+	case "open":
+		return formatFloat(row.Open)
+	case "high":
+		return formatFloat(row.High)
+	case "low":
+		return formatFloat(row.Low)
+	case "close":
+		return formatFloat(row.Close)
+	case "volume":
+		return formatFloat(row.Volume)
+	case "base_asset_volume":
+		if row.BaseAssetVolume != nil {
+			return formatFloat(*row.BaseAssetVolume)
+		}
+	case "number_of_trades":
+		if row.NumberOfTrades != nil {
+			return strconv.FormatInt(*row.NumberOfTrades, 10)
+		}
+	}
+	return ""
+}
+
+// ReadOhlcCsv parses the rows from a csv file with a header. The columns are
+// matched by the header names, so their order does not matter. Each row
+// is validated by market_dto.NewOhlcRow.
+func ReadOhlcCsv(r io.Reader, settings ...CsvSettings) ([]market_dto.OhlcRow, error) {
+	conf := csvSettings(settings)
+
+	records, header, err := readCsv(conf.reader(r), requiredOhlcCsvColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []market_dto.OhlcRow
+	for line, rec := range records {
+		row, err := parseOhlcCsvRecord(rec, header, conf)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line+2, err)
+		}
+		rows = append(rows, *row)
+	}
+
+	return rows, nil
+}
+
+func parseOhlcCsvRecord(rec []string, header map[string]int, conf CsvSettings) (*market_dto.OhlcRow, error) {
+	get := func(col string) string {
+		if idx, ok := header[col]; ok && idx < len(rec) {
+			return strings.TrimSpace(rec[idx])
+		}
+		return ""
+	}
+
+	startTime, err := conf.parseTime(get("start_time"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_time: %v", err)
+	}
+
+	interval, err := strconv.ParseInt(get("interval"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %v", err)
+	}
+
+	var prices [5]float64
+	for i, col := range []string{"open", "high", "low", "close", "volume"} {
+		if prices[i], err = strconv.ParseFloat(get(col), 64); err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", col, err)
+		}
+	}
+
+	endTime := startTime.Add(time.Duration(interval) * time.Millisecond)
+	row, err := market_dto.NewOhlcRow(get("symbol"), startTime, endTime, prices[0], prices[1], prices[2], prices[3], prices[4])
+	if err != nil {
+		return nil, err
+	}
+
+	// the rows without a market get the one of the store on upsert
+	row.SetMarket(get("source"), get("market"))
+
+	if val := get("base_asset_volume"); val != "" {
+		vol, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base_asset_volume: %v", err)
+		}
+		row.SetBaseAssetVolume(vol)
+	}
+
+	if val := get("number_of_trades"); val != "" {
+		num, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number_of_trades: %v", err)
+		}
+		row.SetNumberOfTrades(num)
+	}
+
+	return row, nil
+}
+
+// ImportOhlcCsv reads the rows from the csv file and upserts them into the store.
+func ImportOhlcCsv(r io.Reader, store market_dto.OhlcStore, settings ...CsvSettings) (*mongodb.UpsertLog, error) {
+	rows, err := ReadOhlcCsv(r, settings...)
+	if err != nil {
+		return nil, err
+	}
+	return store.UpsertOhlcRows(rows)
+}
+
+// Columns of the asset csv files. The order types are separated by a space.
+var AssetCsvColumns = []string{
+	"source",
+	"symbol",
+	"status",
+	"base_asset",
+	"quote_asset",
+	"order_types",
+	"updated_at",
+}
+
+// WriteAssetCsv writes the assets as csv, including the header.
+func WriteAssetCsv(w io.Writer, assets []market_dto.AssetBase, settings ...CsvSettings) error {
+	conf := csvSettings(settings)
+
+	cols, err := conf.columns(AssetCsvColumns)
+	if err != nil {
+		return err
+	}
+
+	cw := conf.writer(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+
+	record := make([]string, len(cols))
+	for _, asset := range assets {
+		for i, col := range cols {
+			record[i] = assetCsvValue(asset, col, conf)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func assetCsvValue(asset market_dto.AssetBase, col string, conf CsvSettings) string {
+	switch col {
+	case "source":
+		return asset.Source
+	case "symbol":
+		return asset.Symbol
+	case "status":
+		return asset.Status
+	case "base_asset":
+		return asset.BaseAsset
+	case "quote_asset":
+		return asset.QuoteAsset
+	case "order_types":
+		return strings.Join(asset.OrderTypes, " ")
+	case "updated_at":
+		if !asset.UpdatedAt.IsZero() {
+			return conf.formatTime(asset.UpdatedAt)
+		}
+	}
+	return ""
+}
+
+// ReadAssetCsv parses the assets from a csv file with a header.
+func ReadAssetCsv(r io.Reader, settings ...CsvSettings) ([]market_dto.AssetBase, error) {
+	conf := csvSettings(settings)
+
+	records, header, err := readCsv(conf.reader(r), []string{"source", "symbol"})
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []market_dto.AssetBase
+	for line, rec := range records {
+		get := func(col string) string {
+			if idx, ok := header[col]; ok && idx < len(rec) {
+				return strings.TrimSpace(rec[idx])
+			}
+			return ""
+		}
+
+		asset := market_dto.AssetBase{
+			Source:     get("source"),
+			Symbol:     get("symbol"),
+			Status:     get("status"),
+			BaseAsset:  get("base_asset"),
+			QuoteAsset: get("quote_asset"),
+			OrderTypes: strings.Fields(get("order_types")),
+		}
+
+		if asset.Source == "" || asset.Symbol == "" {
+			return nil, fmt.Errorf("line %d: symbol or source is empty", line+2)
+		}
+
+		if val := get("updated_at"); val != "" {
+			updatedAt, err := conf.parseTime(val)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid updated_at: %v", line+2, err)
+			}
+			asset.UpdatedAt = updatedAt.UTC()
+		}
+
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// ImportAssetCsv reads the assets from the csv file and upserts them into the
+// store. The asset specific data is not part of the csv, so existing data
+// of the stored assets is kept.
+func ImportAssetCsv(r io.Reader, store market_dto.AssetStore, settings ...CsvSettings) (*mongodb.UpsertLog, error) {
+	assets, err := ReadAssetCsv(r, settings...)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]market_dto.Asset[any], len(assets))
+	for i, asset := range assets {
+		data[i] = market_dto.Asset[any]{AssetBase: asset}
+	}
+
+	return store.UpsertAssets(data)
+}
+
+// readCsv reads all of the records of the csv file and returns them along with
+// a map of the header names to column indexes.
+func readCsv(r *csv.Reader, required []string) ([][]string, map[string]int, error) {
+	r.FieldsPerRecord = -1
+
+	headerRow, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("csv file is empty")
+		}
+		return nil, nil, err
+	}
+
+	header := make(map[string]int, len(headerRow))
+	for i, col := range headerRow {
+		header[strings.TrimSpace(col)] = i
+	}
+
+	for _, col := range required {
+		if _, ok := header[col]; !ok {
+			return nil, nil, fmt.Errorf("missing required column: %v", col)
+		}
+	}
+
+	records, err := r.ReadAll()
+	return records, header, err
+}
+
+func csvSettings(settings []CsvSettings) CsvSettings {
+	if len(settings) == 1 {
+		return settings[0]
+	}
+	return CsvSettings{}
+}
+
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
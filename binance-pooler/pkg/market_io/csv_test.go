@@ -0,0 +1,123 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOhlcCsv(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var rows []market_dto.OhlcRow
+	for i := 0; i < 3; i++ {
+		start := t1.Add(time.Duration(i) * time.Minute)
+		row, err := market_dto.NewOhlcRow("BTCUSDT", start, start.Add(time.Minute), 1.1, 2.25, 0.5, 1.75, 10.125)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.SetNumberOfTrades(int64(i))
+		row.SetMarket("binance", market_dto.MarketSpot)
+		rows = append(rows, *row)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, format := range []string{"", TimeFormatUnixMilli, "2006-01-02 15:04:05"} {
+			settings := CsvSettings{TimeFormat: format}
+
+			var buf bytes.Buffer
+			if err := WriteOhlcCsv(&buf, rows, settings); err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ReadOhlcCsv(&buf, settings)
+			if err != nil {
+				t.Fatalf("format %q: %v", format, err)
+			}
+
+			if len(parsed) != len(rows) {
+				t.Fatalf("expected %d rows, got %d", len(rows), len(parsed))
+			}
+
+			for i := range rows {
+				a, b := rows[i], parsed[i]
+				if !a.StartTime.Equal(b.StartTime) || a.Interval != b.Interval || a.OHLC != b.OHLC || a.Source != b.Source || a.Market != b.Market {
+					t.Fatalf("format %q: rows differ: %+v != %+v", format, a, b)
+				}
+
+				if b.NumberOfTrades == nil || *b.NumberOfTrades != *a.NumberOfTrades || b.BaseAssetVolume != nil {
+					t.Fatalf("format %q: optional fields differ", format)
+				}
+			}
+		}
+	})
+
+	t.Run("column selection", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteOhlcCsv(&buf, rows[:1], CsvSettings{Columns: []string{"start_time", "close"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := buf.String(); got != "start_time,close\n2024-01-01T00:00:00Z,1.75\n" {
+			t.Fatalf("unexpected csv: %q", got)
+		}
+
+		if err := WriteOhlcCsv(&buf, rows, CsvSettings{Columns: []string{"unknown"}}); err == nil {
+			t.Fatal("expected an error for an unknown column")
+		}
+	})
+
+	t.Run("import validates rows", func(t *testing.T) {
+		store := market_dto.NewMemoryOhlcStore("test_ohlc")
+
+		// the symbol of the second row is empty
+		csv := "symbol,interval,start_time,open,high,low,close,volume\n" +
+			"BTCUSDT,60000,2024-01-01T00:00:00Z,1,2,0.5,1.5,10\n" +
+			",60000,2024-01-01T00:01:00Z,1,2,0.5,1.5,10\n"
+
+		if _, err := ImportOhlcCsv(strings.NewReader(csv), store); err == nil {
+			t.Fatal("expected an error for a row without a symbol")
+		}
+
+		csv = "symbol,interval,start_time,open,high,low,close,volume\n" +
+			"BTCUSDT,60000,2024-01-01T00:00:00Z,1,2,0.5,1.5,10\n"
+
+		if _, err := ImportOhlcCsv(strings.NewReader(csv), store); err != nil {
+			t.Fatal(err)
+		}
+
+		docs, _ := store.GetOhlcRows(market_dto.OhlcFilter{Symbol: "BTCUSDT"})
+		if len(docs) != 1 || docs[0].Interval != 60000 {
+			t.Fatalf("unexpected imported rows: %+v", docs)
+		}
+	})
+}
+
+func TestAssetCsv(t *testing.T) {
+	store := market_dto.NewMemoryAssetStore("test_assets")
+
+	assets := []market_dto.AssetBase{
+		{Source: "binance", Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT", OrderTypes: []string{"LIMIT", "MARKET"}},
+		{Source: "binance", Symbol: "ETHUSDT", Status: "BREAK", BaseAsset: "ETH", QuoteAsset: "USDT"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAssetCsv(&buf, assets); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportAssetCsv(&buf, store); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := store.GetAssets(market_dto.AssetFilter{Source: "binance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 2 || docs[0].Symbol != "BTCUSDT" || len(docs[0].OrderTypes) != 2 || docs[1].Status != "BREAK" {
+		t.Fatalf("unexpected imported assets: %+v", docs)
+	}
+}
@@ -0,0 +1,90 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// OhlcNdjsonWriter writes the ohlc rows as newline delimited json objects,
+// with the keys in the order of the columns. The time values are strings,
+// or numbers if the time format is TimeFormatUnixMilli, and the missing
+// optional values are null. The separator of the settings is not used.
+type OhlcNdjsonWriter struct {
+	w    *bufio.Writer
+	conf CsvSettings
+	cols []string
+	keys [][]byte // quoted keys of the columns
+	buf  []byte
+}
+
+// NewOhlcNdjsonWriter returns a writer of the json lines.
+func NewOhlcNdjsonWriter(w io.Writer, settings ...CsvSettings) (*OhlcNdjsonWriter, error) {
+	conf := csvSettings(settings)
+
+	cols, err := conf.columns(OhlcCsvColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, len(cols))
+	for i, col := range cols {
+		keys[i] = appendJsonString(nil, col)
+	}
+
+	return &OhlcNdjsonWriter{w: bufio.NewWriter(w), conf: conf, cols: cols, keys: keys}, nil
+}
+
+func (w *OhlcNdjsonWriter) Write(row market_dto.OhlcRow) error {
+	b := append(w.buf[:0], '{')
+
+	for i, col := range w.cols {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, w.keys[i]...)
+		b = append(b, ':')
+		b = w.appendValue(b, row, col)
+	}
+
+	b = append(b, '}', '\n')
+	w.buf = b
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *OhlcNdjsonWriter) appendValue(b []byte, row market_dto.OhlcRow, col string) []byte {
+	switch col {
+	case "source", "market", "symbol":
+		return appendJsonString(b, ohlcCsvValue(row, col, w.conf))
+	case "start_time":
+		if w.conf.timeFormat() == TimeFormatUnixMilli {
+			return strconv.AppendInt(b, row.StartTime.UnixMilli(), 10)
+		}
+		return appendJsonString(b, w.conf.formatTime(row.StartTime))
+	case "base_asset_volume":
+		if row.BaseAssetVolume == nil {
+			return append(b, "null"...)
+		}
+	case "number_of_trades":
+		if row.NumberOfTrades == nil {
+			return append(b, "null"...)
+		}
+	}
+
+	// the remaining values are numbers
+	return append(b, ohlcCsvValue(row, col, w.conf)...)
+}
+
+// Close flushes the buffered rows.
+func (w *OhlcNdjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+func appendJsonString(b []byte, s string) []byte {
+	quoted, _ := json.Marshal(s)
+	return append(b, quoted...)
+}
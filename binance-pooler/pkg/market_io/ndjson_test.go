@@ -0,0 +1,76 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOhlcNdjson(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	row, err := market_dto.NewOhlcRow("BTCUSDT", t1, t1.Add(time.Minute), 1.1, 2.25, 0.5, 1.75, 10.125)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row.SetNumberOfTrades(7)
+
+	var buf bytes.Buffer
+	w, err := NewOhlcNdjsonWriter(&buf, CsvSettings{TimeFormat: TimeFormatUnixMilli})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := w.Write(*row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines++
+
+		var doc map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+
+		if doc["symbol"] != "BTCUSDT" || doc["start_time"] != float64(t1.UnixMilli()) || doc["high"] != 2.25 ||
+			doc["number_of_trades"] != float64(7) || doc["base_asset_volume"] != nil || len(doc) != len(OhlcCsvColumns) {
+			t.Fatalf("unexpected line: %v", scanner.Text())
+		}
+	}
+
+	if lines != 2 {
+		t.Fatalf("expected 2 lines, got %v", lines)
+	}
+
+	t.Run("columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewOhlcNdjsonWriter(&buf, CsvSettings{Columns: []string{"start_time", "close"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Write(*row); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+
+		if expected := `{"start_time":"2024-01-01T00:00:00Z","close":1.75}` + "\n"; buf.String() != expected {
+			t.Fatalf("expected %q, got %q", expected, buf.String())
+		}
+
+		if _, err := NewOhlcNdjsonWriter(&buf, CsvSettings{Columns: []string{"vwap"}}); err == nil {
+			t.Fatal("expected an error for an unknown column")
+		}
+	})
+}
@@ -0,0 +1,228 @@
+// Package market_io contains functions for exporting and importing
+// the stored market data to and from files.
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// ParquetOhlcRow is the layout of the ohlc rows in the parquet files.
+type ParquetOhlcRow struct {
+	Source          string    `parquet:"source"`
+	Market          string    `parquet:"market"`
+	Symbol          string    `parquet:"symbol"`
+	Interval        int64     `parquet:"interval"`
+	StartTime       time.Time `parquet:"start_time,timestamp(millisecond)"`
+	Open            float64   `parquet:"open"`
+	High            float64   `parquet:"high"`
+	Low             float64   `parquet:"low"`
+	Close           float64   `parquet:"close"`
+	Volume          float64   `parquet:"volume"`
+	BaseAssetVolume *float64  `parquet:"base_asset_volume,optional"`
+	NumberOfTrades  *int64    `parquet:"number_of_trades,optional"`
+}
+
+func newParquetOhlcRow(row market_dto.OhlcRow) ParquetOhlcRow {
+	return ParquetOhlcRow{
+		Source:          row.Source,
+		Market:          row.Market,
+		Symbol:          row.Symbol,
+		Interval:        row.Interval,
+		StartTime:       row.StartTime.UTC(),
+		Open:            row.Open,
+		High:            row.High,
+		Low:             row.Low,
+		Close:           row.Close,
+		Volume:          row.Volume,
+		BaseAssetVolume: row.BaseAssetVolume,
+		NumberOfTrades:  row.NumberOfTrades,
+	}
+}
+
+// ParquetExportParams defines which rows are exported and where the files are written.
+type ParquetExportParams struct {
+	Dir      string    // Root directory of the partitioned dataset
+	Symbol   string    // Symbol of the exported rows (e.g. BTCUSDT)
+	Interval int64     // Interval of the exported rows in milliseconds
+	From     time.Time // Start of the exported period. The month of the date is exported in full.
+	To       time.Time // End (inclusive) of the exported period. Defaults to the current time.
+}
+
+// ParquetExportLog holds information about the written and skipped partitions.
+type ParquetExportLog struct {
+	Written []string `json:"written"`
+	Skipped []string `json:"skipped"`
+	NumRows int      `json:"num_rows"`
+}
+
+// metadata key which marks if the partition holds the data for the whole month
+const completeMonthKey = "complete_month"
+
+// ExportOhlcToParquet writes the ohlc rows of the symbol and interval into parquet
+// files, partitioned by symbol, interval and month in the hive layout, e.g.
+//
+//	<dir>/symbol=BTCUSDT/interval=60000/month=2024-01/data.parquet
+//
+// which can be read directly by pandas, polars or DuckDB. The export is incremental:
+// partitions of months which were complete at the time of the previous export are
+// skipped, so only the new (and the previously incomplete) months are written. A
+// month is complete once it ended and the stored rows of the interval reach its
+// end (or it holds all of the rows of the month).
+func ExportOhlcToParquet(store market_dto.OhlcStore, params ParquetExportParams) (*ParquetExportLog, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+
+	if params.Dir == "" || params.Symbol == "" || params.Interval <= 0 {
+		return nil, fmt.Errorf("dir, symbol and interval are required")
+	}
+
+	if params.From.IsZero() {
+		return nil, fmt.Errorf("from time is required")
+	}
+
+	to := params.To
+	if to.IsZero() || to.After(time.Now()) {
+		to = time.Now()
+	}
+
+	if params.From.After(to) {
+		return nil, fmt.Errorf("from date is after to date")
+	}
+
+	coverage, err := store.GetCoverage(params.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the coverage of %v: %v", params.Symbol, err)
+	}
+
+	// start time of the latest stored row of the interval
+	var storedTo time.Time
+	for _, c := range coverage {
+		if c.Interval == params.Interval {
+			storedTo = c.To
+		}
+	}
+
+	interval := time.Duration(params.Interval) * time.Millisecond
+	log := &ParquetExportLog{}
+
+	for month := startOfMonth(params.From); !month.After(to); month = month.AddDate(0, 1, 0) {
+		nextMonth := month.AddDate(0, 1, 0)
+		path := ParquetPartitionPath(params.Dir, params.Symbol, params.Interval, month)
+
+		complete, err := isCompleteParquetPartition(path)
+		if err != nil {
+			return log, err
+		}
+
+		if complete {
+			log.Skipped = append(log.Skipped, path)
+			continue
+		}
+
+		monthEnd := nextMonth.Add(-time.Millisecond)
+		if monthEnd.After(to) {
+			monthEnd = to
+		}
+
+		rows, err := store.GetOhlcRows(market_dto.OhlcFilter{
+			Symbol:   params.Symbol,
+			Interval: params.Interval,
+			From:     month,
+			To:       monthEnd,
+		})
+		if err != nil {
+			return log, fmt.Errorf("failed to query rows for %v: %v", month.Format("2006-01"), err)
+		}
+
+		if len(rows) == 0 {
+			continue
+		}
+
+		// the month is complete only if it ended before the end of the exported
+		// period and the scraped rows are not behind the end of the month
+		monthEnded := !nextMonth.Add(-time.Millisecond).After(to)
+		expected := int(nextMonth.Sub(month) / interval)
+		complete = monthEnded && (len(rows) == expected || !storedTo.Before(nextMonth.Add(-interval)))
+
+		if err := writeParquetPartition(path, rows, complete); err != nil {
+			return log, err
+		}
+
+		log.Written = append(log.Written, path)
+		log.NumRows += len(rows)
+	}
+
+	return log, nil
+}
+
+// ParquetPartitionPath returns the path of the file which holds the rows of the month.
+func ParquetPartitionPath(dir, symbol string, interval int64, month time.Time) string {
+	return filepath.Join(dir,
+		"symbol="+symbol,
+		"interval="+strconv.FormatInt(interval, 10),
+		"month="+month.UTC().Format("2006-01"),
+		"data.parquet")
+}
+
+func writeParquetPartition(path string, rows []market_dto.OhlcRow, complete bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data := make([]ParquetOhlcRow, len(rows))
+	for i, row := range rows {
+		data[i] = newParquetOhlcRow(row)
+	}
+
+	// write to a temp file first, so that a failed export does not leave a corrupt partition
+	tmp := path + ".tmp"
+	if err := parquet.WriteFile(tmp, data,
+		parquet.Compression(&zstd.Codec{}),
+		parquet.KeyValueMetadata(completeMonthKey, strconv.FormatBool(complete)),
+	); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %v: %v", path, err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// isCompleteParquetPartition returns true if the file exists and was
+// marked as holding the data of the whole month.
+func isCompleteParquetPartition(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		return false, fmt.Errorf("failed to open %v: %v", path, err)
+	}
+
+	complete, _ := pf.Lookup(completeMonthKey)
+	return complete == "true", nil
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
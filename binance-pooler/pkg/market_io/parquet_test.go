@@ -0,0 +1,110 @@
+package market_io
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestExportOhlcToParquet(t *testing.T) {
+	store := market_dto.NewMemoryOhlcStore("test_ohlc").WithMarket("binance", market_dto.MarketSpot)
+	dir := t.TempDir()
+
+	// one row per day in january and february
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows []market_dto.OhlcRow
+	for day := 0; day < 60; day++ {
+		start := t1.AddDate(0, 0, day)
+		row, err := market_dto.NewOhlcRow("BTCUSDT", start, start.Add(time.Hour), 1, 2, 0.5, 1.5, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, *row)
+	}
+
+	if _, err := store.UpsertOhlcRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	params := ParquetExportParams{
+		Dir:      dir,
+		Symbol:   "BTCUSDT",
+		Interval: time.Hour.Milliseconds(),
+		From:     t1,
+		To:       time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	log, err := ExportOhlcToParquet(store, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.Written) != 2 || len(log.Skipped) != 0 {
+		t.Fatalf("expected 2 written partitions, got %+v", log)
+	}
+
+	jan, err := parquet.ReadFile[ParquetOhlcRow](ParquetPartitionPath(dir, "BTCUSDT", params.Interval, t1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jan) != 31 || !jan[0].StartTime.Equal(t1) || jan[0].Close != 1.5 || jan[0].Source != "binance" {
+		t.Fatalf("unexpected january partition: %d rows, first %+v", len(jan), jan[0])
+	}
+
+	if log.NumRows != 31+15 {
+		t.Fatalf("expected rows up to the end of the period to be exported, got %d", log.NumRows)
+	}
+
+	// the second run should skip january, which was complete, and rewrite
+	// february up to the end of its last day, as with the -to date of cmd/export
+	params.To = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	log, err = ExportOhlcToParquet(store, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.Skipped) != 1 || len(log.Written) != 1 {
+		t.Fatalf("expected january to be skipped and february written, got %+v", log)
+	}
+
+	feb, err := parquet.ReadFile[ParquetOhlcRow](log.Written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feb) != 29 {
+		t.Fatalf("expected 29 rows in february, got %d", len(feb))
+	}
+
+	// february ended, but the stored rows don't reach its end yet, so it's
+	// exported again once they do
+	if log, err = ExportOhlcToParquet(store, params); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.Skipped) != 1 || len(log.Written) != 1 {
+		t.Fatalf("expected february to be written again, got %+v", log)
+	}
+
+	last, err := market_dto.NewOhlcRow("BTCUSDT", time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 1, 2, 0.5, 1.5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.UpsertOhlcRows([]market_dto.OhlcRow{*last}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, skipped := range []int{1, 2} {
+		if log, err = ExportOhlcToParquet(store, params); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(log.Skipped) != skipped {
+			t.Fatalf("expected %v skipped partitions, got %+v", skipped, log)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package market_io
+
+import "binance-pooler/pkg/dto/market_dto"
+
+// OhlcWriter writes the ohlc rows one at a time, so that large exports are
+// not held in memory. Close writes the buffered rows, but does not close
+// the underlying writer.
+type OhlcWriter interface {
+	Write(row market_dto.OhlcRow) error
+	Close() error
+}
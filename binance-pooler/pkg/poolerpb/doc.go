@@ -0,0 +1,6 @@
+// Package poolerpb holds the protobuf messages and the grpc service of the
+// query api, generated from pooler.proto. The python clients are generated
+// from the same file.
+package poolerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pooler.proto
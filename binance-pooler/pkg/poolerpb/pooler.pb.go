@@ -0,0 +1,886 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: pooler.proto
+
+package poolerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListAssetsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Market        string                 `protobuf:"bytes,1,opt,name=market,proto3" json:"market,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Base          string                 `protobuf:"bytes,3,opt,name=base,proto3" json:"base,omitempty"`
+	Quote         string                 `protobuf:"bytes,4,opt,name=quote,proto3" json:"quote,omitempty"`
+	ContractType  string                 `protobuf:"bytes,5,opt,name=contract_type,json=contractType,proto3" json:"contract_type,omitempty"`
+	OrderTypes    []string               `protobuf:"bytes,6,rep,name=order_types,json=orderTypes,proto3" json:"order_types,omitempty"` // Assets which support all of the order types
+	Q             string                 `protobuf:"bytes,7,opt,name=q,proto3" json:"q,omitempty"`                                     // Case insensitive part of the symbol
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAssetsRequest) Reset() {
+	*x = ListAssetsRequest{}
+	mi := &file_pooler_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAssetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAssetsRequest) ProtoMessage() {}
+
+func (x *ListAssetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAssetsRequest.ProtoReflect.Descriptor instead.
+func (*ListAssetsRequest) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListAssetsRequest) GetMarket() string {
+	if x != nil {
+		return x.Market
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetBase() string {
+	if x != nil {
+		return x.Base
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetQuote() string {
+	if x != nil {
+		return x.Quote
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetContractType() string {
+	if x != nil {
+		return x.ContractType
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetOrderTypes() []string {
+	if x != nil {
+		return x.OrderTypes
+	}
+	return nil
+}
+
+func (x *ListAssetsRequest) GetQ() string {
+	if x != nil {
+		return x.Q
+	}
+	return ""
+}
+
+type ListAssetsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Assets        []*Asset               `protobuf:"bytes,1,rep,name=assets,proto3" json:"assets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAssetsResponse) Reset() {
+	*x = ListAssetsResponse{}
+	mi := &file_pooler_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAssetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAssetsResponse) ProtoMessage() {}
+
+func (x *ListAssetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAssetsResponse.ProtoReflect.Descriptor instead.
+func (*ListAssetsResponse) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListAssetsResponse) GetAssets() []*Asset {
+	if x != nil {
+		return x.Assets
+	}
+	return nil
+}
+
+type Asset struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Symbol        string                 `protobuf:"bytes,3,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	BaseAsset     string                 `protobuf:"bytes,5,opt,name=base_asset,json=baseAsset,proto3" json:"base_asset,omitempty"`
+	QuoteAsset    string                 `protobuf:"bytes,6,opt,name=quote_asset,json=quoteAsset,proto3" json:"quote_asset,omitempty"`
+	OrderTypes    []string               `protobuf:"bytes,7,rep,name=order_types,json=orderTypes,proto3" json:"order_types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Asset) Reset() {
+	*x = Asset{}
+	mi := &file_pooler_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Asset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Asset) ProtoMessage() {}
+
+func (x *Asset) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Asset.ProtoReflect.Descriptor instead.
+func (*Asset) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Asset) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Asset) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Asset) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Asset) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Asset) GetBaseAsset() string {
+	if x != nil {
+		return x.BaseAsset
+	}
+	return ""
+}
+
+func (x *Asset) GetQuoteAsset() string {
+	if x != nil {
+		return x.QuoteAsset
+	}
+	return ""
+}
+
+func (x *Asset) GetOrderTypes() []string {
+	if x != nil {
+		return x.OrderTypes
+	}
+	return nil
+}
+
+type GetCandlesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Market        string                 `protobuf:"bytes,1,opt,name=market,proto3" json:"market,omitempty"`
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Interval      string                 `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`        // Inclusive
+	To            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`            // Inclusive
+	Limit         int64                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`     // Max number of candles, 0 streams the whole range
+	Partial       bool                   `protobuf:"varint,7,opt,name=partial,proto3" json:"partial,omitempty"` // Include the incomplete trailing bucket of the resampled intervals
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCandlesRequest) Reset() {
+	*x = GetCandlesRequest{}
+	mi := &file_pooler_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCandlesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCandlesRequest) ProtoMessage() {}
+
+func (x *GetCandlesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCandlesRequest.ProtoReflect.Descriptor instead.
+func (*GetCandlesRequest) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetCandlesRequest) GetMarket() string {
+	if x != nil {
+		return x.Market
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetCandlesRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetCandlesRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetCandlesRequest) GetPartial() bool {
+	if x != nil {
+		return x.Partial
+	}
+	return false
+}
+
+type SubscribeCandlesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Market        string                 `protobuf:"bytes,1,opt,name=market,proto3" json:"market,omitempty"`
+	Symbols       []string               `protobuf:"bytes,2,rep,name=symbols,proto3" json:"symbols,omitempty"`   // All of the symbols if empty
+	Interval      string                 `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"` // All of the intervals if empty
+	Since         *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeCandlesRequest) Reset() {
+	*x = SubscribeCandlesRequest{}
+	mi := &file_pooler_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeCandlesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeCandlesRequest) ProtoMessage() {}
+
+func (x *SubscribeCandlesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeCandlesRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeCandlesRequest) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SubscribeCandlesRequest) GetMarket() string {
+	if x != nil {
+		return x.Market
+	}
+	return ""
+}
+
+func (x *SubscribeCandlesRequest) GetSymbols() []string {
+	if x != nil {
+		return x.Symbols
+	}
+	return nil
+}
+
+func (x *SubscribeCandlesRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *SubscribeCandlesRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+type Candle struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Source          string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Market          string                 `protobuf:"bytes,2,opt,name=market,proto3" json:"market,omitempty"`
+	Symbol          string                 `protobuf:"bytes,3,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Interval        int64                  `protobuf:"varint,4,opt,name=interval,proto3" json:"interval,omitempty"` // Interval in milliseconds
+	StartTime       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	Open            float64                `protobuf:"fixed64,6,opt,name=open,proto3" json:"open,omitempty"`
+	High            float64                `protobuf:"fixed64,7,opt,name=high,proto3" json:"high,omitempty"`
+	Low             float64                `protobuf:"fixed64,8,opt,name=low,proto3" json:"low,omitempty"`
+	Close           float64                `protobuf:"fixed64,9,opt,name=close,proto3" json:"close,omitempty"`
+	Volume          float64                `protobuf:"fixed64,10,opt,name=volume,proto3" json:"volume,omitempty"`
+	BaseAssetVolume *float64               `protobuf:"fixed64,11,opt,name=base_asset_volume,json=baseAssetVolume,proto3,oneof" json:"base_asset_volume,omitempty"`
+	NumberOfTrades  *int64                 `protobuf:"varint,12,opt,name=number_of_trades,json=numberOfTrades,proto3,oneof" json:"number_of_trades,omitempty"`
+	Revision        int64                  `protobuf:"varint,13,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Candle) Reset() {
+	*x = Candle{}
+	mi := &file_pooler_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Candle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Candle) ProtoMessage() {}
+
+func (x *Candle) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Candle.ProtoReflect.Descriptor instead.
+func (*Candle) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Candle) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Candle) GetMarket() string {
+	if x != nil {
+		return x.Market
+	}
+	return ""
+}
+
+func (x *Candle) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Candle) GetInterval() int64 {
+	if x != nil {
+		return x.Interval
+	}
+	return 0
+}
+
+func (x *Candle) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *Candle) GetOpen() float64 {
+	if x != nil {
+		return x.Open
+	}
+	return 0
+}
+
+func (x *Candle) GetHigh() float64 {
+	if x != nil {
+		return x.High
+	}
+	return 0
+}
+
+func (x *Candle) GetLow() float64 {
+	if x != nil {
+		return x.Low
+	}
+	return 0
+}
+
+func (x *Candle) GetClose() float64 {
+	if x != nil {
+		return x.Close
+	}
+	return 0
+}
+
+func (x *Candle) GetVolume() float64 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Candle) GetBaseAssetVolume() float64 {
+	if x != nil && x.BaseAssetVolume != nil {
+		return *x.BaseAssetVolume
+	}
+	return 0
+}
+
+func (x *Candle) GetNumberOfTrades() int64 {
+	if x != nil && x.NumberOfTrades != nil {
+		return *x.NumberOfTrades
+	}
+	return 0
+}
+
+func (x *Candle) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type GetCoverageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Market        string                 `protobuf:"bytes,1,opt,name=market,proto3" json:"market,omitempty"`
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCoverageRequest) Reset() {
+	*x = GetCoverageRequest{}
+	mi := &file_pooler_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCoverageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCoverageRequest) ProtoMessage() {}
+
+func (x *GetCoverageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCoverageRequest.ProtoReflect.Descriptor instead.
+func (*GetCoverageRequest) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetCoverageRequest) GetMarket() string {
+	if x != nil {
+		return x.Market
+	}
+	return ""
+}
+
+func (x *GetCoverageRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+type GetCoverageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Coverage      []*Coverage            `protobuf:"bytes,1,rep,name=coverage,proto3" json:"coverage,omitempty"`
+	Latest        *Candle                `protobuf:"bytes,2,opt,name=latest,proto3" json:"latest,omitempty"` // Latest candle of the finest scraped interval, if any
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCoverageResponse) Reset() {
+	*x = GetCoverageResponse{}
+	mi := &file_pooler_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCoverageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCoverageResponse) ProtoMessage() {}
+
+func (x *GetCoverageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCoverageResponse.ProtoReflect.Descriptor instead.
+func (*GetCoverageResponse) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetCoverageResponse) GetCoverage() []*Coverage {
+	if x != nil {
+		return x.Coverage
+	}
+	return nil
+}
+
+func (x *GetCoverageResponse) GetLatest() *Candle {
+	if x != nil {
+		return x.Latest
+	}
+	return nil
+}
+
+type Coverage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Interval      int64                  `protobuf:"varint,1,opt,name=interval,proto3" json:"interval,omitempty"` // Interval in milliseconds
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Count         int64                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	Derived       bool                   `protobuf:"varint,5,opt,name=derived,proto3" json:"derived,omitempty"` // Aggregated from the scraped candles
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Coverage) Reset() {
+	*x = Coverage{}
+	mi := &file_pooler_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Coverage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coverage) ProtoMessage() {}
+
+func (x *Coverage) ProtoReflect() protoreflect.Message {
+	mi := &file_pooler_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coverage.ProtoReflect.Descriptor instead.
+func (*Coverage) Descriptor() ([]byte, []int) {
+	return file_pooler_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Coverage) GetInterval() int64 {
+	if x != nil {
+		return x.Interval
+	}
+	return 0
+}
+
+func (x *Coverage) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *Coverage) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *Coverage) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Coverage) GetDerived() bool {
+	if x != nil {
+		return x.Derived
+	}
+	return false
+}
+
+var File_pooler_proto protoreflect.FileDescriptor
+
+const file_pooler_proto_rawDesc = "" +
+	"\n" +
+	"\fpooler.proto\x12\tpooler.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc1\x01\n" +
+	"\x11ListAssetsRequest\x12\x16\n" +
+	"\x06market\x18\x01 \x01(\tR\x06market\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x12\n" +
+	"\x04base\x18\x03 \x01(\tR\x04base\x12\x14\n" +
+	"\x05quote\x18\x04 \x01(\tR\x05quote\x12#\n" +
+	"\rcontract_type\x18\x05 \x01(\tR\fcontractType\x12\x1f\n" +
+	"\vorder_types\x18\x06 \x03(\tR\n" +
+	"orderTypes\x12\f\n" +
+	"\x01q\x18\a \x01(\tR\x01q\">\n" +
+	"\x12ListAssetsResponse\x12(\n" +
+	"\x06assets\x18\x01 \x03(\v2\x10.pooler.v1.AssetR\x06assets\"\xeb\x01\n" +
+	"\x05Asset\x129\n" +
+	"\n" +
+	"updated_at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x16\n" +
+	"\x06symbol\x18\x03 \x01(\tR\x06symbol\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"base_asset\x18\x05 \x01(\tR\tbaseAsset\x12\x1f\n" +
+	"\vquote_asset\x18\x06 \x01(\tR\n" +
+	"quoteAsset\x12\x1f\n" +
+	"\vorder_types\x18\a \x03(\tR\n" +
+	"orderTypes\"\xeb\x01\n" +
+	"\x11GetCandlesRequest\x12\x16\n" +
+	"\x06market\x18\x01 \x01(\tR\x06market\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12\x1a\n" +
+	"\binterval\x18\x03 \x01(\tR\binterval\x12.\n" +
+	"\x04from\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x03R\x05limit\x12\x18\n" +
+	"\apartial\x18\a \x01(\bR\apartial\"\x99\x01\n" +
+	"\x17SubscribeCandlesRequest\x12\x16\n" +
+	"\x06market\x18\x01 \x01(\tR\x06market\x12\x18\n" +
+	"\asymbols\x18\x02 \x03(\tR\asymbols\x12\x1a\n" +
+	"\binterval\x18\x03 \x01(\tR\binterval\x120\n" +
+	"\x05since\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\"\xb6\x03\n" +
+	"\x06Candle\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x16\n" +
+	"\x06market\x18\x02 \x01(\tR\x06market\x12\x16\n" +
+	"\x06symbol\x18\x03 \x01(\tR\x06symbol\x12\x1a\n" +
+	"\binterval\x18\x04 \x01(\x03R\binterval\x129\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x12\x12\n" +
+	"\x04open\x18\x06 \x01(\x01R\x04open\x12\x12\n" +
+	"\x04high\x18\a \x01(\x01R\x04high\x12\x10\n" +
+	"\x03low\x18\b \x01(\x01R\x03low\x12\x14\n" +
+	"\x05close\x18\t \x01(\x01R\x05close\x12\x16\n" +
+	"\x06volume\x18\n" +
+	" \x01(\x01R\x06volume\x12/\n" +
+	"\x11base_asset_volume\x18\v \x01(\x01H\x00R\x0fbaseAssetVolume\x88\x01\x01\x12-\n" +
+	"\x10number_of_trades\x18\f \x01(\x03H\x01R\x0enumberOfTrades\x88\x01\x01\x12\x1a\n" +
+	"\brevision\x18\r \x01(\x03R\brevisionB\x14\n" +
+	"\x12_base_asset_volumeB\x13\n" +
+	"\x11_number_of_trades\"D\n" +
+	"\x12GetCoverageRequest\x12\x16\n" +
+	"\x06market\x18\x01 \x01(\tR\x06market\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\"q\n" +
+	"\x13GetCoverageResponse\x12/\n" +
+	"\bcoverage\x18\x01 \x03(\v2\x13.pooler.v1.CoverageR\bcoverage\x12)\n" +
+	"\x06latest\x18\x02 \x01(\v2\x11.pooler.v1.CandleR\x06latest\"\xb2\x01\n" +
+	"\bCoverage\x12\x1a\n" +
+	"\binterval\x18\x01 \x01(\x03R\binterval\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x03R\x05count\x12\x18\n" +
+	"\aderived\x18\x05 \x01(\bR\aderived2\xaf\x02\n" +
+	"\x06Pooler\x12I\n" +
+	"\n" +
+	"ListAssets\x12\x1c.pooler.v1.ListAssetsRequest\x1a\x1d.pooler.v1.ListAssetsResponse\x12?\n" +
+	"\n" +
+	"GetCandles\x12\x1c.pooler.v1.GetCandlesRequest\x1a\x11.pooler.v1.Candle0\x01\x12K\n" +
+	"\x10SubscribeCandles\x12\".pooler.v1.SubscribeCandlesRequest\x1a\x11.pooler.v1.Candle0\x01\x12L\n" +
+	"\vGetCoverage\x12\x1d.pooler.v1.GetCoverageRequest\x1a\x1e.pooler.v1.GetCoverageResponseB\x1dZ\x1bbinance-pooler/pkg/poolerpbb\x06proto3"
+
+var (
+	file_pooler_proto_rawDescOnce sync.Once
+	file_pooler_proto_rawDescData []byte
+)
+
+func file_pooler_proto_rawDescGZIP() []byte {
+	file_pooler_proto_rawDescOnce.Do(func() {
+		file_pooler_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pooler_proto_rawDesc), len(file_pooler_proto_rawDesc)))
+	})
+	return file_pooler_proto_rawDescData
+}
+
+var file_pooler_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_pooler_proto_goTypes = []any{
+	(*ListAssetsRequest)(nil),       // 0: pooler.v1.ListAssetsRequest
+	(*ListAssetsResponse)(nil),      // 1: pooler.v1.ListAssetsResponse
+	(*Asset)(nil),                   // 2: pooler.v1.Asset
+	(*GetCandlesRequest)(nil),       // 3: pooler.v1.GetCandlesRequest
+	(*SubscribeCandlesRequest)(nil), // 4: pooler.v1.SubscribeCandlesRequest
+	(*Candle)(nil),                  // 5: pooler.v1.Candle
+	(*GetCoverageRequest)(nil),      // 6: pooler.v1.GetCoverageRequest
+	(*GetCoverageResponse)(nil),     // 7: pooler.v1.GetCoverageResponse
+	(*Coverage)(nil),                // 8: pooler.v1.Coverage
+	(*timestamppb.Timestamp)(nil),   // 9: google.protobuf.Timestamp
+}
+var file_pooler_proto_depIdxs = []int32{
+	2,  // 0: pooler.v1.ListAssetsResponse.assets:type_name -> pooler.v1.Asset
+	9,  // 1: pooler.v1.Asset.updated_at:type_name -> google.protobuf.Timestamp
+	9,  // 2: pooler.v1.GetCandlesRequest.from:type_name -> google.protobuf.Timestamp
+	9,  // 3: pooler.v1.GetCandlesRequest.to:type_name -> google.protobuf.Timestamp
+	9,  // 4: pooler.v1.SubscribeCandlesRequest.since:type_name -> google.protobuf.Timestamp
+	9,  // 5: pooler.v1.Candle.start_time:type_name -> google.protobuf.Timestamp
+	8,  // 6: pooler.v1.GetCoverageResponse.coverage:type_name -> pooler.v1.Coverage
+	5,  // 7: pooler.v1.GetCoverageResponse.latest:type_name -> pooler.v1.Candle
+	9,  // 8: pooler.v1.Coverage.from:type_name -> google.protobuf.Timestamp
+	9,  // 9: pooler.v1.Coverage.to:type_name -> google.protobuf.Timestamp
+	0,  // 10: pooler.v1.Pooler.ListAssets:input_type -> pooler.v1.ListAssetsRequest
+	3,  // 11: pooler.v1.Pooler.GetCandles:input_type -> pooler.v1.GetCandlesRequest
+	4,  // 12: pooler.v1.Pooler.SubscribeCandles:input_type -> pooler.v1.SubscribeCandlesRequest
+	6,  // 13: pooler.v1.Pooler.GetCoverage:input_type -> pooler.v1.GetCoverageRequest
+	1,  // 14: pooler.v1.Pooler.ListAssets:output_type -> pooler.v1.ListAssetsResponse
+	5,  // 15: pooler.v1.Pooler.GetCandles:output_type -> pooler.v1.Candle
+	5,  // 16: pooler.v1.Pooler.SubscribeCandles:output_type -> pooler.v1.Candle
+	7,  // 17: pooler.v1.Pooler.GetCoverage:output_type -> pooler.v1.GetCoverageResponse
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_pooler_proto_init() }
+func file_pooler_proto_init() {
+	if File_pooler_proto != nil {
+		return
+	}
+	file_pooler_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pooler_proto_rawDesc), len(file_pooler_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pooler_proto_goTypes,
+		DependencyIndexes: file_pooler_proto_depIdxs,
+		MessageInfos:      file_pooler_proto_msgTypes,
+	}.Build()
+	File_pooler_proto = out.File
+	file_pooler_proto_goTypes = nil
+	file_pooler_proto_depIdxs = nil
+}
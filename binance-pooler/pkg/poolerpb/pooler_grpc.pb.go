@@ -0,0 +1,260 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: pooler.proto
+
+package poolerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pooler_ListAssets_FullMethodName       = "/pooler.v1.Pooler/ListAssets"
+	Pooler_GetCandles_FullMethodName       = "/pooler.v1.Pooler/GetCandles"
+	Pooler_SubscribeCandles_FullMethodName = "/pooler.v1.Pooler/SubscribeCandles"
+	Pooler_GetCoverage_FullMethodName      = "/pooler.v1.Pooler/GetCoverage"
+)
+
+// PoolerClient is the client API for Pooler service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Pooler is the query api of the pooler, which mirrors the assets, ohlc and
+// live endpoints of the http api.
+type PoolerClient interface {
+	// Assets of the market which match the filters.
+	ListAssets(ctx context.Context, in *ListAssetsRequest, opts ...grpc.CallOption) (*ListAssetsResponse, error)
+	// Stored or resampled candles of the symbol, streamed in pages so that
+	// large ranges are not held in memory.
+	GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Candle], error)
+	// Candles which are written by the pooler, optionally preceded by the
+	// stored candles which start at or after the since time.
+	SubscribeCandles(ctx context.Context, in *SubscribeCandlesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Candle], error)
+	// Stored time range of each interval of the symbol and its latest candle.
+	GetCoverage(ctx context.Context, in *GetCoverageRequest, opts ...grpc.CallOption) (*GetCoverageResponse, error)
+}
+
+type poolerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPoolerClient(cc grpc.ClientConnInterface) PoolerClient {
+	return &poolerClient{cc}
+}
+
+func (c *poolerClient) ListAssets(ctx context.Context, in *ListAssetsRequest, opts ...grpc.CallOption) (*ListAssetsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAssetsResponse)
+	err := c.cc.Invoke(ctx, Pooler_ListAssets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *poolerClient) GetCandles(ctx context.Context, in *GetCandlesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Candle], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pooler_ServiceDesc.Streams[0], Pooler_GetCandles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetCandlesRequest, Candle]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pooler_GetCandlesClient = grpc.ServerStreamingClient[Candle]
+
+func (c *poolerClient) SubscribeCandles(ctx context.Context, in *SubscribeCandlesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Candle], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pooler_ServiceDesc.Streams[1], Pooler_SubscribeCandles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeCandlesRequest, Candle]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pooler_SubscribeCandlesClient = grpc.ServerStreamingClient[Candle]
+
+func (c *poolerClient) GetCoverage(ctx context.Context, in *GetCoverageRequest, opts ...grpc.CallOption) (*GetCoverageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCoverageResponse)
+	err := c.cc.Invoke(ctx, Pooler_GetCoverage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PoolerServer is the server API for Pooler service.
+// All implementations must embed UnimplementedPoolerServer
+// for forward compatibility.
+//
+// Pooler is the query api of the pooler, which mirrors the assets, ohlc and
+// live endpoints of the http api.
+type PoolerServer interface {
+	// Assets of the market which match the filters.
+	ListAssets(context.Context, *ListAssetsRequest) (*ListAssetsResponse, error)
+	// Stored or resampled candles of the symbol, streamed in pages so that
+	// large ranges are not held in memory.
+	GetCandles(*GetCandlesRequest, grpc.ServerStreamingServer[Candle]) error
+	// Candles which are written by the pooler, optionally preceded by the
+	// stored candles which start at or after the since time.
+	SubscribeCandles(*SubscribeCandlesRequest, grpc.ServerStreamingServer[Candle]) error
+	// Stored time range of each interval of the symbol and its latest candle.
+	GetCoverage(context.Context, *GetCoverageRequest) (*GetCoverageResponse, error)
+	mustEmbedUnimplementedPoolerServer()
+}
+
+// UnimplementedPoolerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPoolerServer struct{}
+
+func (UnimplementedPoolerServer) ListAssets(context.Context, *ListAssetsRequest) (*ListAssetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAssets not implemented")
+}
+func (UnimplementedPoolerServer) GetCandles(*GetCandlesRequest, grpc.ServerStreamingServer[Candle]) error {
+	return status.Errorf(codes.Unimplemented, "method GetCandles not implemented")
+}
+func (UnimplementedPoolerServer) SubscribeCandles(*SubscribeCandlesRequest, grpc.ServerStreamingServer[Candle]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeCandles not implemented")
+}
+func (UnimplementedPoolerServer) GetCoverage(context.Context, *GetCoverageRequest) (*GetCoverageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCoverage not implemented")
+}
+func (UnimplementedPoolerServer) mustEmbedUnimplementedPoolerServer() {}
+func (UnimplementedPoolerServer) testEmbeddedByValue()                {}
+
+// UnsafePoolerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PoolerServer will
+// result in compilation errors.
+type UnsafePoolerServer interface {
+	mustEmbedUnimplementedPoolerServer()
+}
+
+func RegisterPoolerServer(s grpc.ServiceRegistrar, srv PoolerServer) {
+	// If the following call pancis, it indicates UnimplementedPoolerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pooler_ServiceDesc, srv)
+}
+
+func _Pooler_ListAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAssetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoolerServer).ListAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pooler_ListAssets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoolerServer).ListAssets(ctx, req.(*ListAssetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pooler_GetCandles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetCandlesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PoolerServer).GetCandles(m, &grpc.GenericServerStream[GetCandlesRequest, Candle]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pooler_GetCandlesServer = grpc.ServerStreamingServer[Candle]
+
+func _Pooler_SubscribeCandles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeCandlesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PoolerServer).SubscribeCandles(m, &grpc.GenericServerStream[SubscribeCandlesRequest, Candle]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pooler_SubscribeCandlesServer = grpc.ServerStreamingServer[Candle]
+
+func _Pooler_GetCoverage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCoverageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoolerServer).GetCoverage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pooler_GetCoverage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoolerServer).GetCoverage(ctx, req.(*GetCoverageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Pooler_ServiceDesc is the grpc.ServiceDesc for Pooler service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pooler_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pooler.v1.Pooler",
+	HandlerType: (*PoolerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAssets",
+			Handler:    _Pooler_ListAssets_Handler,
+		},
+		{
+			MethodName: "GetCoverage",
+			Handler:    _Pooler_GetCoverage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetCandles",
+			Handler:       _Pooler_GetCandles_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeCandles",
+			Handler:       _Pooler_SubscribeCandles_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pooler.proto",
+}
@@ -0,0 +1,303 @@
+package binance
+
+import (
+	"archive/zip"
+	"binance-pooler/pkg/dto/market_dto"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+// DefaultArchiveUrl is the url of the binance public data archives
+//   - https://github.com/binance/binance-public-data
+const DefaultArchiveUrl = "https://data.binance.vision"
+
+// ErrArchiveNotFound is returned if the archive for the requested period does
+// not exist (e.g. the daily archive for today is not published yet).
+var ErrArchiveNotFound = errors.New("archive not found")
+
+// ArchiveMarket is the market segment in the archive urls.
+type ArchiveMarket string
+
+const (
+	ArchiveMarketSpot       ArchiveMarket = "spot"
+	ArchiveMarketFuturesUsd ArchiveMarket = "futures/um"
+)
+
+// Market returns the market of the rows of the archive.
+func (m ArchiveMarket) Market() string {
+	if m == ArchiveMarketFuturesUsd {
+		return market_dto.MarketUsdm
+	}
+	return market_dto.MarketSpot
+}
+
+// ArchivePeriod defines if the archive holds the data for a day or a month.
+type ArchivePeriod string
+
+const (
+	ArchivePeriodDaily   ArchivePeriod = "daily"
+	ArchivePeriodMonthly ArchivePeriod = "monthly"
+)
+
+// ArchiveSource reads the kline zip archives from the binance public data
+// repository (or a mirror of it). If the Dir is set, the archives are read
+// from the directory first, using the same layout as the remote, and the
+// downloaded archives are saved to it.
+type ArchiveSource struct {
+	BaseUrl string // Url of the archive mirror. Defaults to DefaultArchiveUrl.
+	Dir     string // Optional local directory of the archives.
+	Offline bool   // If true, the archives are only read from the Dir.
+}
+
+// ArchivePath returns the path of the kline archive, relative to the base url, e.g.
+//
+//	data/spot/monthly/klines/BTCUSDT/1m/BTCUSDT-1m-2024-01.zip
+func ArchivePath(market ArchiveMarket, period ArchivePeriod, symbol string, tf Timeframe, date time.Time) string {
+	symbol = strings.ToUpper(symbol)
+
+	layout := "2006-01-02"
+	if period == ArchivePeriodMonthly {
+		layout = "2006-01"
+	}
+
+	name := fmt.Sprintf("%v-%v-%v.zip", symbol, tf.UrlParam, date.UTC().Format(layout))
+	return path.Join("data", string(market), string(period), "klines", symbol, tf.UrlParam, name)
+}
+
+// GetKlines returns the parsed rows of the archive for the day or month
+// of the date, after verifying the checksum of the archive. The downloaded
+// files are only saved into the Dir once the checksum matches.
+func (s ArchiveSource) GetKlines(market ArchiveMarket, period ArchivePeriod, symbol string, tf Timeframe, date time.Time) ([]market_dto.OhlcRow, error) {
+	archivePath := ArchivePath(market, period, symbol, tf, date)
+
+	archive, archiveSaved, err := s.read(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, checksumSaved, err := s.read(archivePath + ".CHECKSUM")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the checksum of %v: %v", archivePath, err)
+	}
+
+	if err := verifyChecksum(archive, checksum); err != nil {
+		return nil, fmt.Errorf("%v: %v", archivePath, err)
+	}
+
+	if !archiveSaved {
+		if err := s.save(archivePath, archive); err != nil {
+			return nil, err
+		}
+	}
+
+	if !checksumSaved {
+		if err := s.save(archivePath+".CHECKSUM", checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	docs, err := parseKlineArchive(symbol, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedAt := time.Now().UTC()
+	for i := range docs {
+		docs[i].SetMarket(Source, market.Market())
+		docs[i].FetchedAt = fetchedAt
+		docs[i].Endpoint = archivePath
+	}
+
+	return docs, nil
+}
+
+// read returns the file from the local dir if it exists (saved is true),
+// otherwise downloads it.
+func (s ArchiveSource) read(relPath string) ([]byte, bool, error) {
+	if s.Dir != "" {
+		localPath := filepath.Join(s.Dir, filepath.FromSlash(relPath))
+
+		data, err := os.ReadFile(localPath)
+		if err == nil {
+			return data, true, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+
+		if s.Offline {
+			return nil, false, fmt.Errorf("%w: %v", ErrArchiveNotFound, localPath)
+		}
+	}
+
+	baseUrl := s.BaseUrl
+	if baseUrl == "" {
+		baseUrl = DefaultArchiveUrl
+	}
+
+	url := strings.TrimSuffix(baseUrl, "/") + "/" + relPath
+
+	res, err := syro.NewRequest("GET", url).WithIgnoreStatusCodes(true).Do()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, fmt.Errorf("%w: %v", ErrArchiveNotFound, url)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to download %v, status: %v", url, res.StatusCode)
+	}
+
+	return res.Body, false, nil
+}
+
+// save writes the downloaded file into the local dir, if it's set. The file
+// is written to a temporary file first, so that the partially written files
+// are not read by the next calls.
+func (s ArchiveSource) save(relPath string, data []byte) error {
+	if s.Dir == "" {
+		return nil
+	}
+
+	localPath := filepath.Join(s.Dir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), filepath.Base(localPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %v: %v", localPath, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %v: %v", localPath, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), localPath)
+}
+
+// verifyChecksum compares the sha256 hash of the archive with the content of the
+// CHECKSUM file, which has the format of "<sha256>  <file name>".
+func verifyChecksum(archive, checksum []byte) error {
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file is empty")
+	}
+
+	hash := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(hash[:]); !strings.EqualFold(actual, fields[0]) {
+		return fmt.Errorf("checksum mismatch, expected %v, got %v", fields[0], actual)
+	}
+
+	return nil
+}
+
+// parseKlineArchive parses the csv files of the zip archive. The csv columns are
+// the same as the ones returned by the klines endpoint, so the rows are parsed
+// with parseKineRow. Some of the archives include a header row, which is skipped.
+func parseKlineArchive(symbol string, archive []byte) ([]market_dto.OhlcRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %v", err)
+	}
+
+	var docs []market_dto.OhlcRow
+
+	for _, file := range zr.File {
+		if !strings.HasSuffix(file.Name, ".csv") {
+			continue
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %v", file.Name, err)
+		}
+
+		for i, rec := range records {
+			if i == 0 && len(rec) > 0 && !isNumeric(rec[0]) {
+				continue
+			}
+
+			d, err := archiveRecordToKline(rec)
+			if err != nil {
+				return nil, fmt.Errorf("%v line %d: %v", file.Name, i+1, err)
+			}
+
+			kline, err := parseKineRow(symbol, d)
+			if err != nil {
+				return nil, fmt.Errorf("%v line %d: %v", file.Name, i+1, err)
+			}
+
+			docs = append(docs, *kline)
+		}
+	}
+
+	return docs, nil
+}
+
+// archiveRecordToKline converts the csv record into the same types as the decoded
+// json response of the klines endpoint. Since 2025 the spot archives use
+// microseconds for the timestamps, which are converted to milliseconds.
+func archiveRecordToKline(rec []string) ([]any, error) {
+	if len(rec) != 12 {
+		return nil, fmt.Errorf("expected 12 fields, got %d", len(rec))
+	}
+
+	d := make([]any, len(rec))
+	for i, val := range rec {
+		d[i] = val
+	}
+
+	for _, idx := range []int{0, 6, 8} {
+		num, err := strconv.ParseFloat(rec[idx], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in column %d: %v", idx, err)
+		}
+
+		// unix milliseconds have 13 digits until the year 2286
+		if idx != 8 && num >= 1e14 {
+			num = float64(int64(num) / 1000)
+		}
+
+		d[idx] = num
+	}
+
+	return d, nil
+}
+
+func isNumeric(val string) bool {
+	_, err := strconv.ParseFloat(val, 64)
+	return err == nil
+}
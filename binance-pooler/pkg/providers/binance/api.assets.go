@@ -5,13 +5,21 @@ import (
 	"binance-pooler/pkg/lib/timeset"
 	"encoding/json"
 	"time"
-
-	"github.com/tompston/syro"
 )
 
 type GetAssetsFunc[T any] func() ([]market_dto.Asset[T], error)
 
 func (api API) GetAllSpotAssets() ([]market_dto.SpotAsset, error) {
+	body, fetchedAt, err := api.get(SpotExchangeInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSpotAssets(body, fetchedAt)
+}
+
+// parseSpotAssets parses the exchangeInfo response of the spot market.
+func parseSpotAssets(body []byte, updatedAt time.Time) ([]market_dto.SpotAsset, error) {
 	type apiResponse struct {
 		Timezone   string `json:"timezone"`
 		ServerTime int64  `json:"serverTime"`
@@ -73,13 +81,8 @@ func (api API) GetAllSpotAssets() ([]market_dto.SpotAsset, error) {
 		} `json:"symbols"`
 	}
 
-	res, err := syro.NewRequest("GET", "https://api.binance.com/api/v3/exchangeInfo").WithJsonHeader().Do()
-	if err != nil {
-		return nil, err
-	}
-
 	var data apiResponse
-	if err := json.Unmarshal(res.Body, &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, err
 	}
 
@@ -91,7 +94,7 @@ func (api API) GetAllSpotAssets() ([]market_dto.SpotAsset, error) {
 		}
 
 		base := market_dto.AssetBase{
-			UpdatedAt:  time.Now().UTC(),
+			UpdatedAt:  updatedAt,
 			Source:     Source,
 			Symbol:     symb,
 			Status:     symbol.Status,
@@ -126,6 +129,16 @@ func (api API) GetAllSpotAssets() ([]market_dto.SpotAsset, error) {
 }
 
 func (api API) GetAllFutureSymbols() ([]market_dto.FuturesAsset, error) {
+	body, fetchedAt, err := api.get(FuturesExchangeInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFuturesAssets(body, fetchedAt)
+}
+
+// parseFuturesAssets parses the exchangeInfo response of the usd-m futures market.
+func parseFuturesAssets(body []byte, updatedAt time.Time) ([]market_dto.FuturesAsset, error) {
 	type apiResponse struct {
 		Timezone    string `json:"timezone"`
 		ServerTime  int64  `json:"serverTime"`
@@ -184,13 +197,8 @@ func (api API) GetAllFutureSymbols() ([]market_dto.FuturesAsset, error) {
 		} `json:"symbols"`
 	}
 
-	res, err := syro.NewRequest("GET", "https://fapi.binance.com/fapi/v1/exchangeInfo").WithJsonHeader().Do()
-	if err != nil {
-		return nil, err
-	}
-
 	var data apiResponse
-	if err := json.Unmarshal(res.Body, &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, err
 	}
 
@@ -231,7 +239,7 @@ func (api API) GetAllFutureSymbols() ([]market_dto.FuturesAsset, error) {
 		}
 
 		base := market_dto.AssetBase{
-			UpdatedAt:  time.Now().UTC(),
+			UpdatedAt:  updatedAt,
 			Source:     Source,
 			Symbol:     symb,
 			Status:     symbol.Status,
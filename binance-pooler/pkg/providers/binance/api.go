@@ -1,13 +1,34 @@
 package binance
 
-import "time"
+import (
+	"binance-pooler/pkg/lib/rawlake"
+	"fmt"
+	"time"
+)
 
 const Source = "binance"
 
-type API struct{}
+// Endpoints of the api which are used by the app
+const (
+	SpotKlinesEndpoint          = "https://api.binance.com/api/v3/klines"
+	FuturesKlinesEndpoint       = "https://fapi.binance.com/fapi/v1/klines"
+	SpotExchangeInfoEndpoint    = "https://api.binance.com/api/v3/exchangeInfo"
+	FuturesExchangeInfoEndpoint = "https://fapi.binance.com/fapi/v1/exchangeInfo"
+)
+
+type API struct {
+	lake rawlake.Store // optional store of the raw responses
+}
 
 func New() API { return API{} }
 
+// WithLake stores the raw responses of the api in the store, so that the
+// collections can be rebuilt from them.
+func (api API) WithLake(store rawlake.Store) API {
+	api.lake = store
+	return api
+}
+
 var TopPairs = []string{
 	"BTCUSDT",
 	"ETHUSDT",
@@ -37,6 +58,25 @@ var (
 	Timeframe1H  = Timeframe{"1h", 60 * minInMillis}
 )
 
+// Timeframes holds all of the timeframes which are supported by the app
+var Timeframes = []Timeframe{
+	Timeframe1M,
+	Timeframe5M,
+	Timeframe15M,
+	Timeframe30M,
+	Timeframe1H,
+}
+
+// ParseTimeframe returns the timeframe with the matching url param (e.g. "15m").
+func ParseTimeframe(param string) (Timeframe, error) {
+	for _, tf := range Timeframes {
+		if tf.UrlParam == param {
+			return tf, nil
+		}
+	}
+	return Timeframe{}, fmt.Errorf("unsupported timeframe: %v", param)
+}
+
 // GetMaxReqPeriod returns the maximum period that can be requested from the
 // binance api, based on the requested resolution of the data. The api has a
 // limit of 1000 data points per request.
@@ -4,11 +4,10 @@ import (
 	"binance-pooler/pkg/dto/market_dto"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/tompston/syro"
 )
 
 type GetHistoryFunc func(symbol string, from, to time.Time, tf Timeframe) ([]market_dto.OhlcRow, error)
@@ -17,39 +16,47 @@ type GetHistoryFunc func(symbol string, from, to time.Time, tf Timeframe) ([]mar
 //   - https://binance-docs.github.io/apidocs/spot/en/#kline-candlestick-data
 //   - endpoint url - https://api.binance.com/api/v3/klines?symbol=BTCUSDT&interval=1m&startTime=1633833600000&endTime=1633833900000&limit=1000
 func (api API) GetSpotKline(symbol string, from, to time.Time, tf Timeframe) ([]market_dto.OhlcRow, error) {
-	return api.requestKlines("https://api.binance.com/api/v3/klines", symbol, from, to, tf)
+	return api.requestKlines(SpotKlinesEndpoint, symbol, from, to, tf)
 }
 
 // https://developers.binance.com/docs/derivatives/coin-margined-futures/market-data/Continuous-Contract-Kline-Candlestick-Data#response-example
 func (api API) GetFutureKline(symbol string, from, to time.Time, tf Timeframe) ([]market_dto.OhlcRow, error) {
-	return api.requestKlines("https://fapi.binance.com/fapi/v1/klines", symbol, from, to, tf)
+	return api.requestKlines(FuturesKlinesEndpoint, symbol, from, to, tf)
 }
 
 // Futures and Spot markets have the same data structure. The only difference
 // is the endpoint url.
-func (api API) requestKlines(baseUrl string, symbol string, from, to time.Time, timeframe Timeframe) ([]market_dto.OhlcRow, error) {
+func (api API) requestKlines(endpoint string, symbol string, from, to time.Time, timeframe Timeframe) ([]market_dto.OhlcRow, error) {
 	if symbol == "" {
 		return nil, fmt.Errorf("symbol is required")
 	}
 
-	urlSymbol := strings.ToUpper(symbol)
+	const limit = 1000
 
-	// covert time to ms
-	t1 := from.UnixMilli()
-	t2 := to.UnixMilli()
+	params := url.Values{}
+	params.Set("symbol", strings.ToUpper(symbol))
+	params.Set("interval", timeframe.UrlParam)
+	params.Set("startTime", strconv.FormatInt(from.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(to.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(limit))
 
-	const limit = 1000
+	body, fetchedAt, err := api.get(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
 
-	url := fmt.Sprintf("%v?symbol=%s&interval=%v&startTime=%d&endTime=%d&limit=%d",
-		baseUrl, urlSymbol, timeframe.UrlParam, t1, t2, limit)
+	return parseKlines(body, symbol, endpoint, fetchedAt)
+}
 
-	res, err := syro.NewRequest("GET", url).WithJsonHeader().Do()
+// parseKlines parses the kline response of the endpoint.
+func parseKlines(body []byte, symbol, endpoint string, fetchedAt time.Time) ([]market_dto.OhlcRow, error) {
+	market, err := endpointMarket(endpoint)
 	if err != nil {
 		return nil, err
 	}
 
 	var data [][]any
-	if err := json.Unmarshal(res.Body, &data); err != nil {
+	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, err
 	}
 
@@ -61,6 +68,9 @@ func (api API) requestKlines(baseUrl string, symbol string, from, to time.Time,
 			return nil, err
 		}
 
+		kline.SetMarket(Source, market)
+		kline.FetchedAt = fetchedAt
+		kline.Endpoint = endpoint
 		docs = append(docs, *kline)
 	}
 
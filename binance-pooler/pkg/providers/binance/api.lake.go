@@ -0,0 +1,77 @@
+package binance
+
+import (
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/rawlake"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tompston/syro"
+)
+
+// get requests the endpoint and returns the body of the response with the
+// time at which it was fetched. If the api has a lake, the response is
+// stored in it before it's parsed.
+func (api API) get(endpoint string, params url.Values) ([]byte, time.Time, error) {
+	reqUrl := endpoint
+	if len(params) > 0 {
+		reqUrl += "?" + params.Encode()
+	}
+
+	res, err := syro.NewRequest("GET", reqUrl).WithJsonHeader().Do()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	fetchedAt := time.Now().UTC()
+
+	if api.lake != nil {
+		rec := rawlake.Record{Endpoint: endpoint, Params: params, FetchedAt: fetchedAt, Body: res.Body}
+		if err := api.lake.Put(rec); err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to store the response of %v: %v", endpoint, err)
+		}
+	}
+
+	return res.Body, fetchedAt, nil
+}
+
+// endpointMarket returns the market of the kline endpoint.
+func endpointMarket(endpoint string) (string, error) {
+	switch endpoint {
+	case SpotKlinesEndpoint, SpotExchangeInfoEndpoint:
+		return market_dto.MarketSpot, nil
+	case FuturesKlinesEndpoint, FuturesExchangeInfoEndpoint:
+		return market_dto.MarketUsdm, nil
+	default:
+		return "", fmt.Errorf("unknown endpoint: %v", endpoint)
+	}
+}
+
+// ParseRawKlines returns the rows of a stored kline response.
+func ParseRawKlines(rec rawlake.Record) ([]market_dto.OhlcRow, error) {
+	symbol := rec.Params.Get("symbol")
+	if symbol == "" {
+		return nil, fmt.Errorf("record of %v has no symbol param", rec.Endpoint)
+	}
+
+	return parseKlines(rec.Body, symbol, rec.Endpoint, rec.FetchedAt)
+}
+
+// ParseRawSpotAssets returns the assets of a stored spot exchangeInfo response.
+func ParseRawSpotAssets(rec rawlake.Record) ([]market_dto.SpotAsset, error) {
+	if rec.Endpoint != SpotExchangeInfoEndpoint {
+		return nil, fmt.Errorf("record is not a spot exchangeInfo response: %v", rec.Endpoint)
+	}
+
+	return parseSpotAssets(rec.Body, rec.FetchedAt)
+}
+
+// ParseRawFuturesAssets returns the assets of a stored futures exchangeInfo response.
+func ParseRawFuturesAssets(rec rawlake.Record) ([]market_dto.FuturesAsset, error) {
+	if rec.Endpoint != FuturesExchangeInfoEndpoint {
+		return nil, fmt.Errorf("record is not a futures exchangeInfo response: %v", rec.Endpoint)
+	}
+
+	return parseFuturesAssets(rec.Body, rec.FetchedAt)
+}
@@ -1,6 +1,19 @@
 package binance
 
 import (
+	"archive/zip"
+	"binance-pooler/pkg/dto/market_dto"
+	"binance-pooler/pkg/lib/rawlake"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -36,3 +49,151 @@ func TestApi(t *testing.T) {
 		})
 	*/
 }
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	month := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tf := Timeframe1M
+
+	// the first row uses milliseconds, the second one microseconds (used in the
+	// spot archives since 2025). The header row is present in some archives.
+	csv := "open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore\n" +
+		"1735689600000,93576.00,93610.93,93537.50,93610.93,8.21827000,1735689659999,768978.03,2631,3.95,369855.81,0\n" +
+		"1735689660000000,93610.93,93652.00,93606.00,93652.00,6.26812000,1735689719999999,586908.36,1963,3.19,298795.48,0\n"
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("BTCUSDT-1m-2025-01.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(csv))
+	zw.Close()
+
+	archivePath := filepath.Join(dir, filepath.FromSlash(ArchivePath(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month)))
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	os.WriteFile(archivePath, buf.Bytes(), 0644)
+	os.WriteFile(archivePath+".CHECKSUM", []byte(hex.EncodeToString(hash[:])+"  BTCUSDT-1m-2025-01.zip\n"), 0644)
+
+	source := ArchiveSource{Dir: dir, Offline: true}
+
+	t.Run("parse archive", func(t *testing.T) {
+		docs, err := source.GetKlines(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(docs) != 2 {
+			t.Fatalf("expected 2 rows, got %d", len(docs))
+		}
+
+		if !docs[1].StartTime.Equal(month.Add(time.Minute)) || docs[1].Interval != tf.Milis {
+			t.Fatalf("unexpected timestamps of the microsecond row: %v, %v", docs[1].StartTime, docs[1].Interval)
+		}
+
+		if docs[0].Close != 93610.93 || *docs[0].NumberOfTrades != 2631 {
+			t.Fatalf("unexpected values: %+v", docs[0])
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		os.WriteFile(archivePath+".CHECKSUM", []byte("abc  BTCUSDT-1m-2025-01.zip\n"), 0644)
+		if _, err := source.GetKlines(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month); err == nil {
+			t.Fatal("expected a checksum error")
+		}
+	})
+
+	t.Run("missing archive", func(t *testing.T) {
+		_, err := source.GetKlines(ArchiveMarketSpot, ArchivePeriodDaily, "BTCUSDT", tf, month)
+		if !errors.Is(err, ErrArchiveNotFound) {
+			t.Fatalf("expected ErrArchiveNotFound, got %v", err)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		checksum := []byte(hex.EncodeToString(hash[:]) + "  BTCUSDT-1m-2025-01.zip\n")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, ".CHECKSUM") {
+				w.Write(checksum)
+				return
+			}
+			w.Write(buf.Bytes())
+		}))
+		defer srv.Close()
+
+		cache := t.TempDir()
+		cachedPath := filepath.Join(cache, filepath.FromSlash(ArchivePath(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month)))
+		source := ArchiveSource{BaseUrl: srv.URL, Dir: cache}
+
+		// the archives which don't match the checksum are not cached
+		checksum = []byte("abc  BTCUSDT-1m-2025-01.zip\n")
+		if _, err := source.GetKlines(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month); err == nil {
+			t.Fatal("expected a checksum error")
+		}
+
+		if _, err := os.Stat(cachedPath); !os.IsNotExist(err) {
+			t.Fatalf("expected the archive not to be cached, got %v", err)
+		}
+
+		checksum = []byte(hex.EncodeToString(hash[:]) + "  BTCUSDT-1m-2025-01.zip\n")
+		if _, err := source.GetKlines(ArchiveMarketSpot, ArchivePeriodMonthly, "BTCUSDT", tf, month); err != nil {
+			t.Fatal(err)
+		}
+
+		if data, err := os.ReadFile(cachedPath); err != nil || !bytes.Equal(data, buf.Bytes()) {
+			t.Fatalf("expected the verified archive to be cached, got %v", err)
+		}
+	})
+}
+
+func TestRawLake(t *testing.T) {
+	lake, err := rawlake.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	body := `[[1704067200000,"42283.58","42298.62","42261.02","42298.61","35.92724000",1704067259999,"1519032.76",1327,"19.28","815162.19","0"]]`
+
+	rec := rawlake.Record{
+		Endpoint:  FuturesKlinesEndpoint,
+		Params:    url.Values{"symbol": {"BTCUSDT"}, "interval": {"1m"}},
+		FetchedAt: fetchedAt,
+		Body:      []byte(body),
+	}
+	if err := lake.Put(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	var docs []market_dto.OhlcRow
+	err = lake.Walk(rawlake.Filter{Endpoint: FuturesKlinesEndpoint}, func(rec rawlake.Record) error {
+		rows, err := ParseRawKlines(rec)
+		docs = append(docs, rows...)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(docs))
+	}
+
+	row := docs[0]
+	if row.Symbol != "BTCUSDT" || row.Market != market_dto.MarketUsdm || row.Close != 42298.61 {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+
+	if !row.FetchedAt.Equal(fetchedAt) || row.Endpoint != FuturesKlinesEndpoint {
+		t.Fatalf("unexpected provenance: %v %v", row.FetchedAt, row.Endpoint)
+	}
+
+	if _, err := ParseRawSpotAssets(rec); err == nil {
+		t.Fatal("expected an error for a kline record")
+	}
+}
@@ -9,6 +9,7 @@ if [ $# -eq 0 ]; then
     echo "
 ~ Available commands
     pooler          # Start the pooler
+    api             # Start the query api
     ports           # List all ports in use
     cloc            # Count lines of code
     test go         # Run Go tests"
@@ -21,10 +22,10 @@ case $FIRST_ARG in
     cd binance-pooler && reflex -r '\.go' -s -- sh -c "go run cmd/pooler/main.go"
     ;;
 
-# "api")
-#     echo " * Starting the api"
-#     cd binance-pooler && reflex -r '\.go' -s -- sh -c "go run cmd/api/main.go"
-#     ;;
+"api")
+    echo " * Starting the api"
+    cd binance-pooler && reflex -r '\.go' -s -- sh -c "go run cmd/api/main.go"
+    ;;
 
 "flamegraph")
     cd binance-pooler